@@ -0,0 +1,1139 @@
+// =============================================================================
+// Bicep → Terraform Conversion
+// =============================================================================
+// Converts a parsed Bicep file to an azurerm configuration:
+//
+//   - params become variables (decorators become description, sensitive and
+//     validation blocks); defaults that are not constants move to locals
+//   - vars become locals and outputs become outputs
+//   - resources use the mappings in convertmap.go. Loops and conditions
+//     become count, nested resources and parent: become the parent argument,
+//     and an existing resource becomes a data source.
+//   - resource-group-scoped files get a resource_group_name variable;
+//     resourceGroup().location reads the azurerm_resource_group data source
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tfConverter converts one Bicep file
+type tfConverter struct {
+	file      *bicepFile
+	params    map[string]*bicepParam
+	vars      map[string]*bicepVar
+	resources map[string]*tfResource // by symbol
+	// paramLocals are params whose non-constant default moved to a local
+	paramLocals map[string]bool
+	scopes      []map[string]string // loop variables → Terraform expressions
+	pending     []string            // problems in the expression being rendered
+	todos       int
+
+	needRGName, needRGData, needClient bool
+}
+
+// tfResource is a Bicep resource or module being converted
+type tfResource struct {
+	src     *bicepResource
+	mapping *resourceMapping
+	address string // empty when there is no mapping
+	body    *tfBody
+	todos   int
+}
+
+// convertBicepToTerraform converts a single Bicep file
+func convertBicepToTerraform(sources map[string][]byte) (*conversionOutput, error) {
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("convert one Bicep file at a time")
+	}
+	var file *bicepFile
+	for name, src := range sources {
+		var err error
+		if file, err = parseBicep(string(src)); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	c := &tfConverter{
+		file:        file,
+		params:      make(map[string]*bicepParam),
+		vars:        make(map[string]*bicepVar),
+		resources:   make(map[string]*tfResource),
+		paramLocals: make(map[string]bool),
+	}
+	return c.convert(), nil
+}
+
+// convert renders the whole configuration
+func (c *tfConverter) convert() *conversionOutput {
+	for _, p := range c.file.params {
+		c.params[p.name] = p
+		if p.value != nil && !isConstant(p.value) {
+			c.paramLocals[p.name] = true
+		}
+	}
+	for _, v := range c.file.vars {
+		c.vars[v.name] = v
+	}
+	for _, r := range c.file.resources {
+		tr := &tfResource{src: r}
+		c.resources[r.symbol] = tr
+		switch {
+		case r.module:
+			tr.address = "module." + snakeCase(r.symbol)
+		default:
+			tr.mapping = bicepMapping(r.typ, r.body)
+			if tr.mapping == nil || tr.mapping.mergeBlock != "" {
+				continue
+			}
+			tr.address = tr.mapping.tfType + "." + snakeCase(r.symbol)
+			if r.existing {
+				tr.address = "data." + tr.address
+			}
+		}
+	}
+
+	// Declarations are rendered first: they decide which data sources and
+	// variables the file needs
+	var notes []string
+	if c.file.targetScope != "resourceGroup" && c.file.targetScope != "subscription" {
+		notes = append(notes, c.todoLines(fmt.Sprintf("the Bicep file targets the %s scope; Terraform resources are not deployed to a scope", c.file.targetScope), "")...)
+	}
+	for _, skipped := range c.file.skipped {
+		notes = append(notes, c.todoLines("Bicep declaration not converted", skipped)...)
+	}
+
+	var resources []*tfItem
+	var results []ConvertedResource
+	merged := make(map[*tfResource]bool)
+	for _, r := range c.file.resources {
+		tr := c.resources[r.symbol]
+		if tr.mapping != nil && tr.mapping.mergeBlock != "" {
+			merged[tr] = true
+			continue
+		}
+		items := c.resource(tr)
+		resources = append(resources, items...)
+	}
+	for _, r := range c.file.resources {
+		tr := c.resources[r.symbol]
+		if merged[tr] {
+			c.mergeChild(tr)
+		}
+	}
+	for _, r := range c.file.resources {
+		results = append(results, c.result(c.resources[r.symbol]))
+	}
+
+	locals := &tfBody{}
+	var variables []*tfItem
+	for _, p := range c.file.params {
+		variables = append(variables, c.variable(p, locals))
+	}
+	for _, v := range c.file.vars {
+		locals.attr(snakeCase(v.name), c.expr(v.value), c.takePending(v.name+" = …"))
+	}
+	var outputs []*tfItem
+	for _, o := range c.file.outputs {
+		outputs = append(outputs, c.output(o))
+	}
+
+	// Assemble the file
+	var out strings.Builder
+	out.WriteString("terraform {\n  required_providers {\n    azurerm = {\n      source  = \"hashicorp/azurerm\"\n      version = \"~> 4.0\"\n    }\n  }\n}\n\n")
+	out.WriteString("provider \"azurerm\" {\n  features {}\n}\n")
+	if len(notes) > 0 {
+		out.WriteString("\n" + strings.Join(notes, "\n") + "\n")
+	}
+
+	var items []*tfItem
+	if c.needRGName && c.params["resourceGroupName"] == nil {
+		rg := &tfBody{}
+		rg.attr("description", hclQuote("Resource group to deploy into (the scope of the Bicep deployment)"), nil)
+		rg.attr("type", "string", nil)
+		items = append(items, &tfItem{name: "variable", labels: []string{"resource_group_name"}, block: rg})
+	}
+	items = append(items, variables...)
+	if c.needClient {
+		items = append(items, &tfItem{name: "data", labels: []string{"azurerm_client_config", "current"}, block: &tfBody{}})
+	}
+	if c.needRGData {
+		rg := &tfBody{}
+		rg.attr("name", "var.resource_group_name", nil)
+		items = append(items, &tfItem{name: "data", labels: []string{"azurerm_resource_group", "current"}, block: rg})
+	}
+	if len(locals.items) > 0 {
+		items = append(items, &tfItem{name: "locals", block: locals})
+	}
+	items = append(items, resources...)
+	items = append(items, outputs...)
+	for _, item := range items {
+		out.WriteString("\n")
+		(&tfBody{items: []*tfItem{item}}).write(&out, "")
+	}
+
+	code := formatHCL(out.String())
+	return &conversionOutput{code: code, resources: results, problems: parseHCLProblems(code)}
+}
+
+// result summarizes how a resource was converted
+func (c *tfConverter) result(tr *tfResource) ConvertedResource {
+	result := ConvertedResource{Source: tr.src.symbol, SourceType: tr.src.typ, Status: convertedStatus}
+	switch {
+	case tr.src.module:
+		result.SourceType = "module " + tr.src.typ
+		result.Target, result.TargetType = tr.address, "module"
+	case tr.mapping == nil:
+		result.Status = unmappedStatus
+		return result
+	case tr.mapping.mergeBlock != "":
+		parent, _ := c.parentOf(tr.src)
+		if parent == nil || parent.address == "" {
+			result.Status = unmappedStatus
+			return result
+		}
+		result.Target, result.TargetType = parent.address, tr.mapping.mergeBlock+" block"
+	default:
+		result.Target, result.TargetType = tr.address, tr.mapping.tfType
+	}
+	if tr.todos > 0 {
+		result.Status = partialStatus
+	}
+	return result
+}
+
+// =============================================================================
+// Declarations
+// =============================================================================
+
+// variable converts a param; a non-constant default moves to a local
+func (c *tfConverter) variable(p *bicepParam, locals *tfBody) *tfItem {
+	name := snakeCase(p.name)
+	body := &tfBody{}
+	if d := decoratorNamed(p.decorators, "description"); d != nil && len(d.args) == 1 {
+		body.attr("description", c.expr(d.args[0]), c.takePending(""))
+	}
+	typ, exact := terraformType(p.typ)
+	var comments []string
+	if !exact {
+		comments = c.todoLines(fmt.Sprintf("Bicep type %s has no exact Terraform equivalent", p.typ), "")
+	}
+	body.attr("type", typ, comments)
+
+	if p.value != nil {
+		if isConstant(p.value) {
+			body.attr("default", c.expr(p.value), c.takePending(""))
+		} else {
+			// Terraform defaults must be constants
+			body.attr("default", "null", nil)
+			locals.attr(name, fmt.Sprintf("coalesce(var.%s, %s)", name, c.expr(p.value)), c.takePending(""))
+		}
+	}
+	if decoratorNamed(p.decorators, "secure") != nil {
+		body.attr("sensitive", "true", nil)
+	}
+
+	validation := func(condition, message string) {
+		v := body.newBlock("validation")
+		v.attr("condition", condition, c.takePending(""))
+		v.attr("error_message", hclQuote(message), nil)
+	}
+	ref := "var." + name
+	if d := decoratorNamed(p.decorators, "allowed"); d != nil && len(d.args) == 1 {
+		validation(fmt.Sprintf("contains(%s, %s)", c.expr(d.args[0]), ref), fmt.Sprintf("%s must be one of the allowed values.", name))
+	}
+	bounds := []struct{ decorator, check, message string }{
+		{"minLength", "length(%s) >= %s", "%s must be at least %s long."},
+		{"maxLength", "length(%s) <= %s", "%s must be at most %s long."},
+		{"minValue", "%s >= %s", "%s must be at least %s."},
+		{"maxValue", "%s <= %s", "%s must be at most %s."},
+	}
+	for _, bound := range bounds {
+		if d := decoratorNamed(p.decorators, bound.decorator); d != nil && len(d.args) == 1 {
+			limit := c.expr(d.args[0])
+			validation(fmt.Sprintf(bound.check, ref, limit), fmt.Sprintf(bound.message, name, limit))
+		}
+	}
+	return &tfItem{name: "variable", labels: []string{name}, block: body}
+}
+
+// terraformType converts a Bicep type; exact is false for approximations
+func terraformType(bicepType string) (typ string, exact bool) {
+	switch strings.TrimSuffix(bicepType, "?") {
+	case "string":
+		return "string", true
+	case "int":
+		return "number", true
+	case "bool":
+		return "bool", true
+	case "array":
+		return "list(any)", true
+	case "object":
+		return "any", true
+	case "string[]":
+		return "list(string)", true
+	case "int[]":
+		return "list(number)", true
+	case "bool[]":
+		return "list(bool)", true
+	}
+	// Unions of string literals such as 'dev' | 'prod'
+	if strings.HasPrefix(bicepType, "'") {
+		return "string", true
+	}
+	return "any", false
+}
+
+// isConstant reports whether an expression contains no references or calls
+func isConstant(node irNode) bool {
+	switch n := node.(type) {
+	case *irString, *irNumber, *irBool, *irNull:
+		return true
+	case *irArray:
+		for _, item := range n.Items {
+			if !isConstant(item) {
+				return false
+			}
+		}
+		return true
+	case *irObject:
+		for _, item := range n.Items {
+			if !isConstant(item.Value) {
+				return false
+			}
+		}
+		return true
+	case *irUnary:
+		return isConstant(n.X)
+	}
+	return false
+}
+
+// output converts an output
+func (c *tfConverter) output(o *bicepOutput) *tfItem {
+	body := &tfBody{}
+	if d := decoratorNamed(o.decorators, "description"); d != nil && len(d.args) == 1 {
+		body.attr("description", c.expr(d.args[0]), c.takePending(""))
+	}
+	body.attr("value", c.expr(o.value), c.takePending(""))
+	if decoratorNamed(o.decorators, "secure") != nil {
+		body.attr("sensitive", "true", nil)
+	}
+	return &tfItem{name: "output", labels: []string{snakeCase(o.name)}, block: body}
+}
+
+// =============================================================================
+// Resources
+// =============================================================================
+
+// resource converts a resource or module; unmapped ones become a TODO
+// comment quoting the Bicep code
+func (c *tfConverter) resource(tr *tfResource) []*tfItem {
+	before := c.todos
+	defer func() { tr.todos = c.todos - before }()
+	r := tr.src
+
+	if r.module {
+		return []*tfItem{c.module(tr)}
+	}
+	if tr.mapping == nil {
+		note := &tfBody{}
+		note.note(c.todoLines(fmt.Sprintf("no azurerm mapping for %s (%s); convert it by hand", r.typ, r.symbol), r.src))
+		return note.items
+	}
+
+	body := &tfBody{}
+	tr.body = body
+	popScope := c.loopScope(r, body)
+	defer popScope()
+	consumed := map[string]bool{"name": true, "parent": true, "dependsOn": true, "scope": true}
+
+	if item := r.body.get("name"); item != nil {
+		body.attr("name", c.expr(item.Value), c.takePending("name: "+item.Src))
+	}
+	if tr.mapping.resourceGroup {
+		body.attr("resource_group_name", c.resourceGroupName(r.body), c.takePending(""))
+	}
+	if link := tr.mapping.parent; link != nil {
+		parent, index := c.parentOf(r)
+		switch {
+		case parent == nil:
+			c.todo(body, fmt.Sprintf("set %s: the Bicep resource has no parent", link.arg), "")
+		case parent.address == "":
+			c.todo(body, fmt.Sprintf("set %s: the parent %s was not converted", link.arg, parent.src.symbol), "")
+		default:
+			body.attr(link.arg, fmt.Sprintf("%s%s.%s", parent.address, index, link.attr), nil)
+		}
+	}
+	if item := r.body.get("scope"); item != nil && !tr.mapping.resourceGroup {
+		c.todo(body, "Terraform resources have no scope; check where this resource is created", "scope: "+item.Src)
+	}
+
+	if r.existing {
+		item := &tfItem{name: "data", labels: []string{tr.mapping.tfType, snakeCase(r.symbol)}, block: body}
+		c.unmapped(r.body, body, "", consumed, "data source")
+		return []*tfItem{item}
+	}
+
+	c.applyRules(tr.mapping.rules, r.body, body, consumed)
+	extra := c.subnetAssociation(tr, body, consumed)
+	c.unmapped(r.body, body, "", consumed, tr.mapping.tfType)
+	if item := r.body.get("dependsOn"); item != nil {
+		body.attr("depends_on", c.dependsOn(item.Value), c.takePending("dependsOn: "+item.Src))
+	}
+
+	items := []*tfItem{{name: "resource", labels: []string{tr.mapping.tfType, snakeCase(r.symbol)}, block: body}}
+	return append(items, extra...)
+}
+
+// module converts a module to a module call; the module itself still has
+// to be converted
+func (c *tfConverter) module(tr *tfResource) *tfItem {
+	r := tr.src
+	body := &tfBody{}
+	popScope := c.loopScope(r, body)
+	defer popScope()
+
+	source := r.typ
+	if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+		source = strings.TrimSuffix(source, ".bicep")
+		body.attr("source", hclQuote(source), c.todoLines(fmt.Sprintf("convert the Bicep module %s to a Terraform module in %s", r.typ, source), ""))
+	} else {
+		body.attr("source", hclQuote(source), c.todoLines(fmt.Sprintf("%s is a registry or template spec reference; point source at the equivalent Terraform module", r.typ), ""))
+	}
+
+	consumed := map[string]bool{"name": true, "params": true, "dependsOn": true}
+	if params := r.body.get("params"); params != nil {
+		object, ok := params.Value.(*irObject)
+		if !ok {
+			c.todo(body, "module params are not an object literal", "params: "+params.Src)
+		} else {
+			for _, item := range object.Items {
+				body.attr(snakeCase(item.Key), c.expr(item.Value), c.takePending(item.Key+": "+item.Src))
+			}
+		}
+	}
+	c.unmapped(r.body, body, "", consumed, "module")
+	if item := r.body.get("dependsOn"); item != nil {
+		body.attr("depends_on", c.dependsOn(item.Value), c.takePending("dependsOn: "+item.Src))
+	}
+	return &tfItem{name: "module", labels: []string{snakeCase(r.symbol)}, block: body}
+}
+
+// mergeChild folds a Bicep child such as blobServices into a nested block
+// of the parent's Terraform resource
+func (c *tfConverter) mergeChild(tr *tfResource) {
+	before := c.todos
+	defer func() { tr.todos = c.todos - before }()
+
+	parent, _ := c.parentOf(tr.src)
+	if parent == nil || parent.body == nil {
+		return
+	}
+	block := parent.body.child(tr.mapping.mergeBlock)
+	consumed := map[string]bool{"name": true, "parent": true, "dependsOn": true}
+	c.applyRules(tr.mapping.rules, tr.src.body, block, consumed)
+	c.unmapped(tr.src.body, block, "", consumed, tr.mapping.mergeBlock)
+}
+
+// loopScope turns a loop or condition into count and makes the loop
+// variables resolve; the returned function restores the scope
+func (c *tfConverter) loopScope(r *bicepResource, body *tfBody) func() {
+	if r.loop == nil {
+		if r.cond != nil {
+			body.attr("count", c.expr(r.cond)+" ? 1 : 0", c.takePending(""))
+		}
+		return func() {}
+	}
+
+	count, item := "", ""
+	if call, ok := r.loop.Source.(*irCall); ok && strings.EqualFold(call.Name, "range") && len(call.Args) == 2 {
+		if start, ok := call.Args[0].(*irNumber); ok && start.Text == "0" {
+			count, item = c.expr(call.Args[1]), "count.index"
+		}
+	}
+	if count == "" {
+		source := c.expr(r.loop.Source)
+		count, item = fmt.Sprintf("length(%s)", source), source+"[count.index]"
+	}
+	comments := c.takePending("")
+	if r.cond != nil {
+		comments = append(comments, c.todoLines("the Bicep loop has a filter; drop the filtered elements from the list", "if ("+c.expr(r.cond)+")")...)
+		c.pending = nil
+	}
+	body.attr("count", count, comments)
+
+	scope := map[string]string{r.loop.Value: item}
+	if r.loop.Key != "" {
+		scope[r.loop.Key] = "count.index"
+	}
+	c.scopes = append(c.scopes, scope)
+	return func() { c.scopes = c.scopes[:len(c.scopes)-1] }
+}
+
+// parentOf finds the parent of a resource, from nesting or parent:, and
+// the index expression when the parent is a loop
+func (c *tfConverter) parentOf(r *bicepResource) (*tfResource, string) {
+	if r.parent != "" {
+		return c.resources[r.parent], ""
+	}
+	item := r.body.get("parent")
+	if item == nil {
+		return nil, ""
+	}
+	index := ""
+	node := item.Value
+	if idx, ok := node.(*irIndex); ok {
+		index = "[" + c.expr(idx.Key) + "]"
+		node = idx.Target
+	}
+	ref, ok := node.(*irRef)
+	if !ok {
+		return nil, ""
+	}
+	parent := c.resources[ref.Name]
+	// Merged parents such as blobServices stand for their own parent
+	if parent != nil && parent.mapping != nil && parent.mapping.mergeBlock != "" {
+		return c.parentOf(parent.src)
+	}
+	return parent, index
+}
+
+// resourceGroupName returns the resource_group_name of a resource
+func (c *tfConverter) resourceGroupName(body *irObject) string {
+	if item := body.get("scope"); item != nil {
+		if call, ok := item.Value.(*irCall); ok && strings.EqualFold(call.Name, "resourceGroup") && len(call.Args) == 1 {
+			return c.expr(call.Args[0])
+		}
+		c.pending = append(c.pending, "Terraform has no deployment scopes; set the resource group this resource belongs to")
+	}
+	if c.params["resourceGroupName"] != nil {
+		return c.reference(&irRef{Name: "resourceGroupName"})
+	}
+	c.needRGName = true
+	return "var.resource_group_name"
+}
+
+// applyRules maps the properties of a Bicep object into a Terraform body
+func (c *tfConverter) applyRules(rules []propertyRule, src *irObject, dst *tfBody, consumed map[string]bool) {
+	for _, rule := range rules {
+		item := src.lookup(rule.bicep)
+		if item == nil {
+			continue
+		}
+		if rule.tf == "" {
+			consumed[rule.bicep] = true
+			continue
+		}
+		if rule.elements != nil {
+			consumed[rule.bicep] = true
+			c.applyElements(rule, item, dst)
+			continue
+		}
+
+		text, ok := "", true
+		if rule.toTerraform != nil {
+			text, ok = rule.toTerraform(c, item.Value, src)
+		} else {
+			text = c.expr(item.Value)
+		}
+		if !ok {
+			c.pending = nil
+			continue
+		}
+		consumed[rule.bicep] = true
+		dst.set(rule.tf, text, c.takePending(item.Key+": "+item.Src))
+	}
+}
+
+// applyElements maps an array of objects to repeated nested blocks, and a
+// for-expression to a dynamic block
+func (c *tfConverter) applyElements(rule propertyRule, item *irItem, dst *tfBody) {
+	switch value := item.Value.(type) {
+	case *irArray:
+		for i, element := range value.Items {
+			if rule.first && i > 0 {
+				c.todo(dst, fmt.Sprintf("only the first element of %s maps to %s; add the others as separate resources", rule.bicep, rule.tf), "")
+				return
+			}
+			object, ok := element.(*irObject)
+			if !ok {
+				c.todo(dst, fmt.Sprintf("an element of %s is not an object literal", rule.bicep), "")
+				continue
+			}
+			block := dst.newBlock(rule.tf)
+			consumed := make(map[string]bool)
+			c.applyRules(rule.elements, object, block, consumed)
+			c.unmapped(object, block, "", consumed, rule.tf)
+		}
+	case *irFor:
+		object, ok := value.Body.(*irObject)
+		if !ok || rule.first || value.Cond != nil {
+			c.todo(dst, fmt.Sprintf("convert the %s loop to %s blocks", rule.bicep, rule.tf), item.Key+": "+item.Src)
+			return
+		}
+		dynamic := dst.newBlock("dynamic", rule.tf)
+		dynamic.attr("for_each", c.expr(value.Source), c.takePending(""))
+		if value.Value != rule.tf {
+			dynamic.attr("iterator", value.Value, nil)
+		}
+		scope := map[string]string{value.Value: value.Value + ".value"}
+		if value.Key != "" {
+			scope[value.Key] = value.Value + ".key"
+		}
+		c.scopes = append(c.scopes, scope)
+		content := dynamic.newBlock("content")
+		consumed := make(map[string]bool)
+		c.applyRules(rule.elements, object, content, consumed)
+		c.unmapped(object, content, "", consumed, rule.tf)
+		c.scopes = c.scopes[:len(c.scopes)-1]
+	default:
+		c.todo(dst, fmt.Sprintf("convert %s to %s blocks", rule.bicep, rule.tf), item.Key+": "+item.Src)
+	}
+}
+
+// unmapped adds a TODO for every Bicep property no rule consumed
+func (c *tfConverter) unmapped(src *irObject, dst *tfBody, prefix string, consumed map[string]bool, target string) {
+	for _, item := range src.Items {
+		path := prefix + item.Key
+		if consumed[path] {
+			continue
+		}
+		if object, ok := item.Value.(*irObject); ok && consumedUnder(consumed, path) {
+			c.unmapped(object, dst, path+".", consumed, target)
+			continue
+		}
+		c.todo(dst, fmt.Sprintf("no %s argument for Bicep property %s", target, path), item.Key+": "+item.Src)
+	}
+}
+
+// consumedUnder reports whether any path below path was consumed
+func consumedUnder(consumed map[string]bool, path string) bool {
+	for key := range consumed {
+		if strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// subnetAssociation turns a subnet's networkSecurityGroup into an
+// azurerm_subnet_network_security_group_association
+func (c *tfConverter) subnetAssociation(tr *tfResource, body *tfBody, consumed map[string]bool) []*tfItem {
+	item := tr.src.body.lookup("properties.networkSecurityGroup.id")
+	if tr.mapping.tfType != "azurerm_subnet" || item == nil {
+		return nil
+	}
+	consumed["properties.networkSecurityGroup.id"] = true
+
+	association := &tfBody{}
+	subnet := tr.address
+	for _, meta := range body.items {
+		if meta.name == "count" {
+			association.attr("count", meta.expr, nil)
+			subnet += "[count.index]"
+		}
+	}
+	association.attr("subnet_id", subnet+".id", nil)
+	association.attr("network_security_group_id", c.expr(item.Value), c.takePending("id: "+item.Src))
+	return []*tfItem{{name: "resource", labels: []string{subnetNSGAssociation, snakeCase(tr.src.symbol)}, block: association}}
+}
+
+// dependsOn converts dependsOn to depends_on
+func (c *tfConverter) dependsOn(node irNode) string {
+	list, ok := node.(*irArray)
+	if !ok {
+		c.pending = append(c.pending, "dependsOn is not an array literal")
+		return "[]"
+	}
+	var refs []string
+	for _, item := range list.Items {
+		if idx, ok := item.(*irIndex); ok {
+			item = idx.Target // depends_on takes whole resources
+		}
+		ref, ok := item.(*irRef)
+		if !ok || c.resources[ref.Name] == nil {
+			c.pending = append(c.pending, "dependsOn entry is not a resource or module symbol")
+			continue
+		}
+		if target := c.resources[ref.Name]; target.address != "" {
+			refs = append(refs, target.address)
+		} else {
+			c.pending = append(c.pending, fmt.Sprintf("dependsOn %s, which was not converted", ref.Name))
+		}
+	}
+	return "[" + strings.Join(refs, ", ") + "]"
+}
+
+// todoLines renders a TODO comment and counts it
+func (c *tfConverter) todoLines(message, quoted string) []string {
+	c.todos++
+	return todoLines("#", message, quoted)
+}
+
+// todo adds a standalone TODO comment to a body
+func (c *tfConverter) todo(body *tfBody, message, quoted string) {
+	body.note(c.todoLines(message, quoted))
+}
+
+// takePending turns problems in the last rendered expression into a TODO
+// comment quoting the Bicep source
+func (c *tfConverter) takePending(quoted string) []string {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	message := strings.Join(c.pending, "; ")
+	c.pending = nil
+	return c.todoLines(message, quoted)
+}
+
+// =============================================================================
+// Expressions
+// =============================================================================
+
+// expr renders an expression as Terraform. Parts without an equivalent
+// render as null and are recorded in pending.
+func (c *tfConverter) expr(node irNode) string {
+	switch n := node.(type) {
+	case *irString:
+		return hclQuote(n.Value)
+	case *irNumber:
+		return n.Text
+	case *irBool:
+		return fmt.Sprint(n.Value)
+	case *irNull:
+		return "null"
+	case *irTemplate:
+		var b strings.Builder
+		b.WriteString(`"`)
+		for _, part := range n.Parts {
+			if s, ok := part.(*irString); ok {
+				quoted := hclQuote(s.Value)
+				b.WriteString(quoted[1 : len(quoted)-1])
+			} else {
+				b.WriteString("${" + c.expr(part) + "}")
+			}
+		}
+		b.WriteString(`"`)
+		return b.String()
+	case *irArray:
+		var items []string
+		multiline := false
+		for _, item := range n.Items {
+			text := c.expr(item)
+			multiline = multiline || strings.Contains(text, "\n")
+			items = append(items, text)
+		}
+		if multiline {
+			return "[\n" + strings.Join(items, ",\n") + ",\n]"
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *irObject:
+		if len(n.Items) == 0 {
+			return "{}"
+		}
+		var lines []string
+		for _, item := range n.Items {
+			key := item.Key
+			if !identifierPattern.MatchString(key) {
+				key = hclQuote(key)
+			}
+			lines = append(lines, key+" = "+c.expr(item.Value))
+		}
+		return "{\n" + strings.Join(lines, "\n") + "\n}"
+	case *irParen:
+		return "(" + c.expr(n.X) + ")"
+	case *irUnary:
+		return n.Op + c.expr(n.X)
+	case *irBinary:
+		left, right := c.expr(n.Left), c.expr(n.Right)
+		switch n.Op {
+		case "??":
+			return fmt.Sprintf("coalesce(%s, %s)", left, right)
+		case "=~":
+			return fmt.Sprintf("lower(%s) == lower(%s)", left, right)
+		case "!~":
+			return fmt.Sprintf("lower(%s) != lower(%s)", left, right)
+		}
+		return fmt.Sprintf("%s %s %s", left, n.Op, right)
+	case *irCond:
+		return fmt.Sprintf("%s ? %s : %s", c.expr(n.Cond), c.expr(n.Then), c.expr(n.Else))
+	case *irRef, *irGet, *irIndex:
+		return c.reference(node)
+	case *irCall:
+		return c.call(n)
+	case *irFor:
+		scope := map[string]string{n.Value: n.Value}
+		vars := n.Value
+		if n.Key != "" {
+			scope[n.Key] = n.Key
+			vars = n.Key + ", " + n.Value
+		}
+		source := c.expr(n.Source)
+		c.scopes = append(c.scopes, scope)
+		defer func() { c.scopes = c.scopes[:len(c.scopes)-1] }()
+		text := fmt.Sprintf("[for %s in %s : %s", vars, source, c.expr(n.Body))
+		if n.Cond != nil {
+			text += " if " + c.expr(n.Cond)
+		}
+		return text + "]"
+	case *irLambda:
+		c.pending = append(c.pending, "Bicep lambdas only convert inside map() and filter()")
+	case *irRaw:
+		c.pending = append(c.pending, fmt.Sprintf("no Terraform equivalent for %s", n.Src))
+	case *irTarget:
+		return n.Text
+	}
+	return "null"
+}
+
+// reference renders a symbol reference with its property accesses
+func (c *tfConverter) reference(node irNode) string {
+	root, accessors := chain(node)
+	switch r := root.(type) {
+	case *irRef:
+		for i := len(c.scopes) - 1; i >= 0; i-- {
+			if text, ok := c.scopes[i][r.Name]; ok {
+				return text + c.accessors(accessors)
+			}
+		}
+		if c.params[r.Name] != nil {
+			prefix := "var."
+			if c.paramLocals[r.Name] {
+				prefix = "local."
+			}
+			return prefix + snakeCase(r.Name) + c.accessors(accessors)
+		}
+		if c.vars[r.Name] != nil {
+			return "local." + snakeCase(r.Name) + c.accessors(accessors)
+		}
+		if tr := c.resources[r.Name]; tr != nil {
+			return c.resourceReference(tr, accessors)
+		}
+		c.pending = append(c.pending, fmt.Sprintf("unknown symbol %s", r.Name))
+		return "null"
+	case *irCall:
+		if len(accessors) > 0 {
+			if get, ok := accessors[0].(*irGet); ok {
+				if text, ok := c.deploymentFunction(r, get.Name); ok {
+					return text + c.accessors(accessors[1:])
+				}
+			}
+		}
+		if r.Method && strings.EqualFold(r.Name, "listKeys") && len(accessors) == 3 {
+			// storage.listKeys().keys[0].value
+			if ref, ok := r.Args[0].(*irRef); ok && c.resources[ref.Name] != nil {
+				tr := c.resources[ref.Name]
+				if idx, ok := accessors[1].(*irIndex); ok && tr.mapping != nil && tr.mapping.tfType == "azurerm_storage_account" {
+					keys := map[string]string{"0": "primary_access_key", "1": "secondary_access_key"}
+					if number, ok := idx.Key.(*irNumber); ok && keys[number.Text] != "" {
+						return tr.address + "." + keys[number.Text]
+					}
+				}
+			}
+		}
+	}
+	return c.expr(root) + c.accessors(accessors)
+}
+
+// accessors renders .name and [index] accessors
+func (c *tfConverter) accessors(accessors []irNode) string {
+	var b strings.Builder
+	for _, accessor := range accessors {
+		switch a := accessor.(type) {
+		case *irGet:
+			b.WriteString("." + a.Name)
+		case *irIndex:
+			b.WriteString("[" + c.expr(a.Key) + "]")
+		}
+	}
+	return b.String()
+}
+
+// deploymentFunction converts resourceGroup(), subscription() and tenant()
+// property reads
+func (c *tfConverter) deploymentFunction(call *irCall, property string) (string, bool) {
+	if len(call.Args) > 0 {
+		return "", false
+	}
+	switch strings.ToLower(call.Name) + "." + property {
+	case "resourcegroup.name":
+		return c.resourceGroupName(&irObject{}), true
+	case "resourcegroup.location", "resourcegroup.id", "resourcegroup.tags":
+		c.needRGName, c.needRGData = true, true
+		return "data.azurerm_resource_group.current." + property, true
+	case "subscription.subscriptionId":
+		c.needClient = true
+		return "data.azurerm_client_config.current.subscription_id", true
+	case "subscription.tenantId", "tenant.tenantId":
+		c.needClient = true
+		return "data.azurerm_client_config.current.tenant_id", true
+	case "subscription.id":
+		c.needClient = true
+		return `"/subscriptions/${data.azurerm_client_config.current.subscription_id}"`, true
+	}
+	return "", false
+}
+
+// resourceReference renders a reference to a resource or module symbol
+func (c *tfConverter) resourceReference(tr *tfResource, accessors []irNode) string {
+	if tr.address == "" {
+		c.pending = append(c.pending, fmt.Sprintf("%s (%s) was not converted", tr.src.symbol, tr.src.typ))
+		return "null"
+	}
+	base := tr.address
+	if len(accessors) > 0 {
+		if idx, ok := accessors[0].(*irIndex); ok {
+			base += "[" + c.expr(idx.Key) + "]"
+			accessors = accessors[1:]
+		}
+	}
+	if base == tr.address && (tr.src.loop != nil || tr.src.cond != nil) {
+		if tr.src.loop != nil {
+			c.pending = append(c.pending, fmt.Sprintf("%s is a loop; pick an element", tr.src.symbol))
+		} else {
+			base += "[0]"
+		}
+	}
+
+	var names []string
+	for _, accessor := range accessors {
+		get, ok := accessor.(*irGet)
+		if !ok {
+			break
+		}
+		names = append(names, get.Name)
+	}
+
+	if tr.src.module {
+		if len(names) >= 2 && names[0] == "outputs" {
+			return base + "." + snakeCase(names[1]) + c.accessors(accessors[2:])
+		}
+		c.pending = append(c.pending, fmt.Sprintf("read module %s through its outputs", tr.src.symbol))
+		return "null"
+	}
+	if len(names) == 0 {
+		return base + ".id" + c.accessors(accessors)
+	}
+	for n := len(names); n > 0; n-- {
+		if attr, ok := terraformAttribute(tr.mapping, strings.Join(names[:n], ".")); ok {
+			return base + "." + attr + c.accessors(accessors[n:])
+		}
+	}
+	c.pending = append(c.pending, fmt.Sprintf("no %s attribute for %s.%s", tr.mapping.tfType, tr.src.symbol, strings.Join(names, ".")))
+	return "null"
+}
+
+// terraformAttribute maps a property read from a Bicep resource
+func terraformAttribute(mapping *resourceMapping, path string) (string, bool) {
+	switch path {
+	case "id", "name":
+		return path, true
+	case "location", "tags":
+		for _, rule := range mapping.rules {
+			if rule.bicep == path && rule.tf == path {
+				return path, true
+			}
+		}
+	}
+	if attr, ok := mapping.attributes[path]; ok {
+		return attr, true
+	}
+	for _, rule := range mapping.rules {
+		if rule.bicep == path && rule.tf != "" && !strings.Contains(rule.tf, ".") && rule.toTerraform == nil && rule.elements == nil {
+			return rule.tf, true
+		}
+	}
+	return "", false
+}
+
+// terraformFunctions are Bicep functions with a same-argument Terraform
+// equivalent, keyed by lower-case name
+var terraformFunctions = map[string]string{
+	"tolower":          "lower",
+	"toupper":          "upper",
+	"length":           "length",
+	"contains":         "contains",
+	"replace":          "replace",
+	"union":            "merge",
+	"trim":             "trimspace",
+	"startswith":       "startswith",
+	"endswith":         "endswith",
+	"string":           "tostring",
+	"int":              "tonumber",
+	"bool":             "tobool",
+	"json":             "jsondecode",
+	"base64":           "base64encode",
+	"base64tostring":   "base64decode",
+	"coalesce":         "coalesce",
+	"indexof":          "index",
+	"flatten":          "flatten",
+	"objectkeys":       "keys",
+	"utcnow":           "timestamp",
+	"loadtextcontent":  "file",
+	"loadfileasbase64": "filebase64",
+}
+
+// functionHints explain Bicep functions that have no Terraform equivalent
+var functionHints = map[string]string{
+	"uniquestring":           "uniqueString() has no Terraform equivalent; use a random_string resource or substr(sha1(...), 0, 13)",
+	"guid":                   "guid() has no Terraform equivalent; use uuidv5() or a random_uuid resource",
+	"resourceid":             "use the id attribute of the referenced resource instead of resourceId()",
+	"subscriptionresourceid": "use the id attribute of the referenced resource instead of subscriptionResourceId()",
+	"extensionresourceid":    "use the id attribute of the referenced resource instead of extensionResourceId()",
+	"tenantresourceid":       "use the id attribute of the referenced resource instead of tenantResourceId()",
+	"environment":            "environment() has no Terraform equivalent; use the azurerm provider's environment setting",
+	"deployment":             "deployment() has no Terraform equivalent",
+	"newguid":                "newGuid() has no Terraform equivalent; use a random_uuid resource",
+}
+
+// formatPlaceholder matches {0}-style placeholders in format()
+var formatPlaceholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// call converts a function call
+func (c *tfConverter) call(n *irCall) string {
+	name := strings.ToLower(n.Name)
+	if n.Method {
+		c.pending = append(c.pending, fmt.Sprintf("no Terraform equivalent for the resource function %s()", n.Name))
+		return "null"
+	}
+	if hint, ok := functionHints[name]; ok {
+		c.pending = append(c.pending, hint)
+		return "null"
+	}
+
+	// Lambdas bind their parameter while the body renders
+	if (name == "map" || name == "filter") && len(n.Args) == 2 {
+		if lambda, ok := n.Args[1].(*irLambda); ok && len(lambda.Params) == 1 {
+			source := c.expr(n.Args[0])
+			param := lambda.Params[0]
+			c.scopes = append(c.scopes, map[string]string{param: param})
+			body := c.expr(lambda.Body)
+			c.scopes = c.scopes[:len(c.scopes)-1]
+			if name == "map" {
+				return fmt.Sprintf("[for %s in %s : %s]", param, source, body)
+			}
+			return fmt.Sprintf("[for %s in %s : %s if %s]", param, source, param, body)
+		}
+	}
+
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = c.expr(arg)
+	}
+	if renamed, ok := terraformFunctions[name]; ok {
+		return fmt.Sprintf("%s(%s)", renamed, strings.Join(args, ", "))
+	}
+
+	switch {
+	case name == "any" && len(args) == 1:
+		return args[0]
+	case name == "split" && len(args) == 2:
+		return fmt.Sprintf("split(%s, %s)", args[1], args[0])
+	case name == "join" && len(args) == 2:
+		return fmt.Sprintf("join(%s, %s)", args[1], args[0])
+	case name == "empty" && len(args) == 1:
+		return fmt.Sprintf("length(%s) == 0", args[0])
+	case name == "take" && len(args) == 2:
+		if c.isString(n.Args[0]) {
+			return fmt.Sprintf("substr(%s, 0, %s)", args[0], args[1])
+		}
+		return fmt.Sprintf("slice(%s, 0, %s)", args[0], args[1])
+	case name == "skip" && len(args) == 2:
+		if c.isString(n.Args[0]) {
+			return fmt.Sprintf("substr(%s, %s, -1)", args[0], args[1])
+		}
+		return fmt.Sprintf("slice(%s, %s, length(%s))", args[0], args[1], args[0])
+	case name == "substring" && (len(args) == 2 || len(args) == 3):
+		if len(args) == 2 {
+			return fmt.Sprintf("substr(%s, %s, -1)", args[0], args[1])
+		}
+		return fmt.Sprintf("substr(%s, %s, %s)", args[0], args[1], args[2])
+	case name == "first" && len(args) == 1:
+		if c.isString(n.Args[0]) {
+			return fmt.Sprintf("substr(%s, 0, 1)", args[0])
+		}
+		return args[0] + "[0]"
+	case name == "last" && len(args) == 1:
+		if c.isString(n.Args[0]) {
+			return fmt.Sprintf("substr(%s, length(%s) - 1, 1)", args[0], args[0])
+		}
+		return fmt.Sprintf("%s[length(%s) - 1]", args[0], args[0])
+	case name == "concat":
+		for _, arg := range n.Args {
+			if c.isString(arg) {
+				return fmt.Sprintf("join(\"\", [%s])", strings.Join(args, ", "))
+			}
+		}
+		return fmt.Sprintf("concat(%s)", strings.Join(args, ", "))
+	case (name == "min" || name == "max") && len(args) > 0:
+		if len(args) == 1 {
+			return fmt.Sprintf("%s(%s...)", name, args[0])
+		}
+		return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+	case name == "range" && len(args) == 2:
+		if start, ok := n.Args[0].(*irNumber); ok && start.Text == "0" {
+			return fmt.Sprintf("range(%s)", args[1])
+		}
+		return fmt.Sprintf("range(%s, %s + %s)", args[0], args[0], args[1])
+	case name == "items" && len(args) == 1:
+		return fmt.Sprintf("[for key, value in %s : { key = key, value = value }]", args[0])
+	case name == "loadjsoncontent" && len(args) == 1:
+		return fmt.Sprintf("jsondecode(file(%s))", args[0])
+	case name == "format" && len(args) > 0:
+		if layout, ok := literalString(n.Args[0]); ok {
+			if converted, ok := terraformFormat(layout); ok {
+				return fmt.Sprintf("format(%s)", strings.Join(append([]string{hclQuote(converted)}, args[1:]...), ", "))
+			}
+		}
+	}
+	c.pending = append(c.pending, fmt.Sprintf("no Terraform equivalent for %s()", n.Name))
+	return "null"
+}
+
+// terraformFormat converts a format() string with {0}, {1}, ... in order to
+// %v verbs
+func terraformFormat(layout string) (string, bool) {
+	layout = strings.ReplaceAll(layout, "%", "%%")
+	next := 0
+	ok := true
+	converted := formatPlaceholder.ReplaceAllStringFunc(layout, func(match string) string {
+		if match != fmt.Sprintf("{%d}", next) {
+			ok = false
+		}
+		next++
+		return "%v"
+	})
+	return converted, ok && !strings.ContainsAny(formatPlaceholder.ReplaceAllString(layout, ""), "{}")
+}
+
+// stringFunctions are Bicep functions that return strings
+var stringFunctions = map[string]bool{
+	"tolower": true, "toupper": true, "replace": true, "string": true, "substring": true,
+	"format": true, "trim": true, "uniquestring": true, "guid": true, "concat": false,
+}
+
+// isString guesses whether an expression is a string, to pick between the
+// string and list forms of take(), first() and friends
+func (c *tfConverter) isString(node irNode) bool {
+	switch n := node.(type) {
+	case *irString, *irTemplate:
+		return true
+	case *irRef:
+		if p := c.params[n.Name]; p != nil {
+			return p.typ == "string"
+		}
+		if v := c.vars[n.Name]; v != nil {
+			return c.isString(v.value)
+		}
+	case *irCall:
+		return stringFunctions[strings.ToLower(n.Name)]
+	case *irGet:
+		return n.Name == "name" || n.Name == "id"
+	}
+	return false
+}
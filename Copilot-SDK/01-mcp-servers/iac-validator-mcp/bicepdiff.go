@@ -0,0 +1,697 @@
+// =============================================================================
+// Bicep Template Diff Tool
+// =============================================================================
+// Compiles two versions of a Bicep file to ARM JSON and compares the
+// templates semantically: parameters, resources (matched by type and name),
+// apiVersions, properties and outputs. This gives reviewers a what-if style
+// view of a change without deploying anything or needing a subscription.
+//
+// The two sides are either two files, or two git revisions of the same file.
+// A git revision is exported (with `git archive`) together with the rest of
+// its workspace root, so relative module references keep working.
+// =============================================================================
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Diff actions for parameters, resources, outputs and properties
+const (
+	diffAdded    = "added"
+	diffRemoved  = "removed"
+	diffModified = "modified"
+)
+
+// TemplateDiff is the structured result of diff_bicep
+type TemplateDiff struct {
+	Before     string         `json:"before"`
+	After      string         `json:"after"`
+	Identical  bool           `json:"identical"`
+	Parameters []MemberDiff   `json:"parameters"`
+	Resources  []ResourceDiff `json:"resources"`
+	Outputs    []MemberDiff   `json:"outputs"`
+}
+
+// MemberDiff describes a changed parameter or output
+type MemberDiff struct {
+	Name    string           `json:"name"`
+	Action  string           `json:"action"`
+	Changes []PropertyChange `json:"changes,omitempty"`
+}
+
+// ResourceDiff describes a resource that was added, removed or modified
+type ResourceDiff struct {
+	Type             string           `json:"type"`
+	Name             string           `json:"name"`
+	Action           string           `json:"action"`
+	APIVersionBefore string           `json:"apiVersionBefore,omitempty"`
+	APIVersionAfter  string           `json:"apiVersionAfter,omitempty"`
+	Changes          []PropertyChange `json:"changes,omitempty"`
+}
+
+// PropertyChange is a single value that differs between the two templates
+type PropertyChange struct {
+	Path   string      `json:"path"`
+	Action string      `json:"action"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// propertyChangeSchema is the JSON Schema of PropertyChange
+var propertyChangeSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":   map[string]interface{}{"type": "string"},
+		"action": map[string]interface{}{"type": "string", "enum": []string{diffAdded, diffRemoved, diffModified}},
+		"before": map[string]interface{}{},
+		"after":  map[string]interface{}{},
+	},
+	"required": []string{"path", "action"},
+}
+
+// memberDiffSchema is the JSON Schema of MemberDiff
+var memberDiffSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name":    map[string]interface{}{"type": "string"},
+		"action":  map[string]interface{}{"type": "string", "enum": []string{diffAdded, diffRemoved, diffModified}},
+		"changes": map[string]interface{}{"type": "array", "items": propertyChangeSchema},
+	},
+	"required": []string{"name", "action"},
+}
+
+// templateDiffOutputSchema is the JSON Schema of TemplateDiff
+var templateDiffOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"before":     map[string]interface{}{"type": "string"},
+		"after":      map[string]interface{}{"type": "string"},
+		"identical":  map[string]interface{}{"type": "boolean"},
+		"parameters": map[string]interface{}{"type": "array", "items": memberDiffSchema},
+		"resources": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type":             map[string]interface{}{"type": "string"},
+					"name":             map[string]interface{}{"type": "string"},
+					"action":           map[string]interface{}{"type": "string", "enum": []string{diffAdded, diffRemoved, diffModified}},
+					"apiVersionBefore": map[string]interface{}{"type": "string"},
+					"apiVersionAfter":  map[string]interface{}{"type": "string"},
+					"changes":          map[string]interface{}{"type": "array", "items": propertyChangeSchema},
+				},
+				"required": []string{"type", "name", "action"},
+			},
+		},
+		"outputs": map[string]interface{}{"type": "array", "items": memberDiffSchema},
+	},
+	"required": []string{"before", "after", "identical", "parameters", "resources", "outputs"},
+}
+
+// bicepSide is one side of the comparison: a file on disk plus a label
+type bicepSide struct {
+	label string
+	file  string
+}
+
+// handleDiffBicep compiles two versions of a Bicep file and diffs the ARM
+// templates
+func (s *MCPServer) handleDiffBicep(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+	comparePath, _ := args["compare_path"].(string)
+	baseRef, _ := args["base_ref"].(string)
+	headRef, _ := args["head_ref"].(string)
+
+	if (comparePath == "") == (baseRef == "") {
+		return nil, fmt.Errorf("exactly one of compare_path or base_ref is required")
+	}
+	if headRef != "" && baseRef == "" {
+		return nil, fmt.Errorf("head_ref requires base_ref")
+	}
+
+	absPath, err := s.resolveBicepFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := exec.LookPath("az"); err != nil {
+		return &ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: "❌ Azure CLI not found. Please install Azure CLI: https://docs.microsoft.com/cli/azure/install-azure-cli"}},
+			IsError: true,
+		}, nil
+	}
+
+	var before, after bicepSide
+	if comparePath != "" {
+		otherPath, err := s.resolveBicepFile(comparePath)
+		if err != nil {
+			return nil, err
+		}
+		before = bicepSide{label: s.displayPath(absPath), file: absPath}
+		after = bicepSide{label: s.displayPath(otherPath), file: otherPath}
+	} else {
+		if _, err := exec.LookPath("git"); err != nil {
+			return nil, fmt.Errorf("git not found: base_ref requires git on the PATH")
+		}
+
+		exportDir, err := os.MkdirTemp("", "iac-bicep-diff-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(exportDir)
+
+		label := s.displayPath(absPath)
+		before = bicepSide{label: label + "@" + baseRef}
+		if before.file, err = s.exportGitRevision(ctx, absPath, baseRef, filepath.Join(exportDir, "before")); err != nil {
+			return nil, err
+		}
+		after = bicepSide{label: label, file: absPath}
+		if headRef != "" {
+			after.label = label + "@" + headRef
+			if after.file, err = s.exportGitRevision(ctx, absPath, headRef, filepath.Join(exportDir, "after")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🔀 Comparing `%s` → `%s`\n\n", before.label, after.label))
+
+	reporter := reporterFrom(ctx)
+	var templates [2][]byte
+	for i, side := range []bicepSide{before, after} {
+		reporter.progress(i+1, 3, "Compiling "+side.label)
+		arm, failure, err := compileBicep(ctx, side.file)
+		if err != nil {
+			return nil, err
+		}
+		if failure != "" {
+			output.WriteString(fmt.Sprintf("❌ **Bicep build failed for %s**\n\n```\n%s\n```\n", side.label, failure))
+			return &ToolCallResult{
+				Content: []ContentBlock{{Type: "text", Text: output.String()}},
+				IsError: true,
+			}, nil
+		}
+		templates[i] = arm
+	}
+
+	reporter.progress(3, 3, "Comparing ARM templates")
+	diff, err := diffARMTemplates(templates[0], templates[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ARM template: %w", err)
+	}
+	diff.Before = before.label
+	diff.After = after.label
+
+	writeTemplateDiff(&output, diff)
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: diff,
+	}, nil
+}
+
+// resolveBicepFile resolves a path argument that must name an existing
+// .bicep file
+func (s *MCPServer) resolveBicepFile(path string) (string, error) {
+	absPath, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(strings.ToLower(absPath), ".bicep") {
+		return "", fmt.Errorf("file must have .bicep extension: %s", absPath)
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return "", fmt.Errorf("file not found: %s", absPath)
+	}
+	return absPath, nil
+}
+
+// displayPath shortens path to be relative to its workspace root
+func (s *MCPServer) displayPath(path string) string {
+	for _, root := range s.roots() {
+		if withinRoots(path, []string{root}) {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return path
+}
+
+// compileBicep builds file to ARM JSON. A non-empty failure holds the
+// compiler output of a failed build.
+func compileBicep(ctx context.Context, file string) (arm []byte, failure string, err error) {
+	cmd := commandContext(ctx, "az", "bicep", "build", "--file", file, "--stdout")
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, strings.TrimSpace(string(exitErr.Stderr)), nil
+		}
+		return nil, "", fmt.Errorf("failed to run az bicep build: %w", err)
+	}
+	return out, "", nil
+}
+
+// =============================================================================
+// Git Revisions
+// =============================================================================
+
+// exportGitRevision extracts the workspace root containing file, as it was
+// at rev, into dest and returns the path of file inside the export
+func (s *MCPServer) exportGitRevision(ctx context.Context, file, rev, dest string) (string, error) {
+	if strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("invalid git revision: %s", rev)
+	}
+
+	cmd := commandContext(ctx, "git", "-C", filepath.Dir(file), "rev-parse", "--show-toplevel")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s is not inside a git repository", file)
+	}
+	top, err := filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+	if err != nil {
+		return "", fmt.Errorf("invalid git repository: %w", err)
+	}
+
+	// Export the workspace root if it lies inside the repository, otherwise
+	// the whole repository
+	scope := top
+	for _, root := range s.roots() {
+		if withinRoots(file, []string{root}) && withinRoots(root, []string{top}) {
+			scope = root
+			break
+		}
+	}
+
+	archiveArgs := []string{"-C", top, "archive", "--format=tar", rev}
+	if rel, _ := filepath.Rel(top, scope); rel != "." {
+		archiveArgs = append(archiveArgs, "--", filepath.ToSlash(rel))
+	}
+	cmd = commandContext(ctx, "git", archiveArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	archive, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git archive %s failed: %s", rev, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := extractTar(bytes.NewReader(archive), dest); err != nil {
+		return "", fmt.Errorf("failed to extract %s: %w", rev, err)
+	}
+
+	rel, err := filepath.Rel(top, file)
+	if err != nil {
+		return "", err
+	}
+	exported := filepath.Join(dest, rel)
+	if _, err := os.Stat(exported); err != nil {
+		return "", fmt.Errorf("%s does not exist at revision %s", filepath.ToSlash(rel), rev)
+	}
+	return exported, nil
+}
+
+// extractTar writes the regular files and directories of a tar stream below
+// dest. Entries that would escape dest are rejected.
+func extractTar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(hdr.Name))
+		if !withinRoots(target, []string{dest}) {
+			return fmt.Errorf("archive entry outside destination: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// =============================================================================
+// ARM Template Comparison
+// =============================================================================
+
+// armTemplate is the subset of an ARM template that is compared
+type armTemplate struct {
+	Parameters map[string]interface{} `json:"parameters"`
+	Resources  json.RawMessage        `json:"resources"`
+	Outputs    map[string]interface{} `json:"outputs"`
+}
+
+// armResource is a resource keyed by type and name
+type armResource struct {
+	key, typ, name, apiVersion string
+	body                       map[string]interface{}
+}
+
+// diffARMTemplates compares two compiled ARM templates
+func diffARMTemplates(beforeJSON, afterJSON []byte) (*TemplateDiff, error) {
+	var before, after armTemplate
+	if err := json.Unmarshal(beforeJSON, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(afterJSON, &after); err != nil {
+		return nil, err
+	}
+
+	beforeResources, err := armResources(before.Resources)
+	if err != nil {
+		return nil, err
+	}
+	afterResources, err := armResources(after.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &TemplateDiff{
+		Parameters: diffMembers(before.Parameters, after.Parameters),
+		Resources:  diffResources(beforeResources, afterResources),
+		Outputs:    diffMembers(before.Outputs, after.Outputs),
+	}
+	diff.Identical = len(diff.Parameters) == 0 && len(diff.Resources) == 0 && len(diff.Outputs) == 0
+	return diff, nil
+}
+
+// armResources reads the resources of a template. Templates compiled with
+// symbolic names (languageVersion 2.0) store them as an object instead of an
+// array; both are keyed by type and name so they can be compared.
+func armResources(raw json.RawMessage) ([]armResource, error) {
+	var list []map[string]interface{}
+	if len(raw) > 0 && raw[0] == '{' {
+		var symbolic map[string]map[string]interface{}
+		if err := json.Unmarshal(raw, &symbolic); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(symbolic))
+		for name := range symbolic {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			list = append(list, symbolic[name])
+		}
+	} else if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]int)
+	resources := make([]armResource, 0, len(list))
+	for _, body := range list {
+		r := armResource{body: body}
+		r.typ, _ = body["type"].(string)
+		r.name = fmt.Sprint(body["name"])
+		r.apiVersion, _ = body["apiVersion"].(string)
+		key := strings.ToLower(r.typ) + "|" + r.name
+
+		// Resources declared twice with the same name expression (e.g. in
+		// conditional branches) are told apart by position
+		r.key = key
+		if n := seen[key]; n > 0 {
+			r.key = fmt.Sprintf("%s|%d", key, n)
+		}
+		seen[key]++
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+// diffResources matches resources by type and name and compares them
+func diffResources(before, after []armResource) []ResourceDiff {
+	beforeByKey := make(map[string]armResource, len(before))
+	for _, r := range before {
+		beforeByKey[r.key] = r
+	}
+	afterKeys := make(map[string]bool, len(after))
+
+	diffs := []ResourceDiff{}
+	for _, a := range after {
+		afterKeys[a.key] = true
+		b, ok := beforeByKey[a.key]
+		if !ok {
+			diffs = append(diffs, ResourceDiff{
+				Type:            a.typ,
+				Name:            a.name,
+				Action:          diffAdded,
+				APIVersionAfter: a.apiVersion,
+			})
+			continue
+		}
+
+		var changes []PropertyChange
+		diffValues("", withoutIdentity(b.body), withoutIdentity(a.body), &changes)
+		if len(changes) == 0 && a.apiVersion == b.apiVersion {
+			continue
+		}
+		rd := ResourceDiff{Type: a.typ, Name: a.name, Action: diffModified, Changes: changes}
+		if a.apiVersion != b.apiVersion {
+			rd.APIVersionBefore = b.apiVersion
+			rd.APIVersionAfter = a.apiVersion
+		}
+		diffs = append(diffs, rd)
+	}
+	for _, b := range before {
+		if !afterKeys[b.key] {
+			diffs = append(diffs, ResourceDiff{
+				Type:             b.typ,
+				Name:             b.name,
+				Action:           diffRemoved,
+				APIVersionBefore: b.apiVersion,
+			})
+		}
+	}
+	return diffs
+}
+
+// withoutIdentity drops the fields resources are matched on, so only the
+// remaining properties are compared
+func withoutIdentity(body map[string]interface{}) map[string]interface{} {
+	rest := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		switch k {
+		case "type", "name", "apiVersion":
+			continue
+		}
+		rest[k] = v
+	}
+	return rest
+}
+
+// diffMembers compares the parameters or outputs sections of two templates
+func diffMembers(before, after map[string]interface{}) []MemberDiff {
+	diffs := []MemberDiff{}
+	for _, name := range unionKeys(before, after) {
+		b, inBefore := before[name]
+		a, inAfter := after[name]
+		switch {
+		case !inBefore:
+			diffs = append(diffs, MemberDiff{Name: name, Action: diffAdded})
+		case !inAfter:
+			diffs = append(diffs, MemberDiff{Name: name, Action: diffRemoved})
+		default:
+			var changes []PropertyChange
+			diffValues("", b, a, &changes)
+			if len(changes) > 0 {
+				diffs = append(diffs, MemberDiff{Name: name, Action: diffModified, Changes: changes})
+			}
+		}
+	}
+	return diffs
+}
+
+// diffValues appends the differences between before and after, recursing
+// into objects and equally long arrays
+func diffValues(path string, before, after interface{}, changes *[]PropertyChange) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(b, a) {
+			// Generator metadata changes with every Bicep release
+			if key == "_generator" && strings.HasSuffix(path, "metadata") {
+				continue
+			}
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			bv, inBefore := b[key]
+			av, inAfter := a[key]
+			switch {
+			case !inBefore:
+				*changes = append(*changes, PropertyChange{Path: child, Action: diffAdded, After: av})
+			case !inAfter:
+				*changes = append(*changes, PropertyChange{Path: child, Action: diffRemoved, Before: bv})
+			default:
+				diffValues(child, bv, av, changes)
+			}
+		}
+		return
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range b {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), b[i], a[i], changes)
+		}
+		return
+	}
+
+	bj, _ := json.Marshal(before)
+	aj, _ := json.Marshal(after)
+	if !bytes.Equal(bj, aj) {
+		*changes = append(*changes, PropertyChange{Path: path, Action: diffModified, Before: before, After: after})
+	}
+}
+
+// unionKeys returns the sorted keys present in either map
+func unionKeys(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		seen[k] = true
+	}
+	for k := range b {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// =============================================================================
+// Rendering
+// =============================================================================
+
+// writeTemplateDiff renders a TemplateDiff as markdown
+func writeTemplateDiff(output *strings.Builder, diff *TemplateDiff) {
+	if diff.Identical {
+		output.WriteString("✅ No differences. Both versions compile to equivalent ARM templates.\n")
+		return
+	}
+
+	var added, removed, modified int
+	for _, r := range diff.Resources {
+		switch r.Action {
+		case diffAdded:
+			added++
+		case diffRemoved:
+			removed++
+		case diffModified:
+			modified++
+		}
+	}
+	output.WriteString(fmt.Sprintf("📊 **Resources:** %d added, %d removed, %d modified\n\n", added, removed, modified))
+
+	if len(diff.Parameters) > 0 {
+		output.WriteString("🔧 **Parameters**\n")
+		writeMemberDiffs(output, diff.Parameters)
+		output.WriteString("\n")
+	}
+
+	sections := []struct {
+		action, title string
+	}{
+		{diffAdded, "➕ **Added resources**"},
+		{diffRemoved, "🗑️ **Removed resources**"},
+		{diffModified, "🔄 **Modified resources**"},
+	}
+	for _, section := range sections {
+		var lines []string
+		for _, r := range diff.Resources {
+			if r.Action != section.action {
+				continue
+			}
+			line := fmt.Sprintf("   - `%s` %s", r.Type, r.Name)
+			switch {
+			case r.Action == diffAdded:
+				line += fmt.Sprintf(" (@%s)", r.APIVersionAfter)
+			case r.Action == diffRemoved:
+				line += fmt.Sprintf(" (@%s)", r.APIVersionBefore)
+			case r.APIVersionBefore != r.APIVersionAfter:
+				line += fmt.Sprintf("\n       apiVersion: %s → %s", r.APIVersionBefore, r.APIVersionAfter)
+			}
+			for _, c := range r.Changes {
+				line += "\n       " + formatPropertyChange(c)
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		output.WriteString(section.title + "\n")
+		output.WriteString(strings.Join(lines, "\n") + "\n\n")
+	}
+
+	if len(diff.Outputs) > 0 {
+		output.WriteString("📤 **Outputs**\n")
+		writeMemberDiffs(output, diff.Outputs)
+	}
+}
+
+// writeMemberDiffs renders parameter or output changes
+func writeMemberDiffs(output *strings.Builder, diffs []MemberDiff) {
+	for _, d := range diffs {
+		output.WriteString(fmt.Sprintf("   - %s (%s)\n", d.Name, d.Action))
+		for _, c := range d.Changes {
+			output.WriteString("       " + formatPropertyChange(c) + "\n")
+		}
+	}
+}
+
+// formatPropertyChange renders a single property change
+func formatPropertyChange(c PropertyChange) string {
+	switch c.Action {
+	case diffAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, formatPlanValue(c.After))
+	case diffRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, formatPlanValue(c.Before))
+	}
+	return fmt.Sprintf("~ %s: %s → %s", c.Path, formatPlanValue(c.Before), formatPlanValue(c.After))
+}
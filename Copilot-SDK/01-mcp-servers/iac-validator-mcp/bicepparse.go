@@ -0,0 +1,754 @@
+// =============================================================================
+// Bicep Parser
+// =============================================================================
+// A recursive-descent parser for the subset of Bicep that convert_iac
+// translates: targetScope, param, var, resource (including nested child
+// resources, conditions and loops), module and output declarations, with
+// decorators. Expressions are parsed into the conversion IR (see convert.go).
+//
+// Declarations the converter does not handle (type, func, import, metadata,
+// ...) are kept as source text so they can be reported as TODOs.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// bicepDecorator is an @name(args) decorator
+type bicepDecorator struct {
+	name string
+	args []irNode
+}
+
+// bicepParam is a param declaration
+type bicepParam struct {
+	name       string
+	typ        string
+	value      irNode // default, nil when absent
+	decorators []bicepDecorator
+}
+
+// bicepVar is a var declaration
+type bicepVar struct {
+	name  string
+	value irNode
+}
+
+// bicepResource is a resource or module declaration. Loops and conditions are
+// unwrapped: body is always the object literal.
+type bicepResource struct {
+	symbol     string
+	typ        string // resource type, or the module path
+	apiVersion string
+	existing   bool
+	module     bool
+	body       *irObject
+	cond       irNode // if (cond)
+	loop       *irFor // [for ...: body], with Body unset
+	parent     string // symbol of the enclosing resource for nested declarations
+	line       int
+	src        string
+}
+
+// bicepOutput is an output declaration
+type bicepOutput struct {
+	name       string
+	typ        string
+	value      irNode
+	decorators []bicepDecorator
+}
+
+// bicepFile is a parsed Bicep file
+type bicepFile struct {
+	targetScope string
+	params      []*bicepParam
+	vars        []*bicepVar
+	resources   []*bicepResource // resources and modules in declaration order
+	outputs     []*bicepOutput
+	skipped     []string // declarations that are not converted
+}
+
+// decoratorNamed returns the named decorator, if present
+func decoratorNamed(decorators []bicepDecorator, name string) *bicepDecorator {
+	for i := range decorators {
+		if strings.EqualFold(decorators[i].name, name) {
+			return &decorators[i]
+		}
+	}
+	return nil
+}
+
+// bicepParser parses Bicep source text
+type bicepParser struct {
+	src   string
+	pos   int
+	owner *bicepResource // resource whose body is being parsed
+	file  *bicepFile
+}
+
+// parseBicep parses a Bicep file
+func parseBicep(src string) (file *bicepFile, err error) {
+	p := &bicepParser{src: src, file: &bicepFile{targetScope: "resourceGroup"}}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(bicepSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			file, err = nil, perr
+		}
+	}()
+	p.parseFile()
+	return p.file, nil
+}
+
+// bicepSyntaxError is raised through panic and recovered by parseBicep
+type bicepSyntaxError struct {
+	line, column int
+	message      string
+}
+
+func (e bicepSyntaxError) Error() string {
+	return fmt.Sprintf("Bicep syntax error at line %d, column %d: %s", e.line, e.column, e.message)
+}
+
+// fail aborts parsing at the current position
+func (p *bicepParser) fail(format string, args ...interface{}) {
+	line, column := p.lineColumn(p.pos)
+	panic(bicepSyntaxError{line, column, fmt.Sprintf(format, args...)})
+}
+
+// lineColumn converts an offset to a 1-based line and column
+func (p *bicepParser) lineColumn(offset int) (int, int) {
+	before := p.src[:offset]
+	line := strings.Count(before, "\n") + 1
+	return line, offset - strings.LastIndex(before, "\n")
+}
+
+// peek returns the byte at the current position, or 0 at the end
+func (p *bicepParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// skip skips blanks and comments, and newlines too when newlines is set
+func (p *bicepParser) skip(newlines bool) {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += end
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.fail("unterminated comment")
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+// accept consumes text if it comes next on the line
+func (p *bicepParser) accept(text string) bool {
+	p.skip(false)
+	if strings.HasPrefix(p.src[p.pos:], text) {
+		p.pos += len(text)
+		return true
+	}
+	return false
+}
+
+// expect consumes text or fails
+func (p *bicepParser) expect(text string) {
+	if !p.accept(text) {
+		p.fail("expected %q", text)
+	}
+}
+
+// ident reads an identifier, or returns "" when none follows
+func (p *bicepParser) ident() string {
+	p.skip(false)
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := rune(p.src[p.pos])
+		if c == '_' || unicode.IsLetter(c) || (p.pos > start && unicode.IsDigit(c)) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+// keywordAhead reports whether word follows as a whole identifier
+func (p *bicepParser) keywordAhead(word string) bool {
+	p.skip(false)
+	if !strings.HasPrefix(p.src[p.pos:], word) {
+		return false
+	}
+	end := p.pos + len(word)
+	return end == len(p.src) || !isIdentChar(p.src[end])
+}
+
+// isIdentChar reports whether c can continue an identifier
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// parseFile parses top-level declarations
+func (p *bicepParser) parseFile() {
+	var decorators []bicepDecorator
+	for {
+		p.skip(true)
+		if p.pos >= len(p.src) {
+			return
+		}
+		start := p.pos
+		if p.peek() == '@' {
+			decorators = append(decorators, p.parseDecorator())
+			continue
+		}
+
+		switch keyword := p.ident(); keyword {
+		case "targetScope":
+			p.expect("=")
+			if scope, ok := p.parseExpr().(*irString); ok {
+				p.file.targetScope = scope.Value
+			}
+		case "param":
+			param := &bicepParam{name: p.ident(), decorators: decorators}
+			param.typ = p.typeText()
+			if p.accept("=") {
+				param.value = p.parseExpr()
+			}
+			p.file.params = append(p.file.params, param)
+		case "var":
+			v := &bicepVar{name: p.ident()}
+			p.expect("=")
+			v.value = p.parseExpr()
+			p.file.vars = append(p.file.vars, v)
+		case "resource", "module":
+			p.parseResource(keyword == "module", start, nil)
+		case "output":
+			output := &bicepOutput{name: p.ident(), decorators: decorators}
+			output.typ = p.typeText()
+			p.expect("=")
+			output.value = p.parseExpr()
+			p.file.outputs = append(p.file.outputs, output)
+		case "":
+			p.fail("unexpected %q", string(p.peek()))
+		default:
+			p.skipStatement()
+			p.file.skipped = append(p.file.skipped, strings.TrimSpace(p.src[start:p.pos]))
+		}
+		decorators = nil
+	}
+}
+
+// parseDecorator parses @name(args) or @sys.name(args)
+func (p *bicepParser) parseDecorator() bicepDecorator {
+	p.pos++ // @
+	name := p.ident()
+	if p.accept(".") {
+		name = p.ident()
+	}
+	decorator := bicepDecorator{name: name}
+	if p.accept("(") {
+		decorator.args = p.parseList(")")
+	}
+	return decorator
+}
+
+// typeText reads a param or output type up to '=' or the end of the line
+func (p *bicepParser) typeText() string {
+	p.skip(false)
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\'':
+			p.parseString()
+			continue
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case depth == 0 && (c == '=' || c == '\n' || strings.HasPrefix(p.src[p.pos:], "//")):
+			return strings.TrimSpace(p.src[start:p.pos])
+		}
+		p.pos++
+	}
+	return strings.TrimSpace(p.src[start:p.pos])
+}
+
+// skipStatement skips to the end of the current declaration
+func (p *bicepParser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\'':
+			p.parseString()
+			continue
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case c == '\n' && depth <= 0:
+			return
+		case strings.HasPrefix(p.src[p.pos:], "//") || strings.HasPrefix(p.src[p.pos:], "/*"):
+			p.skip(false)
+			continue
+		}
+		p.pos++
+	}
+}
+
+// parseResource parses a resource or module declaration after its keyword
+func (p *bicepParser) parseResource(module bool, start int, owner *bicepResource) {
+	resource := &bicepResource{symbol: p.ident(), module: module}
+	resource.line, _ = p.lineColumn(start)
+	p.skip(false)
+	typ, ok := p.parseString().(*irString)
+	if !ok {
+		p.fail("resource type must be a plain string")
+	}
+	resource.typ = typ.Value
+	if !module {
+		if at := strings.LastIndex(typ.Value, "@"); at >= 0 {
+			resource.typ, resource.apiVersion = typ.Value[:at], typ.Value[at+1:]
+		}
+		if owner != nil {
+			if !strings.Contains(resource.typ, "/") {
+				resource.typ = owner.typ + "/" + resource.typ
+			}
+			if resource.apiVersion == "" {
+				resource.apiVersion = owner.apiVersion
+			}
+			resource.parent = owner.symbol
+		}
+	}
+	resource.existing = p.keywordAhead("existing") && p.ident() == "existing"
+	p.expect("=")
+
+	// Nested resources are parsed while this resource owns the body
+	saved := p.owner
+	p.owner = resource
+	defer func() { p.owner = saved }()
+
+	var body irNode
+	if p.keywordAhead("if") {
+		p.ident()
+		p.expect("(")
+		resource.cond = p.parseExpr()
+		p.skip(true)
+		p.expect(")")
+		body = p.parseExpr()
+	} else {
+		body = p.parseExpr()
+		if loop, ok := body.(*irFor); ok {
+			resource.loop = loop
+			body = loop.Body
+			if loop.Cond != nil {
+				resource.cond = loop.Cond
+				loop.Cond = nil
+			}
+			loop.Body = nil
+		}
+	}
+	object, ok := body.(*irObject)
+	if !ok {
+		p.fail("expected an object body for %s", resource.symbol)
+	}
+	resource.body = object
+	resource.src = strings.TrimSpace(p.src[start:p.pos])
+	p.file.resources = append(p.file.resources, resource)
+}
+
+// parseExpr parses an expression, including ternaries
+func (p *bicepParser) parseExpr() irNode {
+	cond := p.parseBinary(1)
+	p.skip(false)
+	if p.peek() == '?' && !strings.HasPrefix(p.src[p.pos:], "??") {
+		p.pos++
+		then := p.parseExprMultiline()
+		p.skip(true)
+		p.expect(":")
+		return &irCond{Cond: cond, Then: then, Else: p.parseExprMultiline()}
+	}
+	return cond
+}
+
+// parseExprMultiline parses an expression that may start on the next line
+func (p *bicepParser) parseExprMultiline() irNode {
+	p.skip(true)
+	return p.parseExpr()
+}
+
+// binaryPrecedence ranks Bicep binary operators, loosest first
+var binaryPrecedence = map[string]int{
+	"??": 1,
+	"||": 2,
+	"&&": 3,
+	"==": 4, "!=": 4, "=~": 4, "!~": 4,
+	"<": 5, ">": 5, "<=": 5, ">=": 5,
+	"+": 6, "-": 6,
+	"*": 7, "/": 7, "%": 7,
+}
+
+// binaryOperators lists operators longest first so prefixes match correctly
+var binaryOperators = []string{"??", "||", "&&", "==", "!=", "=~", "!~", "<=", ">=", "<", ">", "+", "-", "*", "/", "%"}
+
+// parseBinary parses binary operators of at least the given precedence
+func (p *bicepParser) parseBinary(minPrecedence int) irNode {
+	left := p.parseUnary()
+	for {
+		p.skip(false)
+		op := ""
+		for _, candidate := range binaryOperators {
+			if strings.HasPrefix(p.src[p.pos:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" || binaryPrecedence[op] < minPrecedence {
+			return left
+		}
+		p.pos += len(op)
+		p.skip(true)
+		right := p.parseBinary(binaryPrecedence[op] + 1)
+		left = &irBinary{Op: op, Left: left, Right: right}
+	}
+}
+
+// parseUnary parses ! and - prefixes
+func (p *bicepParser) parseUnary() irNode {
+	p.skip(false)
+	switch c := p.peek(); {
+	case c == '!':
+		p.pos++
+		return &irUnary{Op: "!", X: p.parseUnary()}
+	case c == '-' && p.pos+1 < len(p.src) && !unicode.IsDigit(rune(p.src[p.pos+1])):
+		p.pos++
+		return &irUnary{Op: "-", X: p.parseUnary()}
+	}
+	start := p.pos
+	return p.parsePostfix(p.parsePrimary(), start)
+}
+
+// parsePostfix parses property access, indexing and calls
+func (p *bicepParser) parsePostfix(node irNode, start int) irNode {
+	for {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], ".?"):
+			p.pos += 2
+			node = &irGet{Target: node, Name: p.ident()}
+		case p.peek() == '.':
+			p.pos++
+			name := p.ident()
+			if name == "" {
+				p.fail("expected a property name")
+			}
+			if p.peek() == '(' {
+				// Namespaced call such as sys.toLower(x)
+				p.pos++
+				if ref, ok := node.(*irRef); ok && (ref.Name == "sys" || ref.Name == "az") {
+					node = &irCall{Name: name, Args: p.parseList(")")}
+					continue
+				}
+				node = &irCall{Name: name, Args: append([]irNode{node}, p.parseList(")")...), Method: true}
+				continue
+			}
+			node = &irGet{Target: node, Name: name}
+		case strings.HasPrefix(p.src[p.pos:], "::"):
+			// Nested resource accessors have no counterpart
+			p.pos += 2
+			p.ident()
+			node = &irRaw{Src: p.src[start:p.pos]}
+		case p.peek() == '[':
+			p.pos++
+			p.accept("?")
+			p.skip(true)
+			key := p.parseExpr()
+			p.skip(true)
+			p.expect("]")
+			node = &irIndex{Target: node, Key: key}
+		case p.peek() == '(':
+			ref, ok := node.(*irRef)
+			if !ok {
+				return node
+			}
+			p.pos++
+			node = &irCall{Name: ref.Name, Args: p.parseList(")")}
+		case p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!="):
+			p.pos++ // non-null assertion
+		default:
+			return node
+		}
+	}
+}
+
+// parseList parses comma-separated expressions up to the closing text
+func (p *bicepParser) parseList(closing string) []irNode {
+	var items []irNode
+	for {
+		p.skip(true)
+		if p.accept(closing) {
+			return items
+		}
+		items = append(items, p.parseExpr())
+		p.skip(true)
+		if !p.accept(",") && !strings.HasPrefix(p.src[p.pos:], closing) {
+			p.fail("expected ',' or %q", closing)
+		}
+	}
+}
+
+// parsePrimary parses literals, references, objects, arrays and lambdas
+func (p *bicepParser) parsePrimary() irNode {
+	p.skip(false)
+	start := p.pos
+	switch c := p.peek(); {
+	case c == '\'':
+		return p.parseString()
+	case c == '{':
+		return p.parseObject()
+	case c == '[':
+		return p.parseArray()
+	case c == '(':
+		if lambda := p.tryLambda(); lambda != nil {
+			return lambda
+		}
+		p.pos++
+		p.skip(true)
+		inner := p.parseExpr()
+		p.skip(true)
+		p.expect(")")
+		return &irParen{X: inner}
+	case c == '-' || unicode.IsDigit(rune(c)):
+		p.pos++
+		for p.pos < len(p.src) && (unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return &irNumber{Text: p.src[start:p.pos]}
+	}
+
+	name := p.ident()
+	switch name {
+	case "":
+		p.fail("expected an expression")
+	case "true", "false":
+		return &irBool{Value: name == "true"}
+	case "null":
+		return &irNull{}
+	}
+	if p.accept("=>") {
+		p.skip(true)
+		return &irLambda{Params: []string{name}, Body: p.parseExpr()}
+	}
+	return &irRef{Name: name}
+}
+
+// tryLambda parses (a, b) => body, restoring the position if it is not one
+func (p *bicepParser) tryLambda() irNode {
+	start := p.pos
+	p.pos++
+	var params []string
+	for {
+		p.skip(false)
+		name := p.ident()
+		if name == "" {
+			break
+		}
+		params = append(params, name)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if len(params) > 0 && p.accept(")") && p.accept("=>") {
+		p.skip(true)
+		return &irLambda{Params: params, Body: p.parseExpr()}
+	}
+	p.pos = start
+	return nil
+}
+
+// parseString parses a single-quoted string with interpolation, or a
+// ”'multi-line”' string
+func (p *bicepParser) parseString() irNode {
+	if strings.HasPrefix(p.src[p.pos:], "'''") {
+		end := strings.Index(p.src[p.pos+3:], "'''")
+		if end < 0 {
+			p.fail("unterminated multi-line string")
+		}
+		text := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return &irString{Value: strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")}
+	}
+
+	p.pos++ // opening quote
+	var parts []irNode
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, &irString{Value: literal.String()})
+			literal.Reset()
+		}
+	}
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '\'':
+			p.pos++
+			flush()
+			if len(parts) == 0 {
+				return &irString{}
+			}
+			if s, ok := parts[0].(*irString); ok && len(parts) == 1 {
+				return s
+			}
+			return &irTemplate{Parts: parts}
+		case c == '\\' && p.pos+1 < len(p.src):
+			escapes := map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '\\': "\\", '\'': "'", '$': "$"}
+			if text, ok := escapes[p.src[p.pos+1]]; ok {
+				literal.WriteString(text)
+				p.pos += 2
+				continue
+			}
+			literal.WriteByte(c)
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "${"):
+			flush()
+			p.pos += 2
+			p.skip(true)
+			parts = append(parts, p.parseExpr())
+			p.skip(true)
+			p.expect("}")
+		default:
+			literal.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseObject parses an object literal, including nested resources
+func (p *bicepParser) parseObject() irNode {
+	p.pos++ // {
+	object := &irObject{}
+	for {
+		p.skip(true)
+		if p.accept(",") {
+			continue
+		}
+		if p.accept("}") {
+			return object
+		}
+		if p.peek() == '@' {
+			p.parseDecorator()
+			continue
+		}
+
+		start := p.pos
+		var key string
+		if p.peek() == '\'' {
+			s, ok := p.parseString().(*irString)
+			if !ok {
+				p.fail("object keys cannot be interpolated")
+			}
+			key = s.Value
+		} else {
+			key = p.ident()
+			if key == "" {
+				p.fail("expected a property name")
+			}
+			p.skip(false)
+			if key == "resource" && p.owner != nil && p.peek() != ':' {
+				p.parseResource(false, start, p.owner)
+				continue
+			}
+		}
+		p.expect(":")
+		p.skip(false)
+		valueStart := p.pos
+		value := p.parseExpr()
+		line, _ := p.lineColumn(start)
+		object.Items = append(object.Items, &irItem{
+			Key:   key,
+			Value: value,
+			Src:   strings.TrimSpace(p.src[valueStart:p.pos]),
+			Line:  line,
+		})
+	}
+}
+
+// parseArray parses an array literal or a for-expression
+func (p *bicepParser) parseArray() irNode {
+	p.pos++ // [
+	p.skip(true)
+	if p.keywordAhead("for") {
+		p.ident()
+		loop := &irFor{}
+		if p.accept("(") {
+			loop.Value = p.ident()
+			if p.accept(",") {
+				loop.Key = p.ident()
+			}
+			p.expect(")")
+		} else {
+			loop.Value = p.ident()
+		}
+		if p.ident() != "in" {
+			p.fail("expected 'in'")
+		}
+		p.skip(true)
+		loop.Source = p.parseExpr()
+		p.skip(true)
+		p.expect(":")
+		p.skip(true)
+		if p.keywordAhead("if") {
+			p.ident()
+			p.expect("(")
+			p.skip(true)
+			loop.Cond = p.parseExpr()
+			p.skip(true)
+			p.expect(")")
+			p.skip(true)
+		}
+		loop.Body = p.parseExpr()
+		p.skip(true)
+		p.expect("]")
+		return loop
+	}
+
+	array := &irArray{}
+	for {
+		p.skip(true)
+		if p.accept(",") {
+			continue
+		}
+		if p.accept("]") {
+			return array
+		}
+		array.Items = append(array.Items, p.parseExpr())
+	}
+}
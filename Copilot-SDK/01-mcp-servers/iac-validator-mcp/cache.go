@@ -0,0 +1,296 @@
+// =============================================================================
+// Validation Cache
+// =============================================================================
+// Running terraform validate or az bicep build is slow, and terraform init
+// downloads providers every time it runs in a fresh directory. Two caches
+// below the --cache-dir directory make repeated validation of unchanged code
+// return instantly:
+//
+//   - results/: tool results, content-addressed by a SHA-256 over the tool,
+//     the CLI version and the path and content of every input file
+//   - plugins/: a shared TF_PLUGIN_CACHE_DIR for the temp workspaces of
+//     check_iac_syntax and plan_terraform, so providers are downloaded once
+//
+// Results are only reused when every input file is byte-for-byte identical
+// and the same CLI version is installed, so a hit is always safe.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cacheFormatVersion is part of every key; bump it when cached results change
+// shape
+const cacheFormatVersion = "1"
+
+// defaultCacheDir returns the cache directory used when --cache-dir isn't set
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "iac-validator-mcp")
+}
+
+// pluginCacheEnv returns the environment variables that point terraform init
+// in a temp workspace at a shared provider cache below cacheDir, or nil when
+// the cache is disabled or TF_PLUGIN_CACHE_DIR is already set.
+//
+// They are only ever set on the init command of a temp workspace, never on
+// the process: TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE turns off the
+// lock-file checksum check, which must stay on in the user's directories.
+func pluginCacheEnv(cacheDir string) []string {
+	if cacheDir == "" || os.Getenv("TF_PLUGIN_CACHE_DIR") != "" {
+		return nil
+	}
+	pluginDir := filepath.Join(cacheDir, "plugins")
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		debugLog("Provider plugin cache disabled: %v", err)
+		return nil
+	}
+
+	// Without the second variable, Terraform skips the cache in directories
+	// that have no .terraform.lock.hcl, which is every temp workspace
+	return []string{
+		"TF_PLUGIN_CACHE_DIR=" + pluginDir,
+		"TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE=true",
+	}
+}
+
+// pluginCacheMu serializes terraform init, because Terraform doesn't support
+// concurrent writes to the plugin cache
+var pluginCacheMu sync.Mutex
+
+// runTerraformInit runs a terraform init command like runCommand, one at a
+// time
+func runTerraformInit(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	pluginCacheMu.Lock()
+	defer pluginCacheMu.Unlock()
+	return runCommand(ctx, cmd)
+}
+
+// =============================================================================
+// Result Cache
+// =============================================================================
+
+// resultCache stores tool results on disk, keyed by their inputs
+type resultCache struct {
+	dir string // empty disables the cache
+
+	mu       sync.Mutex
+	versions map[string]string // binary path and mtime → version
+}
+
+// newResultCache creates a result cache below cacheDir. An empty cacheDir
+// disables caching.
+func newResultCache(cacheDir string) *resultCache {
+	c := &resultCache{versions: make(map[string]string)}
+	if cacheDir != "" {
+		c.dir = filepath.Join(cacheDir, "results")
+	}
+	return c
+}
+
+// cached returns the stored result for key, or calls run and stores its
+// result if run reports it as cacheable. Results that depend on something
+// outside the key, such as a failed provider download, must not be stored,
+// and neither are results of a run that timed out or was cancelled. An empty
+// key bypasses the cache.
+func (c *resultCache) cached(ctx context.Context, key string, run func() (*ToolCallResult, bool, error)) (*ToolCallResult, error) {
+	if c.dir == "" || key == "" {
+		result, _, err := run()
+		return result, err
+	}
+
+	path := filepath.Join(c.dir, key[:2], key+".json")
+	if data, err := os.ReadFile(path); err == nil {
+		var result ToolCallResult
+		if err := json.Unmarshal(data, &result); err == nil && len(result.Content) > 0 {
+			debugLog("Cache hit: %s", key)
+			result.Content[0].Text += "\n⚡ Cached result: the files and tool version are unchanged since the last run.\n"
+			return &result, nil
+		}
+	}
+
+	result, cacheable, err := run()
+	if err != nil || result == nil || !cacheable || ctx.Err() != nil {
+		return result, err
+	}
+
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		// Write atomically so concurrent calls never read a partial entry
+		var tmp *os.File
+		if tmp, err = os.CreateTemp(filepath.Dir(path), "*.tmp"); err == nil {
+			_, err = tmp.Write(data)
+			if closeErr := tmp.Close(); err == nil {
+				err = closeErr
+			}
+			if err == nil {
+				err = os.Rename(tmp.Name(), path)
+			}
+			if err != nil {
+				os.Remove(tmp.Name())
+			}
+		}
+	}
+	if err != nil {
+		debugLog("Failed to store cache entry: %v", err)
+	}
+	return result, nil
+}
+
+// toolVersion returns the output of a version command such as `terraform
+// version -json`, remembered for as long as the binary doesn't change. It
+// returns "" if the version can't be determined.
+func (c *resultCache) toolVersion(ctx context.Context, name string, args ...string) string {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	id := fmt.Sprintf("%s|%d|%d", path, info.ModTime().UnixNano(), info.Size())
+
+	c.mu.Lock()
+	version, ok := c.versions[id]
+	c.mu.Unlock()
+	if ok {
+		return version
+	}
+
+	out, err := commandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	version = strings.TrimSpace(string(out))
+
+	c.mu.Lock()
+	c.versions[id] = version
+	c.mu.Unlock()
+	return version
+}
+
+// =============================================================================
+// Cache Keys
+// =============================================================================
+
+// cacheKey hashes the identifying parts of a call (tool, mode, CLI version,
+// ...) together with the given input files (display name → path). It returns
+// "" if the version is unknown or a file can't be read.
+func cacheKey(parts []string, files map[string]string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%s\x00", cacheFormatVersion)
+	for _, part := range parts {
+		if part == "" {
+			return ""
+		}
+		fmt.Fprintf(h, "%s\x00", part)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(files[name])
+		if err != nil {
+			return ""
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(name), hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// terraformInputs returns the files terraform validate reads in dir: the
+// configuration, the lock file and, recursively, local modules
+func terraformInputs(dir string) map[string]string {
+	files := make(map[string]string)
+	visited := make(map[string]bool)
+
+	var collect func(moduleDir string)
+	collect = func(moduleDir string) {
+		if visited[moduleDir] {
+			return
+		}
+		visited[moduleDir] = true
+
+		entries, err := os.ReadDir(moduleDir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.Type().IsRegular() && workspaceCopyExtensions[strings.ToLower(filepath.Ext(name))] {
+				path := filepath.Join(moduleDir, name)
+				rel, _ := filepath.Rel(dir, path)
+				files[rel] = path
+			}
+		}
+		for _, source := range terraformModuleSources(moduleDir) {
+			collect(filepath.Join(moduleDir, filepath.FromSlash(source)))
+		}
+	}
+	collect(dir)
+	return files
+}
+
+// bicepInputs returns the files az bicep build reads for file: the file,
+// bicepconfig.json next to it and, recursively, local modules
+func bicepInputs(file string) map[string]string {
+	base := filepath.Dir(file)
+	files := make(map[string]string)
+	if _, err := os.Stat(filepath.Join(base, "bicepconfig.json")); err == nil {
+		files["bicepconfig.json"] = filepath.Join(base, "bicepconfig.json")
+	}
+
+	var collect func(path string)
+	collect = func(path string) {
+		rel, _ := filepath.Rel(base, path)
+		if _, ok := files[rel]; ok {
+			return
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		files[rel] = path
+		for _, match := range bicepModulePattern.FindAllStringSubmatch(string(src), -1) {
+			collect(filepath.Join(filepath.Dir(path), filepath.FromSlash(match[1])))
+		}
+	}
+	collect(file)
+	return files
+}
+
+// workspaceInputs returns every file in a check_iac_syntax workspace
+func workspaceInputs(ws *syntaxWorkspace) map[string]string {
+	files := make(map[string]string)
+	filepath.Walk(ws.root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			rel, _ := filepath.Rel(ws.root, path)
+			files[rel] = path
+		}
+		return nil
+	})
+	return files
+}
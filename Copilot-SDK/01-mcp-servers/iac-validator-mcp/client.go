@@ -0,0 +1,148 @@
+// =============================================================================
+// Server-to-Client Requests
+// =============================================================================
+// MCP is bidirectional: besides answering the client, the server can send its
+// own requests (roots/list, sampling/createMessage, ...). Each outgoing request
+// gets a server-generated ID and the caller blocks until the matching response
+// arrives through the normal read loop, or until its context is done.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// ClientCapabilities is the subset of client capabilities the server uses
+type ClientCapabilities struct {
+	Roots *struct {
+		ListChanged bool `json:"listChanged,omitempty"`
+	} `json:"roots,omitempty"`
+	Sampling *struct{} `json:"sampling,omitempty"`
+}
+
+// InitializeParams contains the parameters of the initialize request
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+}
+
+// JSONRPCOutgoingRequest is a request sent from the server to the client
+type JSONRPCOutgoingRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      string      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// SamplingMessage is one message of a sampling/createMessage request
+type SamplingMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+// ModelPreferences tells the client what matters when it picks a model, each
+// priority from 0 to 1
+type ModelPreferences struct {
+	CostPriority         float64 `json:"costPriority,omitempty"`
+	SpeedPriority        float64 `json:"speedPriority,omitempty"`
+	IntelligencePriority float64 `json:"intelligencePriority,omitempty"`
+}
+
+// CreateMessageParams contains the parameters of sampling/createMessage
+type CreateMessageParams struct {
+	Messages         []SamplingMessage `json:"messages"`
+	ModelPreferences *ModelPreferences `json:"modelPreferences,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	IncludeContext   string            `json:"includeContext,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+}
+
+// CreateMessageResult is the client's answer to sampling/createMessage
+type CreateMessageResult struct {
+	Role       string       `json:"role"`
+	Content    ContentBlock `json:"content"`
+	Model      string       `json:"model"`
+	StopReason string       `json:"stopReason,omitempty"`
+}
+
+// clientRequests correlates outgoing requests with the client's responses
+type clientRequests struct {
+	nextID  atomic.Int64
+	pending map[string]chan *JSONRPCRequest
+}
+
+// callClient sends a request to the client and waits for its result
+func (s *MCPServer) callClient(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := fmt.Sprintf("srv-%d", s.outgoing.nextID.Add(1))
+	reply := make(chan *JSONRPCRequest, 1)
+
+	s.outgoingMu.Lock()
+	s.outgoing.pending[id] = reply
+	s.outgoingMu.Unlock()
+
+	defer func() {
+		s.outgoingMu.Lock()
+		delete(s.outgoing.pending, id)
+		s.outgoingMu.Unlock()
+	}()
+
+	s.send(JSONRPCOutgoingRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resp := <-reply:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s failed: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+		}
+		return resp.Result, nil
+	}
+}
+
+// handleClientResponse delivers a response from the client to the waiting
+// callClient. The pending entry is removed with the first response, so a
+// duplicate is ignored instead of blocking the read loop on a full channel.
+func (s *MCPServer) handleClientResponse(resp *JSONRPCRequest) {
+	id, _ := resp.ID.(string)
+
+	s.outgoingMu.Lock()
+	reply, ok := s.outgoing.pending[id]
+	delete(s.outgoing.pending, id)
+	s.outgoingMu.Unlock()
+
+	if !ok {
+		debugLog("Ignoring response to unknown request: %v", resp.ID)
+		return
+	}
+	reply <- resp
+}
+
+// createMessage asks the client's model for a completion. It fails when the
+// client did not declare the sampling capability.
+func (s *MCPServer) createMessage(ctx context.Context, params CreateMessageParams) (*CreateMessageResult, error) {
+	if s.clientCaps.Sampling == nil {
+		return nil, fmt.Errorf("the client does not support sampling/createMessage")
+	}
+
+	raw, err := s.callClient(ctx, "sampling/createMessage", params)
+	if err != nil {
+		return nil, err
+	}
+	var result CreateMessageResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid sampling/createMessage result: %w", err)
+	}
+	if result.Content.Type != "text" {
+		return nil, fmt.Errorf("sampling/createMessage returned %s content instead of text", result.Content.Type)
+	}
+	return &result, nil
+}
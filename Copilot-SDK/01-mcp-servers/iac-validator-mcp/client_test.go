@@ -0,0 +1,55 @@
+// =============================================================================
+// Client Request Tests
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleClientResponseIgnoresDuplicates(t *testing.T) {
+	server := NewMCPServer(strings.NewReader(""), io.Discard, Config{})
+	defer server.Close()
+
+	result := make(chan json.RawMessage, 1)
+	go func() {
+		raw, err := server.callClient(context.Background(), "roots/list", nil)
+		if err != nil {
+			t.Errorf("callClient: %v", err)
+		}
+		result <- raw
+	}()
+
+	// Wait for the request to be registered, then answer it three times
+	for {
+		server.outgoingMu.Lock()
+		_, pending := server.outgoing.pending["srv-1"]
+		server.outgoingMu.Unlock()
+		if pending {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	delivered := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			server.handleClientResponse(&JSONRPCRequest{ID: "srv-1", Result: json.RawMessage(`{"roots":[]}`)})
+		}
+		close(delivered)
+	}()
+
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handleClientResponse blocked on a duplicate response")
+	}
+	if raw := <-result; string(raw) != `{"roots":[]}` {
+		t.Errorf("result = %s", raw)
+	}
+}
@@ -0,0 +1,932 @@
+// =============================================================================
+// Bicep ↔ Terraform Conversion Tool
+// =============================================================================
+// convert_iac translates Bicep to Terraform (azurerm) and back. Both sides are
+// parsed into a small expression IR; declarations are converted one by one:
+//
+//   - param ↔ variable, var ↔ locals, output ↔ output
+//   - resource ↔ resource for the types in convertmap.go, with property
+//     mapping, loops (count / for_each), conditions, parents and dependsOn
+//   - module ↔ module calls (the module itself must be converted separately)
+//
+// Anything without a counterpart is kept in the output as a "TODO:" comment
+// that quotes the original code, so nothing is dropped silently. The lab
+// exercises keep matching bicep/ and terraform/ solutions, which make good
+// reference translations.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Conversion statuses of a resource
+const (
+	convertedStatus = "converted"
+	partialStatus   = "partial"
+	unmappedStatus  = "unmapped"
+)
+
+// ConvertedResource reports how one resource or module was translated
+type ConvertedResource struct {
+	Source     string `json:"source"`
+	SourceType string `json:"sourceType"`
+	Target     string `json:"target,omitempty"`
+	TargetType string `json:"targetType,omitempty"`
+	Status     string `json:"status"`
+}
+
+// ConversionTODO is a TODO marker in the converted code
+type ConversionTODO struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ConversionResult is the structured result of convert_iac
+type ConversionResult struct {
+	From      string              `json:"from"`
+	To        string              `json:"to"`
+	Path      string              `json:"path,omitempty"`
+	Code      string              `json:"code"`
+	Resources []ConvertedResource `json:"resources"`
+	TODOs     []ConversionTODO    `json:"todos"`
+}
+
+// conversionOutputSchema is the JSON Schema of ConversionResult
+var conversionOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"from": map[string]interface{}{"type": "string", "enum": []string{"bicep", "terraform"}},
+		"to":   map[string]interface{}{"type": "string", "enum": []string{"bicep", "terraform"}},
+		"path": map[string]interface{}{"type": "string"},
+		"code": map[string]interface{}{"type": "string"},
+		"resources": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"source":     map[string]interface{}{"type": "string"},
+					"sourceType": map[string]interface{}{"type": "string"},
+					"target":     map[string]interface{}{"type": "string"},
+					"targetType": map[string]interface{}{"type": "string"},
+					"status":     map[string]interface{}{"type": "string", "enum": []string{convertedStatus, partialStatus, unmappedStatus}},
+				},
+				"required": []string{"source", "sourceType", "status"},
+			},
+		},
+		"todos": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"line":    map[string]interface{}{"type": "integer"},
+					"message": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"line", "message"},
+			},
+		},
+	},
+	"required": []string{"from", "to", "code", "resources", "todos"},
+}
+
+// handleConvertIaC converts a Bicep file to Terraform or a Terraform
+// configuration to Bicep
+func (s *MCPServer) handleConvertIaC(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	from, _ := args["from"].(string)
+	from = strings.ToLower(from)
+	if from != "" && from != "bicep" && from != "terraform" {
+		return nil, fmt.Errorf("unsupported from: %s (use 'bicep' or 'terraform')", from)
+	}
+
+	// Collect the sources: inline code, a file, or a Terraform directory
+	sources := make(map[string][]byte)
+	displayPath := ""
+	if code, ok := args["code"].(string); ok {
+		if from == "" {
+			return nil, fmt.Errorf("from parameter is required with code (bicep or terraform)")
+		}
+		name := "main.tf"
+		if from == "bicep" {
+			name = "main.bicep"
+		}
+		sources[name] = []byte(code)
+	} else {
+		path, ok := args["path"].(string)
+		if !ok {
+			return nil, fmt.Errorf("path or code parameter is required")
+		}
+		absPath, err := s.resolvePath(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("path not found: %s", absPath)
+		}
+		displayPath = s.displayPath(absPath)
+
+		files := []string{filepath.Base(absPath)}
+		dir := filepath.Dir(absPath)
+		if info.IsDir() {
+			dir = absPath
+			if files, err = convertibleFiles(dir, from); err != nil {
+				return nil, err
+			}
+		}
+		if from == "" {
+			from = formatTypeFor(files[0])
+		}
+		for _, file := range files {
+			if formatTypeFor(file) != from || strings.HasSuffix(file, ".tfvars") || strings.HasSuffix(file, ".bicepparam") {
+				return nil, fmt.Errorf("cannot convert %s: expected a .bicep file, a .tf file or a Terraform directory", file)
+			}
+			content, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			sources[file] = content
+		}
+	}
+
+	var conversion *conversionOutput
+	var err error
+	if from == "bicep" {
+		conversion, err = convertBicepToTerraform(sources)
+	} else {
+		conversion, err = convertTerraformToBicep(sources)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	to := "terraform"
+	if from == "terraform" {
+		to = "bicep"
+	}
+	result := &ConversionResult{
+		From:      from,
+		To:        to,
+		Path:      displayPath,
+		Code:      conversion.code,
+		Resources: conversion.resources,
+		TODOs:     conversionTODOs(conversion.code),
+	}
+	if result.Resources == nil {
+		result.Resources = []ConvertedResource{}
+	}
+
+	var output strings.Builder
+	title := map[string]string{"bicep": "Bicep", "terraform": "Terraform"}
+	output.WriteString(fmt.Sprintf("🔄 Converted %s → %s", title[from], title[to]))
+	if displayPath != "" {
+		output.WriteString(": " + displayPath)
+	}
+	output.WriteString("\n\n")
+
+	counts := make(map[string]int)
+	for _, resource := range result.Resources {
+		counts[resource.Status]++
+	}
+	output.WriteString(fmt.Sprintf("📊 %d resource(s): %d converted, %d partially converted, %d not mapped\n\n",
+		len(result.Resources), counts[convertedStatus], counts[partialStatus], counts[unmappedStatus]))
+	if len(result.Resources) > 0 {
+		icons := map[string]string{convertedStatus: "✅", partialStatus: "⚠️", unmappedStatus: "❌"}
+		output.WriteString("| Source | Target | Status |\n|---|---|---|\n")
+		for _, resource := range result.Resources {
+			target := "-"
+			if resource.Target != "" {
+				target = fmt.Sprintf("`%s` (%s)", resource.Target, resource.TargetType)
+			}
+			output.WriteString(fmt.Sprintf("| `%s` (%s) | %s | %s %s |\n",
+				resource.Source, resource.SourceType, target, icons[resource.Status], resource.Status))
+		}
+		output.WriteString("\n")
+	}
+
+	if len(result.TODOs) > 0 {
+		output.WriteString(fmt.Sprintf("⚠️ **%d TODO marker(s)** need manual review:\n", len(result.TODOs)))
+		for _, todo := range result.TODOs {
+			output.WriteString(fmt.Sprintf("   - Line %d: %s\n", todo.Line, todo.Message))
+		}
+		output.WriteString("\n")
+	} else {
+		output.WriteString("✅ **Everything was mapped.** Validate and plan the result before deploying.\n\n")
+	}
+	for _, problem := range conversion.problems {
+		output.WriteString(fmt.Sprintf("❌ %s\n", problem))
+	}
+	if len(conversion.problems) > 0 {
+		output.WriteString("\n")
+	}
+
+	lang := "hcl"
+	if to == "bicep" {
+		lang = "bicep"
+	}
+	output.WriteString(fmt.Sprintf("```%s\n%s```\n", lang, result.Code))
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: result,
+	}, nil
+}
+
+// convertibleFiles lists the files to convert in a directory: its .tf files,
+// or its only .bicep file
+func convertibleFiles(dir, from string) ([]string, error) {
+	if from != "bicep" {
+		tfFiles, err := walkIaCFiles(dir, false, map[string]bool{".tf": true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+		if len(tfFiles) > 0 {
+			return tfFiles, nil
+		}
+	}
+	if from != "terraform" {
+		bicepFiles, err := walkIaCFiles(dir, false, map[string]bool{".bicep": true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+		if len(bicepFiles) == 1 {
+			return bicepFiles, nil
+		}
+		if len(bicepFiles) > 1 {
+			return nil, fmt.Errorf("%s contains %d Bicep files: pass the path of the one to convert", dir, len(bicepFiles))
+		}
+	}
+	return nil, fmt.Errorf("no files to convert found in %s", dir)
+}
+
+// todoPattern finds TODO markers in converted code
+var todoPattern = regexp.MustCompile(`(?:#|//)\s*TODO:\s*(.*)`)
+
+// conversionTODOs lists the TODO markers in converted code
+func conversionTODOs(code string) []ConversionTODO {
+	todos := []ConversionTODO{}
+	for i, line := range strings.Split(code, "\n") {
+		if match := todoPattern.FindStringSubmatch(line); match != nil {
+			todos = append(todos, ConversionTODO{Line: i + 1, Message: strings.TrimSpace(match[1])})
+		}
+	}
+	return todos
+}
+
+// conversionOutput is what either direction of the converter produces
+type conversionOutput struct {
+	code      string
+	resources []ConvertedResource
+	problems  []string // the converted code does not parse
+}
+
+// =============================================================================
+// Expression IR
+// =============================================================================
+// Expressions from either language are parsed into these nodes. Function
+// names and references keep their source-language spelling; each direction
+// of the converter translates them while rendering.
+
+// irNode is any IR expression node
+type irNode interface{}
+
+type (
+	irString struct{ Value string }
+	irNumber struct{ Text string }
+	irBool   struct{ Value bool }
+	irNull   struct{}
+	// irTemplate is an interpolated string; literal parts are *irString
+	irTemplate struct{ Parts []irNode }
+	// irRef is a bare identifier: a Bicep symbol, or the root of a
+	// Terraform traversal such as var, local or azurerm_storage_account
+	irRef struct{ Name string }
+	irGet struct {
+		Target irNode
+		Name   string
+	}
+	irIndex struct{ Target, Key irNode }
+	irCall  struct {
+		Name   string
+		Args   []irNode
+		Method bool // called on a value, e.g. storage.listKeys()
+	}
+	irArray  struct{ Items []irNode }
+	irObject struct{ Items []*irItem }
+	irItem   struct {
+		Key   string
+		Value irNode
+		Src   string // source text of the value, quoted in TODOs
+		Line  int
+	}
+	irCond   struct{ Cond, Then, Else irNode }
+	irBinary struct {
+		Op          string
+		Left, Right irNode
+	}
+	irUnary struct {
+		Op string
+		X  irNode
+	}
+	irParen struct{ X irNode }
+	// irFor is a for-expression. KeyExpr is set for Terraform object
+	// for-expressions; Key is the index (Bicep) or key (Terraform) variable.
+	irFor struct {
+		Key, Value                  string
+		Source, Body, KeyExpr, Cond irNode
+	}
+	irLambda struct {
+		Params []string
+		Body   irNode
+	}
+	// irSplat is a Terraform splat such as subnets[*].id
+	irSplat struct {
+		Source irNode
+		Path   []string
+	}
+	// irRaw is source code that cannot be represented
+	irRaw struct{ Src string }
+	// irTarget is code already in the target language
+	irTarget struct{ Text string }
+)
+
+// get returns the value of key in an object, or nil
+func (o *irObject) get(key string) *irItem {
+	if o == nil {
+		return nil
+	}
+	for _, item := range o.Items {
+		if item.Key == key {
+			return item
+		}
+	}
+	return nil
+}
+
+// lookup follows a dotted path of object keys
+func (o *irObject) lookup(path string) *irItem {
+	keys := strings.Split(path, ".")
+	current := o
+	for i, key := range keys {
+		item := current.get(key)
+		if item == nil {
+			return nil
+		}
+		if i == len(keys)-1 {
+			return item
+		}
+		next, ok := item.Value.(*irObject)
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+	return nil
+}
+
+// literalString returns the value of a plain string node
+func literalString(node irNode) (string, bool) {
+	s, ok := node.(*irString)
+	if !ok {
+		return "", false
+	}
+	return s.Value, true
+}
+
+// chain flattens a reference such as a.b[0].c into its root and accessors
+func chain(node irNode) (irNode, []irNode) {
+	var accessors []irNode
+	for {
+		switch n := node.(type) {
+		case *irGet:
+			accessors = append([]irNode{n}, accessors...)
+			node = n.Target
+		case *irIndex:
+			accessors = append([]irNode{n}, accessors...)
+			node = n.Target
+		default:
+			return node, accessors
+		}
+	}
+}
+
+// irFromHCL converts a Terraform expression to the IR
+func irFromHCL(expr hclsyntax.Expression, src []byte) irNode {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		return irFromCty(e.Val, e, src)
+	case *hclsyntax.TemplateExpr:
+		var parts []irNode
+		for _, part := range e.Parts {
+			parts = append(parts, irFromHCL(part, src))
+		}
+		if len(parts) == 0 {
+			return &irString{}
+		}
+		if s, ok := parts[0].(*irString); ok && len(parts) == 1 {
+			return s
+		}
+		return &irTemplate{Parts: parts}
+	case *hclsyntax.TemplateWrapExpr:
+		return irFromHCL(e.Wrapped, src)
+	case *hclsyntax.ScopeTraversalExpr:
+		return irFromTraversal(&irRef{Name: e.Traversal.RootName()}, e.Traversal[1:], e, src)
+	case *hclsyntax.RelativeTraversalExpr:
+		return irFromTraversal(irFromHCL(e.Source, src), e.Traversal, e, src)
+	case *hclsyntax.IndexExpr:
+		return &irIndex{Target: irFromHCL(e.Collection, src), Key: irFromHCL(e.Key, src)}
+	case *hclsyntax.SplatExpr:
+		splat := &irSplat{Source: irFromHCL(e.Source, src)}
+		switch each := e.Each.(type) {
+		case *hclsyntax.AnonSymbolExpr:
+			return splat.Source
+		case *hclsyntax.RelativeTraversalExpr:
+			if _, ok := each.Source.(*hclsyntax.AnonSymbolExpr); ok {
+				for _, step := range each.Traversal {
+					attr, ok := step.(hcl.TraverseAttr)
+					if !ok {
+						return rawHCL(e, src)
+					}
+					splat.Path = append(splat.Path, attr.Name)
+				}
+				return splat
+			}
+		}
+		return rawHCL(e, src)
+	case *hclsyntax.FunctionCallExpr:
+		if e.ExpandFinal {
+			return rawHCL(e, src)
+		}
+		call := &irCall{Name: e.Name}
+		for _, arg := range e.Args {
+			call.Args = append(call.Args, irFromHCL(arg, src))
+		}
+		return call
+	case *hclsyntax.ConditionalExpr:
+		return &irCond{Cond: irFromHCL(e.Condition, src), Then: irFromHCL(e.TrueResult, src), Else: irFromHCL(e.FalseResult, src)}
+	case *hclsyntax.BinaryOpExpr:
+		op, ok := hclOperators[e.Op]
+		if !ok {
+			return rawHCL(e, src)
+		}
+		return &irBinary{Op: op, Left: irFromHCL(e.LHS, src), Right: irFromHCL(e.RHS, src)}
+	case *hclsyntax.UnaryOpExpr:
+		op, ok := hclOperators[e.Op]
+		if !ok {
+			return rawHCL(e, src)
+		}
+		return &irUnary{Op: op, X: irFromHCL(e.Val, src)}
+	case *hclsyntax.ParenthesesExpr:
+		return &irParen{X: irFromHCL(e.Expression, src)}
+	case *hclsyntax.TupleConsExpr:
+		array := &irArray{}
+		for _, item := range e.Exprs {
+			array.Items = append(array.Items, irFromHCL(item, src))
+		}
+		return array
+	case *hclsyntax.ObjectConsExpr:
+		object := &irObject{}
+		for _, item := range e.Items {
+			key := hcl.ExprAsKeyword(item.KeyExpr)
+			if key == "" {
+				s, ok := irFromHCL(item.KeyExpr.(hclsyntax.Expression), src).(*irString)
+				if !ok {
+					return rawHCL(e, src)
+				}
+				key = s.Value
+			}
+			object.Items = append(object.Items, &irItem{
+				Key:   key,
+				Value: irFromHCL(item.ValueExpr, src),
+				Src:   hclSource(item.ValueExpr, src),
+				Line:  item.ValueExpr.Range().Start.Line,
+			})
+		}
+		return object
+	case *hclsyntax.ObjectConsKeyExpr:
+		return irFromHCL(e.Wrapped, src)
+	case *hclsyntax.ForExpr:
+		if e.Group {
+			return rawHCL(e, src)
+		}
+		loop := &irFor{Key: e.KeyVar, Value: e.ValVar, Source: irFromHCL(e.CollExpr, src), Body: irFromHCL(e.ValExpr, src)}
+		if e.KeyExpr != nil {
+			loop.KeyExpr = irFromHCL(e.KeyExpr, src)
+		}
+		if e.CondExpr != nil {
+			loop.Cond = irFromHCL(e.CondExpr, src)
+		}
+		return loop
+	}
+	return rawHCL(expr, src)
+}
+
+// hclOperators maps HCL operations to their shared spelling
+var hclOperators = map[*hclsyntax.Operation]string{
+	hclsyntax.OpLogicalOr:          "||",
+	hclsyntax.OpLogicalAnd:         "&&",
+	hclsyntax.OpLogicalNot:         "!",
+	hclsyntax.OpEqual:              "==",
+	hclsyntax.OpNotEqual:           "!=",
+	hclsyntax.OpGreaterThan:        ">",
+	hclsyntax.OpGreaterThanOrEqual: ">=",
+	hclsyntax.OpLessThan:           "<",
+	hclsyntax.OpLessThanOrEqual:    "<=",
+	hclsyntax.OpAdd:                "+",
+	hclsyntax.OpSubtract:           "-",
+	hclsyntax.OpMultiply:           "*",
+	hclsyntax.OpDivide:             "/",
+	hclsyntax.OpModulo:             "%",
+	hclsyntax.OpNegate:             "-",
+}
+
+// irFromTraversal appends traversal steps to a node
+func irFromTraversal(node irNode, traversal hcl.Traversal, expr hclsyntax.Expression, src []byte) irNode {
+	for _, step := range traversal {
+		switch t := step.(type) {
+		case hcl.TraverseAttr:
+			node = &irGet{Target: node, Name: t.Name}
+		case hcl.TraverseIndex:
+			node = &irIndex{Target: node, Key: irFromCty(t.Key, expr, src)}
+		default:
+			return rawHCL(expr, src)
+		}
+	}
+	return node
+}
+
+// irFromCty converts a literal value
+func irFromCty(value cty.Value, expr hclsyntax.Expression, src []byte) irNode {
+	switch {
+	case value.IsNull():
+		return &irNull{}
+	case value.Type() == cty.String:
+		return &irString{Value: value.AsString()}
+	case value.Type() == cty.Number:
+		return &irNumber{Text: value.AsBigFloat().Text('f', -1)}
+	case value.Type() == cty.Bool:
+		return &irBool{Value: value.True()}
+	}
+	return rawHCL(expr, src)
+}
+
+// rawHCL keeps an expression as source text
+func rawHCL(expr hclsyntax.Expression, src []byte) irNode {
+	return &irRaw{Src: hclSource(expr, src)}
+}
+
+// hclSource returns the source text of an expression
+func hclSource(expr hcl.Expression, src []byte) string {
+	r := expr.Range()
+	if r.End.Byte > len(src) || r.Start.Byte > r.End.Byte {
+		return ""
+	}
+	return strings.TrimSpace(string(src[r.Start.Byte:r.End.Byte]))
+}
+
+// =============================================================================
+// Naming
+// =============================================================================
+
+// snakeCase converts a Bicep name such as storageAccountName or vnetID to
+// storage_account_name and vnet_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ' || r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// camelCase converts a Terraform name such as storage_account_name to
+// storageAccountName
+func camelCase(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' })
+	for i, part := range parts {
+		if i == 0 {
+			parts[i] = strings.ToLower(part[:1]) + part[1:]
+		} else {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// identifierPattern matches keys that need no quoting in either language
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// hclQuote renders a Terraform string literal
+func hclQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{")
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// bicepQuote renders a Bicep string literal
+func bicepQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", `\${`)
+	return "'" + replacer.Replace(s) + "'"
+}
+
+// todoLines renders a TODO comment, quoting source code below it
+func todoLines(marker, message, quoted string) []string {
+	lines := []string{fmt.Sprintf("%s TODO: %s", marker, message)}
+	if quoted != "" {
+		for _, line := range strings.Split(strings.TrimRight(quoted, "\n"), "\n") {
+			lines = append(lines, strings.TrimRight(marker+"   "+line, " "))
+		}
+	}
+	return lines
+}
+
+// indentLines indents every line of text but the first
+func indentLines(text, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
+}
+
+// =============================================================================
+// Output Builders
+// =============================================================================
+
+// tfBody is a Terraform block body under construction
+type tfBody struct {
+	items []*tfItem
+}
+
+// tfItem is an attribute, a nested block or a standalone comment
+type tfItem struct {
+	name     string
+	labels   []string
+	expr     string
+	block    *tfBody
+	comments []string
+}
+
+// attr sets an attribute; the first value set for a name wins
+func (b *tfBody) attr(name, expr string, comments []string) {
+	for _, item := range b.items {
+		if item.name == name && item.block == nil {
+			return
+		}
+	}
+	b.items = append(b.items, &tfItem{name: name, expr: expr, comments: comments})
+}
+
+// child returns the last nested block of that type, creating it if needed
+func (b *tfBody) child(name string) *tfBody {
+	for i := len(b.items) - 1; i >= 0; i-- {
+		if b.items[i].name == name && b.items[i].block != nil {
+			return b.items[i].block
+		}
+	}
+	return b.newBlock(name)
+}
+
+// newBlock appends a nested block
+func (b *tfBody) newBlock(name string, labels ...string) *tfBody {
+	block := &tfBody{}
+	b.items = append(b.items, &tfItem{name: name, labels: labels, block: block})
+	return block
+}
+
+// set sets a dotted path; leading segments are nested blocks
+func (b *tfBody) set(path, expr string, comments []string) {
+	keys := strings.Split(path, ".")
+	body := b
+	for _, key := range keys[:len(keys)-1] {
+		body = body.child(key)
+	}
+	body.attr(keys[len(keys)-1], expr, comments)
+}
+
+// note adds standalone comment lines
+func (b *tfBody) note(lines []string) {
+	if len(lines) > 0 {
+		b.items = append(b.items, &tfItem{comments: lines})
+	}
+}
+
+// tfGroups orders the contents of a block: meta-arguments, attributes,
+// nested blocks, then tags and dependencies
+func tfGroup(item *tfItem) int {
+	switch {
+	case item.name == "count" || item.name == "for_each" || item.name == "provider" || item.name == "source":
+		return 0
+	case item.name == "tags" || item.name == "depends_on" || item.name == "lifecycle":
+		return 3
+	case item.block != nil:
+		return 2
+	}
+	return 1
+}
+
+// write renders the body's contents
+func (b *tfBody) write(out *strings.Builder, indent string) {
+	items := make([]*tfItem, len(b.items))
+	copy(items, b.items)
+	sort.SliceStable(items, func(i, j int) bool { return tfGroup(items[i]) < tfGroup(items[j]) })
+
+	for i, item := range items {
+		if i > 0 && (tfGroup(item) != tfGroup(items[i-1]) || item.block != nil || items[i-1].block != nil) {
+			out.WriteString("\n")
+		}
+		for _, comment := range item.comments {
+			out.WriteString(indent + comment + "\n")
+		}
+		switch {
+		case item.block != nil:
+			out.WriteString(indent + item.name)
+			for _, label := range item.labels {
+				out.WriteString(" " + hclQuote(label))
+			}
+			out.WriteString(" {\n")
+			item.block.write(out, indent+"  ")
+			out.WriteString(indent + "}\n")
+		case item.name != "":
+			out.WriteString(fmt.Sprintf("%s%s = %s\n", indent, item.name, indentLines(item.expr, indent)))
+		}
+	}
+}
+
+// formatHCL aligns and indents generated Terraform code
+func formatHCL(code string) string {
+	return string(hclwrite.Format([]byte(code)))
+}
+
+// parseHCLProblems reports whether generated Terraform code parses
+func parseHCLProblems(code string) []string {
+	_, diags := hclparse.NewParser().ParseHCL([]byte(code), "main.tf")
+	var problems []string
+	for _, diag := range diags {
+		if diag.Severity == hcl.DiagError {
+			line := 0
+			if diag.Subject != nil {
+				line = diag.Subject.Start.Line
+			}
+			problems = append(problems, fmt.Sprintf("The converted code does not parse (line %d): %s", line, diag.Summary))
+		}
+	}
+	return problems
+}
+
+// bicepObject is a Bicep object literal under construction
+type bicepObject struct {
+	items []*bicepItem
+}
+
+// bicepItem is a property, a nested object, an array of objects or a
+// standalone comment
+type bicepItem struct {
+	key      string
+	value    string
+	object   *bicepObject
+	array    []*bicepObject
+	isArray  bool
+	comments []string
+}
+
+// find returns the property with that key
+func (o *bicepObject) find(key string) *bicepItem {
+	for _, item := range o.items {
+		if item.key == key {
+			return item
+		}
+	}
+	return nil
+}
+
+// lookup follows a dotted path of nested objects
+func (o *bicepObject) lookup(path string) *bicepItem {
+	keys := strings.Split(path, ".")
+	object := o
+	for _, key := range keys[:len(keys)-1] {
+		item := object.find(key)
+		if item == nil || item.object == nil {
+			return nil
+		}
+		object = item.object
+	}
+	return object.find(keys[len(keys)-1])
+}
+
+// set sets a dotted path; leading segments are nested objects. The first
+// value set for a path wins.
+func (o *bicepObject) set(path, value string, comments []string) {
+	keys := strings.Split(path, ".")
+	object := o
+	for _, key := range keys[:len(keys)-1] {
+		object = object.child(key)
+	}
+	if object.find(keys[len(keys)-1]) == nil {
+		object.items = append(object.items, &bicepItem{key: keys[len(keys)-1], value: value, comments: comments})
+	}
+}
+
+// child returns the nested object at key, creating it if needed
+func (o *bicepObject) child(key string) *bicepObject {
+	if item := o.find(key); item != nil && item.object != nil {
+		return item.object
+	}
+	item := &bicepItem{key: key, object: &bicepObject{}}
+	o.items = append(o.items, item)
+	return item.object
+}
+
+// appendElement appends an object to the array of objects at a dotted path
+func (o *bicepObject) appendElement(path string) *bicepObject {
+	keys := strings.Split(path, ".")
+	object := o
+	for _, key := range keys[:len(keys)-1] {
+		object = object.child(key)
+	}
+	item := object.find(keys[len(keys)-1])
+	if item == nil || !item.isArray {
+		item = &bicepItem{key: keys[len(keys)-1], isArray: true}
+		object.items = append(object.items, item)
+	}
+	element := &bicepObject{}
+	item.array = append(item.array, element)
+	return element
+}
+
+// note adds standalone comment lines
+func (o *bicepObject) note(lines []string) {
+	if len(lines) > 0 {
+		o.items = append(o.items, &bicepItem{comments: lines})
+	}
+}
+
+// bicepKeyOrder is the conventional order of top-level resource properties
+var bicepKeyOrder = []string{"parent", "scope", "name", "location", "kind", "sku", "identity", "", "properties", "tags", "dependsOn"}
+
+// sortTopLevel orders resource properties conventionally; unknown keys go
+// before properties
+func (o *bicepObject) sortTopLevel() {
+	rank := func(key string) int {
+		for i, known := range bicepKeyOrder {
+			if known == key {
+				return i
+			}
+		}
+		return 7
+	}
+	sort.SliceStable(o.items, func(i, j int) bool { return rank(o.items[i].key) < rank(o.items[j].key) })
+}
+
+// write renders the object literal, starting at the current column
+func (o *bicepObject) write(out *strings.Builder, indent string) {
+	out.WriteString("{\n")
+	inner := indent + "  "
+	for _, item := range o.items {
+		for _, comment := range item.comments {
+			out.WriteString(inner + comment + "\n")
+		}
+		if item.key == "" {
+			continue
+		}
+		key := item.key
+		if !identifierPattern.MatchString(key) {
+			key = bicepQuote(key)
+		}
+		out.WriteString(inner + key + ": ")
+		switch {
+		case item.object != nil:
+			item.object.write(out, inner)
+		case item.isArray:
+			out.WriteString("[\n")
+			for _, element := range item.array {
+				out.WriteString(inner + "  ")
+				element.write(out, inner+"  ")
+				out.WriteString("\n")
+			}
+			out.WriteString(inner + "]")
+		default:
+			out.WriteString(indentLines(item.value, inner))
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(indent + "}")
+}
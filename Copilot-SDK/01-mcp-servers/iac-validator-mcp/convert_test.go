@@ -0,0 +1,276 @@
+// =============================================================================
+// Bicep ↔ Terraform Conversion Tests
+// =============================================================================
+// Golden tests over the lab solutions, which are matching Bicep/Terraform
+// pairs. Each solution is converted in both directions and back again; the
+// results are compared with testdata/convert/<lab>/*.golden. After an
+// intended converter change, regenerate them with:
+//
+//   go test -run TestConvert -update
+// =============================================================================
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// labRoot is the repository root, which holds the Level-* lab folders
+const labRoot = "../../.."
+
+// conversionLabs are the lab solutions with a Bicep and a Terraform version
+var conversionLabs = []struct {
+	name      string
+	bicep     string // directory of main.bicep
+	terraform string // directory of the *.tf files
+
+	// notInBicep lists Bicep types the converted Terraform solution declares
+	// although the Bicep solution doesn't, because the two differ
+	notInBicep []string
+}{
+	{name: "hello-azure", bicep: "Level-1-Fundamentals/bicep/01-hello-azure/solution", terraform: "Level-1-Fundamentals/terraform/01-hello-azure/solution"},
+	{name: "storage-account", bicep: "Level-1-Fundamentals/bicep/02-storage-account/solution", terraform: "Level-1-Fundamentals/terraform/02-storage-account/solution"},
+	{
+		name: "outputs", bicep: "Level-1-Fundamentals/bicep/03-outputs-variables/solution", terraform: "Level-1-Fundamentals/terraform/03-outputs-locals/solution",
+		// Only the Terraform solution stores a Key Vault secret
+		notInBicep: []string{"Microsoft.KeyVault/vaults/secrets"},
+	},
+	{
+		name: "networking", bicep: "Level-2-Intermediate/bicep/01-networking/solution", terraform: "Level-2-Intermediate/terraform/01-networking/solution",
+		// The Bicep solution declares its subnets inline
+		notInBicep: []string{"Microsoft.Network/virtualNetworks/subnets"},
+	},
+	{
+		name: "compute", bicep: "Level-2-Intermediate/bicep/02-compute/solution", terraform: "Level-2-Intermediate/terraform/02-compute/solution",
+		notInBicep: []string{"Microsoft.Network/virtualNetworks/subnets"},
+	},
+	{name: "app-service", bicep: "Level-2-Intermediate/bicep/03-app-service/solution", terraform: "Level-2-Intermediate/terraform/03-app-service/solution"},
+	{name: "aks-cluster", bicep: "Level-3-Advanced/bicep/03-aks-cluster/solution", terraform: "Level-3-Advanced/terraform/03-aks-cluster/solution"},
+}
+
+// Resource type declarations in converted code
+var (
+	bicepTypePattern     = regexp.MustCompile(`(?m)^\s*resource\s+\w+\s+'([^'@]+)@`)
+	terraformTypePattern = regexp.MustCompile(`(?m)^resource\s+"(\w+)"`)
+)
+
+func TestConvertBicepToTerraform(t *testing.T) {
+	for _, lab := range conversionLabs {
+		t.Run(lab.name, func(t *testing.T) {
+			source := readLabBicep(t, lab.bicep)
+			reference := resourceTypes(terraformTypePattern, joinSources(readLabTerraform(t, lab.terraform)))
+
+			out := convertOrFail(t, "bicep", source)
+			checkGolden(t, lab.name, "bicep2tf.tf.golden", out.code)
+
+			// Everything the converter emits is what the lab's own Terraform
+			// solution uses for the same infrastructure
+			for _, typ := range resourceTypes(terraformTypePattern, out.code) {
+				if !contains(reference, typ) {
+					t.Errorf("converted code declares %s, which the Terraform solution doesn't use (%v)", typ, reference)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertTerraformToBicep(t *testing.T) {
+	for _, lab := range conversionLabs {
+		t.Run(lab.name, func(t *testing.T) {
+			source := readLabTerraform(t, lab.terraform)
+			reference := resourceTypes(bicepTypePattern, joinSources(readLabBicep(t, lab.bicep)))
+
+			out := convertOrFail(t, "terraform", source)
+			checkGolden(t, lab.name, "tf2bicep.bicep.golden", out.code)
+
+			for _, typ := range resourceTypes(bicepTypePattern, out.code) {
+				if !contains(reference, typ) && !contains(lab.notInBicep, typ) {
+					t.Errorf("converted code declares %s, which the Bicep solution doesn't use (%v)", typ, reference)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, lab := range conversionLabs {
+		t.Run(lab.name+"/bicep", func(t *testing.T) {
+			source := readLabBicep(t, lab.bicep)
+			there := convertOrFail(t, "bicep", source)
+			back := convertOrFail(t, "terraform", map[string][]byte{"main.tf": []byte(there.code)})
+			checkGolden(t, lab.name, "roundtrip.bicep.golden", back.code)
+
+			want := mappedTypes(resourceTypes(bicepTypePattern, joinSources(source)), there.resources)
+			if got := resourceTypes(bicepTypePattern, back.code); !equalStrings(got, want) {
+				t.Errorf("round trip resource types = %v, want %v", got, want)
+			}
+		})
+
+		t.Run(lab.name+"/terraform", func(t *testing.T) {
+			source := readLabTerraform(t, lab.terraform)
+			there := convertOrFail(t, "terraform", source)
+			back := convertOrFail(t, "bicep", map[string][]byte{"main.bicep": []byte(there.code)})
+			checkGolden(t, lab.name, "roundtrip.tf.golden", back.code)
+
+			want := mappedTypes(resourceTypes(terraformTypePattern, joinSources(source)), there.resources)
+			if got := resourceTypes(terraformTypePattern, back.code); !equalStrings(got, want) {
+				t.Errorf("round trip resource types = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// convertOrFail converts sources and fails unless the result parses
+func convertOrFail(t *testing.T, from string, sources map[string][]byte) *conversionOutput {
+	t.Helper()
+	var out *conversionOutput
+	var err error
+	if from == "bicep" {
+		out, err = convertBicepToTerraform(sources)
+	} else {
+		out, err = convertTerraformToBicep(sources)
+	}
+	if err != nil {
+		t.Fatalf("convert from %s: %v", from, err)
+	}
+	for _, problem := range out.problems {
+		t.Errorf("converted code does not parse: %s", problem)
+	}
+	return out
+}
+
+// checkGolden compares got with testdata/convert/<lab>/<name>, or rewrites
+// the file with -update
+func checkGolden(t *testing.T, lab, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "convert", lab, name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the converted code:\n%s", path, lineDiff(string(want), got))
+	}
+}
+
+// lineDiff lists the first lines that differ between want and got
+func lineDiff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var out []string
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			out = append(out, fmt.Sprintf("line %d:\n  - %s\n  + %s", i+1, w, g))
+		}
+		if len(out) == 5 {
+			out = append(out, "...")
+			break
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func readLabBicep(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(labRoot, dir, "main.bicep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{"main.bicep": data}
+}
+
+func readLabTerraform(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(labRoot, dir, "*.tf"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no Terraform files in %s", dir)
+	}
+	sources := make(map[string][]byte)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(file)] = data
+	}
+	return sources
+}
+
+func joinSources(sources map[string][]byte) string {
+	var all strings.Builder
+	for _, data := range sources {
+		all.Write(data)
+		all.WriteString("\n")
+	}
+	return all.String()
+}
+
+// resourceTypes lists the distinct resource types declared in code
+func resourceTypes(pattern *regexp.Regexp, code string) []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, match := range pattern.FindAllStringSubmatch(code, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			types = append(types, match[1])
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// mappedTypes drops the types the first conversion reported as unmapped or
+// turned into the deployment scope, which can't come back
+func mappedTypes(types []string, resources []ConvertedResource) []string {
+	lost := make(map[string]bool)
+	for _, r := range resources {
+		if r.Status == unmappedStatus || r.TargetType == "deployment scope" {
+			lost[r.SourceType] = true
+		}
+	}
+	var mapped []string
+	for _, typ := range types {
+		if !lost[typ] {
+			mapped = append(mapped, typ)
+		}
+	}
+	return mapped
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
@@ -0,0 +1,781 @@
+// =============================================================================
+// Conversion Mappings
+// =============================================================================
+// Resource type and property mappings between Microsoft.* resource types and
+// the azurerm provider (4.x), used by convert_iac in both directions. Each
+// propertyRule pairs a dotted path in the Bicep resource body with a dotted
+// path in the Terraform resource (leading segments are nested blocks).
+// Properties that are not listed here become TODO comments.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// propertyRule maps one Bicep property to one Terraform argument
+type propertyRule struct {
+	bicep string
+	// tf is empty when Terraform has no argument because the value is
+	// implied; bicepDefault is then written when converting to Bicep
+	tf           string
+	bicepDefault string
+	// elements maps an array of objects to repeated nested blocks; with
+	// first, only the first element maps to a single block
+	elements []propertyRule
+	first    bool
+	// Value conversions. ok=false falls through to the next rule for the
+	// same property, or to a TODO.
+	toTerraform func(c *tfConverter, value irNode, body *irObject) (string, bool)
+	toBicep     func(c *bicepConverter, value irNode, body *irObject) (string, bool)
+}
+
+// parentLink is the Terraform argument that points a child resource at its
+// parent, such as virtual_network_name or key_vault_id
+type parentLink struct {
+	arg  string
+	attr string // attribute of the parent it takes: name or id
+}
+
+// resourceMapping pairs a Microsoft.* type with an azurerm resource type
+type resourceMapping struct {
+	bicepType  string
+	apiVersion string // used when converting to Bicep
+	tfType     string
+	// resourceGroup: the Terraform resource takes resource_group_name
+	resourceGroup bool
+	parent        *parentLink
+	rules         []propertyRule
+	// attributes maps property paths read from a Bicep symbol (after
+	// vault.) to Terraform attributes
+	attributes map[string]string
+	// match picks between mappings of the same Bicep type, such as Linux
+	// and Windows web apps
+	match func(body *irObject) bool
+	// mergeBlock folds the Bicep child into a nested block of the parent's
+	// Terraform resource; mergeName is the child's fixed Bicep name
+	mergeBlock string
+	mergeName  string
+}
+
+// located are the location and tags rules shared by most top-level types
+var located = []propertyRule{
+	{bicep: "location", tf: "location"},
+	{bicep: "tags", tf: "tags"},
+}
+
+// withLocation prepends the location and tags rules
+func withLocation(rules ...propertyRule) []propertyRule {
+	return append(append([]propertyRule{}, located...), rules...)
+}
+
+// prefixed returns rules with a path prefix on the Bicep side
+func prefixed(prefix string, rules []propertyRule) []propertyRule {
+	out := make([]propertyRule, len(rules))
+	for i, rule := range rules {
+		rule.bicep = prefix + rule.bicep
+		out[i] = rule
+	}
+	return out
+}
+
+// securityRuleRules map an NSG security rule, relative to its properties
+var securityRuleRules = []propertyRule{
+	{bicep: "priority", tf: "priority"},
+	{bicep: "direction", tf: "direction"},
+	{bicep: "access", tf: "access"},
+	{bicep: "protocol", tf: "protocol"},
+	{bicep: "sourcePortRange", tf: "source_port_range"},
+	{bicep: "sourcePortRanges", tf: "source_port_ranges"},
+	{bicep: "destinationPortRange", tf: "destination_port_range"},
+	{bicep: "destinationPortRanges", tf: "destination_port_ranges"},
+	{bicep: "sourceAddressPrefix", tf: "source_address_prefix"},
+	{bicep: "sourceAddressPrefixes", tf: "source_address_prefixes"},
+	{bicep: "destinationAddressPrefix", tf: "destination_address_prefix"},
+	{bicep: "destinationAddressPrefixes", tf: "destination_address_prefixes"},
+	{bicep: "description", tf: "description"},
+}
+
+// subnetRules map a subnet, relative to its properties
+var subnetRules = []propertyRule{
+	{bicep: "addressPrefix", tf: "address_prefixes", toTerraform: wrapList, toBicep: unwrapList},
+	{bicep: "addressPrefixes", tf: "address_prefixes"},
+}
+
+// agentPoolRules map an AKS agent pool profile
+var agentPoolRules = []propertyRule{
+	{bicep: "count", tf: "node_count"},
+	{bicep: "vmSize", tf: "vm_size"},
+	{bicep: "osDiskSizeGB", tf: "os_disk_size_gb"},
+	{bicep: "maxPods", tf: "max_pods"},
+	{bicep: "vnetSubnetID", tf: "vnet_subnet_id"},
+	{bicep: "enableAutoScaling", tf: "auto_scaling_enabled"},
+	{bicep: "minCount", tf: "min_count"},
+	{bicep: "maxCount", tf: "max_count"},
+	{bicep: "availabilityZones", tf: "zones"},
+	{bicep: "nodeLabels", tf: "node_labels"},
+}
+
+// siteRules map the properties web apps and slots share
+var siteRules = []propertyRule{
+	{bicep: "properties.serverFarmId", tf: "service_plan_id"},
+	{bicep: "properties.httpsOnly", tf: "https_only"},
+	{bicep: "properties.clientAffinityEnabled", tf: "client_affinity_enabled"},
+	{bicep: "properties.publicNetworkAccess", tf: "public_network_access_enabled", toTerraform: enabledToBool, toBicep: boolToEnabled},
+	{bicep: "properties.virtualNetworkSubnetId", tf: "virtual_network_subnet_id"},
+	{bicep: "properties.keyVaultReferenceIdentity", tf: "key_vault_reference_identity_id"},
+	{bicep: "identity.type", tf: "identity.type"},
+	{bicep: "properties.siteConfig.alwaysOn", tf: "site_config.always_on"},
+	{bicep: "properties.siteConfig.minTlsVersion", tf: "site_config.minimum_tls_version"},
+	{bicep: "properties.siteConfig.ftpsState", tf: "site_config.ftps_state"},
+	{bicep: "properties.siteConfig.http20Enabled", tf: "site_config.http2_enabled"},
+	{bicep: "properties.siteConfig.healthCheckPath", tf: "site_config.health_check_path"},
+	{bicep: "properties.siteConfig.vnetRouteAllEnabled", tf: "site_config.vnet_route_all_enabled"},
+	{bicep: "properties.siteConfig.appSettings", tf: "app_settings", toTerraform: nameValueToMap, toBicep: mapToNameValue},
+}
+
+// linuxSiteRules add the Linux runtime stack
+var linuxSiteRules = append(append([]propertyRule{}, siteRules...),
+	linuxStack("PYTHON", "python_version"),
+	linuxStack("NODE", "node_version"),
+	linuxStack("DOTNETCORE", "dotnet_version"),
+	linuxStack("PHP", "php_version"),
+	linuxStack("DOCKER", "docker_image_name"),
+)
+
+// windowsSiteRules add the Windows .NET version
+var windowsSiteRules = append(append([]propertyRule{}, siteRules...),
+	propertyRule{bicep: "properties.siteConfig.netFrameworkVersion", tf: "site_config.application_stack.dotnet_version"},
+)
+
+// siteAttributes are the attributes web apps and slots share
+var siteAttributes = map[string]string{
+	"properties.defaultHostName":            "default_hostname",
+	"properties.outboundIpAddresses":        "outbound_ip_addresses",
+	"properties.customDomainVerificationId": "custom_domain_verification_id",
+	"identity.principalId":                  "identity[0].principal_id",
+	"identity.tenantId":                     "identity[0].tenant_id",
+}
+
+// resourceMappings lists the supported resource types
+var resourceMappings = []*resourceMapping{
+	{
+		bicepType:  "Microsoft.Resources/resourceGroups",
+		apiVersion: "2024-03-01",
+		tfType:     "azurerm_resource_group",
+		rules:      located,
+	},
+	{
+		bicepType:     "Microsoft.Storage/storageAccounts",
+		apiVersion:    "2023-05-01",
+		tfType:        "azurerm_storage_account",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "kind", tf: "account_kind"},
+			propertyRule{bicep: "kind", bicepDefault: "'StorageV2'"},
+			propertyRule{bicep: "sku.name", tf: "account_tier", toTerraform: storageTier, toBicep: storageSku},
+			propertyRule{bicep: "sku.name", tf: "account_replication_type", toTerraform: storageReplication, toBicep: impliedBy},
+			propertyRule{bicep: "properties.accessTier", tf: "access_tier"},
+			propertyRule{bicep: "properties.supportsHttpsTrafficOnly", tf: "https_traffic_only_enabled"},
+			propertyRule{bicep: "properties.minimumTlsVersion", tf: "min_tls_version"},
+			propertyRule{bicep: "properties.allowBlobPublicAccess", tf: "allow_nested_items_to_be_public"},
+			propertyRule{bicep: "properties.allowSharedKeyAccess", tf: "shared_access_key_enabled"},
+			propertyRule{bicep: "properties.isHnsEnabled", tf: "is_hns_enabled"},
+			propertyRule{bicep: "properties.publicNetworkAccess", tf: "public_network_access_enabled", toTerraform: enabledToBool, toBicep: boolToEnabled},
+			propertyRule{bicep: "properties.networkAcls.defaultAction", tf: "network_rules.default_action"},
+		),
+		attributes: map[string]string{
+			"properties.primaryEndpoints.blob":  "primary_blob_endpoint",
+			"properties.primaryEndpoints.web":   "primary_web_endpoint",
+			"properties.primaryEndpoints.dfs":   "primary_dfs_endpoint",
+			"properties.primaryEndpoints.file":  "primary_file_endpoint",
+			"properties.primaryEndpoints.queue": "primary_queue_endpoint",
+			"properties.primaryEndpoints.table": "primary_table_endpoint",
+		},
+	},
+	{
+		bicepType:  "Microsoft.Storage/storageAccounts/blobServices",
+		apiVersion: "2023-05-01",
+		mergeBlock: "blob_properties",
+		mergeName:  "default",
+		rules: []propertyRule{
+			{bicep: "properties.isVersioningEnabled", tf: "versioning_enabled"},
+			{bicep: "properties.changeFeed.enabled", tf: "change_feed_enabled"},
+			{bicep: "properties.deleteRetentionPolicy.enabled", bicepDefault: "true"},
+			{bicep: "properties.deleteRetentionPolicy.days", tf: "delete_retention_policy.days"},
+			{bicep: "properties.containerDeleteRetentionPolicy.enabled", bicepDefault: "true"},
+			{bicep: "properties.containerDeleteRetentionPolicy.days", tf: "container_delete_retention_policy.days"},
+		},
+	},
+	{
+		bicepType:  "Microsoft.Storage/storageAccounts/blobServices/containers",
+		apiVersion: "2023-05-01",
+		tfType:     "azurerm_storage_container",
+		parent:     &parentLink{arg: "storage_account_id", attr: "id"},
+		rules: []propertyRule{
+			{bicep: "properties.publicAccess", tf: "container_access_type", toTerraform: containerAccessToTerraform, toBicep: containerAccessToBicep},
+		},
+	},
+	{
+		bicepType:     "Microsoft.Network/virtualNetworks",
+		apiVersion:    "2024-01-01",
+		tfType:        "azurerm_virtual_network",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "properties.addressSpace.addressPrefixes", tf: "address_space"},
+			propertyRule{bicep: "properties.dhcpOptions.dnsServers", tf: "dns_servers"},
+			propertyRule{bicep: "properties.subnets", tf: "subnet", elements: append(
+				append([]propertyRule{{bicep: "name", tf: "name"}}, prefixed("properties.", subnetRules)...),
+				propertyRule{bicep: "properties.networkSecurityGroup.id", tf: "security_group"},
+			)},
+		),
+	},
+	{
+		bicepType:     "Microsoft.Network/virtualNetworks/subnets",
+		apiVersion:    "2024-01-01",
+		tfType:        "azurerm_subnet",
+		resourceGroup: true,
+		parent:        &parentLink{arg: "virtual_network_name", attr: "name"},
+		rules: append(prefixed("properties.", subnetRules),
+			propertyRule{bicep: "properties.serviceEndpoints", tf: "service_endpoints", toTerraform: serviceEndpointsToList, toBicep: listToServiceEndpoints},
+			propertyRule{bicep: "properties.privateEndpointNetworkPolicies", tf: "private_endpoint_network_policies"},
+		),
+	},
+	{
+		bicepType:     "Microsoft.Network/networkSecurityGroups",
+		apiVersion:    "2024-01-01",
+		tfType:        "azurerm_network_security_group",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "properties.securityRules", tf: "security_rule", elements: append(
+				[]propertyRule{{bicep: "name", tf: "name"}}, prefixed("properties.", securityRuleRules)...,
+			)},
+		),
+	},
+	{
+		bicepType:     "Microsoft.Network/networkSecurityGroups/securityRules",
+		apiVersion:    "2024-01-01",
+		tfType:        "azurerm_network_security_rule",
+		resourceGroup: true,
+		parent:        &parentLink{arg: "network_security_group_name", attr: "name"},
+		rules:         prefixed("properties.", securityRuleRules),
+	},
+	{
+		bicepType:     "Microsoft.KeyVault/vaults",
+		apiVersion:    "2023-07-01",
+		tfType:        "azurerm_key_vault",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "properties.tenantId", tf: "tenant_id"},
+			propertyRule{bicep: "properties.sku.name", tf: "sku_name"},
+			propertyRule{bicep: "properties.sku.family", bicepDefault: "'A'"},
+			propertyRule{bicep: "properties.enableRbacAuthorization", tf: "enable_rbac_authorization"},
+			propertyRule{bicep: "properties.enableSoftDelete"},
+			propertyRule{bicep: "properties.softDeleteRetentionInDays", tf: "soft_delete_retention_days"},
+			propertyRule{bicep: "properties.enablePurgeProtection", tf: "purge_protection_enabled"},
+			propertyRule{bicep: "properties.enabledForDeployment", tf: "enabled_for_deployment"},
+			propertyRule{bicep: "properties.enabledForDiskEncryption", tf: "enabled_for_disk_encryption"},
+			propertyRule{bicep: "properties.enabledForTemplateDeployment", tf: "enabled_for_template_deployment"},
+			propertyRule{bicep: "properties.publicNetworkAccess", tf: "public_network_access_enabled", toTerraform: enabledToBool, toBicep: boolToEnabled},
+			propertyRule{bicep: "properties.networkAcls.defaultAction", tf: "network_acls.default_action"},
+			propertyRule{bicep: "properties.networkAcls.bypass", tf: "network_acls.bypass"},
+			propertyRule{bicep: "properties.accessPolicies", tf: "access_policy", elements: []propertyRule{
+				{bicep: "tenantId", tf: "tenant_id"},
+				{bicep: "objectId", tf: "object_id"},
+				{bicep: "permissions.keys", tf: "key_permissions", toTerraform: permissionsToTerraform, toBicep: permissionsToBicep},
+				{bicep: "permissions.secrets", tf: "secret_permissions", toTerraform: permissionsToTerraform, toBicep: permissionsToBicep},
+				{bicep: "permissions.certificates", tf: "certificate_permissions", toTerraform: permissionsToTerraform, toBicep: permissionsToBicep},
+				{bicep: "permissions.storage", tf: "storage_permissions", toTerraform: permissionsToTerraform, toBicep: permissionsToBicep},
+			}},
+		),
+		attributes: map[string]string{
+			"properties.vaultUri": "vault_uri",
+		},
+	},
+	{
+		bicepType:  "Microsoft.KeyVault/vaults/secrets",
+		apiVersion: "2023-07-01",
+		tfType:     "azurerm_key_vault_secret",
+		parent:     &parentLink{arg: "key_vault_id", attr: "id"},
+		rules: []propertyRule{
+			{bicep: "properties.value", tf: "value"},
+			{bicep: "properties.contentType", tf: "content_type"},
+			{bicep: "tags", tf: "tags"},
+		},
+		attributes: map[string]string{
+			"properties.secretUri":            "versionless_id",
+			"properties.secretUriWithVersion": "id",
+		},
+	},
+	{
+		bicepType:     "Microsoft.ContainerService/managedClusters",
+		apiVersion:    "2024-02-01",
+		tfType:        "azurerm_kubernetes_cluster",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "properties.dnsPrefix", tf: "dns_prefix"},
+			propertyRule{bicep: "properties.kubernetesVersion", tf: "kubernetes_version"},
+			propertyRule{bicep: "sku.name", bicepDefault: "'Base'"},
+			propertyRule{bicep: "sku.tier", tf: "sku_tier"},
+			propertyRule{bicep: "identity.type", tf: "identity.type"},
+			propertyRule{bicep: "properties.agentPoolProfiles", tf: "default_node_pool", first: true, elements: append(
+				[]propertyRule{
+					{bicep: "name", tf: "name"},
+					{bicep: "type", tf: "type"},
+					{bicep: "osType", bicepDefault: "'Linux'"},
+					{bicep: "mode", bicepDefault: "'System'"},
+				}, agentPoolRules...,
+			)},
+			propertyRule{bicep: "properties.networkProfile.networkPlugin", tf: "network_profile.network_plugin"},
+			propertyRule{bicep: "properties.networkProfile.networkPluginMode", tf: "network_profile.network_plugin_mode"},
+			propertyRule{bicep: "properties.networkProfile.networkPolicy", tf: "network_profile.network_policy"},
+			propertyRule{bicep: "properties.networkProfile.serviceCidr", tf: "network_profile.service_cidr"},
+			propertyRule{bicep: "properties.networkProfile.dnsServiceIP", tf: "network_profile.dns_service_ip"},
+			propertyRule{bicep: "properties.networkProfile.podCidr", tf: "network_profile.pod_cidr"},
+			propertyRule{bicep: "properties.networkProfile.outboundType", tf: "network_profile.outbound_type"},
+			propertyRule{bicep: "properties.networkProfile.loadBalancerSku", tf: "network_profile.load_balancer_sku"},
+			propertyRule{bicep: "properties.enableRBAC", tf: "role_based_access_control_enabled"},
+			propertyRule{bicep: "properties.aadProfile.managed", bicepDefault: "true"},
+			propertyRule{bicep: "properties.aadProfile.enableAzureRBAC", tf: "azure_active_directory_role_based_access_control.azure_rbac_enabled"},
+			propertyRule{bicep: "properties.aadProfile.adminGroupObjectIDs", tf: "azure_active_directory_role_based_access_control.admin_group_object_ids"},
+			propertyRule{bicep: "properties.oidcIssuerProfile.enabled", tf: "oidc_issuer_enabled"},
+			propertyRule{bicep: "properties.securityProfile.workloadIdentity.enabled", tf: "workload_identity_enabled"},
+			propertyRule{bicep: "properties.nodeResourceGroup", tf: "node_resource_group"},
+			propertyRule{bicep: "properties.addonProfiles.omsagent.enabled", bicepDefault: "true"},
+			propertyRule{bicep: "properties.addonProfiles.omsagent.config.logAnalyticsWorkspaceResourceID", tf: "oms_agent.log_analytics_workspace_id"},
+		),
+		attributes: map[string]string{
+			"properties.fqdn":                                     "fqdn",
+			"properties.oidcIssuerProfile.issuerURL":              "oidc_issuer_url",
+			"properties.nodeResourceGroup":                        "node_resource_group",
+			"identity.principalId":                                "identity[0].principal_id",
+			"properties.identityProfile.kubeletidentity.objectId": "kubelet_identity[0].object_id",
+		},
+	},
+	{
+		bicepType:  "Microsoft.ContainerService/managedClusters/agentPools",
+		apiVersion: "2024-02-01",
+		tfType:     "azurerm_kubernetes_cluster_node_pool",
+		parent:     &parentLink{arg: "kubernetes_cluster_id", attr: "id"},
+		rules: prefixed("properties.", append([]propertyRule{
+			{bicep: "osType", tf: "os_type"},
+			{bicep: "mode", tf: "mode"},
+			{bicep: "nodeTaints", tf: "node_taints"},
+			{bicep: "tags", tf: "tags"},
+		}, agentPoolRules...)),
+	},
+	{
+		bicepType:     "Microsoft.Web/serverfarms",
+		apiVersion:    "2023-12-01",
+		tfType:        "azurerm_service_plan",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "sku.name", tf: "sku_name"},
+			propertyRule{bicep: "sku.tier"},
+			propertyRule{bicep: "sku.capacity", tf: "worker_count"},
+			propertyRule{bicep: "properties.reserved", tf: "os_type", toTerraform: reservedToOSType, toBicep: osTypeToReserved},
+			propertyRule{bicep: "kind", tf: "os_type", toTerraform: kindToOSType, toBicep: osTypeToKind},
+			propertyRule{bicep: "properties.zoneRedundant", tf: "zone_balancing_enabled"},
+			propertyRule{bicep: "properties.perSiteScaling", tf: "per_site_scaling_enabled"},
+			propertyRule{bicep: "properties.maximumElasticWorkerCount", tf: "maximum_elastic_worker_count"},
+		),
+	},
+	{
+		bicepType:     "Microsoft.Web/sites",
+		apiVersion:    "2023-12-01",
+		tfType:        "azurerm_linux_web_app",
+		resourceGroup: true,
+		match:         isLinuxSite,
+		rules:         withLocation(append([]propertyRule{{bicep: "kind", bicepDefault: "'app,linux'"}}, linuxSiteRules...)...),
+		attributes:    siteAttributes,
+	},
+	{
+		bicepType:     "Microsoft.Web/sites",
+		apiVersion:    "2023-12-01",
+		tfType:        "azurerm_windows_web_app",
+		resourceGroup: true,
+		rules:         withLocation(append([]propertyRule{{bicep: "kind", bicepDefault: "'app'"}}, windowsSiteRules...)...),
+		attributes:    siteAttributes,
+	},
+	{
+		bicepType:  "Microsoft.Web/sites/slots",
+		apiVersion: "2023-12-01",
+		tfType:     "azurerm_linux_web_app_slot",
+		parent:     &parentLink{arg: "app_service_id", attr: "id"},
+		match:      isLinuxSite,
+		rules: append([]propertyRule{
+			{bicep: "location", bicepDefault: "resourceGroup().location"},
+			{bicep: "tags", tf: "tags"},
+			{bicep: "kind", bicepDefault: "'app,linux'"},
+		}, linuxSiteRules...),
+		attributes: siteAttributes,
+	},
+	{
+		bicepType:  "Microsoft.Web/sites/slots",
+		apiVersion: "2023-12-01",
+		tfType:     "azurerm_windows_web_app_slot",
+		parent:     &parentLink{arg: "app_service_id", attr: "id"},
+		rules: append([]propertyRule{
+			{bicep: "location", bicepDefault: "resourceGroup().location"},
+			{bicep: "tags", tf: "tags"},
+			{bicep: "kind", bicepDefault: "'app'"},
+		}, windowsSiteRules...),
+		attributes: siteAttributes,
+	},
+	{
+		bicepType:     "Microsoft.OperationalInsights/workspaces",
+		apiVersion:    "2023-09-01",
+		tfType:        "azurerm_log_analytics_workspace",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "properties.sku.name", tf: "sku"},
+			propertyRule{bicep: "properties.retentionInDays", tf: "retention_in_days"},
+			propertyRule{bicep: "properties.workspaceCapping.dailyQuotaGb", tf: "daily_quota_gb"},
+		),
+		attributes: map[string]string{
+			"properties.customerId": "workspace_id",
+		},
+	},
+	{
+		bicepType:     "Microsoft.Insights/components",
+		apiVersion:    "2020-02-02",
+		tfType:        "azurerm_application_insights",
+		resourceGroup: true,
+		rules: withLocation(
+			propertyRule{bicep: "kind", bicepDefault: "'web'"},
+			propertyRule{bicep: "properties.Application_Type", tf: "application_type"},
+			propertyRule{bicep: "properties.WorkspaceResourceId", tf: "workspace_id"},
+			propertyRule{bicep: "properties.RetentionInDays", tf: "retention_in_days"},
+		),
+		attributes: map[string]string{
+			"properties.InstrumentationKey": "instrumentation_key",
+			"properties.ConnectionString":   "connection_string",
+			"properties.AppId":              "app_id",
+		},
+	},
+}
+
+// subnetNSGAssociation is the azurerm resource that attaches an NSG to a
+// subnet; in Bicep it is the subnet's networkSecurityGroup property
+const subnetNSGAssociation = "azurerm_subnet_network_security_group_association"
+
+// bicepMapping finds the mapping for a Bicep resource type and body
+func bicepMapping(bicepType string, body *irObject) *resourceMapping {
+	var fallback *resourceMapping
+	for _, mapping := range resourceMappings {
+		if !strings.EqualFold(mapping.bicepType, bicepType) {
+			continue
+		}
+		if mapping.match == nil {
+			if fallback == nil {
+				fallback = mapping
+			}
+			continue
+		}
+		if mapping.match(body) {
+			return mapping
+		}
+	}
+	return fallback
+}
+
+// terraformMapping finds the mapping for an azurerm resource type
+func terraformMapping(tfType string) *resourceMapping {
+	for _, mapping := range resourceMappings {
+		if mapping.tfType == tfType && mapping.tfType != "" {
+			return mapping
+		}
+	}
+	return nil
+}
+
+// mergedChildren finds the mappings folded into Terraform blocks of the
+// given parent type
+func mergedChildren(parentType string) []*resourceMapping {
+	var children []*resourceMapping
+	for _, mapping := range resourceMappings {
+		if mapping.mergeBlock != "" && strings.EqualFold(parentBicepType(mapping.bicepType), parentType) {
+			children = append(children, mapping)
+		}
+	}
+	return children
+}
+
+// parentBicepType strips the last segment of a child resource type
+func parentBicepType(bicepType string) string {
+	if i := strings.LastIndex(bicepType, "/"); i > strings.Index(bicepType, "/") {
+		return bicepType[:i]
+	}
+	return ""
+}
+
+// isLinuxSite tells Linux web apps from Windows ones
+func isLinuxSite(body *irObject) bool {
+	if item := body.get("kind"); item != nil {
+		kind, ok := literalString(item.Value)
+		return !ok || strings.Contains(strings.ToLower(kind), "linux")
+	}
+	return body.lookup("properties.siteConfig.linuxFxVersion") != nil || body.lookup("properties.reserved") != nil
+}
+
+// =============================================================================
+// Value Conversions
+// =============================================================================
+
+// impliedBy consumes a Terraform argument already covered by another rule
+func impliedBy(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	return "", true
+}
+
+// storageTier converts a storage SKU such as Standard_LRS to account_tier
+func storageTier(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	if sku, ok := literalString(value); ok {
+		tier, _, _ := strings.Cut(sku, "_")
+		return hclQuote(tier), true
+	}
+	return fmt.Sprintf("split(\"_\", %s)[0]", c.expr(value)), true
+}
+
+// storageReplication converts a storage SKU to account_replication_type
+func storageReplication(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	if sku, ok := literalString(value); ok {
+		_, replication, _ := strings.Cut(sku, "_")
+		return hclQuote(replication), true
+	}
+	return fmt.Sprintf("split(\"_\", %s)[1]", c.expr(value)), true
+}
+
+// storageSku joins account_tier and account_replication_type
+func storageSku(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	replication := body.get("account_replication_type")
+	if replication == nil {
+		return "", false
+	}
+	tier, tierOK := literalString(value)
+	kind, kindOK := literalString(replication.Value)
+	if tierOK && kindOK {
+		return bicepQuote(tier + "_" + kind), true
+	}
+	return fmt.Sprintf("'${%s}_${%s}'", c.expr(value), c.expr(replication.Value)), true
+}
+
+// enabledToBool converts 'Enabled'/'Disabled' to a boolean
+func enabledToBool(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	if s, ok := literalString(value); ok {
+		return fmt.Sprint(strings.EqualFold(s, "Enabled")), true
+	}
+	return fmt.Sprintf("%s == \"Enabled\"", c.expr(value)), true
+}
+
+// boolToEnabled converts a boolean to 'Enabled'/'Disabled'
+func boolToEnabled(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	if b, ok := value.(*irBool); ok {
+		if b.Value {
+			return "'Enabled'", true
+		}
+		return "'Disabled'", true
+	}
+	return fmt.Sprintf("%s ? 'Enabled' : 'Disabled'", c.expr(value)), true
+}
+
+// wrapList converts a single address prefix to a list
+func wrapList(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	return "[" + c.expr(value) + "]", true
+}
+
+// unwrapList converts a one-element list to its element
+func unwrapList(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	list, ok := value.(*irArray)
+	if !ok || len(list.Items) != 1 {
+		return "", false
+	}
+	return c.expr(list.Items[0]), true
+}
+
+// serviceEndpointsToList converts [{service: 'X'}] to ["X"]
+func serviceEndpointsToList(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	list, ok := value.(*irArray)
+	if !ok {
+		return fmt.Sprintf("[for endpoint in %s : endpoint.service]", c.expr(value)), true
+	}
+	var services []string
+	for _, item := range list.Items {
+		object, ok := item.(*irObject)
+		if !ok || object.get("service") == nil {
+			return "", false
+		}
+		services = append(services, c.expr(object.get("service").Value))
+	}
+	return "[" + strings.Join(services, ", ") + "]", true
+}
+
+// listToServiceEndpoints converts ["X"] to [{service: 'X'}]
+func listToServiceEndpoints(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	list, ok := value.(*irArray)
+	if !ok {
+		return fmt.Sprintf("map(%s, service => { service: service })", c.expr(value)), true
+	}
+	var lines []string
+	for _, item := range list.Items {
+		lines = append(lines, fmt.Sprintf("  {\n    service: %s\n  }", c.expr(item)))
+	}
+	return "[\n" + strings.Join(lines, "\n") + "\n]", true
+}
+
+// permissionsToTerraform capitalizes Key Vault permissions: get → Get
+func permissionsToTerraform(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	list, ok := value.(*irArray)
+	if !ok {
+		return "", false
+	}
+	var permissions []string
+	for _, item := range list.Items {
+		s, ok := literalString(item)
+		if !ok {
+			return "", false
+		}
+		permissions = append(permissions, hclQuote(strings.ToUpper(s[:1])+s[1:]))
+	}
+	return "[" + strings.Join(permissions, ", ") + "]", true
+}
+
+// permissionsToBicep lower-cases Key Vault permissions: Get → get
+func permissionsToBicep(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	list, ok := value.(*irArray)
+	if !ok {
+		return "", false
+	}
+	var permissions []string
+	for _, item := range list.Items {
+		s, ok := literalString(item)
+		if !ok {
+			return "", false
+		}
+		permissions = append(permissions, bicepQuote(strings.ToLower(s[:1])+s[1:]))
+	}
+	return "[" + strings.Join(permissions, ", ") + "]", true
+}
+
+// containerAccessLevels maps Bicep publicAccess to container_access_type
+var containerAccessLevels = map[string]string{"None": "private", "Blob": "blob", "Container": "container"}
+
+// containerAccessToTerraform converts a container's publicAccess
+func containerAccessToTerraform(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	s, ok := literalString(value)
+	if !ok || containerAccessLevels[s] == "" {
+		return "", false
+	}
+	return hclQuote(containerAccessLevels[s]), true
+}
+
+// containerAccessToBicep converts a container_access_type
+func containerAccessToBicep(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	s, ok := literalString(value)
+	if !ok {
+		return "", false
+	}
+	for level, access := range containerAccessLevels {
+		if access == s {
+			return bicepQuote(level), true
+		}
+	}
+	return "", false
+}
+
+// reservedToOSType converts an App Service plan's reserved flag to os_type
+func reservedToOSType(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	if b, ok := value.(*irBool); ok {
+		if b.Value {
+			return `"Linux"`, true
+		}
+		return `"Windows"`, true
+	}
+	return fmt.Sprintf("%s ? \"Linux\" : \"Windows\"", c.expr(value)), true
+}
+
+// osTypeToReserved sets reserved for Linux plans
+func osTypeToReserved(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	if s, ok := literalString(value); ok {
+		return fmt.Sprint(s == "Linux"), true
+	}
+	return fmt.Sprintf("%s == 'Linux'", c.expr(value)), true
+}
+
+// kindToOSType converts an App Service plan kind to os_type
+func kindToOSType(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	kind, ok := literalString(value)
+	if !ok {
+		return "", false
+	}
+	if strings.Contains(strings.ToLower(kind), "linux") {
+		return `"Linux"`, true
+	}
+	return `"Windows"`, true
+}
+
+// osTypeToKind converts os_type to an App Service plan kind
+func osTypeToKind(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	osType, ok := literalString(value)
+	if !ok {
+		return "", false
+	}
+	if osType == "Linux" {
+		return "'linux'", true
+	}
+	return "'app'", true
+}
+
+// nameValueToMap converts appSettings [{name, value}] to app_settings
+func nameValueToMap(c *tfConverter, value irNode, body *irObject) (string, bool) {
+	list, ok := value.(*irArray)
+	if !ok {
+		return fmt.Sprintf("{ for setting in %s : setting.name => setting.value }", c.expr(value)), true
+	}
+	var lines []string
+	for _, item := range list.Items {
+		object, ok := item.(*irObject)
+		if !ok || object.get("name") == nil || object.get("value") == nil || len(object.Items) != 2 {
+			return "", false
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", c.expr(object.get("name").Value), c.expr(object.get("value").Value)))
+	}
+	return "{\n" + strings.Join(lines, "\n") + "\n}", true
+}
+
+// mapToNameValue converts app_settings to appSettings [{name, value}]
+func mapToNameValue(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+	object, ok := value.(*irObject)
+	if !ok {
+		return fmt.Sprintf("map(items(%s), setting => {\n  name: setting.key\n  value: setting.value\n})", c.expr(value)), true
+	}
+	var lines []string
+	for _, item := range object.Items {
+		lines = append(lines, fmt.Sprintf("  {\n    name: %s\n    value: %s\n  }", bicepQuote(item.Key), indentLines(c.expr(item.Value), "    ")))
+	}
+	return "[\n" + strings.Join(lines, "\n") + "\n]", true
+}
+
+// linuxStack maps linuxFxVersion 'RUNTIME|version' to one application_stack
+// argument
+func linuxStack(runtime, argument string) propertyRule {
+	return propertyRule{
+		bicep: "properties.siteConfig.linuxFxVersion",
+		tf:    "site_config.application_stack." + argument,
+		toTerraform: func(c *tfConverter, value irNode, body *irObject) (string, bool) {
+			fx, ok := literalString(value)
+			name, version, found := strings.Cut(fx, "|")
+			if !ok || !found || !strings.EqualFold(name, runtime) {
+				return "", false
+			}
+			return hclQuote(version), true
+		},
+		toBicep: func(c *bicepConverter, value irNode, body *irObject) (string, bool) {
+			if version, ok := literalString(value); ok {
+				return bicepQuote(runtime + "|" + version), true
+			}
+			return fmt.Sprintf("'%s|${%s}'", runtime, c.expr(value)), true
+		},
+	}
+}
@@ -0,0 +1,426 @@
+// =============================================================================
+// Structured Diagnostics
+// =============================================================================
+// Machine-readable validation results. The validate_terraform, validate_bicep,
+// check_iac_syntax and lint_iac tools return a DiagnosticsReport as MCP
+// structuredContent (described by diagnosticsOutputSchema) next to the usual
+// markdown text, so editor integrations don't have to parse the text.
+// Clients that negotiated a protocol revision before 2025-06-18 get the same
+// report serialized as a JSON text block (see withStructuredText).
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Diagnostic severities
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// Position is a 1-based line/column location in a source file
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Diagnostic is a single validation finding
+type Diagnostic struct {
+	Severity string    `json:"severity"`
+	Code     string    `json:"code,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Start    *Position `json:"start,omitempty"`
+	End      *Position `json:"end,omitempty"`
+	Summary  string    `json:"summary"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// DiagnosticsReport is the structured result of a validation tool
+type DiagnosticsReport struct {
+	Tool         string       `json:"tool"`
+	Valid        bool         `json:"valid"`
+	ErrorCount   int          `json:"errorCount"`
+	WarningCount int          `json:"warningCount"`
+	Diagnostics  []Diagnostic `json:"diagnostics"`
+}
+
+// diagnosticsOutputSchema is the JSON Schema of DiagnosticsReport, declared as
+// the outputSchema of every validation tool
+var diagnosticsOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"tool":         map[string]interface{}{"type": "string"},
+		"valid":        map[string]interface{}{"type": "boolean"},
+		"errorCount":   map[string]interface{}{"type": "integer"},
+		"warningCount": map[string]interface{}{"type": "integer"},
+		"diagnostics":  map[string]interface{}{"type": "array", "items": diagnosticSchema},
+	},
+	"required": []string{"tool", "valid", "errorCount", "warningCount", "diagnostics"},
+}
+
+// diagnosticSchema is the JSON Schema of Diagnostic
+var diagnosticSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"severity": map[string]interface{}{"type": "string", "enum": []string{severityError, severityWarning, severityInfo}},
+		"code":     map[string]interface{}{"type": "string"},
+		"file":     map[string]interface{}{"type": "string"},
+		"start":    positionSchema,
+		"end":      positionSchema,
+		"summary":  map[string]interface{}{"type": "string"},
+		"detail":   map[string]interface{}{"type": "string"},
+	},
+	"required": []string{"severity", "summary"},
+}
+
+// positionSchema is the JSON Schema of Position
+var positionSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"line":   map[string]interface{}{"type": "integer"},
+		"column": map[string]interface{}{"type": "integer"},
+	},
+	"required": []string{"line", "column"},
+}
+
+// newDiagnosticsReport builds a report, filling in the counts and validity
+func newDiagnosticsReport(tool string, diags []Diagnostic) *DiagnosticsReport {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	errors, warnings := countDiagnostics(diags)
+	return &DiagnosticsReport{
+		Tool:         tool,
+		Valid:        errors == 0,
+		ErrorCount:   errors,
+		WarningCount: warnings,
+		Diagnostics:  diags,
+	}
+}
+
+// diagnosticsResult wraps the human-readable text and the structured report
+// into a tool result
+func diagnosticsResult(text string, report *DiagnosticsReport) *ToolCallResult {
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: text}},
+		StructuredContent: report,
+		IsError:           !report.Valid,
+	}
+}
+
+// countDiagnostics returns the number of errors and warnings in diags
+func countDiagnostics(diags []Diagnostic) (errors, warnings int) {
+	for _, diag := range diags {
+		switch diag.Severity {
+		case severityError:
+			errors++
+		case severityWarning:
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// writeDiagnostics renders diagnostics as a numbered markdown list
+func writeDiagnostics(output *strings.Builder, diags []Diagnostic) {
+	for i, diag := range diags {
+		icon := "⚠️"
+		if diag.Severity == severityError {
+			icon = "❌"
+		} else if diag.Severity == severityInfo {
+			icon = "ℹ️"
+		}
+		summary := diag.Summary
+		if diag.Code != "" {
+			summary = diag.Code + ": " + summary
+		}
+		output.WriteString(fmt.Sprintf("%d. %s **%s**\n", i+1, icon, summary))
+		if diag.Start != nil {
+			output.WriteString(fmt.Sprintf("   📍 File: %s, Line: %d, Column: %d\n",
+				diag.File, diag.Start.Line, diag.Start.Column))
+		}
+		if diag.Detail != "" {
+			output.WriteString(fmt.Sprintf("   💡 %s\n", diag.Detail))
+		}
+		output.WriteString("\n")
+	}
+}
+
+// =============================================================================
+// Parsers
+// =============================================================================
+
+// fromHCLDiagnostics converts diagnostics produced by the HCL parser
+func fromHCLDiagnostics(diags hcl.Diagnostics) []Diagnostic {
+	result := make([]Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		diag := Diagnostic{
+			Severity: severityWarning,
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+		if d.Severity == hcl.DiagError {
+			diag.Severity = severityError
+		}
+		if d.Subject != nil {
+			diag.File = d.Subject.Filename
+			diag.Start = &Position{Line: d.Subject.Start.Line, Column: d.Subject.Start.Column}
+			diag.End = &Position{Line: d.Subject.End.Line, Column: d.Subject.End.Column}
+		}
+		result = append(result, diag)
+	}
+	return result
+}
+
+// terraformValidateOutput is the JSON document printed by `terraform validate -json`
+type terraformValidateOutput struct {
+	Valid        bool `json:"valid"`
+	ErrorCount   int  `json:"error_count"`
+	WarningCount int  `json:"warning_count"`
+	Diagnostics  []struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+		Range    *struct {
+			Filename string   `json:"filename"`
+			Start    Position `json:"start"`
+			End      Position `json:"end"`
+		} `json:"range"`
+	} `json:"diagnostics"`
+}
+
+// parseTerraformValidateJSON converts `terraform validate -json` output
+func parseTerraformValidateJSON(data []byte) ([]Diagnostic, error) {
+	var result terraformValidateOutput
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	diags := make([]Diagnostic, 0, len(result.Diagnostics))
+	for _, d := range result.Diagnostics {
+		diag := Diagnostic{
+			Severity: d.Severity,
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+		if d.Range != nil {
+			start, end := d.Range.Start, d.Range.End
+			diag.File = d.Range.Filename
+			diag.Start = &start
+			diag.End = &end
+		}
+		diags = append(diags, diag)
+	}
+	return diags, nil
+}
+
+// bicepDiagnosticPattern matches az bicep / bicep CLI diagnostics such as
+// `/src/main.bicep(12,5) : Error BCP035: The specified "object" declaration ...`
+var bicepDiagnosticPattern = regexp.MustCompile(`^(.+?)\((\d+),(\d+)\)\s*:\s*(Error|Warning|Info)\s+([A-Za-z0-9-]+):\s*(.*)$`)
+
+// bicepDocLinkPattern matches the trailing documentation link bicep appends
+var bicepDocLinkPattern = regexp.MustCompile(`\s*\[(https?://[^\]]+)\]$`)
+
+// parseBicepOutput extracts diagnostics from az bicep build output. Bicep
+// only reports the start of a span, so End is left empty. When baseDir is set,
+// file paths are made relative to it.
+func parseBicepOutput(output, baseDir string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		match := bicepDiagnosticPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		lineNo, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+
+		file := match[1]
+		if baseDir != "" {
+			if rel, err := filepath.Rel(baseDir, file); err == nil {
+				file = rel
+			}
+		}
+
+		summary, detail := match[6], ""
+		if link := bicepDocLinkPattern.FindStringSubmatch(summary); link != nil {
+			summary = strings.TrimSpace(strings.TrimSuffix(summary, link[0]))
+			detail = "See " + link[1]
+		}
+
+		diags = append(diags, Diagnostic{
+			Severity: strings.ToLower(match[4]),
+			Code:     match[5],
+			File:     file,
+			Start:    &Position{Line: lineNo, Column: column},
+			Summary:  summary,
+			Detail:   detail,
+		})
+	}
+	return diags
+}
+
+// tflintRange is a source range in tflint's JSON output
+type tflintRange struct {
+	Filename string   `json:"filename"`
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+}
+
+// tflintOutput is the JSON document printed by `tflint --format=json`
+type tflintOutput struct {
+	Issues []struct {
+		Rule struct {
+			Name     string `json:"name"`
+			Severity string `json:"severity"`
+			Link     string `json:"link"`
+		} `json:"rule"`
+		Message string       `json:"message"`
+		Range   *tflintRange `json:"range"`
+	} `json:"issues"`
+	Errors []struct {
+		Message  string       `json:"message"`
+		Severity string       `json:"severity"`
+		Range    *tflintRange `json:"range"`
+	} `json:"errors"`
+}
+
+// parseTFLintJSON converts `tflint --format=json` output
+func parseTFLintJSON(data []byte) ([]Diagnostic, error) {
+	var result tflintOutput
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	diags := make([]Diagnostic, 0, len(result.Issues)+len(result.Errors))
+	for _, issue := range result.Issues {
+		diag := Diagnostic{
+			Severity: lintSeverity(issue.Rule.Severity),
+			Code:     issue.Rule.Name,
+			Summary:  issue.Message,
+		}
+		if issue.Rule.Link != "" {
+			diag.Detail = "See " + issue.Rule.Link
+		}
+		diag.File, diag.Start, diag.End = tflintLocation(issue.Range)
+		diags = append(diags, diag)
+	}
+	for _, e := range result.Errors {
+		diag := Diagnostic{
+			Severity: lintSeverity(e.Severity),
+			Summary:  e.Message,
+		}
+		diag.File, diag.Start, diag.End = tflintLocation(e.Range)
+		diags = append(diags, diag)
+	}
+	return diags, nil
+}
+
+// tflintLocation unpacks an optional tflint range
+func tflintLocation(r *tflintRange) (string, *Position, *Position) {
+	if r == nil || r.Filename == "" {
+		return "", nil, nil
+	}
+	start, end := r.Start, r.End
+	return r.Filename, &start, &end
+}
+
+// lintSeverity maps linter severities (tflint, SARIF) onto ours
+func lintSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "error":
+		return severityError
+	case "warning":
+		return severityWarning
+	}
+	return severityInfo
+}
+
+// sarifLog is the subset of a SARIF 2.1.0 log we read
+type sarifLog struct {
+	Runs []struct {
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine   int `json:"startLine"`
+						StartColumn int `json:"startColumn"`
+						EndLine     int `json:"endLine"`
+						EndColumn   int `json:"endColumn"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// parseSARIF converts a SARIF log, as written by `bicep lint
+// --diagnostics-format sarif`. When baseDir is set, file paths are made
+// relative to it.
+func parseSARIF(data []byte, baseDir string) ([]Diagnostic, error) {
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, err
+	}
+
+	var diags []Diagnostic
+	for _, run := range log.Runs {
+		for _, r := range run.Results {
+			// SARIF's default level is "warning"
+			level := r.Level
+			if level == "" {
+				level = "warning"
+			}
+			diag := Diagnostic{
+				Severity: lintSeverity(level),
+				Code:     r.RuleID,
+				Summary:  r.Message.Text,
+			}
+			if link := bicepDocLinkPattern.FindStringSubmatch(diag.Summary); link != nil {
+				diag.Summary = strings.TrimSpace(strings.TrimSuffix(diag.Summary, link[0]))
+				diag.Detail = "See " + link[1]
+			}
+
+			if len(r.Locations) > 0 {
+				loc := r.Locations[0].PhysicalLocation
+				file := loc.ArtifactLocation.URI
+				if path, err := pathFromFileURI(file); err == nil {
+					file = path
+				}
+				if baseDir != "" {
+					if rel, err := filepath.Rel(baseDir, file); err == nil {
+						file = rel
+					}
+				}
+				diag.File = file
+				if loc.Region.StartLine > 0 {
+					diag.Start = &Position{Line: loc.Region.StartLine, Column: loc.Region.StartColumn}
+				}
+				if loc.Region.EndLine > 0 {
+					diag.End = &Position{Line: loc.Region.EndLine, Column: loc.Region.EndColumn}
+				}
+			}
+			diags = append(diags, diag)
+		}
+	}
+	return diags, nil
+}
@@ -0,0 +1,261 @@
+// =============================================================================
+// Unified Diff
+// =============================================================================
+// A small line-based diff (Myers' O(ND) algorithm, in linear space) that
+// renders changes in the unified format of `diff -u` / `git diff`, so tools
+// can show exactly what a rewrite would change. Inputs above maxDiffLines are
+// refused, since the running time still grows with N·D.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around a change
+const diffContextLines = 3
+
+// maxDiffLines bounds the combined line count of the two sides of a diff
+const maxDiffLines = 20000
+
+// lineOp is one line of an edit script: ' ' keeps a[a], '-' deletes a[a],
+// '+' inserts b[b]
+type lineOp struct {
+	kind byte
+	a, b int
+}
+
+// unifiedDiff returns the unified diff between before and after, or "" if
+// they are equal
+func unifiedDiff(fromName, toName, before, after string) (string, error) {
+	if before == after {
+		return "", nil
+	}
+	a, b := splitLines(before), splitLines(after)
+	if len(a)+len(b) > maxDiffLines {
+		return "", fmt.Errorf("too large to diff: %d and %d lines, at most %d in total", len(a), len(b), maxDiffLines)
+	}
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of the hunk around it
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		end, last := first, first
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				last = end
+			} else if end-last > 2*diffContextLines {
+				break
+			}
+			end++
+		}
+		from := max(first-diffContextLines, start)
+		to := min(last+diffContextLines+1, len(ops))
+
+		writeHunk(&out, a, b, ops[from:to])
+		start = to
+	}
+	return out.String(), nil
+}
+
+// writeHunk writes one @@ hunk
+func writeHunk(out *strings.Builder, a, b []string, ops []lineOp) {
+	aStart, bStart := ops[0].a, ops[0].b
+	var aLen, bLen int
+	for _, op := range ops {
+		switch op.kind {
+		case ' ':
+			aLen++
+			bLen++
+		case '-':
+			aLen++
+		case '+':
+			bLen++
+		}
+	}
+	// Empty ranges point at the line before them
+	if aLen > 0 {
+		aStart++
+	}
+	if bLen > 0 {
+		bStart++
+	}
+	out.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen))
+
+	for _, op := range ops {
+		line := ""
+		switch op.kind {
+		case ' ', '-':
+			line = a[op.a]
+		case '+':
+			line = b[op.b]
+		}
+		out.WriteByte(op.kind)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits text into lines, keeping the line endings
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script turning a into b. It uses the
+// linear-space variant of Myers' algorithm: find the middle snake of the
+// shortest path with a forward and a backward search, then solve the two
+// halves on either side of it, so memory stays O(N+M) rather than O(D·(N+M)).
+func diffLines(a, b []string) []lineOp {
+	// Diagonals reach from -(N+M) to N+M plus the delta between the sides
+	size := 2*(len(a)+len(b)) + 2
+	d := &differ{a: a, b: b, offset: size, vf: make([]int, 2*size+1), vb: make([]int, 2*size+1)}
+	d.diff(0, len(a), 0, len(b))
+	return deletionsFirst(d.ops)
+}
+
+// deletionsFirst reorders every run of changes so its deletions come before
+// its insertions, as in diff -u and git diff
+func deletionsFirst(ops []lineOp) []lineOp {
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		x, y := ops[start].a, ops[start].b
+		end, deleted := start, 0
+		for ; end < len(ops) && ops[end].kind != ' '; end++ {
+			if ops[end].kind == '-' {
+				deleted++
+			}
+		}
+		for i := start; i < end; i++ {
+			if i-start < deleted {
+				ops[i] = lineOp{kind: '-', a: x + i - start, b: y}
+			} else {
+				ops[i] = lineOp{kind: '+', a: x + deleted, b: y + i - start - deleted}
+			}
+		}
+		start = end
+	}
+	return ops
+}
+
+// differ holds the state of one diffLines run. vf and vb hold, per diagonal
+// k = x - y (shifted by offset), the furthest x reached by the forward and
+// the backward search of the current middleSnake call.
+type differ struct {
+	a, b   []string
+	offset int
+	vf, vb []int
+	ops    []lineOp
+}
+
+// diff appends the edit script turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, lineOp{kind: ' ', a: aLo, b: bLo})
+		aLo++
+		bLo++
+	}
+	aEnd, bEnd := aHi, bHi
+	for aLo < aEnd && bLo < bEnd && d.a[aEnd-1] == d.b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	switch {
+	case aLo == aEnd:
+		for y := bLo; y < bEnd; y++ {
+			d.ops = append(d.ops, lineOp{kind: '+', a: aLo, b: y})
+		}
+	case bLo == bEnd:
+		for x := aLo; x < aEnd; x++ {
+			d.ops = append(d.ops, lineOp{kind: '-', a: x, b: bLo})
+		}
+	default:
+		// With the common prefix and suffix gone, at least two edits remain,
+		// so both halves are smaller than the whole
+		x, y, u, v := d.middleSnake(aLo, aEnd, bLo, bEnd)
+		d.diff(aLo, x, bLo, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.ops = append(d.ops, lineOp{kind: ' ', a: x, b: y})
+		}
+		d.diff(u, aEnd, v, bEnd)
+	}
+
+	for i := 0; i < aHi-aEnd; i++ {
+		d.ops = append(d.ops, lineOp{kind: ' ', a: aEnd + i, b: bEnd + i})
+	}
+}
+
+// middleSnake returns the snake (x, y) → (u, v) in the middle of a shortest
+// edit script turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	delta := n - m
+	vf, vb, off := d.vf, d.vb, d.offset
+	vf[off+1] = 0
+	vb[off+delta+1] = n + 1
+
+	for D := 0; D <= (n+m+1)/2; D++ {
+		// Forward search from (0, 0)
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if delta%2 != 0 && k >= delta-(D-1) && k <= delta+(D-1) && vb[off+k] <= x {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y
+			}
+		}
+
+		// Backward search from (n, m), on the same diagonals numbering
+		for r := -D; r <= D; r += 2 {
+			k := r + delta
+			var x int
+			if r == -D || (r != D && vb[off+k+1]-1 < vb[off+k-1]) {
+				x = vb[off+k+1] - 1
+			} else {
+				x = vb[off+k-1]
+			}
+			y := x - k
+			endX, endY := x, y
+			for x > 0 && y > 0 && a[x-1] == b[y-1] {
+				x--
+				y--
+			}
+			vb[off+k] = x
+			if delta%2 == 0 && k >= -D && k <= D && vf[off+k] >= x {
+				return aLo + x, bLo + y, aLo + endX, bLo + endY
+			}
+		}
+	}
+	panic("diffLines: no middle snake")
+}
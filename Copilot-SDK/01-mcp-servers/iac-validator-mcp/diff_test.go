@@ -0,0 +1,136 @@
+// =============================================================================
+// Unified Diff Tests
+// =============================================================================
+// diffLines is checked against a quadratic LCS table on random inputs: the
+// edit script must rebuild both sides and keep as many lines as the longest
+// common subsequence, which is what makes it a shortest edit script.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDiffLinesIsShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+		checkEditScript(t, a, b, ops)
+		if kept, want := keptLines(ops), lcsLength(a, b); kept != want {
+			t.Fatalf("diffLines(%q, %q) keeps %d lines, want %d", a, b, kept, want)
+		}
+	}
+}
+
+func TestDiffLinesLargeInput(t *testing.T) {
+	// 4,000 lines with a change every few lines, the case whose trace used
+	// to need about a gigabyte
+	var a, b []string
+	for i := 0; i < 4000; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		a = append(a, line)
+		switch i % 7 {
+		case 0:
+			b = append(b, "changed "+line)
+		case 3:
+		default:
+			b = append(b, line)
+		}
+	}
+	ops := diffLines(a, b)
+	checkEditScript(t, a, b, ops)
+	if kept, want := keptLines(ops), 4000-2*572+1; kept != want {
+		t.Errorf("kept %d lines, want %d", kept, want)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\n"
+	after := "a\nB\nc\nd\ne"
+	want := "--- a/main.tf\n+++ b/main.tf\n@@ -1,4 +1,5 @@\n a\n-b\n+B\n c\n d\n+e\n\\ No newline at end of file\n"
+
+	got, err := unifiedDiff("a/main.tf", "b/main.tf", before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+	if got, err := unifiedDiff("a", "b", before, before); got != "" || err != nil {
+		t.Errorf("equal inputs: %q, %v", got, err)
+	}
+}
+
+func TestUnifiedDiffTooLarge(t *testing.T) {
+	big := strings.Repeat("x\n", maxDiffLines)
+	_, err := unifiedDiff("a", "b", big, big+"y\n")
+	if err == nil || !strings.Contains(err.Error(), "too large to diff") {
+		t.Errorf("err = %v, want a too large error", err)
+	}
+}
+
+// checkEditScript fails unless ops turns a into b, visiting every line of
+// both sides in order
+func checkEditScript(t *testing.T, a, b []string, ops []lineOp) {
+	t.Helper()
+	x, y := 0, 0
+	for _, op := range ops {
+		if op.a != x || op.b != y {
+			t.Fatalf("op %c at (%d, %d), expected (%d, %d)", op.kind, op.a, op.b, x, y)
+		}
+		switch op.kind {
+		case ' ':
+			if a[x] != b[y] {
+				t.Fatalf("kept %q as %q", a[x], b[y])
+			}
+			x++
+			y++
+		case '-':
+			x++
+		case '+':
+			y++
+		}
+	}
+	if x != len(a) || y != len(b) {
+		t.Fatalf("script ends at (%d, %d), want (%d, %d)", x, y, len(a), len(b))
+	}
+}
+
+func keptLines(ops []lineOp) int {
+	kept := 0
+	for _, op := range ops {
+		if op.kind == ' ' {
+			kept++
+		}
+	}
+	return kept
+}
+
+// lcsLength is the length of the longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(cur[j], prev[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
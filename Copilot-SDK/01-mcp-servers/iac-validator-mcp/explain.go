@@ -0,0 +1,230 @@
+// =============================================================================
+// Explain Errors Tool
+// =============================================================================
+// Validates a Terraform module or Bicep file, then asks the client's own
+// model to explain each error and warning and to propose a fix. The server
+// sends one sampling/createMessage request per diagnostic (see client.go),
+// with the numbered source lines around it attached, so the explanation is
+// grounded in the code that actually failed.
+//
+// Sampling needs a client that declares the sampling capability. Hosts
+// usually let the user review each request before it reaches the model.
+// Without sampling, the explain_validation_error prompt offers the same
+// workflow (see prompts.go).
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// explainMaxTokens caps the length of each explanation
+const explainMaxTokens = 800
+
+// explainSystemPrompt is sent with every sampling request
+const explainSystemPrompt = "You are an expert in Terraform (azurerm) and Azure Bicep. " +
+	"Explain validation errors to an engineer in plain language and give the corrected code. Be concise."
+
+// ErrorExplanation is the model's explanation of one diagnostic
+type ErrorExplanation struct {
+	Diagnostic  Diagnostic `json:"diagnostic"`
+	Excerpt     string     `json:"excerpt,omitempty"`
+	Explanation string     `json:"explanation,omitempty"`
+	Model       string     `json:"model,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// ExplanationReport is the structured result of explain_errors
+type ExplanationReport struct {
+	Tool         string             `json:"tool"`
+	Valid        bool               `json:"valid"`
+	Explanations []ErrorExplanation `json:"explanations"`
+	Omitted      int                `json:"omitted"`
+}
+
+// explanationOutputSchema is the JSON Schema of ExplanationReport
+var explanationOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"tool":  map[string]interface{}{"type": "string"},
+		"valid": map[string]interface{}{"type": "boolean"},
+		"explanations": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"diagnostic":  diagnosticSchema,
+					"excerpt":     map[string]interface{}{"type": "string"},
+					"explanation": map[string]interface{}{"type": "string"},
+					"model":       map[string]interface{}{"type": "string"},
+					"error":       map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"diagnostic"},
+			},
+		},
+		"omitted": map[string]interface{}{"type": "integer"},
+	},
+	"required": []string{"tool", "valid", "explanations", "omitted"},
+}
+
+// handleExplainErrors validates path and has the client's model explain
+// every error and warning
+func (s *MCPServer) handleExplainErrors(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+	if s.clientCaps.Sampling == nil {
+		return nil, fmt.Errorf("the client does not support sampling (sampling/createMessage); use the explain_validation_error prompt instead")
+	}
+
+	absPath, err := s.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %s", absPath)
+	}
+	tool, toolArgs, baseDir, err := validationTarget(absPath, info)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.callPromptTool(ctx, tool, toolArgs)
+	if err != nil {
+		return nil, err
+	}
+	report, ok := result.StructuredContent.(*DiagnosticsReport)
+	if !ok {
+		return nil, fmt.Errorf("%s returned no diagnostics", tool)
+	}
+
+	var diags []Diagnostic
+	for _, diag := range report.Diagnostics {
+		if diag.Severity != severityInfo {
+			diags = append(diags, diag)
+		}
+	}
+	explained := &ExplanationReport{Tool: tool, Valid: report.Valid, Explanations: []ErrorExplanation{}}
+	if len(diags) > promptMaxExcerpts {
+		explained.Omitted = len(diags) - promptMaxExcerpts
+		diags = diags[:promptMaxExcerpts]
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("💬 Explaining validation errors in: %s\n\n", absPath))
+	if len(diags) == 0 {
+		output.WriteString(fmt.Sprintf("✅ **%s found nothing to explain!**\n", tool))
+		return &ToolCallResult{
+			Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+			StructuredContent: explained,
+		}, nil
+	}
+
+	reporter := reporterFrom(ctx)
+	failed := 0
+	for i, diag := range diags {
+		reporter.progress(i+1, len(diags), fmt.Sprintf("Explaining: %s", diag.Summary))
+
+		explanation := ErrorExplanation{Diagnostic: diag, Excerpt: diagnosticExcerpt(baseDir, diag)}
+		sample, err := s.createMessage(ctx, explainRequest(tool, explanation))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			explanation.Error = err.Error()
+			failed++
+		} else {
+			explanation.Explanation = strings.TrimSpace(sample.Content.Text)
+			explanation.Model = sample.Model
+		}
+		explained.Explanations = append(explained.Explanations, explanation)
+		writeExplanation(&output, i+1, explanation)
+	}
+	if explained.Omitted > 0 {
+		output.WriteString(fmt.Sprintf("ℹ️ %d more diagnostic(s) not explained\n", explained.Omitted))
+	}
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: explained,
+		IsError:           failed == len(diags),
+	}, nil
+}
+
+// explainRequest builds the sampling request for one diagnostic
+func explainRequest(tool string, explanation ErrorExplanation) CreateMessageParams {
+	diag := explanation.Diagnostic
+	lang := "hcl"
+	if tool == "validate_bicep" {
+		lang = "bicep"
+	}
+
+	var text strings.Builder
+	location := ""
+	if diag.File != "" {
+		location = fmt.Sprintf(" in `%s`", filepath.ToSlash(diag.File))
+		if diag.Start != nil {
+			location += fmt.Sprintf(" at line %d", diag.Start.Line)
+		}
+	}
+	text.WriteString(fmt.Sprintf("`%s` reported this %s%s:\n\n", tool, diag.Severity, location))
+	summary := diag.Summary
+	if diag.Code != "" {
+		summary = diag.Code + ": " + summary
+	}
+	text.WriteString(fmt.Sprintf("```\n%s\n", summary))
+	if diag.Detail != "" {
+		text.WriteString(diag.Detail + "\n")
+	}
+	text.WriteString("```\n\n")
+	if explanation.Excerpt != "" {
+		text.WriteString(fmt.Sprintf("Source (reported lines are marked with >):\n\n```%s\n%s```\n\n", lang, explanation.Excerpt))
+	}
+	text.WriteString("Explain what the message means and why this code causes it, then give the corrected code.")
+
+	return CreateMessageParams{
+		Messages: []SamplingMessage{{
+			Role:    "user",
+			Content: ContentBlock{Type: "text", Text: text.String()},
+		}},
+		ModelPreferences: &ModelPreferences{IntelligencePriority: 0.8, SpeedPriority: 0.5},
+		SystemPrompt:     explainSystemPrompt,
+		IncludeContext:   "none",
+		MaxTokens:        explainMaxTokens,
+	}
+}
+
+// writeExplanation renders one explained diagnostic as markdown
+func writeExplanation(output *strings.Builder, n int, explanation ErrorExplanation) {
+	diag := explanation.Diagnostic
+	icon := "⚠️"
+	if diag.Severity == severityError {
+		icon = "❌"
+	}
+	summary := diag.Summary
+	if diag.Code != "" {
+		summary = diag.Code + ": " + summary
+	}
+	output.WriteString(fmt.Sprintf("### %d. %s %s\n\n", n, icon, summary))
+	if diag.Start != nil {
+		output.WriteString(fmt.Sprintf("📍 File: %s, Line: %d, Column: %d\n\n", diag.File, diag.Start.Line, diag.Start.Column))
+	}
+	if explanation.Excerpt != "" {
+		output.WriteString(fmt.Sprintf("```\n%s```\n\n", explanation.Excerpt))
+	}
+	if explanation.Error != "" {
+		output.WriteString(fmt.Sprintf("⚠️ No explanation: %s\n\n", explanation.Error))
+		return
+	}
+	output.WriteString(fmt.Sprintf("💡 %s\n\n", explanation.Explanation))
+	if explanation.Model != "" {
+		output.WriteString(fmt.Sprintf("_Explained by %s_\n\n", explanation.Model))
+	}
+}
@@ -0,0 +1,278 @@
+// =============================================================================
+// Formatting Tool
+// =============================================================================
+// Rewrites Terraform and Bicep code into its canonical format and returns the
+// result together with a unified diff against the input. Files can optionally
+// be rewritten in place; the path policy keeps writes inside the workspace
+// roots.
+//
+//   - Terraform: `terraform fmt`, or the HCL library's formatter when the
+//     Terraform CLI is not installed
+//   - Bicep:     `bicep format`, or `az bicep format`
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// FormatResult is the structured result of format_iac
+type FormatResult struct {
+	Type      string `json:"type"`
+	Path      string `json:"path,omitempty"`
+	Formatter string `json:"formatter"`
+	Changed   bool   `json:"changed"`
+	Written   bool   `json:"written"`
+	Formatted string `json:"formatted"`
+	Diff      string `json:"diff,omitempty"`
+}
+
+// formatResultOutputSchema is the JSON Schema of FormatResult
+var formatResultOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"type":      map[string]interface{}{"type": "string", "enum": []string{"terraform", "bicep"}},
+		"path":      map[string]interface{}{"type": "string"},
+		"formatter": map[string]interface{}{"type": "string"},
+		"changed":   map[string]interface{}{"type": "boolean"},
+		"written":   map[string]interface{}{"type": "boolean"},
+		"formatted": map[string]interface{}{"type": "string"},
+		"diff":      map[string]interface{}{"type": "string"},
+	},
+	"required": []string{"type", "formatter", "changed", "written", "formatted"},
+}
+
+// formatFailure is returned by the formatters when the input can't be
+// formatted, usually because of syntax errors
+type formatFailure struct {
+	detail string
+}
+
+// Error implements error
+func (e *formatFailure) Error() string { return e.detail }
+
+// handleFormatIaC formats a file or a code snippet
+func (s *MCPServer) handleFormatIaC(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	code, hasCode := args["code"].(string)
+	path, _ := args["path"].(string)
+	codeType, _ := args["type"].(string)
+	write, _ := args["write"].(bool)
+
+	if hasCode == (path != "") {
+		return nil, fmt.Errorf("exactly one of code or path is required")
+	}
+	if write && path == "" {
+		return nil, fmt.Errorf("write requires path")
+	}
+
+	result := &FormatResult{Type: strings.ToLower(codeType)}
+	name := "input"
+
+	var absPath string
+	var mode os.FileMode = 0644
+	if path != "" {
+		var err error
+		if absPath, err = s.resolvePath(path); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("file not found: %s", absPath)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("path must be a file: %s", absPath)
+		}
+		mode = info.Mode().Perm()
+
+		src, err := os.ReadFile(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		code = string(src)
+
+		result.Path = s.displayPath(absPath)
+		name = result.Path
+		if result.Type == "" {
+			result.Type = formatTypeFor(absPath)
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🎨 Formatting %s code: %s\n\n", result.Type, name))
+
+	var formatted string
+	var err error
+	switch result.Type {
+	case "terraform":
+		formatted, result.Formatter, err = formatTerraform(ctx, code, filepath.Base(name))
+	case "bicep":
+		ext := ".bicep"
+		if absPath != "" {
+			ext = filepath.Ext(absPath)
+		}
+		formatted, result.Formatter, err = formatBicep(ctx, code, ext)
+	default:
+		return nil, fmt.Errorf("unsupported type: %s (use 'terraform' or 'bicep')", codeType)
+	}
+
+	var failure *formatFailure
+	if errors.As(err, &failure) {
+		output.WriteString("❌ **Formatting failed**\n\n")
+		output.WriteString(fmt.Sprintf("```\n%s\n```\n", failure.detail))
+		return &ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: output.String()}},
+			IsError: true,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Formatted = formatted
+	result.Changed = formatted != code
+	diff, diffErr := unifiedDiff("a/"+name, "b/"+name, code, formatted)
+	result.Diff = diff
+
+	if !result.Changed {
+		output.WriteString(fmt.Sprintf("✅ **Already formatted** (%s)\n", result.Formatter))
+		return &ToolCallResult{
+			Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+			StructuredContent: result,
+		}, nil
+	}
+
+	if write {
+		if err := os.WriteFile(absPath, []byte(formatted), mode); err != nil {
+			return nil, fmt.Errorf("failed to write file: %w", err)
+		}
+		result.Written = true
+		output.WriteString(fmt.Sprintf("✏️ **Formatted and saved** %s (%s)\n\n", name, result.Formatter))
+	} else {
+		output.WriteString(fmt.Sprintf("⚠️ **Formatting changes** (%s)\n\n", result.Formatter))
+	}
+	if diffErr != nil {
+		output.WriteString(fmt.Sprintf("⚠️ Diff not shown: %v\n", diffErr))
+	} else {
+		output.WriteString(fmt.Sprintf("```diff\n%s```\n", result.Diff))
+	}
+	if !write {
+		output.WriteString(fmt.Sprintf("\n📝 **Formatted code:**\n\n```\n%s\n```\n", strings.TrimRight(formatted, "\n")))
+	}
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: result,
+	}, nil
+}
+
+// formatTypeFor infers the IaC type from a file extension
+func formatTypeFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tf", ".tfvars":
+		return "terraform"
+	case ".bicep", ".bicepparam":
+		return "bicep"
+	}
+	return ""
+}
+
+// formatTerraform formats Terraform source with `terraform fmt`, falling back
+// to the HCL formatter when the CLI isn't installed. filename is only used
+// in error messages.
+func formatTerraform(ctx context.Context, src, filename string) (formatted, formatter string, err error) {
+	if _, err := exec.LookPath("terraform"); err != nil {
+		file, diags := hclwrite.ParseConfig([]byte(src), filename, hcl.InitialPos)
+		if diags.HasErrors() {
+			var lines []string
+			for _, diag := range diags {
+				lines = append(lines, diag.Error())
+			}
+			return "", "", &formatFailure{detail: strings.Join(lines, "\n")}
+		}
+		return string(hclwrite.Format(file.Bytes())), "hclwrite", nil
+	}
+
+	reporterFrom(ctx).progress(1, 1, "Running terraform fmt")
+	cmd := commandContext(ctx, "terraform", "fmt", "-no-color", "-")
+	cmd.Stdin = strings.NewReader(src)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", "", &formatFailure{detail: strings.TrimSpace(stderr.String())}
+		}
+		return "", "", fmt.Errorf("failed to run terraform fmt: %w", err)
+	}
+	return string(out), "terraform fmt", nil
+}
+
+// formatBicep formats Bicep source with the Bicep CLI. ext selects between
+// .bicep and .bicepparam syntax.
+func formatBicep(ctx context.Context, src, ext string) (formatted, formatter string, err error) {
+	if !bicepCLIAvailable() {
+		return "", "", &formatFailure{detail: "Bicep CLI not found. Install the Bicep CLI or the Azure CLI: " + bicepInstallURL}
+	}
+
+	// The formatter works on files, so format a copy and leave the input alone
+	tempDir, err := os.MkdirTemp("", "iac-format-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tempFile := filepath.Join(tempDir, "main"+ext)
+	if err := os.WriteFile(tempFile, []byte(src), 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	cmd, formatter := bicepCommand(ctx, "format", tempFile, "--stdout")
+	reporterFrom(ctx).progress(1, 1, "Running "+formatter)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			detail := strings.ReplaceAll(stderr.String(), tempDir+string(filepath.Separator), "")
+			return "", "", &formatFailure{detail: strings.TrimSpace(detail)}
+		}
+		return "", "", fmt.Errorf("failed to run %s: %w", formatter, err)
+	}
+	return string(out), formatter, nil
+}
+
+// bicepInstallURL documents how to install the Bicep CLI
+const bicepInstallURL = "https://learn.microsoft.com/azure/azure-resource-manager/bicep/install"
+
+// bicepCLIAvailable reports whether bicepCommand can find a Bicep CLI
+func bicepCLIAvailable() bool {
+	for _, name := range []string{"bicep", "az"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bicepCommand builds a Bicep CLI command operating on file, preferring the
+// standalone bicep CLI over `az bicep`. It also returns a label for messages.
+// Check bicepCLIAvailable first.
+func bicepCommand(ctx context.Context, subcommand, file string, flags ...string) (*exec.Cmd, string) {
+	if _, err := exec.LookPath("bicep"); err == nil {
+		args := append(append([]string{subcommand}, flags...), file)
+		return commandContext(ctx, "bicep", args...), "bicep " + subcommand
+	}
+	args := append([]string{"bicep", subcommand, "--file", file}, flags...)
+	return commandContext(ctx, "az", args...), "az bicep " + subcommand
+}
@@ -0,0 +1,849 @@
+// =============================================================================
+// Dependency Graph Tool
+// =============================================================================
+// Builds the dependency graph of a Terraform root module or of Bicep files and
+// renders it as DOT, Mermaid and JSON, together with cycle detection and the
+// longest dependency chain (the minimum number of sequential deployment
+// steps).
+//
+//   - Terraform: resource, data, module, variable, locals and output blocks,
+//     parsed with the HCL library. References such as
+//     azurerm_resource_group.example.name become edges.
+//   - Bicep:     resource, module, param, var and output declarations. Any use
+//     of a symbolic name (vnet.id, dependsOn: [vnet], '${vnet.name}')
+//     becomes an edge.
+//
+// An edge A → B means A depends on B. By default variables, locals and
+// outputs are collapsed so the graph shows resources, data sources and
+// modules only; references through a local become direct edges.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Node kinds. Bicep declarations map onto the Terraform kinds: existing
+// resources are data, params are variables and vars are locals.
+const (
+	nodeResource = "resource"
+	nodeData     = "data"
+	nodeModule   = "module"
+	nodeVariable = "variable"
+	nodeLocal    = "local"
+	nodeOutput   = "output"
+)
+
+// valueKinds are collapsed unless include_values is set
+var valueKinds = map[string]bool{nodeVariable: true, nodeLocal: true, nodeOutput: true}
+
+// GraphNode is one declaration in the dependency graph
+type GraphNode struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Type string `json:"type,omitempty"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// GraphEdge means From depends on To
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DependencyGraph is the structured result of dependency_graph
+type DependencyGraph struct {
+	Type         string      `json:"type"`
+	Path         string      `json:"path"`
+	Nodes        []GraphNode `json:"nodes"`
+	Edges        []GraphEdge `json:"edges"`
+	Cycles       [][]string  `json:"cycles"`
+	LongestChain []string    `json:"longestChain"`
+	DOT          string      `json:"dot"`
+	Mermaid      string      `json:"mermaid"`
+}
+
+// dependencyGraphOutputSchema is the JSON Schema of DependencyGraph
+var dependencyGraphOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"type": map[string]interface{}{"type": "string", "enum": []string{"terraform", "bicep"}},
+		"path": map[string]interface{}{"type": "string"},
+		"nodes": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id":   map[string]interface{}{"type": "string"},
+					"kind": map[string]interface{}{"type": "string", "enum": []string{nodeResource, nodeData, nodeModule, nodeVariable, nodeLocal, nodeOutput}},
+					"type": map[string]interface{}{"type": "string"},
+					"file": map[string]interface{}{"type": "string"},
+					"line": map[string]interface{}{"type": "integer"},
+				},
+				"required": []string{"id", "kind", "file", "line"},
+			},
+		},
+		"edges": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"from": map[string]interface{}{"type": "string"},
+					"to":   map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"from", "to"},
+			},
+		},
+		"cycles": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+		"longestChain": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"dot":          map[string]interface{}{"type": "string"},
+		"mermaid":      map[string]interface{}{"type": "string"},
+	},
+	"required": []string{"type", "path", "nodes", "edges", "cycles", "longestChain", "dot", "mermaid"},
+}
+
+// handleDependencyGraph builds the dependency graph of a Terraform directory
+// or Bicep files
+func (s *MCPServer) handleDependencyGraph(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+	codeType, _ := args["type"].(string)
+	codeType = strings.ToLower(codeType)
+	format, _ := args["format"].(string)
+	format = strings.ToLower(format)
+	if format != "" && format != "mermaid" && format != "dot" && format != "json" {
+		return nil, fmt.Errorf("unsupported format: %s (use 'mermaid', 'dot' or 'json')", format)
+	}
+	includeValues, _ := args["include_values"].(bool)
+
+	absPath, err := s.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %s", absPath)
+	}
+
+	// Work out what to graph: a Terraform root module or Bicep files
+	dir := absPath
+	var bicepFiles []string
+	if !info.IsDir() {
+		dir = filepath.Dir(absPath)
+		if codeType == "" {
+			codeType = formatTypeFor(absPath)
+		}
+		if codeType == "bicep" {
+			bicepFiles = []string{filepath.Base(absPath)}
+		}
+	}
+	if codeType == "" {
+		tfFiles, err := walkIaCFiles(dir, false, map[string]bool{".tf": true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+		codeType = "bicep"
+		if len(tfFiles) > 0 {
+			codeType = "terraform"
+		}
+	}
+	if codeType == "bicep" && bicepFiles == nil {
+		if bicepFiles, err = walkIaCFiles(dir, false, map[string]bool{".bicep": true}); err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+	}
+
+	var builder *graphBuilder
+	switch codeType {
+	case "terraform":
+		if builder, err = terraformGraph(dir); err != nil {
+			return nil, err
+		}
+	case "bicep":
+		if len(bicepFiles) == 0 {
+			return nil, fmt.Errorf("no Bicep files found in %s", dir)
+		}
+		if builder, err = bicepGraph(dir, bicepFiles); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type: %s (use 'terraform' or 'bicep')", codeType)
+	}
+	if len(builder.nodes) == 0 {
+		return nil, fmt.Errorf("no %s declarations found at %s", codeType, absPath)
+	}
+
+	graph := builder.build(includeValues)
+	graph.Type = codeType
+	graph.Path = s.displayPath(absPath)
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🕸️ Dependency graph: %s (%s)\n\n", graph.Path, codeType))
+	output.WriteString(fmt.Sprintf("📊 %d node(s), %d edge(s). An arrow A → B means A depends on B.\n\n", len(graph.Nodes), len(graph.Edges)))
+	if len(graph.Cycles) > 0 {
+		output.WriteString(fmt.Sprintf("❌ **%d dependency cycle(s):**\n", len(graph.Cycles)))
+		for _, cycle := range graph.Cycles {
+			output.WriteString(fmt.Sprintf("   - %s → %s\n", strings.Join(cycle, " → "), cycle[0]))
+		}
+		output.WriteString("\n")
+	} else {
+		output.WriteString("✅ **No dependency cycles**\n\n")
+	}
+	if len(graph.LongestChain) > 1 {
+		output.WriteString(fmt.Sprintf("🔗 **Longest chain** (%d steps, deployment order): %s\n\n",
+			len(graph.LongestChain), strings.Join(graph.LongestChain, " ▸ ")))
+	}
+
+	switch format {
+	case "dot":
+		output.WriteString(fmt.Sprintf("```dot\n%s```\n", graph.DOT))
+	case "json":
+		data, _ := json.MarshalIndent(struct {
+			Nodes []GraphNode `json:"nodes"`
+			Edges []GraphEdge `json:"edges"`
+		}{graph.Nodes, graph.Edges}, "", "  ")
+		output.WriteString(fmt.Sprintf("```json\n%s\n```\n", data))
+	default:
+		output.WriteString(fmt.Sprintf("```mermaid\n%s```\n", graph.Mermaid))
+		if format == "" {
+			output.WriteString(fmt.Sprintf("\n```dot\n%s```\n", graph.DOT))
+		}
+	}
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: graph,
+	}, nil
+}
+
+// =============================================================================
+// Graph Construction
+// =============================================================================
+
+// graphBuilder collects declarations and their raw references
+type graphBuilder struct {
+	nodes map[string]GraphNode
+	refs  map[string][]string // node ID → referenced IDs, possibly undeclared
+}
+
+// newGraphBuilder creates an empty builder
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{nodes: make(map[string]GraphNode), refs: make(map[string][]string)}
+}
+
+// add declares a node and the IDs it references
+func (b *graphBuilder) add(node GraphNode, refs []string) {
+	b.nodes[node.ID] = node
+	b.refs[node.ID] = append(b.refs[node.ID], refs...)
+}
+
+// targets returns the declared nodes id depends on. Unless includeValues is
+// set, references to value nodes are replaced by what those reference.
+func (b *graphBuilder) targets(id string, includeValues bool, visited map[string]bool) []string {
+	var out []string
+	for _, ref := range b.refs[id] {
+		node, ok := b.nodes[ref]
+		if !ok {
+			continue
+		}
+		if includeValues || !valueKinds[node.Kind] {
+			out = append(out, ref)
+			continue
+		}
+		if visited[ref] {
+			continue
+		}
+		visited[ref] = true
+		out = append(out, b.targets(ref, includeValues, visited)...)
+	}
+	return out
+}
+
+// build resolves references into edges and analyses the graph
+func (b *graphBuilder) build(includeValues bool) *DependencyGraph {
+	graph := &DependencyGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}, Cycles: [][]string{}, LongestChain: []string{}}
+
+	ids := make([]string, 0, len(b.nodes))
+	for id, node := range b.nodes {
+		if includeValues || !valueKinds[node.Kind] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	adjacency := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		graph.Nodes = append(graph.Nodes, b.nodes[id])
+		seen := make(map[string]bool)
+		for _, target := range b.targets(id, includeValues, make(map[string]bool)) {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			adjacency[id] = append(adjacency[id], target)
+		}
+		sort.Strings(adjacency[id])
+		for _, target := range adjacency[id] {
+			graph.Edges = append(graph.Edges, GraphEdge{From: id, To: target})
+		}
+	}
+
+	component := stronglyConnected(ids, adjacency)
+	members := make(map[int][]string)
+	for _, id := range ids {
+		members[component[id]] = append(members[component[id]], id)
+	}
+	for _, id := range ids {
+		group := members[component[id]]
+		selfLoop := false
+		for _, target := range adjacency[id] {
+			selfLoop = selfLoop || target == id
+		}
+		if (len(group) > 1 && group[0] == id) || (len(group) == 1 && selfLoop) {
+			graph.Cycles = append(graph.Cycles, findCycle(id, adjacency, component))
+		}
+	}
+
+	graph.LongestChain = longestChain(ids, adjacency, component)
+	graph.DOT = renderDOT(graph, component, members)
+	graph.Mermaid = renderMermaid(graph, component, members)
+	return graph
+}
+
+// stronglyConnected assigns each node its strongly connected component
+// (Tarjan's algorithm). Nodes in the same component with more than one member
+// form a cycle.
+func stronglyConnected(ids []string, adjacency map[string][]string) map[string]int {
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	component := make(map[string]int)
+	var stack []string
+	next, count := 0, 0
+
+	var visit func(id string)
+	visit = func(id string) {
+		index[id], low[id] = next, next
+		next++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, target := range adjacency[id] {
+			if _, seen := index[target]; !seen {
+				visit(target)
+				low[id] = min(low[id], low[target])
+			} else if onStack[target] {
+				low[id] = min(low[id], index[target])
+			}
+		}
+
+		if low[id] == index[id] {
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component[top] = count
+				if top == id {
+					break
+				}
+			}
+			count++
+		}
+	}
+	for _, id := range ids {
+		if _, seen := index[id]; !seen {
+			visit(id)
+		}
+	}
+	return component
+}
+
+// findCycle returns a shortest cycle through start, staying inside its
+// strongly connected component
+func findCycle(start string, adjacency map[string][]string, component map[string]int) []string {
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, target := range adjacency[id] {
+			if component[target] != component[start] {
+				continue
+			}
+			if target == start {
+				cycle := []string{id}
+				for id != start {
+					id = previous[id]
+					cycle = append([]string{id}, cycle...)
+				}
+				return cycle
+			}
+			if _, seen := previous[target]; !seen {
+				previous[target] = id
+				queue = append(queue, target)
+			}
+		}
+	}
+	return []string{start}
+}
+
+// longestChain returns the longest dependency path, in deployment order
+// (dependencies first). Edges inside a cycle are ignored.
+func longestChain(ids []string, adjacency map[string][]string, component map[string]int) []string {
+	depth := make(map[string]int)
+	nextHop := make(map[string]string)
+
+	var visit func(id string) int
+	visit = func(id string) int {
+		if d, ok := depth[id]; ok {
+			return d
+		}
+		depth[id] = 1
+		for _, target := range adjacency[id] {
+			if component[target] == component[id] {
+				continue
+			}
+			if d := visit(target) + 1; d > depth[id] {
+				depth[id] = d
+				nextHop[id] = target
+			}
+		}
+		return depth[id]
+	}
+
+	start := ""
+	for _, id := range ids {
+		if start == "" || visit(id) > visit(start) {
+			start = id
+		}
+	}
+
+	var chain []string
+	for id := start; id != ""; id = nextHop[id] {
+		chain = append([]string{id}, chain...)
+	}
+	return chain
+}
+
+// =============================================================================
+// Rendering
+// =============================================================================
+
+// dotShapes maps node kinds to Graphviz node attributes
+var dotShapes = map[string]string{
+	nodeResource: "shape=box",
+	nodeData:     "shape=box, style=dashed",
+	nodeModule:   "shape=box3d",
+	nodeVariable: "shape=parallelogram",
+	nodeLocal:    "shape=ellipse",
+	nodeOutput:   "shape=note",
+}
+
+// renderDOT renders the graph in Graphviz DOT format. Cycle edges are red.
+func renderDOT(graph *DependencyGraph, component map[string]int, members map[int][]string) string {
+	var out strings.Builder
+	out.WriteString("digraph dependencies {\n  rankdir=LR;\n")
+	for _, node := range graph.Nodes {
+		out.WriteString(fmt.Sprintf("  %q [%s];\n", node.ID, dotShapes[node.Kind]))
+	}
+	for _, edge := range graph.Edges {
+		attrs := ""
+		if component[edge.From] == component[edge.To] && (edge.From == edge.To || len(members[component[edge.From]]) > 1) {
+			attrs = " [color=red]"
+		}
+		out.WriteString(fmt.Sprintf("  %q -> %q%s;\n", edge.From, edge.To, attrs))
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// mermaidShapes maps node kinds to Mermaid shape delimiters
+var mermaidShapes = map[string][2]string{
+	nodeResource: {"[", "]"},
+	nodeData:     {"[/", "/]"},
+	nodeModule:   {"[[", "]]"},
+	nodeVariable: {">", "]"},
+	nodeLocal:    {"(", ")"},
+	nodeOutput:   {"([", "])"},
+}
+
+// renderMermaid renders the graph as a Mermaid flowchart. Nodes in a cycle
+// are highlighted.
+func renderMermaid(graph *DependencyGraph, component map[string]int, members map[int][]string) string {
+	// Mermaid IDs can't contain dots or quotes, so nodes are numbered
+	keys := make(map[string]string, len(graph.Nodes))
+	var out strings.Builder
+	out.WriteString("graph LR\n")
+	for i, node := range graph.Nodes {
+		keys[node.ID] = fmt.Sprintf("n%d", i)
+		shape := mermaidShapes[node.Kind]
+		label := strings.ReplaceAll(node.ID, `"`, "#quot;")
+		out.WriteString(fmt.Sprintf("  %s%s\"%s\"%s\n", keys[node.ID], shape[0], label, shape[1]))
+	}
+	for _, edge := range graph.Edges {
+		out.WriteString(fmt.Sprintf("  %s --> %s\n", keys[edge.From], keys[edge.To]))
+	}
+
+	var inCycle []string
+	for _, cycle := range graph.Cycles {
+		for _, id := range members[component[cycle[0]]] {
+			inCycle = append(inCycle, keys[id])
+		}
+	}
+	if len(inCycle) > 0 {
+		out.WriteString("  classDef cycle stroke:#d00,stroke-width:2px\n")
+		out.WriteString(fmt.Sprintf("  class %s cycle\n", strings.Join(inCycle, ",")))
+	}
+	return out.String()
+}
+
+// =============================================================================
+// Terraform
+// =============================================================================
+
+// ignoredTraversalRoots are reference roots that never name a declaration
+var ignoredTraversalRoots = map[string]bool{"count": true, "each": true, "self": true, "path": true, "terraform": true}
+
+// terraformGraph collects the declarations of the root module in dir
+func terraformGraph(dir string) (*graphBuilder, error) {
+	files, err := walkIaCFiles(dir, false, map[string]bool{".tf": true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Terraform files found in %s", dir)
+	}
+
+	builder := newGraphBuilder()
+	parser := hclparse.NewParser()
+	for _, name := range files {
+		file, diags := parser.ParseHCLFile(filepath.Join(dir, name))
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse %s: %s", name, diags.Error())
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+
+		for _, block := range body.Blocks {
+			node := GraphNode{File: filepath.ToSlash(name), Line: block.DefRange().Start.Line}
+			switch {
+			case block.Type == "resource" && len(block.Labels) == 2:
+				node.ID, node.Kind, node.Type = block.Labels[0]+"."+block.Labels[1], nodeResource, block.Labels[0]
+			case block.Type == "data" && len(block.Labels) == 2:
+				node.ID, node.Kind, node.Type = "data."+block.Labels[0]+"."+block.Labels[1], nodeData, block.Labels[0]
+			case block.Type == "module" && len(block.Labels) == 1:
+				node.ID, node.Kind = "module."+block.Labels[0], nodeModule
+				node.Type = moduleSource(block.AsHCLBlock())
+			case block.Type == "variable" && len(block.Labels) == 1:
+				// Validation rules refer to the variable itself
+				builder.add(GraphNode{ID: "var." + block.Labels[0], Kind: nodeVariable, File: node.File, Line: node.Line}, nil)
+				continue
+			case block.Type == "output" && len(block.Labels) == 1:
+				node.ID, node.Kind = "output."+block.Labels[0], nodeOutput
+			case block.Type == "locals":
+				for attrName, attr := range block.Body.Attributes {
+					builder.add(GraphNode{
+						ID:   "local." + attrName,
+						Kind: nodeLocal,
+						File: node.File,
+						Line: attr.SrcRange.Start.Line,
+					}, traversalIDs(attr.Expr.Variables()))
+				}
+				continue
+			default:
+				continue
+			}
+			builder.add(node, traversalIDs(bodyTraversals(block.Body)))
+		}
+	}
+	return builder, nil
+}
+
+// bodyTraversals returns every variable reference in body, including nested
+// blocks
+func bodyTraversals(body *hclsyntax.Body) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	for _, attr := range body.Attributes {
+		traversals = append(traversals, attr.Expr.Variables()...)
+	}
+	for _, block := range body.Blocks {
+		traversals = append(traversals, bodyTraversals(block.Body)...)
+	}
+	return traversals
+}
+
+// traversalIDs converts references such as azurerm_subnet.app.id or
+// module.net.subnet_ids into node IDs. IDs that aren't declared are dropped
+// when the graph is built.
+func traversalIDs(traversals []hcl.Traversal) []string {
+	var ids []string
+	for _, traversal := range traversals {
+		var names []string
+		for _, step := range traversal {
+			switch t := step.(type) {
+			case hcl.TraverseRoot:
+				names = append(names, t.Name)
+			case hcl.TraverseAttr:
+				names = append(names, t.Name)
+			default:
+				// Index steps end the address part of a reference
+				names = append(names, "")
+			}
+			if len(names) == 3 {
+				break
+			}
+		}
+		if len(names) < 2 || names[1] == "" || ignoredTraversalRoots[names[0]] {
+			continue
+		}
+
+		switch names[0] {
+		case "var":
+			ids = append(ids, "var."+names[1])
+		case "local":
+			ids = append(ids, "local."+names[1])
+		case "module":
+			ids = append(ids, "module."+names[1])
+		case "data":
+			if len(names) == 3 && names[2] != "" {
+				ids = append(ids, "data."+names[1]+"."+names[2])
+			}
+		default:
+			ids = append(ids, names[0]+"."+names[1])
+		}
+	}
+	return ids
+}
+
+// =============================================================================
+// Bicep
+// =============================================================================
+
+// bicepDeclarationKinds maps Bicep declaration keywords to node kinds
+var bicepDeclarationKinds = map[string]string{
+	"resource": nodeResource,
+	"module":   nodeModule,
+	"param":    nodeVariable,
+	"var":      nodeLocal,
+	"output":   nodeOutput,
+}
+
+// bicepStatementKeywords start top-level statements that aren't graphed but
+// end the previous declaration
+var bicepStatementKeywords = map[string]bool{
+	"targetScope": true, "metadata": true, "import": true, "using": true,
+	"extension": true, "provider": true, "type": true, "func": true,
+}
+
+// bicepToken is a token of Bicep source. Strings are returned as a single
+// token with their content; the expressions inside ${...} are tokenized.
+type bicepToken struct {
+	text      string
+	str       bool
+	line      int
+	depth     int  // bracket nesting
+	lineStart bool // first token on its line
+}
+
+// bicepGraph collects the declarations of the given Bicep files (relative to
+// dir). With more than one file, IDs are prefixed with the file name.
+func bicepGraph(dir string, files []string) (*graphBuilder, error) {
+	builder := newGraphBuilder()
+	sort.Strings(files)
+	for _, name := range files {
+		src, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		prefix := ""
+		if len(files) > 1 {
+			prefix = filepath.ToSlash(name) + ":"
+		}
+		addBicepDeclarations(builder, filepath.ToSlash(name), prefix, tokenizeBicep(string(src)))
+	}
+	return builder, nil
+}
+
+// addBicepDeclarations adds the top-level declarations in tokens to builder
+func addBicepDeclarations(builder *graphBuilder, file, prefix string, tokens []bicepToken) {
+	type declaration struct {
+		node GraphNode
+		body []bicepToken
+	}
+	var declarations []*declaration
+	var current *declaration
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.depth == 0 && token.lineStart {
+			kind, isDeclaration := bicepDeclarationKinds[token.text]
+			if isDeclaration && i+1 < len(tokens) && !tokens[i+1].str {
+				current = &declaration{node: GraphNode{ID: tokens[i+1].text, Kind: kind, File: file, Line: token.line}}
+				if i+2 < len(tokens) && tokens[i+2].str {
+					current.node.Type = strings.SplitN(tokens[i+2].text, "@", 2)[0]
+					if i+3 < len(tokens) && tokens[i+3].text == "existing" {
+						current.node.Kind = nodeData
+					}
+				}
+				declarations = append(declarations, current)
+				i++
+				continue
+			}
+			if bicepStatementKeywords[token.text] || token.text == "@" {
+				current = nil
+			}
+		}
+		if current != nil {
+			current.body = append(current.body, token)
+		}
+	}
+
+	symbols := make(map[string]bool, len(declarations))
+	for _, d := range declarations {
+		symbols[d.node.ID] = true
+	}
+	for _, d := range declarations {
+		var refs []string
+		for i, token := range d.body {
+			if token.str || !symbols[token.text] || token.text == d.node.ID {
+				continue
+			}
+			// Skip property accesses (x.name, x::child) and object keys (name: x)
+			previous := ""
+			if i > 0 {
+				previous = d.body[i-1].text
+			}
+			if previous == "." || previous == "::" {
+				continue
+			}
+			if i+1 < len(d.body) && d.body[i+1].text == ":" && (token.lineStart || previous == "{" || previous == ",") {
+				continue
+			}
+			refs = append(refs, prefix+token.text)
+		}
+		d.node.ID = prefix + d.node.ID
+		builder.add(d.node, refs)
+	}
+}
+
+// tokenizeBicep splits Bicep source into identifiers, punctuation and strings,
+// skipping comments
+func tokenizeBicep(src string) []bicepToken {
+	var tokens []bicepToken
+	line, depth := 1, 0
+	lineStart := true
+	var interpolations []int // depth at which each open ${ started
+
+	emit := func(text string, str bool) {
+		tokens = append(tokens, bicepToken{text: text, str: str, line: line, depth: depth, lineStart: lineStart})
+		lineStart = false
+	}
+
+	// scanString reads string content from i up to the closing quote or the
+	// next ${, and returns the position after it
+	scanString := func(i int) (int, bool) {
+		start, startLine := i, line
+		for i < len(src) {
+			switch {
+			case src[i] == '\\':
+				i += 2
+				continue
+			case src[i] == '\n':
+				line++
+			case src[i] == '\'':
+				tokens = append(tokens, bicepToken{text: src[start:i], str: true, line: startLine, depth: depth, lineStart: lineStart})
+				lineStart = false
+				return i + 1, false
+			case strings.HasPrefix(src[i:], "${"):
+				tokens = append(tokens, bicepToken{text: src[start:i], str: true, line: startLine, depth: depth, lineStart: lineStart})
+				lineStart = false
+				return i + 2, true
+			}
+			i++
+		}
+		return i, false
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = true
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 4
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case strings.HasPrefix(src[i:], "'''"):
+			// Multi-line strings have no interpolation
+			end := strings.Index(src[i+3:], "'''")
+			if end < 0 {
+				end = len(src) - i - 6
+			}
+			emit(src[i+3:i+3+end], true)
+			line += strings.Count(src[i:i+3+end], "\n")
+			i += end + 6
+		case c == '\'':
+			var interpolating bool
+			if i, interpolating = scanString(i + 1); interpolating {
+				interpolations = append(interpolations, depth)
+			}
+		case c == '}' && len(interpolations) > 0 && depth == interpolations[len(interpolations)-1]:
+			interpolations = interpolations[:len(interpolations)-1]
+			var interpolating bool
+			if i, interpolating = scanString(i + 1); interpolating {
+				interpolations = append(interpolations, depth)
+			}
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			emit(src[start:i], false)
+		case c == '{' || c == '[' || c == '(':
+			emit(string(c), false)
+			depth++
+			i++
+		case c == '}' || c == ']' || c == ')':
+			depth = max(depth-1, 0)
+			emit(string(c), false)
+			i++
+		case strings.HasPrefix(src[i:], "::"):
+			emit("::", false)
+			i += 2
+		default:
+			emit(string(c), false)
+			i++
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,238 @@
+// =============================================================================
+// In-Process HCL Checks
+// =============================================================================
+// Parses Terraform configuration with the HashiCorp HCL library so syntax and
+// structural errors can be reported without the terraform binary and without
+// downloading any providers. The exec-based `terraform validate` path remains
+// available as the "deep" validation mode.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// Validation modes accepted by the Terraform tools
+const (
+	modeAuto = "auto" // deep when the terraform CLI is installed, hcl otherwise
+	modeHCL  = "hcl"  // in-process HCL parsing only
+	modeDeep = "deep" // terraform init + terraform validate
+)
+
+// terraformRootSchema lists the top-level blocks Terraform accepts in a
+// configuration file. Attributes are not allowed at the top level.
+var terraformRootSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "terraform"},
+		{Type: "provider", LabelNames: []string{"name"}},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "locals"},
+		{Type: "output", LabelNames: []string{"name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+		{Type: "moved"},
+		{Type: "import"},
+		{Type: "check", LabelNames: []string{"name"}},
+		{Type: "removed"},
+	},
+}
+
+// terraformBlockSchema describes the contents of the `terraform` block
+var terraformBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "required_version"},
+		{Name: "experiments"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "required_providers"},
+		{Type: "backend", LabelNames: []string{"type"}},
+		{Type: "cloud"},
+		{Type: "provider_meta", LabelNames: []string{"provider"}},
+	},
+}
+
+// variableBlockSchema describes the contents of a `variable` block
+var variableBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+		{Name: "default"},
+		{Name: "description"},
+		{Name: "sensitive"},
+		{Name: "nullable"},
+		{Name: "ephemeral"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "validation"},
+	},
+}
+
+// outputBlockSchema describes the contents of an `output` block
+var outputBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "value", Required: true},
+		{Name: "description"},
+		{Name: "sensitive"},
+		{Name: "depends_on"},
+		{Name: "ephemeral"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "precondition"},
+	},
+}
+
+// nestedBlockSchemas maps top-level block types to the schema of their body.
+// Block types not listed here (resource, data, provider, module) have bodies
+// defined by providers or child modules and cannot be checked offline.
+var nestedBlockSchemas = map[string]*hcl.BodySchema{
+	"terraform": terraformBlockSchema,
+	"variable":  variableBlockSchema,
+	"output":    outputBlockSchema,
+}
+
+// resolveTerraformMode normalizes the requested validation mode, choosing
+// between the CLI and the in-process parser when mode is "auto"
+func resolveTerraformMode(args map[string]interface{}) (string, error) {
+	mode := modeAuto
+	if m, ok := args["mode"].(string); ok && m != "" {
+		mode = strings.ToLower(m)
+	}
+
+	switch mode {
+	case modeHCL, modeDeep:
+		return mode, nil
+	case modeAuto:
+		if _, err := exec.LookPath("terraform"); err != nil {
+			return modeHCL, nil
+		}
+		return modeDeep, nil
+	default:
+		return "", fmt.Errorf("unsupported mode: %s (use 'auto', 'hcl' or 'deep')", mode)
+	}
+}
+
+// checkTerraformDir parses every Terraform file in dir (not recursively, the
+// same way Terraform loads a module) and checks its structure
+func checkTerraformDir(dir string) (hcl.Diagnostics, int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	parser := hclparse.NewParser()
+	var diags hcl.Diagnostics
+	fileCount := 0
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".tf") && !strings.HasSuffix(name, ".tf.json") {
+			continue
+		}
+
+		src, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		diags = append(diags, checkTerraformSource(parser, name, src)...)
+		fileCount++
+	}
+
+	sortDiagnostics(diags)
+	return diags, fileCount, nil
+}
+
+// checkTerraformSource parses a single Terraform file and checks its blocks
+// against the known Terraform language schema
+func checkTerraformSource(parser *hclparse.Parser, filename string, src []byte) hcl.Diagnostics {
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(filename, ".json") {
+		file, diags = parser.ParseJSON(src, filename)
+	} else {
+		file, diags = parser.ParseHCL(src, filename)
+	}
+	if diags.HasErrors() || file == nil {
+		return diags
+	}
+
+	content, contentDiags := file.Body.Content(terraformRootSchema)
+	diags = append(diags, contentDiags...)
+	if content == nil {
+		return diags
+	}
+
+	for _, block := range content.Blocks {
+		schema, ok := nestedBlockSchemas[block.Type]
+		if !ok {
+			continue
+		}
+		_, blockDiags := block.Body.Content(schema)
+		diags = append(diags, blockDiags...)
+	}
+
+	return diags
+}
+
+// sortDiagnostics orders diagnostics by file, line and column
+func sortDiagnostics(diags hcl.Diagnostics) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Subject, diags[j].Subject
+		if a == nil || b == nil {
+			return a != nil
+		}
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Start.Line != b.Start.Line {
+			return a.Start.Line < b.Start.Line
+		}
+		return a.Start.Column < b.Start.Column
+	})
+}
+
+// validateTerraformHCL runs the in-process checks against a directory and
+// formats the result like handleValidateTerraform does for the CLI path
+func (s *MCPServer) validateTerraformHCL(dir string, output *strings.Builder) (*ToolCallResult, error) {
+	output.WriteString("⚡ Running in-process HCL check (no terraform CLI or providers required)...\n\n")
+
+	hclDiags, fileCount, err := checkTerraformDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	report := newDiagnosticsReport("validate_terraform", fromHCLDiagnostics(hclDiags))
+	if fileCount == 0 {
+		output.WriteString("ℹ️ No Terraform (.tf) files found in this directory.\n")
+		return diagnosticsResult(output.String(), report), nil
+	}
+
+	if report.Valid {
+		output.WriteString("✅ **Terraform syntax and structure are valid!**\n\n")
+		output.WriteString("📊 Summary:\n")
+		output.WriteString(fmt.Sprintf("   - Files checked: %d\n", fileCount))
+		output.WriteString(fmt.Sprintf("   - Errors: %d\n", report.ErrorCount))
+		output.WriteString(fmt.Sprintf("   - Warnings: %d\n", report.WarningCount))
+		if report.WarningCount > 0 {
+			output.WriteString("\n")
+			writeDiagnostics(output, report.Diagnostics)
+		}
+		output.WriteString("\nℹ️ Provider schemas were not checked. Use mode \"deep\" to run terraform validate.\n")
+	} else {
+		output.WriteString("❌ **Terraform validation failed**\n\n")
+		output.WriteString(fmt.Sprintf("📊 Found %d error(s) and %d warning(s):\n\n", report.ErrorCount, report.WarningCount))
+		writeDiagnostics(output, report.Diagnostics)
+	}
+
+	return diagnosticsResult(output.String(), report), nil
+}
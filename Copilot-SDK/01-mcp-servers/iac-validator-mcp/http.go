@@ -0,0 +1,725 @@
+// =============================================================================
+// Streamable HTTP Transport
+// =============================================================================
+// Serves MCP over a single HTTP endpoint (/mcp) as described by the MCP
+// Streamable HTTP transport, so one shared validator can serve a whole team:
+//
+//   POST   /mcp  send JSON-RPC message(s); responses stream back as SSE
+//               (or a plain JSON body when the client doesn't accept SSE)
+//   GET    /mcp  open an SSE stream for server-initiated notifications
+//   DELETE /mcp  end the session
+//
+// Each session (identified by the Mcp-Session-Id header handed out on
+// initialize) gets its own MCPServer with the tools from registerTools.
+//
+// The server runs terraform and the command-backed tools on behalf of its
+// callers, so it listens on localhost unless a bearer token is configured
+// (IAC_VALIDATOR_TOKEN), and browser requests are only accepted from
+// localhost and the --allowed-origin list.
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// sessionHeader carries the session ID assigned on initialize
+	sessionHeader = "Mcp-Session-Id"
+
+	// protocolVersionHeader carries the negotiated protocol revision on every
+	// request after initialize (2025-06-18 and later)
+	protocolVersionHeader = "MCP-Protocol-Version"
+
+	// maxBodySize bounds a POST body; check_iac_syntax snippets are the
+	// largest messages clients send
+	maxBodySize = 4 << 20
+
+	// sessionIdleTimeout is how long an unused session is kept around
+	sessionIdleTimeout = 30 * time.Minute
+
+	// sseKeepAlive is the interval between keep-alive comments on SSE streams
+	sseKeepAlive = 30 * time.Second
+
+	// streamBuffer is the number of messages buffered per open stream
+	streamBuffer = 64
+)
+
+// HTTPOptions controls who may use the HTTP transport
+type HTTPOptions struct {
+	// AllowedOrigins are browser origins (scheme://host[:port]) accepted in
+	// addition to localhost
+	AllowedOrigins []string
+
+	// Token, when set, must be sent as "Authorization: Bearer <token>" on
+	// every request
+	Token string
+}
+
+// ServeHTTP runs the Streamable HTTP transport on addr until it fails
+func ServeHTTP(addr string, config Config, options HTTPOptions) error {
+	if options.Token == "" && !isLoopbackAddr(addr) {
+		return fmt.Errorf("refusing to listen on %s without authentication: set IAC_VALIDATOR_TOKEN or bind to 127.0.0.1", addr)
+	}
+
+	transport := newHTTPTransport(config, options)
+	defer transport.close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", transport)
+
+	debugLog("IaC Validator MCP Server listening on %s (Streamable HTTP)", addr)
+	fmt.Fprintf(os.Stderr, "IaC Validator MCP Server listening on http://%s/mcp\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// httpTransport tracks the active sessions
+type httpTransport struct {
+	config  Config
+	options HTTPOptions
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newHTTPTransport creates an empty transport whose sessions use config and
+// starts sweeping idle sessions until close is called
+func newHTTPTransport(config Config, options HTTPOptions) *httpTransport {
+	t := &httpTransport{
+		config:   config,
+		options:  options,
+		sessions: make(map[string]*httpSession),
+		done:     make(chan struct{}),
+	}
+	go t.sweepIdle(sessionIdleTimeout / 6)
+	return t
+}
+
+// sweepIdle expires idle sessions every interval, so their servers, pollers
+// and file watchers are released even when no new clients connect
+func (t *httpTransport) sweepIdle(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			t.expireIdleLocked()
+			t.mu.Unlock()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// close stops the idle sweep and ends every session
+func (t *httpTransport) close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for id, session := range t.sessions {
+			delete(t.sessions, id)
+			session.close()
+		}
+	})
+}
+
+// ServeHTTP dispatches on the HTTP method
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t.options.Token != "" && !validToken(r, t.options.Token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !t.validOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r)
+	case http.MethodGet:
+		t.handleGet(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost processes one JSON-RPC message or a batch of them
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, maxBodySize)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body larger than %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	requests, err := decodeMessages(body.Bytes())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   &JSONRPCError{Code: -32700, Message: "Parse error", Data: err.Error()},
+		})
+		return
+	}
+
+	session, status := t.sessionFor(r, requests)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set(sessionHeader, session.id)
+
+	// Notifications and client responses are accepted without a body
+	var expected []string
+	for _, req := range requests {
+		if req.ID != nil && req.Method != "" {
+			expected = append(expected, idKey(req.ID))
+		}
+	}
+	if len(expected) == 0 {
+		for _, req := range requests {
+			if req.Method == "notifications/cancelled" {
+				session.releaseCancelled(req)
+			}
+			session.server.handleRequest(req)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	stream := session.openStream(expected)
+	defer session.closeStream(stream)
+
+	go func() {
+		for _, req := range requests {
+			session.server.handleRequest(req)
+		}
+	}()
+
+	if acceptsSSE(r) {
+		t.streamResponses(w, r, stream, len(expected))
+	} else {
+		t.collectResponses(w, r, stream, len(expected))
+	}
+}
+
+// streamResponses writes messages for a POST as server-sent events until
+// every request in it has been answered
+func (t *httpTransport) streamResponses(w http.ResponseWriter, r *http.Request, stream *messageStream, expected int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.collectResponses(w, r, stream, expected)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for expected > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-stream.messages:
+			if msg.data != nil {
+				writeEvent(w, msg.data)
+				flusher.Flush()
+			}
+			if msg.response {
+				expected--
+			}
+		}
+	}
+}
+
+// collectResponses waits for every response of a POST and writes them as a
+// single JSON body. Notifications produced meanwhile are dropped.
+func (t *httpTransport) collectResponses(w http.ResponseWriter, r *http.Request, stream *messageStream, expected int) {
+	var responses []json.RawMessage
+	for expected > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-stream.messages:
+			if msg.response {
+				expected--
+				if msg.data != nil {
+					responses = append(responses, msg.data)
+				}
+			}
+		}
+	}
+
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if len(responses) == 1 {
+		w.Write(responses[0])
+		return
+	}
+	data, _ := json.Marshal(responses)
+	w.Write(data)
+}
+
+// handleGet opens a standalone SSE stream for server-initiated messages
+func (t *httpTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsSSE(r) {
+		http.Error(w, "Client must accept text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	session, status := t.lookup(r)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	stream := session.openStandalone()
+	defer session.closeStream(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set(sessionHeader, session.id)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stream.done:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case msg := <-stream.messages:
+			writeEvent(w, msg.data)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleDelete terminates a session
+func (t *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, status := t.lookup(r)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.sessions, session.id)
+	t.mu.Unlock()
+
+	session.close()
+	debugLog("Session %s terminated by client", session.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionFor returns the session a POST belongs to, creating a new one for
+// an initialize request. When no session can be used, the HTTP status to
+// reply with is returned instead.
+func (t *httpTransport) sessionFor(r *http.Request, requests []*JSONRPCRequest) (*httpSession, int) {
+	if r.Header.Get(sessionHeader) != "" {
+		return t.lookup(r)
+	}
+
+	if len(requests) != 1 || requests[0].Method != "initialize" {
+		return nil, http.StatusBadRequest
+	}
+
+	session := newHTTPSession(t.config)
+
+	t.mu.Lock()
+	t.sessions[session.id] = session
+	t.mu.Unlock()
+
+	debugLog("Session %s created", session.id)
+	return session, http.StatusOK
+}
+
+// lookup finds the session named in the request header
+func (t *httpTransport) lookup(r *http.Request) (*httpSession, int) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest
+	}
+	// Clients of older revisions don't send the header
+	if version := r.Header.Get(protocolVersionHeader); version != "" && !isSupportedProtocolVersion(version) {
+		return nil, http.StatusBadRequest
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session, ok := t.sessions[id]
+	if !ok {
+		return nil, http.StatusNotFound
+	}
+	session.touch()
+	return session, http.StatusOK
+}
+
+// expireIdleLocked drops sessions that have not been used recently. The
+// caller must hold t.mu.
+func (t *httpTransport) expireIdleLocked() {
+	for id, session := range t.sessions {
+		if session.idleSince() > sessionIdleTimeout {
+			delete(t.sessions, id)
+			session.close()
+			debugLog("Session %s expired", id)
+		}
+	}
+}
+
+// =============================================================================
+// Sessions
+// =============================================================================
+
+// httpSession is one MCP session. It owns an MCPServer whose writer routes
+// responses back to the POST that carried the request, and notifications to
+// the standalone GET stream (or the most recent POST stream).
+type httpSession struct {
+	id     string
+	server *MCPServer
+
+	mu         sync.Mutex
+	lastUsed   time.Time
+	pending    map[string]*messageStream // request ID -> POST stream awaiting it
+	streams    []*messageStream          // open POST streams, oldest first
+	standalone *messageStream            // open GET stream, if any
+}
+
+// messageStream is a queue of outgoing messages for one HTTP response
+type messageStream struct {
+	messages chan outgoingMessage
+	done     chan struct{}
+	once     sync.Once
+}
+
+// outgoingMessage is a message on its way to the client
+type outgoingMessage struct {
+	data     json.RawMessage
+	response bool
+}
+
+// newHTTPSession creates a session with a fresh server instance
+func newHTTPSession(config Config) *httpSession {
+	session := &httpSession{
+		id:       uuid.NewString(),
+		lastUsed: time.Now(),
+		pending:  make(map[string]*messageStream),
+	}
+	session.server = NewMCPServer(nil, session, config)
+	return session
+}
+
+// Write receives one newline-terminated JSON-RPC message from the server and
+// routes it to the right stream
+func (h *httpSession) Write(p []byte) (int, error) {
+	data := json.RawMessage(bytes.TrimSpace(append([]byte(nil), p...)))
+
+	var envelope struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return 0, err
+	}
+	isResponse := envelope.Method == "" && len(envelope.ID) > 0
+
+	h.mu.Lock()
+	var target *messageStream
+	if isResponse {
+		key := string(envelope.ID)
+		target = h.pending[key]
+		delete(h.pending, key)
+	} else if h.standalone != nil {
+		target = h.standalone
+	} else if len(h.streams) > 0 {
+		target = h.streams[len(h.streams)-1]
+	}
+	h.mu.Unlock()
+
+	if target == nil {
+		debugLog("Session %s: no open stream, dropping message", h.id)
+		return len(p), nil
+	}
+	target.deliver(outgoingMessage{data: data, response: isResponse})
+	return len(p), nil
+}
+
+// releaseCancelled stops a POST stream from waiting for the response to a
+// cancelled request, since the server will not send one
+func (h *httpSession) releaseCancelled(req *JSONRPCRequest) {
+	var params struct {
+		RequestID interface{} `json:"requestId"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return
+	}
+
+	key := idKey(params.RequestID)
+	h.mu.Lock()
+	stream := h.pending[key]
+	delete(h.pending, key)
+	h.mu.Unlock()
+
+	if stream != nil {
+		stream.deliver(outgoingMessage{response: true})
+	}
+}
+
+// openStream registers a POST stream waiting for the given request IDs
+func (h *httpSession) openStream(ids []string) *messageStream {
+	stream := newMessageStream()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, id := range ids {
+		h.pending[id] = stream
+	}
+	h.streams = append(h.streams, stream)
+	return stream
+}
+
+// openStandalone registers the GET stream, replacing any previous one
+func (h *httpSession) openStandalone() *messageStream {
+	stream := newMessageStream()
+
+	h.mu.Lock()
+	previous := h.standalone
+	h.standalone = stream
+	h.mu.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+	return stream
+}
+
+// closeStream unregisters a stream once its HTTP response has finished
+func (h *httpSession) closeStream(stream *messageStream) {
+	h.mu.Lock()
+	for id, s := range h.pending {
+		if s == stream {
+			delete(h.pending, id)
+		}
+	}
+	for i, s := range h.streams {
+		if s == stream {
+			h.streams = append(h.streams[:i], h.streams[i+1:]...)
+			break
+		}
+	}
+	if h.standalone == stream {
+		h.standalone = nil
+	}
+	h.lastUsed = time.Now()
+	h.mu.Unlock()
+
+	stream.close()
+}
+
+// close shuts down the session's server and open streams
+func (h *httpSession) close() {
+	h.server.Close()
+
+	h.mu.Lock()
+	streams := append([]*messageStream(nil), h.streams...)
+	if h.standalone != nil {
+		streams = append(streams, h.standalone)
+	}
+	h.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.close()
+	}
+}
+
+// touch records that the session was just used
+func (h *httpSession) touch() {
+	h.mu.Lock()
+	h.lastUsed = time.Now()
+	h.mu.Unlock()
+}
+
+// idleSince returns how long the session has had no traffic
+func (h *httpSession) idleSince() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.streams) > 0 || h.standalone != nil {
+		return 0
+	}
+	return time.Since(h.lastUsed)
+}
+
+// newMessageStream creates an empty stream
+func newMessageStream() *messageStream {
+	return &messageStream{
+		messages: make(chan outgoingMessage, streamBuffer),
+		done:     make(chan struct{}),
+	}
+}
+
+// deliver queues a message. Responses wait for room in the queue;
+// notifications are dropped when the client isn't keeping up.
+func (m *messageStream) deliver(msg outgoingMessage) {
+	if msg.response {
+		select {
+		case m.messages <- msg:
+		case <-m.done:
+		}
+		return
+	}
+
+	select {
+	case m.messages <- msg:
+	case <-m.done:
+	default:
+		debugLog("Stream full, dropping notification")
+	}
+}
+
+// close marks the stream as finished
+func (m *messageStream) close() {
+	m.once.Do(func() { close(m.done) })
+}
+
+// =============================================================================
+// Helpers
+// =============================================================================
+
+// decodeMessages parses a POST body holding a single message or a batch
+func decodeMessages(body []byte) ([]*JSONRPCRequest, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []*JSONRPCRequest
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return nil, fmt.Errorf("empty batch")
+		}
+		return batch, nil
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return []*JSONRPCRequest{&req}, nil
+}
+
+// acceptsSSE reports whether the client accepts text/event-stream responses
+func acceptsSSE(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// validOrigin guards against DNS rebinding. A rebound page has the same
+// Origin as the Host it talks to, so Origin must be localhost or listed in
+// --allowed-origin. Requests without an Origin come from non-browser clients
+// (or same-origin GETs); without a token they must be addressed to a
+// loopback name, which a rebound page's Host never is.
+func (t *httpTransport) validOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return t.options.Token != "" || isLoopbackHost(r.Host)
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if isLoopbackHost(u.Host) {
+		return true
+	}
+	for _, allowed := range t.options.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), u.Scheme+"://"+u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// validToken checks the bearer token in constant time
+func validToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// isLoopbackHost reports whether host (with an optional port) names this
+// machine: localhost or a loopback IP
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local
+// connections; ":8090" listens on every interface
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && host != "" && isLoopbackHost(host)
+}
+
+// writeEvent writes a single SSE message event
+func writeEvent(w http.ResponseWriter, data []byte) {
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+}
+
+// writeJSON writes a JSON body with the given status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,273 @@
+// =============================================================================
+// Lint Tool
+// =============================================================================
+// Runs the linters that catch what validation doesn't: naming conventions,
+// deprecated arguments, invalid SKUs and other provider-specific mistakes.
+//
+//   - Terraform: tflint with the azurerm ruleset. A .tflint.hcl in the
+//     directory is used as-is; otherwise a default config enabling the
+//     azurerm plugin is generated.
+//   - Bicep:     the Bicep linter, configured by bicepconfig.json.
+//
+// Results are normalized into the same Diagnostic shape as the validation
+// tools. Linters that aren't installed are skipped with an info diagnostic.
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tflintConfigFile is the config file tflint reads from the working directory
+const tflintConfigFile = ".tflint.hcl"
+
+// tflintDefaultConfig enables the azurerm ruleset when the directory has no
+// .tflint.hcl of its own
+const tflintDefaultConfig = `plugin "terraform" {
+  enabled = true
+  preset  = "recommended"
+}
+
+plugin "azurerm" {
+  enabled = true
+  version = "0.27.0"
+  source  = "github.com/terraform-linters/tflint-ruleset-azurerm"
+}
+`
+
+// tflintBaseConfig is used when the azurerm plugin can't be installed
+const tflintBaseConfig = `plugin "terraform" {
+  enabled = true
+  preset  = "recommended"
+}
+`
+
+// handleLintIaC lints Terraform and/or Bicep code at path
+func (s *MCPServer) handleLintIaC(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+	codeType, _ := args["type"].(string)
+	codeType = strings.ToLower(codeType)
+	if codeType != "" && codeType != "terraform" && codeType != "bicep" {
+		return nil, fmt.Errorf("unsupported type: %s (use 'terraform' or 'bicep')", codeType)
+	}
+
+	absPath, err := s.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %s", absPath)
+	}
+
+	// Work out what to lint: Terraform is linted per directory, Bicep per file
+	dir := absPath
+	var terraformDir string
+	var bicepFiles []string
+	if info.IsDir() {
+		if codeType != "bicep" {
+			tfFiles, err := walkIaCFiles(dir, false, map[string]bool{".tf": true})
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan directory: %w", err)
+			}
+			if len(tfFiles) > 0 {
+				terraformDir = dir
+			}
+		}
+		if codeType != "terraform" {
+			if bicepFiles, err = walkIaCFiles(dir, false, map[string]bool{".bicep": true}); err != nil {
+				return nil, fmt.Errorf("failed to scan directory: %w", err)
+			}
+		}
+	} else {
+		dir = filepath.Dir(absPath)
+		switch formatTypeFor(absPath) {
+		case "terraform":
+			terraformDir = dir
+		case "bicep":
+			bicepFiles = []string{filepath.Base(absPath)}
+		}
+	}
+	if terraformDir == "" && len(bicepFiles) == 0 {
+		return nil, fmt.Errorf("no Terraform or Bicep files found at %s", absPath)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🧹 Linting: %s\n\n", absPath))
+
+	reporter := reporterFrom(ctx)
+	total := len(bicepFiles)
+	if terraformDir != "" {
+		total += 2
+	}
+	step := 0
+
+	var diags []Diagnostic
+	if terraformDir != "" {
+		terraformDiags, err := lintTerraform(ctx, terraformDir, reporter, &step, total)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, terraformDiags...)
+	}
+	if len(bicepFiles) > 0 {
+		bicepDiags, err := lintBicep(ctx, dir, bicepFiles, reporter, &step, total)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, bicepDiags...)
+	}
+
+	report := newDiagnosticsReport("lint_iac", diags)
+	switch {
+	case !report.Valid:
+		output.WriteString(fmt.Sprintf("❌ **%d error(s), %d warning(s)**\n\n", report.ErrorCount, report.WarningCount))
+	case report.WarningCount > 0:
+		output.WriteString(fmt.Sprintf("⚠️ **%d warning(s)**\n\n", report.WarningCount))
+	default:
+		output.WriteString("✅ **No lint issues found!**\n\n")
+	}
+	writeDiagnostics(&output, diags)
+	return diagnosticsResult(output.String(), report), nil
+}
+
+// lintTerraform runs tflint in dir. step is advanced for each progress phase.
+func lintTerraform(ctx context.Context, dir string, reporter *callReporter, step *int, total int) ([]Diagnostic, error) {
+	if _, err := exec.LookPath("tflint"); err != nil {
+		*step += 2
+		return []Diagnostic{{
+			Severity: severityInfo,
+			Code:     "tflint",
+			Summary:  "tflint not found, Terraform linting skipped",
+			Detail:   "Install tflint: https://github.com/terraform-linters/tflint",
+		}}, nil
+	}
+
+	var diags []Diagnostic
+	var configArgs []string
+
+	if _, err := os.Stat(filepath.Join(dir, tflintConfigFile)); err != nil {
+		// No project config: lint with the azurerm ruleset from a temp config
+		configDir, err := os.MkdirTemp("", "iac-tflint-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(configDir)
+
+		configPath := filepath.Join(configDir, tflintConfigFile)
+		if err := os.WriteFile(configPath, []byte(tflintDefaultConfig), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write tflint config: %w", err)
+		}
+		configArgs = []string{"--config=" + configPath}
+
+		*step++
+		reporter.progress(*step, total, "Installing tflint plugins")
+		initCmd := commandContext(ctx, "tflint", "--init", "--config="+configPath)
+		initCmd.Dir = dir
+		if out, err := runCommand(ctx, initCmd); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// Most likely offline: lint without the azurerm rules
+			if err := os.WriteFile(configPath, []byte(tflintBaseConfig), 0644); err != nil {
+				return nil, fmt.Errorf("failed to write tflint config: %w", err)
+			}
+			diags = append(diags, Diagnostic{
+				Severity: severityInfo,
+				Code:     "tflint",
+				Summary:  "azurerm ruleset could not be installed, only core Terraform rules were checked",
+				Detail:   strings.TrimSpace(string(out)),
+			})
+		}
+	} else {
+		*step++
+		reporter.progress(*step, total, "Installing tflint plugins")
+		initCmd := commandContext(ctx, "tflint", "--init")
+		initCmd.Dir = dir
+		runCommand(ctx, initCmd)
+	}
+
+	*step++
+	reporter.progress(*step, total, "Running tflint")
+	cmd := commandContext(ctx, "tflint", append([]string{"--format=json", "--no-color"}, configArgs...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, runErr := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// tflint exits non-zero when it finds issues, so only fail on bad output
+	lintDiags, err := parseTFLintJSON(out)
+	if err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" && runErr != nil {
+			detail = runErr.Error()
+		}
+		return append(diags, Diagnostic{
+			Severity: severityError,
+			Code:     "tflint",
+			Summary:  "tflint failed",
+			Detail:   detail,
+		}), nil
+	}
+	return append(diags, lintDiags...), nil
+}
+
+// lintBicep runs the Bicep linter on each of files (relative to dir). step
+// is advanced for each file.
+func lintBicep(ctx context.Context, dir string, files []string, reporter *callReporter, step *int, total int) ([]Diagnostic, error) {
+	if !bicepCLIAvailable() {
+		*step += len(files)
+		return []Diagnostic{{
+			Severity: severityInfo,
+			Code:     "bicep",
+			Summary:  "Bicep CLI not found, Bicep linting skipped",
+			Detail:   "Install the Bicep CLI or the Azure CLI: " + bicepInstallURL,
+		}}, nil
+	}
+
+	var diags []Diagnostic
+	for _, file := range files {
+		cmd, label := bicepCommand(ctx, "lint", filepath.Join(dir, file), "--diagnostics-format", "sarif")
+		*step++
+		reporter.progress(*step, total, fmt.Sprintf("Running %s on %s", label, file))
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, runErr := cmd.Output()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// The linter exits non-zero when it reports errors. Older CLIs without
+		// SARIF support print diagnostics as text instead.
+		fileDiags, err := parseSARIF(out, dir)
+		if err != nil {
+			text := string(out) + "\n" + stderr.String()
+			fileDiags = parseBicepOutput(text, dir)
+			if len(fileDiags) == 0 && runErr != nil {
+				fileDiags = []Diagnostic{{
+					Severity: severityError,
+					Code:     "bicep",
+					File:     file,
+					Summary:  label + " failed",
+					Detail:   strings.TrimSpace(text),
+				}}
+			}
+		}
+		diags = append(diags, fileDiags...)
+	}
+	return diags, nil
+}
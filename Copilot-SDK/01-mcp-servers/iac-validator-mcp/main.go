@@ -6,34 +6,45 @@
 //
 // Usage:
 //   go build -o iac-validator .
-//   ./iac-validator
+//   ./iac-validator                                # stdio (default)
+//   ./iac-validator --transport http               # Streamable HTTP on 127.0.0.1:8090
 //
-// The server communicates via stdio using JSON-RPC 2.0 protocol.
+// The server communicates using the JSON-RPC 2.0 protocol, either over stdio
+// or over the MCP Streamable HTTP transport (see http.go).
 // =============================================================================
 
 package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // =============================================================================
 // MCP Protocol Types
 // =============================================================================
 
-// JSONRPCRequest represents an incoming JSON-RPC 2.0 request
+// JSONRPCRequest represents an incoming JSON-RPC 2.0 request. Responses from
+// the client to server-initiated requests arrive in the same shape, with
+// Result or Error set instead of Method.
 type JSONRPCRequest struct {
 	JSONRPC string          `json:"jsonrpc"`
 	ID      interface{}     `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
 }
 
 // JSONRPCResponse represents an outgoing JSON-RPC 2.0 response
@@ -51,9 +62,19 @@ type JSONRPCError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// JSONRPCNotification represents an outgoing JSON-RPC 2.0 notification
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // MCPCapabilities defines what the server can do
 type MCPCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
+	Logging   *LoggingCapability   `json:"logging,omitempty"`
 }
 
 // ToolsCapability indicates tool support
@@ -74,11 +95,14 @@ type ServerInfo struct {
 	Version string `json:"version"`
 }
 
-// Tool represents an MCP tool definition
+// Tool represents an MCP tool definition. Handler runs the tool and is not
+// sent to the client.
 type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	InputSchema InputSchema `json:"inputSchema"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	InputSchema  InputSchema            `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+	Handler      ToolHandler            `json:"-"`
 }
 
 // InputSchema defines the JSON Schema for tool inputs
@@ -90,9 +114,10 @@ type InputSchema struct {
 
 // Property defines a single property in the input schema
 type Property struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description"`
-	Enum        []string `json:"enum,omitempty"`
+	Type                 string      `json:"type"`
+	Description          string      `json:"description"`
+	Enum                 []string    `json:"enum,omitempty"`
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
 }
 
 // ToolsListResult contains the list of available tools
@@ -104,12 +129,14 @@ type ToolsListResult struct {
 type ToolCallParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
 }
 
 // ToolCallResult contains the result of a tool invocation
 type ToolCallResult struct {
-	Content []ContentBlock `json:"content"`
-	IsError bool           `json:"isError,omitempty"`
+	Content           []ContentBlock `json:"content"`
+	StructuredContent interface{}    `json:"structuredContent,omitempty"`
+	IsError           bool           `json:"isError,omitempty"`
 }
 
 // ContentBlock represents a content block in tool results
@@ -122,23 +149,90 @@ type ContentBlock struct {
 // MCP Server
 // =============================================================================
 
+// Config holds the server settings that can be set from the command line
+type Config struct {
+	// ToolTimeout bounds how long a single tool call may run
+	ToolTimeout time.Duration
+
+	// Roots restricts path arguments to these directories (see roots.go)
+	Roots []string
+
+	// CacheDir holds cached validation results; empty disables the cache
+	// (see cache.go)
+	CacheDir string
+
+	// ToolsFile declares extra command-backed tools; it is reloaded when it
+	// changes (see toolregistry.go)
+	ToolsFile string
+}
+
+// defaultToolTimeout is used when Config.ToolTimeout is not set
+const defaultToolTimeout = 5 * time.Minute
+
 // MCPServer handles MCP protocol communication
 type MCPServer struct {
-	reader *bufio.Reader
-	writer io.Writer
-	tools  map[string]ToolHandler
+	reader  *bufio.Reader
+	writer  io.Writer
+	writeMu sync.Mutex
+	tools   *toolRegistry
+	config  Config
+
+	// inFlight holds the cancel functions of running tool calls by request ID
+	inFlightMu sync.Mutex
+	inFlight   map[string]context.CancelFunc
+	calls      sync.WaitGroup
+
+	// logLevel is the minimum level of notifications/message sent to the client
+	logMu    sync.Mutex
+	logLevel string
+
+	// Workspace roots path arguments are restricted to (see roots.go)
+	rootsMu     sync.Mutex
+	defaultRoot string
+	clientRoots []string
+	clientCaps  ClientCapabilities
+
+	// outgoing tracks requests sent to the client (see client.go)
+	outgoingMu sync.Mutex
+	outgoing   clientRequests
+
+	resources *resourceWatcher
+	cache     *resultCache
 }
 
-// ToolHandler is a function that handles a tool invocation
-type ToolHandler func(args map[string]interface{}) (*ToolCallResult, error)
+// ToolHandler is a function that handles a tool invocation. ctx is cancelled
+// when the client cancels the request or the tool timeout expires; handlers
+// pass it to any child process they start.
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error)
+
+// NewMCPServer creates a new MCP server instance that reads requests from
+// reader and writes responses and notifications to writer. reader may be nil
+// when requests are delivered directly through handleRequest.
+func NewMCPServer(reader io.Reader, writer io.Writer, config Config) *MCPServer {
+	root, err := os.Getwd()
+	if err != nil {
+		root = "."
+	}
+	if real := canonicalRoots([]string{root}); len(real) == 1 {
+		root = real[0]
+	}
+	if config.ToolTimeout <= 0 {
+		config.ToolTimeout = defaultToolTimeout
+	}
+	config.Roots = canonicalRoots(config.Roots)
 
-// NewMCPServer creates a new MCP server instance
-func NewMCPServer() *MCPServer {
 	server := &MCPServer{
-		reader: bufio.NewReader(os.Stdin),
-		writer: os.Stdout,
-		tools:  make(map[string]ToolHandler),
+		reader:      bufio.NewReader(reader),
+		writer:      writer,
+		tools:       newToolRegistry(),
+		config:      config,
+		inFlight:    make(map[string]context.CancelFunc),
+		logLevel:    defaultLogLevel,
+		defaultRoot: root,
+		outgoing:    clientRequests{pending: make(map[string]chan *JSONRPCRequest)},
 	}
+	server.resources = newResourceWatcher(server)
+	server.cache = newResultCache(config.CacheDir)
 
 	// Register tools
 	server.registerTools()
@@ -146,12 +240,25 @@ func NewMCPServer() *MCPServer {
 	return server
 }
 
-// registerTools sets up all available tools
+// Close cancels running tool calls and releases background resources
+func (s *MCPServer) Close() {
+	s.inFlightMu.Lock()
+	for _, cancel := range s.inFlight {
+		cancel()
+	}
+	s.inFlightMu.Unlock()
+
+	s.resources.stop()
+	s.tools.stop()
+}
+
+// registerTools sets up the built-in tools and the tools declared in
+// Config.ToolsFile
 func (s *MCPServer) registerTools() {
-	s.tools["validate_terraform"] = s.handleValidateTerraform
-	s.tools["validate_bicep"] = s.handleValidateBicep
-	s.tools["check_iac_syntax"] = s.handleCheckSyntax
-	s.tools["list_iac_files"] = s.handleListIaCFiles
+	s.tools.setBuiltin(s.builtinTools())
+	if s.config.ToolsFile != "" {
+		s.watchToolsFile()
+	}
 }
 
 // Run starts the server and processes requests
@@ -163,7 +270,8 @@ func (s *MCPServer) Run() error {
 		line, err := s.reader.ReadString('\n')
 		if err != nil {
 			if err == io.EOF {
-				debugLog("EOF received, shutting down")
+				debugLog("EOF received, waiting for running tool calls")
+				s.calls.Wait()
 				return nil
 			}
 			return fmt.Errorf("read error: %w", err)
@@ -190,33 +298,105 @@ func (s *MCPServer) Run() error {
 
 // handleRequest routes requests to appropriate handlers
 func (s *MCPServer) handleRequest(req *JSONRPCRequest) {
+	if req.Method == "" && req.ID != nil {
+		s.handleClientResponse(req)
+		return
+	}
+
 	debugLog("Handling method: %s", req.Method)
 
 	switch req.Method {
 	case "initialize":
 		s.handleInitialize(req)
-	case "initialized":
+	case "initialized", "notifications/initialized":
 		// Notification, no response needed
 		debugLog("Client initialized")
+		if s.clientCaps.Roots != nil {
+			go s.refreshRoots()
+		}
+	case "notifications/roots/list_changed":
+		go s.refreshRoots()
+	case "notifications/cancelled":
+		s.handleCancelled(req)
 	case "tools/list":
 		s.handleToolsList(req)
 	case "tools/call":
 		s.handleToolsCall(req)
+	case "resources/list":
+		s.handleResourcesList(req)
+	case "resources/read":
+		s.handleResourcesRead(req)
+	case "resources/subscribe":
+		s.handleResourcesSubscribe(req)
+	case "resources/unsubscribe":
+		s.handleResourcesUnsubscribe(req)
+	case "prompts/list":
+		s.handlePromptsList(req)
+	case "prompts/get":
+		s.handlePromptsGet(req)
+	case "logging/setLevel":
+		s.handleSetLogLevel(req)
 	case "ping":
 		s.sendResult(req.ID, map[string]string{})
 	default:
+		if req.ID == nil {
+			// Unknown notifications are ignored, they never get a response
+			debugLog("Ignoring notification: %s", req.Method)
+			return
+		}
 		s.sendError(req.ID, -32601, "Method not found", req.Method)
 	}
 }
 
+// supportedProtocolVersions lists the MCP revisions the server speaks, newest
+// first. structuredContent and outputSchema need 2025-06-18.
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// negotiateProtocolVersion answers the client's requested revision with the
+// same one when it is supported, and with the newest one otherwise
+func negotiateProtocolVersion(requested string) string {
+	if isSupportedProtocolVersion(requested) {
+		return requested
+	}
+	return supportedProtocolVersions[0]
+}
+
+// isSupportedProtocolVersion reports whether version is in
+// supportedProtocolVersions
+func isSupportedProtocolVersion(version string) bool {
+	for _, supported := range supportedProtocolVersions {
+		if version == supported {
+			return true
+		}
+	}
+	return false
+}
+
 // handleInitialize processes the initialize request
 func (s *MCPServer) handleInitialize(req *JSONRPCRequest) {
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.sendError(req.ID, -32602, "Invalid params", err.Error())
+			return
+		}
+	}
+	s.clientCaps = params.Capabilities
+
 	result := InitializeResult{
-		ProtocolVersion: "2024-11-05",
+		ProtocolVersion: negotiateProtocolVersion(params.ProtocolVersion),
 		Capabilities: MCPCapabilities{
 			Tools: &ToolsCapability{
+				ListChanged: s.config.ToolsFile != "",
+			},
+			Resources: &ResourcesCapability{
+				Subscribe:   true,
+				ListChanged: false,
+			},
+			Prompts: &PromptsCapability{
 				ListChanged: false,
 			},
+			Logging: &LoggingCapability{},
 		},
 		ServerInfo: ServerInfo{
 			Name:    "iac-validator-mcp",
@@ -226,12 +406,13 @@ func (s *MCPServer) handleInitialize(req *JSONRPCRequest) {
 	s.sendResult(req.ID, result)
 }
 
-// handleToolsList returns the list of available tools
-func (s *MCPServer) handleToolsList(req *JSONRPCRequest) {
-	tools := []Tool{
+// builtinTools declares the tools implemented by the server itself. Each entry
+// carries its handler, so the definition and the implementation can't drift.
+func (s *MCPServer) builtinTools() []Tool {
+	return []Tool{
 		{
 			Name:        "validate_terraform",
-			Description: "Validate Terraform configuration files in a directory. Runs 'terraform init' (if needed) and 'terraform validate' to check for syntax and configuration errors.",
+			Description: "Validate Terraform configuration files in a directory. Runs 'terraform init' (if needed) and 'terraform validate' to check for syntax and configuration errors, or parses the HCL in-process when the terraform CLI is not available.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -239,9 +420,16 @@ func (s *MCPServer) handleToolsList(req *JSONRPCRequest) {
 						Type:        "string",
 						Description: "Path to the Terraform directory or file to validate. Can be absolute or relative to the workspace.",
 					},
+					"mode": {
+						Type:        "string",
+						Description: "Validation mode: 'hcl' parses in-process without the terraform CLI, 'deep' runs terraform init and validate, 'auto' uses deep when terraform is installed. Defaults to auto.",
+						Enum:        []string{"auto", "hcl", "deep"},
+					},
 				},
 				Required: []string{"path"},
 			},
+			OutputSchema: diagnosticsOutputSchema,
+			Handler:      s.handleValidateTerraform,
 		},
 		{
 			Name:        "validate_bicep",
@@ -256,25 +444,43 @@ func (s *MCPServer) handleToolsList(req *JSONRPCRequest) {
 				},
 				Required: []string{"path"},
 			},
+			OutputSchema: diagnosticsOutputSchema,
+			Handler:      s.handleValidateBicep,
 		},
 		{
 			Name:        "check_iac_syntax",
-			Description: "Quick syntax check for IaC code snippets. Validates Terraform HCL or Bicep syntax without requiring a full project structure.",
+			Description: "Quick syntax check for IaC code snippets. Validates Terraform HCL or Bicep syntax without requiring a full project structure. Snippets can span several files (files) and can be checked in the context of an existing module (base_path), including local modules it calls.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"code": {
 						Type:        "string",
-						Description: "The IaC code to validate.",
+						Description: "The IaC code to validate. Checked as main.tf/main.bicep, or as snippet.tf/snippet.bicep when base_path is set.",
+					},
+					"files": {
+						Type:                 "object",
+						Description:          "Map of file name to content, for snippets that span several files, e.g. {\"main.tf\": \"...\", \"variables.tf\": \"...\"}. Names may include subdirectories such as modules/storage/main.tf.",
+						AdditionalProperties: map[string]interface{}{"type": "string"},
+					},
+					"base_path": {
+						Type:        "string",
+						Description: "Optional directory whose files the snippet is overlaid on, so references to its variables, locals and local modules resolve. Files in files or code replace base files with the same name.",
 					},
 					"type": {
 						Type:        "string",
 						Description: "The type of IaC code.",
 						Enum:        []string{"terraform", "bicep"},
 					},
+					"mode": {
+						Type:        "string",
+						Description: "Terraform only: 'hcl' parses in-process without the terraform CLI, 'deep' runs terraform fmt and validate, 'auto' uses deep when terraform is installed. Defaults to auto.",
+						Enum:        []string{"auto", "hcl", "deep"},
+					},
 				},
-				Required: []string{"code", "type"},
+				Required: []string{"type"},
 			},
+			OutputSchema: diagnosticsOutputSchema,
+			Handler:      s.handleCheckSyntax,
 		},
 		{
 			Name:        "list_iac_files",
@@ -293,10 +499,265 @@ func (s *MCPServer) handleToolsList(req *JSONRPCRequest) {
 				},
 				Required: []string{"path"},
 			},
+			Handler: s.handleListIaCFiles,
+		},
+		{
+			Name:        "plan_terraform",
+			Description: "Run 'terraform plan' against a temporary local backend and summarize the plan: resources to add, change, replace and destroy, replacement reasons and changed attributes. Sensitive values are masked. The configuration's real backend and state are never touched; pass state_path to plan against a copy of an existing local state instead of an empty one.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to the Terraform directory to plan.",
+					},
+					"var_file": {
+						Type:        "string",
+						Description: "Optional .tfvars file with input variable values, relative to the Terraform directory or absolute.",
+					},
+					"state_path": {
+						Type:        "string",
+						Description: "Optional local state file, or directory containing terraform.tfstate, to start the plan from. It is copied and never written to. Relative to the Terraform directory or absolute.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: planSummaryOutputSchema,
+			Handler:      s.handlePlanTerraform,
+		},
+		{
+			Name:        "diff_bicep",
+			Description: "Compile two versions of a Bicep file to ARM templates and show a semantic, what-if style diff: added and removed resources, changed properties and apiVersions, parameter and output changes. Compare two files with compare_path, or two git revisions of one file with base_ref (and optionally head_ref). No Azure subscription is needed.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to the Bicep file. With compare_path it is the 'before' side; with base_ref its working-tree version is the 'after' side unless head_ref is set.",
+					},
+					"compare_path": {
+						Type:        "string",
+						Description: "Path to a second Bicep file to use as the 'after' side.",
+					},
+					"base_ref": {
+						Type:        "string",
+						Description: "Git revision (commit, branch or tag) of path to use as the 'before' side, e.g. HEAD or main.",
+					},
+					"head_ref": {
+						Type:        "string",
+						Description: "Optional git revision of path to use as the 'after' side. Defaults to the working tree.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: templateDiffOutputSchema,
+			Handler:      s.handleDiffBicep,
+		},
+		{
+			Name:        "format_iac",
+			Description: "Format Terraform (terraform fmt) or Bicep (bicep format) code. Returns the formatted code and a unified diff against the input. With path and write=true the file is rewritten in place.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"code": {
+						Type:        "string",
+						Description: "The code to format. Use either code or path.",
+					},
+					"path": {
+						Type:        "string",
+						Description: "Path to a .tf, .tfvars, .bicep or .bicepparam file to format. Use either code or path.",
+					},
+					"type": {
+						Type:        "string",
+						Description: "The type of IaC code. Required with code; inferred from the extension with path.",
+						Enum:        []string{"terraform", "bicep"},
+					},
+					"write": {
+						Type:        "boolean",
+						Description: "Write the formatted code back to path. Defaults to false.",
+					},
+				},
+			},
+			OutputSchema: formatResultOutputSchema,
+			Handler:      s.handleFormatIaC,
+		},
+		{
+			Name:        "lint_iac",
+			Description: "Lint Terraform with tflint (including the azurerm ruleset) and Bicep with the Bicep linter (configured by bicepconfig.json). Finds naming, deprecated-argument and provider-specific issues that validation misses. Linters that aren't installed are skipped.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to a directory, or to a .tf or .bicep file. Terraform is always linted per directory.",
+					},
+					"type": {
+						Type:        "string",
+						Description: "Only lint this type of code. Defaults to both.",
+						Enum:        []string{"terraform", "bicep"},
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: diagnosticsOutputSchema,
+			Handler:      s.handleLintIaC,
+		},
+		{
+			Name:        "list_state_resources",
+			Description: "List the resources in a local Terraform state file (terraform.tfstate, format version 4): addresses, providers and instance counts. Reads the file directly; no terraform CLI or backend access needed.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to the state file, or to a directory containing terraform.tfstate.",
+					},
+					"filter": {
+						Type:        "string",
+						Description: "Only list addresses containing this text, e.g. 'azurerm_subnet' or 'module.network'.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: stateSummaryOutputSchema,
+			Handler:      s.handleListStateResources,
+		},
+		{
+			Name:        "show_state_resource",
+			Description: "Show the attributes and dependencies of a resource in a local Terraform state file. Sensitive values are redacted.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to the state file, or to a directory containing terraform.tfstate.",
+					},
+					"address": {
+						Type:        "string",
+						Description: "Resource address, e.g. 'module.network.azurerm_subnet.app'. Without an instance key such as [0] or [\"web\"], all instances are shown.",
+					},
+				},
+				Required: []string{"path", "address"},
+			},
+			OutputSchema: stateResourceDetailOutputSchema,
+			Handler:      s.handleShowStateResource,
+		},
+		{
+			Name:        "find_state_orphans",
+			Description: "Find managed resources in a local Terraform state file that the configuration no longer declares, which the next plan would destroy. Undeclared data sources are listed separately, since Terraform only drops them from state. Follows local modules and honours moved and removed blocks.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to the state file, or to a directory containing terraform.tfstate.",
+					},
+					"config_path": {
+						Type:        "string",
+						Description: "Directory of the root module to compare against. Defaults to the directory of the state file.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: stateOrphanReportOutputSchema,
+			Handler:      s.handleFindStateOrphans,
+		},
+		{
+			Name:        "dependency_graph",
+			Description: "Build the dependency graph of a Terraform root module (resources, data sources, modules and the references between them) or of Bicep files (symbolic names). Returns Mermaid, DOT and JSON, detects dependency cycles and reports the longest dependency chain.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to a Terraform directory, a directory of Bicep files, or a single .bicep file.",
+					},
+					"type": {
+						Type:        "string",
+						Description: "Type of code to graph. Defaults to terraform when the directory contains .tf files, bicep otherwise.",
+						Enum:        []string{"terraform", "bicep"},
+					},
+					"format": {
+						Type:        "string",
+						Description: "Rendering shown in the text result. Defaults to Mermaid and DOT; structuredContent always holds all three.",
+						Enum:        []string{"mermaid", "dot", "json"},
+					},
+					"include_values": {
+						Type:        "boolean",
+						Description: "Also show variables/params, locals/vars and outputs as nodes. By default they are collapsed into direct edges.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: dependencyGraphOutputSchema,
+			Handler:      s.handleDependencyGraph,
+		},
+		{
+			Name:        "convert_iac",
+			Description: "Convert Bicep to Terraform (azurerm) or Terraform to Bicep. Maps resource groups, storage accounts, virtual networks and subnets, NSGs, Key Vault, AKS and App Service, including loops, conditions, parents and dependencies. Anything without a mapping is kept as a TODO comment that quotes the original code.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Path to a .bicep file, a .tf file or a Terraform directory to convert.",
+					},
+					"code": {
+						Type:        "string",
+						Description: "Inline code to convert instead of a path. Requires from.",
+					},
+					"from": {
+						Type:        "string",
+						Description: "Language of the source. Detected from the path when omitted.",
+						Enum:        []string{"bicep", "terraform"},
+					},
+				},
+			},
+			OutputSchema: conversionOutputSchema,
+			Handler:      s.handleConvertIaC,
+		},
+		{
+			Name:        "scan_secrets",
+			Description: "Scan Terraform, Bicep, .tfvars and .bicepparam files for hard-coded credentials: passwords and keys in secret-named attributes, Azure storage and Service Bus connection strings, account keys, SAS tokens, client secrets, private keys and other high-entropy strings. Values from variables, parameters and Key Vault references are ignored. Findings are reported by file and line with the value redacted.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Directory or single IaC file to scan.",
+					},
+					"recursive": {
+						Type:        "boolean",
+						Description: "Also scan subdirectories. Defaults to true.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: diagnosticsOutputSchema,
+			Handler:      s.handleScanSecrets,
+		},
+		{
+			Name:        "explain_errors",
+			Description: "Validate Terraform or Bicep code and ask the client's model (via MCP sampling) to explain each error and warning and propose a fix, using the surrounding source lines. Requires a client that supports sampling.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"path": {
+						Type:        "string",
+						Description: "Terraform directory, .tf file (validates its directory) or .bicep file.",
+					},
+				},
+				Required: []string{"path"},
+			},
+			OutputSchema: explanationOutputSchema,
+			Handler:      s.handleExplainErrors,
 		},
 	}
+}
 
-	s.sendResult(req.ID, ToolsListResult{Tools: tools})
+// handleToolsList returns the list of available tools
+func (s *MCPServer) handleToolsList(req *JSONRPCRequest) {
+	s.sendResult(req.ID, ToolsListResult{Tools: s.tools.list()})
 }
 
 // handleToolsCall processes a tool invocation
@@ -309,21 +770,111 @@ func (s *MCPServer) handleToolsCall(req *JSONRPCRequest) {
 
 	debugLog("Tool call: %s with args: %v", params.Name, params.Arguments)
 
-	handler, ok := s.tools[params.Name]
+	handler, ok := s.tools.lookup(params.Name)
 	if !ok {
 		s.sendError(req.ID, -32602, "Unknown tool", params.Name)
 		return
 	}
 
-	result, err := handler(params.Arguments)
-	if err != nil {
-		result = &ToolCallResult{
-			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s", err.Error())}},
-			IsError: true,
+	// Run the tool in the background so slow calls don't block other requests
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ToolTimeout)
+	reporter := &callReporter{server: s}
+	if params.Meta != nil {
+		reporter.progressToken = params.Meta.ProgressToken
+	}
+	ctx = withReporter(ctx, reporter)
+	key := idKey(req.ID)
+	s.trackCall(key, cancel)
+
+	s.calls.Add(1)
+	go func() {
+		defer s.calls.Done()
+		defer s.untrackCall(key)
+
+		result, err := handler(ctx, params.Arguments)
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			// The client no longer expects a response
+			debugLog("Tool call %s cancelled", params.Name)
+			return
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			result = &ToolCallResult{
+				Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("⏱️ Tool call timed out after %s", s.config.ToolTimeout)}},
+				IsError: true,
+			}
+		case errors.As(err, new(*PathNotAllowedError)):
+			s.sendError(req.ID, errCodePathNotAllowed, "Path not allowed", err.Error())
+			return
+		case err != nil:
+			result = &ToolCallResult{
+				Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s", err.Error())}},
+				IsError: true,
+			}
 		}
+
+		s.sendResult(req.ID, withStructuredText(result))
+	}()
+}
+
+// withStructuredText adds the serialized structuredContent as a text block,
+// so clients of protocol revisions before 2025-06-18, which ignore
+// structuredContent, still receive the data
+func withStructuredText(result *ToolCallResult) *ToolCallResult {
+	if result == nil || result.StructuredContent == nil {
+		return result
+	}
+	data, err := json.MarshalIndent(result.StructuredContent, "", "  ")
+	if err != nil {
+		debugLog("Failed to serialize structured content: %v", err)
+		return result
 	}
+	content := make([]ContentBlock, 0, len(result.Content)+1)
+	content = append(content, result.Content...)
+	content = append(content, ContentBlock{Type: "text", Text: string(data)})
 
-	s.sendResult(req.ID, result)
+	withText := *result
+	withText.Content = content
+	return &withText
+}
+
+// handleCancelled cancels a running tool call, killing any child process
+func (s *MCPServer) handleCancelled(req *JSONRPCRequest) {
+	var params struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason,omitempty"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		debugLog("Invalid cancel notification: %v", err)
+		return
+	}
+
+	s.inFlightMu.Lock()
+	cancel, ok := s.inFlight[idKey(params.RequestID)]
+	s.inFlightMu.Unlock()
+
+	if ok {
+		debugLog("Cancelling request %v: %s", params.RequestID, params.Reason)
+		cancel()
+	}
+}
+
+// trackCall records the cancel function of a running tool call
+func (s *MCPServer) trackCall(key string, cancel context.CancelFunc) {
+	s.inFlightMu.Lock()
+	s.inFlight[key] = cancel
+	s.inFlightMu.Unlock()
+}
+
+// untrackCall forgets a finished tool call and releases its context
+func (s *MCPServer) untrackCall(key string) {
+	s.inFlightMu.Lock()
+	cancel := s.inFlight[key]
+	delete(s.inFlight, key)
+	s.inFlightMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
 }
 
 // =============================================================================
@@ -331,16 +882,16 @@ func (s *MCPServer) handleToolsCall(req *JSONRPCRequest) {
 // =============================================================================
 
 // handleValidateTerraform validates Terraform files
-func (s *MCPServer) handleValidateTerraform(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *MCPServer) handleValidateTerraform(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	// Resolve path
-	absPath, err := filepath.Abs(path)
+	// Resolve path within the workspace roots
+	absPath, err := s.resolvePath(path)
 	if err != nil {
-		return nil, fmt.Errorf("invalid path: %w", err)
+		return nil, err
 	}
 
 	// Check if path exists
@@ -355,20 +906,39 @@ func (s *MCPServer) handleValidateTerraform(args map[string]interface{}) (*ToolC
 		dir = filepath.Dir(absPath)
 	}
 
+	mode, err := resolveTerraformMode(args)
+	if err != nil {
+		return nil, err
+	}
+
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("🔍 Validating Terraform in: %s\n\n", dir))
 
+	if mode == modeHCL {
+		return s.validateTerraformHCL(dir, &output)
+	}
+
 	// Check if terraform is installed
 	if _, err := exec.LookPath("terraform"); err != nil {
-		return &ToolCallResult{
-			Content: []ContentBlock{{
-				Type: "text",
-				Text: "❌ Terraform CLI not found. Please install Terraform: https://www.terraform.io/downloads",
-			}},
-			IsError: true,
-		}, nil
+		report := newDiagnosticsReport("validate_terraform", []Diagnostic{{
+			Severity: severityError,
+			Summary:  "Terraform CLI not found",
+			Detail:   "Install Terraform or use mode \"hcl\" for an in-process check.",
+		}})
+		return diagnosticsResult("❌ Terraform CLI not found. Please install Terraform: https://www.terraform.io/downloads", report), nil
 	}
 
+	key := cacheKey([]string{"validate_terraform", dir,
+		s.cache.toolVersion(ctx, "terraform", "version", "-json")}, terraformInputs(dir))
+	return s.cache.cached(ctx, key, func() (*ToolCallResult, bool, error) {
+		return s.validateTerraformDeep(ctx, dir, &output)
+	})
+}
+
+// validateTerraformDeep runs terraform init (if needed) and terraform validate
+// in dir. The result is cacheable only when init succeeded and validate
+// produced its -json report.
+func (s *MCPServer) validateTerraformDeep(ctx context.Context, dir string, output *strings.Builder) (*ToolCallResult, bool, error) {
 	// Check if already initialized
 	terraformDir := filepath.Join(dir, ".terraform")
 	needsInit := true
@@ -376,13 +946,23 @@ func (s *MCPServer) handleValidateTerraform(args map[string]interface{}) (*ToolC
 		needsInit = false
 	}
 
+	reporter := reporterFrom(ctx)
+	step, totalSteps := 0, 1
+	if needsInit {
+		totalSteps++
+	}
+	initFailed := false
+
 	// Run terraform init if needed
 	if needsInit {
+		step++
+		reporter.progress(step, totalSteps, "Running terraform init")
 		output.WriteString("📦 Running terraform init...\n")
-		cmd := exec.Command("terraform", "init", "-backend=false", "-no-color")
+		cmd := commandContext(ctx, "terraform", "init", "-backend=false", "-no-color")
 		cmd.Dir = dir
-		initOutput, err := cmd.CombinedOutput()
+		initOutput, err := runTerraformInit(ctx, cmd)
 		if err != nil {
+			initFailed = true
 			output.WriteString(fmt.Sprintf("⚠️ Init warning: %s\n", string(initOutput)))
 		} else {
 			output.WriteString("✅ Terraform initialized\n\n")
@@ -390,85 +970,54 @@ func (s *MCPServer) handleValidateTerraform(args map[string]interface{}) (*ToolC
 	}
 
 	// Run terraform validate
+	step++
+	reporter.progress(step, totalSteps, "Running terraform validate")
 	output.WriteString("🔎 Running terraform validate...\n\n")
-	cmd := exec.Command("terraform", "validate", "-json", "-no-color")
+	cmd := commandContext(ctx, "terraform", "validate", "-json", "-no-color")
 	cmd.Dir = dir
-	validateOutput, err := cmd.CombinedOutput()
+	validateOutput, err := runCommand(ctx, cmd)
 
 	// Parse JSON output
-	var validateResult struct {
-		Valid        bool `json:"valid"`
-		ErrorCount   int  `json:"error_count"`
-		WarningCount int  `json:"warning_count"`
-		Diagnostics  []struct {
-			Severity string `json:"severity"`
-			Summary  string `json:"summary"`
-			Detail   string `json:"detail"`
-			Range    *struct {
-				Filename string `json:"filename"`
-				Start    struct {
-					Line   int `json:"line"`
-					Column int `json:"column"`
-				} `json:"start"`
-			} `json:"range"`
-		} `json:"diagnostics"`
-	}
-
-	if jsonErr := json.Unmarshal(validateOutput, &validateResult); jsonErr != nil {
+	diags, jsonErr := parseTerraformValidateJSON(validateOutput)
+	if jsonErr != nil && err != nil {
 		// If JSON parsing fails, return raw output
-		if err != nil {
-			output.WriteString(fmt.Sprintf("❌ Validation failed:\n%s", string(validateOutput)))
-			return &ToolCallResult{
-				Content: []ContentBlock{{Type: "text", Text: output.String()}},
-				IsError: true,
-			}, nil
-		}
+		output.WriteString(fmt.Sprintf("❌ Validation failed:\n%s", string(validateOutput)))
+		report := newDiagnosticsReport("validate_terraform", []Diagnostic{{
+			Severity: severityError,
+			Summary:  "terraform validate failed",
+			Detail:   strings.TrimSpace(string(validateOutput)),
+		}})
+		return diagnosticsResult(output.String(), report), false, nil
 	}
 
-	if validateResult.Valid {
+	report := newDiagnosticsReport("validate_terraform", diags)
+	if report.Valid {
 		output.WriteString("✅ **Terraform configuration is valid!**\n\n")
 		output.WriteString(fmt.Sprintf("📊 Summary:\n"))
-		output.WriteString(fmt.Sprintf("   - Errors: %d\n", validateResult.ErrorCount))
-		output.WriteString(fmt.Sprintf("   - Warnings: %d\n", validateResult.WarningCount))
+		output.WriteString(fmt.Sprintf("   - Errors: %d\n", report.ErrorCount))
+		output.WriteString(fmt.Sprintf("   - Warnings: %d\n", report.WarningCount))
 	} else {
 		output.WriteString("❌ **Terraform validation failed**\n\n")
 		output.WriteString(fmt.Sprintf("📊 Found %d error(s) and %d warning(s):\n\n",
-			validateResult.ErrorCount, validateResult.WarningCount))
+			report.ErrorCount, report.WarningCount))
 
-		for i, diag := range validateResult.Diagnostics {
-			icon := "⚠️"
-			if diag.Severity == "error" {
-				icon = "❌"
-			}
-			output.WriteString(fmt.Sprintf("%d. %s **%s**\n", i+1, icon, diag.Summary))
-			if diag.Range != nil {
-				output.WriteString(fmt.Sprintf("   📍 File: %s, Line: %d, Column: %d\n",
-					diag.Range.Filename, diag.Range.Start.Line, diag.Range.Start.Column))
-			}
-			if diag.Detail != "" {
-				output.WriteString(fmt.Sprintf("   💡 %s\n", diag.Detail))
-			}
-			output.WriteString("\n")
-		}
+		writeDiagnostics(output, report.Diagnostics)
 	}
 
-	return &ToolCallResult{
-		Content: []ContentBlock{{Type: "text", Text: output.String()}},
-		IsError: !validateResult.Valid,
-	}, nil
+	return diagnosticsResult(output.String(), report), !initFailed && jsonErr == nil, nil
 }
 
 // handleValidateBicep validates Bicep files
-func (s *MCPServer) handleValidateBicep(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *MCPServer) handleValidateBicep(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	// Resolve path
-	absPath, err := filepath.Abs(path)
+	// Resolve path within the workspace roots
+	absPath, err := s.resolvePath(path)
 	if err != nil {
-		return nil, fmt.Errorf("invalid path: %w", err)
+		return nil, err
 	}
 
 	// Verify it's a .bicep file
@@ -486,18 +1035,28 @@ func (s *MCPServer) handleValidateBicep(args map[string]interface{}) (*ToolCallR
 
 	// Check if az CLI is installed
 	if _, err := exec.LookPath("az"); err != nil {
-		return &ToolCallResult{
-			Content: []ContentBlock{{
-				Type: "text",
-				Text: "❌ Azure CLI not found. Please install Azure CLI: https://docs.microsoft.com/cli/azure/install-azure-cli",
-			}},
-			IsError: true,
-		}, nil
+		report := newDiagnosticsReport("validate_bicep", []Diagnostic{{
+			Severity: severityError,
+			Summary:  "Azure CLI not found",
+			Detail:   "Install the Azure CLI with the Bicep extension.",
+		}})
+		return diagnosticsResult("❌ Azure CLI not found. Please install Azure CLI: https://docs.microsoft.com/cli/azure/install-azure-cli", report), nil
 	}
 
+	key := cacheKey([]string{"validate_bicep", absPath,
+		s.cache.toolVersion(ctx, "az", "bicep", "version")}, bicepInputs(absPath))
+	return s.cache.cached(ctx, key, func() (*ToolCallResult, bool, error) {
+		return buildBicepFile(ctx, absPath, &output)
+	})
+}
+
+// buildBicepFile runs az bicep build on absPath. The result is cacheable
+// unless the build failed without a recognizable diagnostic.
+func buildBicepFile(ctx context.Context, absPath string, output *strings.Builder) (*ToolCallResult, bool, error) {
 	// Run az bicep build
+	reporterFrom(ctx).progress(1, 1, "Running az bicep build")
 	output.WriteString("🔎 Running az bicep build...\n\n")
-	cmd := exec.Command("az", "bicep", "build", "--file", absPath, "--stdout")
+	cmd := commandContext(ctx, "az", "bicep", "build", "--file", absPath, "--stdout")
 	buildOutput, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -524,10 +1083,18 @@ func (s *MCPServer) handleValidateBicep(args map[string]interface{}) (*ToolCallR
 			}
 		}
 
-		return &ToolCallResult{
-			Content: []ContentBlock{{Type: "text", Text: output.String()}},
-			IsError: true,
-		}, nil
+		report := newDiagnosticsReport("validate_bicep", parseBicepOutput(string(buildOutput), ""))
+		cacheable := !report.Valid
+		if report.Valid {
+			// The build failed without a recognizable diagnostic
+			report = newDiagnosticsReport("validate_bicep", append(report.Diagnostics, Diagnostic{
+				Severity: severityError,
+				File:     absPath,
+				Summary:  "az bicep build failed",
+				Detail:   strings.TrimSpace(string(buildOutput)),
+			}))
+		}
+		return diagnosticsResult(output.String(), report), cacheable, nil
 	}
 
 	output.WriteString("✅ **Bicep file is valid!**\n\n")
@@ -537,105 +1104,236 @@ func (s *MCPServer) handleValidateBicep(args map[string]interface{}) (*ToolCallR
 	armSize := len(buildOutput)
 	output.WriteString(fmt.Sprintf("   - Generated ARM template size: %d bytes\n", armSize))
 
-	return &ToolCallResult{
-		Content: []ContentBlock{{Type: "text", Text: output.String()}},
-		IsError: false,
-	}, nil
+	// Warnings are printed alongside the ARM JSON on success
+	report := newDiagnosticsReport("validate_bicep", parseBicepOutput(string(buildOutput), ""))
+	return diagnosticsResult(output.String(), report), true, nil
 }
 
-// handleCheckSyntax performs quick syntax checks on code snippets
-func (s *MCPServer) handleCheckSyntax(args map[string]interface{}) (*ToolCallResult, error) {
-	code, ok := args["code"].(string)
-	if !ok {
-		return nil, fmt.Errorf("code parameter is required")
-	}
-
+// handleCheckSyntax performs quick syntax checks on code snippets. Snippets
+// can span several files and be overlaid on an existing directory.
+func (s *MCPServer) handleCheckSyntax(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
 	codeType, ok := args["type"].(string)
 	if !ok {
 		return nil, fmt.Errorf("type parameter is required (terraform or bicep)")
 	}
+	codeType = strings.ToLower(codeType)
+	if codeType != "terraform" && codeType != "bicep" {
+		return nil, fmt.Errorf("unsupported type: %s (use 'terraform' or 'bicep')", codeType)
+	}
+	basePath, _ := args["base_path"].(string)
+
+	files := make(map[string]string)
+	if raw, ok := args["files"].(map[string]interface{}); ok {
+		for name, content := range raw {
+			text, ok := content.(string)
+			if !ok {
+				return nil, fmt.Errorf("files[%q] must be a string", name)
+			}
+			files[name] = text
+		}
+	}
+	if code, ok := args["code"].(string); ok {
+		// Next to real files, main.tf would replace the module's own main.tf
+		name := "main"
+		if basePath != "" {
+			name = "snippet"
+		}
+		if codeType == "bicep" {
+			files[name+".bicep"] = code
+		} else {
+			files[name+".tf"] = code
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("code or files parameter is required")
+	}
 
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("🔍 Checking %s syntax...\n\n", codeType))
 
-	// Create temp directory
-	tempDir, err := os.MkdirTemp("", "iac-syntax-check-*")
+	ws, err := s.newSyntaxWorkspace(codeType, files, basePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, err
+	}
+	defer ws.cleanup()
+
+	if basePath != "" {
+		output.WriteString(fmt.Sprintf("📂 Checking %d file(s) in the context of %s\n\n", len(ws.files), basePath))
 	}
-	defer os.RemoveAll(tempDir)
 
-	switch strings.ToLower(codeType) {
+	// Modules that could not be copied in
+	diags := ws.diags
+
+	switch codeType {
 	case "terraform":
-		// Write code to temp file
-		tempFile := filepath.Join(tempDir, "main.tf")
-		if err := os.WriteFile(tempFile, []byte(code), 0644); err != nil {
-			return nil, fmt.Errorf("failed to write temp file: %w", err)
+		mode, err := resolveTerraformMode(args)
+		if err != nil {
+			return nil, err
+		}
+
+		if mode == modeHCL {
+			hclDiags, err := checkWorkspaceHCL(ws)
+			if err != nil {
+				return nil, err
+			}
+			diags = append(diags, hclDiags...)
+			report := newDiagnosticsReport("check_iac_syntax", diags)
+			if !report.Valid {
+				output.WriteString("❌ **Syntax errors found:**\n\n")
+				writeDiagnostics(&output, diags)
+				return diagnosticsResult(output.String(), report), nil
+			}
+			if len(diags) > 0 {
+				output.WriteString("⚠️ **Warnings:**\n\n")
+				writeDiagnostics(&output, diags)
+			}
+			output.WriteString("✅ **Terraform syntax is valid!** (checked in-process)\n")
+			break
 		}
 
-		// Run terraform fmt to check syntax
-		cmd := exec.Command("terraform", "fmt", "-check", "-no-color", tempFile)
-		fmtOutput, fmtErr := cmd.CombinedOutput()
+		key := cacheKey([]string{"check_iac_syntax", codeType, "base=" + basePath,
+			s.cache.toolVersion(ctx, "terraform", "version", "-json")}, workspaceInputs(ws))
+		return s.cache.cached(ctx, key, func() (*ToolCallResult, bool, error) {
+			return s.checkTerraformWorkspace(ctx, ws, diags, &output)
+		})
 
-		// Run terraform validate
-		initCmd := exec.Command("terraform", "init", "-backend=false", "-no-color")
-		initCmd.Dir = tempDir
-		initCmd.CombinedOutput()
+	case "bicep":
+		key := cacheKey([]string{"check_iac_syntax", codeType, "base=" + basePath,
+			s.cache.toolVersion(ctx, "az", "bicep", "version")}, workspaceInputs(ws))
+		return s.cache.cached(ctx, key, func() (*ToolCallResult, bool, error) {
+			return checkBicepWorkspace(ctx, ws, diags, &output)
+		})
+	}
+
+	return diagnosticsResult(output.String(), newDiagnosticsReport("check_iac_syntax", diags)), nil
+}
 
-		validateCmd := exec.Command("terraform", "validate", "-no-color")
-		validateCmd.Dir = tempDir
-		validateOutput, validateErr := validateCmd.CombinedOutput()
+// checkTerraformWorkspace runs terraform fmt and terraform validate in a
+// check_iac_syntax workspace. diags holds the workspace's own diagnostics.
+// The result is cacheable only when init succeeded and validate produced its
+// -json report.
+func (s *MCPServer) checkTerraformWorkspace(ctx context.Context, ws *syntaxWorkspace, diags []Diagnostic, output *strings.Builder) (*ToolCallResult, bool, error) {
+	reporter := reporterFrom(ctx)
+
+	// Run terraform fmt on the snippet files only
+	var tfFiles []string
+	for _, name := range ws.files {
+		if strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tfvars") {
+			tfFiles = append(tfFiles, name)
+		}
+	}
+	var fmtOutput []byte
+	var fmtErr error
+	if len(tfFiles) > 0 {
+		reporter.progress(1, 3, "Running terraform fmt")
+		cmd := commandContext(ctx, "terraform", append([]string{"fmt", "-check", "-no-color"}, tfFiles...)...)
+		cmd.Dir = ws.dir
+		fmtOutput, fmtErr = runCommand(ctx, cmd)
+	}
 
-		if fmtErr != nil {
-			output.WriteString("⚠️ **Formatting issues detected**\n")
-			output.WriteString(fmt.Sprintf("   %s\n", string(fmtOutput)))
+	// Run terraform validate
+	reporter.progress(2, 3, "Running terraform init")
+	initCmd := commandContext(ctx, "terraform", "init", "-backend=false", "-no-color")
+	initCmd.Dir = ws.dir
+	initCmd.Env = append(os.Environ(), pluginCacheEnv(s.config.CacheDir)...)
+	_, initErr := runTerraformInit(ctx, initCmd)
+
+	reporter.progress(3, 3, "Running terraform validate")
+	validateCmd := commandContext(ctx, "terraform", "validate", "-json", "-no-color")
+	validateCmd.Dir = ws.dir
+	validateOutput, validateErr := runCommand(ctx, validateCmd)
+
+	if fmtErr != nil {
+		output.WriteString("⚠️ **Formatting issues detected**\n")
+		output.WriteString(fmt.Sprintf("   %s\n", string(fmtOutput)))
+		for _, line := range strings.Split(strings.TrimSpace(string(fmtOutput)), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			diags = append(diags, Diagnostic{
+				Severity: severityWarning,
+				Code:     "fmt",
+				File:     line,
+				Summary:  "Formatting issues detected",
+				Detail:   "Run terraform fmt to apply the canonical format.",
+			})
 		}
+	}
 
-		if validateErr != nil {
-			output.WriteString("❌ **Syntax errors found:**\n")
+	validateDiags, jsonErr := parseTerraformValidateJSON(validateOutput)
+	if jsonErr != nil && validateErr != nil {
+		validateDiags = []Diagnostic{{
+			Severity: severityError,
+			Summary:  "terraform validate failed",
+			Detail:   strings.TrimSpace(string(validateOutput)),
+		}}
+	}
+	diags = append(diags, validateDiags...)
+	cacheable := initErr == nil && jsonErr == nil
+
+	if report := newDiagnosticsReport("check_iac_syntax", diags); !report.Valid {
+		output.WriteString("❌ **Syntax errors found:**\n\n")
+		if jsonErr != nil {
 			output.WriteString(fmt.Sprintf("```\n%s\n```\n", string(validateOutput)))
-			return &ToolCallResult{
-				Content: []ContentBlock{{Type: "text", Text: output.String()}},
-				IsError: true,
-			}, nil
+		} else {
+			writeDiagnostics(output, validateDiags)
 		}
+		return diagnosticsResult(output.String(), report), cacheable, nil
+	}
 
-		output.WriteString("✅ **Terraform syntax is valid!**\n")
+	output.WriteString("✅ **Terraform syntax is valid!**\n")
+	return diagnosticsResult(output.String(), newDiagnosticsReport("check_iac_syntax", diags)), cacheable, nil
+}
 
-	case "bicep":
-		// Write code to temp file
-		tempFile := filepath.Join(tempDir, "main.bicep")
-		if err := os.WriteFile(tempFile, []byte(code), 0644); err != nil {
-			return nil, fmt.Errorf("failed to write temp file: %w", err)
+// checkBicepWorkspace runs az bicep build on the snippet files of a
+// check_iac_syntax workspace. The result is cacheable unless the CLI failed
+// without reporting diagnostics.
+func checkBicepWorkspace(ctx context.Context, ws *syntaxWorkspace, diags []Diagnostic, output *strings.Builder) (*ToolCallResult, bool, error) {
+	var bicepFiles []string
+	for _, name := range ws.files {
+		if strings.HasSuffix(name, ".bicep") {
+			bicepFiles = append(bicepFiles, name)
 		}
+	}
 
-		// Run az bicep build
-		cmd := exec.Command("az", "bicep", "build", "--file", tempFile, "--stdout")
+	// Build each snippet file; modules are compiled along with them
+	var failures []string
+	cacheable := true
+	for i, name := range bicepFiles {
+		reporterFrom(ctx).progress(i+1, len(bicepFiles), "Running az bicep build on "+name)
+		cmd := commandContext(ctx, "az", "bicep", "build", "--file", filepath.Join(ws.dir, name), "--stdout")
 		buildOutput, err := cmd.CombinedOutput()
+		fileDiags := parseBicepOutput(string(buildOutput), ws.dir)
 
 		if err != nil {
-			output.WriteString("❌ **Bicep syntax errors found:**\n")
-			output.WriteString(fmt.Sprintf("```\n%s\n```\n", string(buildOutput)))
-			return &ToolCallResult{
-				Content: []ContentBlock{{Type: "text", Text: output.String()}},
-				IsError: true,
-			}, nil
+			text := strings.ReplaceAll(string(buildOutput), ws.dir+string(filepath.Separator), "")
+			failures = append(failures, text)
+			if len(fileDiags) == 0 {
+				// Most likely the CLI itself failed, e.g. installing Bicep
+				cacheable = false
+				fileDiags = []Diagnostic{{
+					Severity: severityError,
+					File:     name,
+					Summary:  "az bicep build failed",
+					Detail:   strings.TrimSpace(text),
+				}}
+			}
 		}
+		diags = append(diags, fileDiags...)
+	}
 
-		output.WriteString("✅ **Bicep syntax is valid!**\n")
-
-	default:
-		return nil, fmt.Errorf("unsupported type: %s (use 'terraform' or 'bicep')", codeType)
+	if len(failures) > 0 {
+		output.WriteString("❌ **Bicep syntax errors found:**\n")
+		output.WriteString(fmt.Sprintf("```\n%s\n```\n", strings.Join(failures, "\n")))
+		return diagnosticsResult(output.String(), newDiagnosticsReport("check_iac_syntax", diags)), cacheable, nil
 	}
 
-	return &ToolCallResult{
-		Content: []ContentBlock{{Type: "text", Text: output.String()}},
-		IsError: false,
-	}, nil
+	output.WriteString("✅ **Bicep syntax is valid!**\n")
+	return diagnosticsResult(output.String(), newDiagnosticsReport("check_iac_syntax", diags)), cacheable, nil
 }
 
 // handleListIaCFiles lists IaC files in a directory
-func (s *MCPServer) handleListIaCFiles(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *MCPServer) handleListIaCFiles(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return nil, fmt.Errorf("path parameter is required")
@@ -646,46 +1344,26 @@ func (s *MCPServer) handleListIaCFiles(args map[string]interface{}) (*ToolCallRe
 		recursive = strings.ToLower(r) != "false"
 	}
 
-	absPath, err := filepath.Abs(path)
+	absPath, err := s.resolvePath(path)
 	if err != nil {
-		return nil, fmt.Errorf("invalid path: %w", err)
+		return nil, err
 	}
 
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("📂 Scanning for IaC files in: %s\n\n", absPath))
 
-	var tfFiles, bicepFiles []string
-
-	walkFn := func(p string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-		if info.IsDir() {
-			// Skip hidden and common non-IaC directories
-			name := info.Name()
-			if strings.HasPrefix(name, ".") || name == "node_modules" || name == ".terraform" {
-				return filepath.SkipDir
-			}
-			if !recursive && p != absPath {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		ext := strings.ToLower(filepath.Ext(p))
-		relPath, _ := filepath.Rel(absPath, p)
+	files, err := walkIaCFiles(absPath, recursive, map[string]bool{".tf": true, ".bicep": true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
 
-		if ext == ".tf" {
+	var tfFiles, bicepFiles []string
+	for _, relPath := range files {
+		if strings.ToLower(filepath.Ext(relPath)) == ".tf" {
 			tfFiles = append(tfFiles, relPath)
-		} else if ext == ".bicep" {
+		} else {
 			bicepFiles = append(bicepFiles, relPath)
 		}
-
-		return nil
-	}
-
-	if err := filepath.Walk(absPath, walkFn); err != nil {
-		return nil, fmt.Errorf("failed to scan directory: %w", err)
 	}
 
 	if len(tfFiles) > 0 {
@@ -717,10 +1395,63 @@ func (s *MCPServer) handleListIaCFiles(args map[string]interface{}) (*ToolCallRe
 	}, nil
 }
 
+// walkIaCFiles returns the paths, relative to root, of all files under root
+// whose extension is in exts. Hidden directories and node_modules are skipped.
+func walkIaCFiles(root string, recursive bool, exts map[string]bool) ([]string, error) {
+	var files []string
+
+	walkFn := func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+		if info.IsDir() {
+			// Skip hidden and common non-IaC directories
+			name := info.Name()
+			if p != root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			if !recursive && p != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if exts[strings.ToLower(filepath.Ext(p))] {
+			relPath, _ := filepath.Rel(root, p)
+			files = append(files, relPath)
+		}
+
+		return nil
+	}
+
+	if err := filepath.Walk(root, walkFn); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// commandWaitDelay is how long a killed command may keep its output open
+const commandWaitDelay = 2 * time.Second
+
+// commandContext creates a command that is killed when ctx is done. WaitDelay
+// makes sure the call returns even if a grandchild keeps the output open.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
 // =============================================================================
 // Response Helpers
 // =============================================================================
 
+// idKey returns the canonical JSON form of a request ID, matching the form it
+// takes when the response is marshaled
+func idKey(id interface{}) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
+
 // sendResult sends a successful response
 func (s *MCPServer) sendResult(id interface{}, result interface{}) {
 	response := JSONRPCResponse{
@@ -745,14 +1476,26 @@ func (s *MCPServer) sendError(id interface{}, code int, message, data string) {
 	s.send(response)
 }
 
-// send writes a response to stdout
-func (s *MCPServer) send(response JSONRPCResponse) {
-	data, err := json.Marshal(response)
+// sendNotification sends a notification to the client
+func (s *MCPServer) sendNotification(method string, params interface{}) {
+	s.send(JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// send writes a message to stdout. Writes are serialized so notifications
+// from background goroutines never interleave with responses.
+func (s *MCPServer) send(message interface{}) {
+	data, err := json.Marshal(message)
 	if err != nil {
-		debugLog("Failed to marshal response: %v", err)
+		debugLog("Failed to marshal message: %v", err)
 		return
 	}
+	s.writeMu.Lock()
 	fmt.Fprintln(s.writer, string(data))
+	s.writeMu.Unlock()
 	debugLog("Sent: %s", string(data))
 }
 
@@ -768,8 +1511,41 @@ func debugLog(format string, args ...interface{}) {
 // =============================================================================
 
 func main() {
-	server := NewMCPServer()
-	if err := server.Run(); err != nil {
+	transport := flag.String("transport", "stdio", "Transport to serve MCP over: stdio or http")
+	addr := flag.String("addr", "127.0.0.1:8090", "Listen address for the http transport (other interfaces require IAC_VALIDATOR_TOKEN)")
+	var allowedOrigins stringList
+	flag.Var(&allowedOrigins, "allowed-origin", "Browser origin allowed to use the http transport besides localhost, e.g. https://portal.example.com (repeatable)")
+	toolTimeout := flag.Duration("tool-timeout", defaultToolTimeout, "Maximum run time of a single tool call")
+	var roots stringList
+	flag.Var(&roots, "root", "Workspace root that path arguments are restricted to (repeatable)")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "Directory for cached validation results and Terraform providers (empty disables caching)")
+	toolsFile := flag.String("tools-file", "", "YAML or JSON file declaring extra command-backed tools (reloaded on change)")
+	flag.Parse()
+
+	if len(canonicalRoots(roots)) != len(roots) {
+		fmt.Fprintf(os.Stderr, "Server error: every --root must be an existing directory\n")
+		os.Exit(1)
+	}
+	if *toolsFile != "" {
+		if _, err := loadToolsFile(*toolsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	config := Config{ToolTimeout: *toolTimeout, Roots: roots, CacheDir: *cacheDir, ToolsFile: *toolsFile}
+
+	var err error
+	switch *transport {
+	case "stdio":
+		server := NewMCPServer(os.Stdin, os.Stdout, config)
+		err = server.Run()
+	case "http":
+		err = ServeHTTP(*addr, config, HTTPOptions{AllowedOrigins: allowedOrigins, Token: os.Getenv("IAC_VALIDATOR_TOKEN")})
+	default:
+		err = fmt.Errorf("unknown transport: %s (use 'stdio' or 'http')", *transport)
+	}
+
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,633 @@
+// =============================================================================
+// Terraform Plan Tool
+// =============================================================================
+// Runs `terraform plan` against a throw-away local backend and summarizes the
+// JSON plan (`terraform show -json`): resources to add, change, replace and
+// destroy, why replacements happen, and which attributes change. Sensitive
+// values are masked and unknown values are shown as "(known after apply)".
+//
+// The user's directory is never written to: the configuration (with the
+// files it reads and its local modules) is copied into a temp workspace,
+// where an *_override.tf file switches the backend to a local state file and
+// TF_DATA_DIR keeps .terraform apart from the copy. Without state_path the
+// plan starts from an empty state; with it, a copy of that state seeds the
+// temporary backend, so the plan shows changes to what is deployed. The
+// copy is discarded afterwards and never written back.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// planOverrideFile is the override written into the copied configuration
+const planOverrideFile = "zz_iac_validator_backend_override.tf"
+
+// Placeholders used in place of masked values
+const (
+	sensitiveValue = "(sensitive value)"
+	unknownValue   = "(known after apply)"
+)
+
+// PlanSummary is the structured result of plan_terraform
+type PlanSummary struct {
+	Add       int             `json:"add"`
+	Change    int             `json:"change"`
+	Destroy   int             `json:"destroy"`
+	Replace   int             `json:"replace"`
+	Resources []PlannedChange `json:"resources"`
+	Outputs   []PlannedOutput `json:"outputs,omitempty"`
+}
+
+// PlannedChange describes the planned action for a single resource
+type PlannedChange struct {
+	Address      string            `json:"address"`
+	Action       string            `json:"action"`
+	Reason       string            `json:"reason,omitempty"`
+	ReplacePaths []string          `json:"replacePaths,omitempty"`
+	Changes      []AttributeChange `json:"changes,omitempty"`
+}
+
+// AttributeChange is a single top-level attribute whose value changes
+type AttributeChange struct {
+	Attribute string      `json:"attribute"`
+	Before    interface{} `json:"before"`
+	After     interface{} `json:"after"`
+}
+
+// PlannedOutput describes a change to a root module output
+type PlannedOutput struct {
+	Name   string      `json:"name"`
+	Action string      `json:"action"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// planSummaryOutputSchema is the JSON Schema of PlanSummary
+var planSummaryOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"add":     map[string]interface{}{"type": "integer"},
+		"change":  map[string]interface{}{"type": "integer"},
+		"destroy": map[string]interface{}{"type": "integer"},
+		"replace": map[string]interface{}{"type": "integer"},
+		"resources": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"address":      map[string]interface{}{"type": "string"},
+					"action":       map[string]interface{}{"type": "string", "enum": []string{"create", "update", "delete", "replace"}},
+					"reason":       map[string]interface{}{"type": "string"},
+					"replacePaths": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					"changes": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"attribute": map[string]interface{}{"type": "string"},
+								"before":    map[string]interface{}{},
+								"after":     map[string]interface{}{},
+							},
+							"required": []string{"attribute"},
+						},
+					},
+				},
+				"required": []string{"address", "action"},
+			},
+		},
+		"outputs": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":   map[string]interface{}{"type": "string"},
+					"action": map[string]interface{}{"type": "string"},
+					"after":  map[string]interface{}{},
+				},
+				"required": []string{"name", "action"},
+			},
+		},
+	},
+	"required": []string{"add", "change", "destroy", "replace", "resources"},
+}
+
+// terraformPlanJSON is the subset of `terraform show -json <plan>` we read
+type terraformPlanJSON struct {
+	ResourceChanges []struct {
+		Address      string     `json:"address"`
+		Mode         string     `json:"mode"`
+		ActionReason string     `json:"action_reason"`
+		Change       planChange `json:"change"`
+	} `json:"resource_changes"`
+	OutputChanges map[string]planChange `json:"output_changes"`
+}
+
+// planChange is the change object shared by resources and outputs
+type planChange struct {
+	Actions         []string        `json:"actions"`
+	Before          interface{}     `json:"before"`
+	After           interface{}     `json:"after"`
+	AfterUnknown    interface{}     `json:"after_unknown"`
+	BeforeSensitive interface{}     `json:"before_sensitive"`
+	AfterSensitive  interface{}     `json:"after_sensitive"`
+	ReplacePaths    [][]interface{} `json:"replace_paths"`
+}
+
+// handlePlanTerraform runs terraform plan and summarizes the result
+func (s *MCPServer) handlePlanTerraform(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+
+	dir, err := s.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %s", dir)
+	}
+	if !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	var varFile string
+	if v, ok := args["var_file"].(string); ok && v != "" {
+		if !filepath.IsAbs(v) {
+			v = filepath.Join(dir, v)
+		}
+		if varFile, err = s.resolvePath(v); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(varFile); err != nil {
+			return nil, fmt.Errorf("var file not found: %s", varFile)
+		}
+	}
+
+	var stateFile string
+	if v, ok := args["state_path"].(string); ok && v != "" {
+		if !filepath.IsAbs(v) {
+			v = filepath.Join(dir, v)
+		}
+		if stateFile, err = s.resolvePath(v); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(stateFile)
+		if err != nil {
+			return nil, fmt.Errorf("state file not found: %s", stateFile)
+		}
+		if info.IsDir() {
+			stateFile = filepath.Join(stateFile, defaultStateFile)
+			if _, err := os.Stat(stateFile); err != nil {
+				return nil, fmt.Errorf("state file not found: %s", stateFile)
+			}
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("📋 Planning Terraform in: %s\n", dir))
+	if stateFile != "" {
+		output.WriteString(fmt.Sprintf("📦 Starting from a copy of: %s\n", stateFile))
+	}
+	output.WriteString("\n")
+
+	if _, err := exec.LookPath("terraform"); err != nil {
+		output.WriteString("❌ Terraform CLI not found. Please install Terraform: https://www.terraform.io/downloads")
+		return &ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: output.String()}},
+			IsError: true,
+		}, nil
+	}
+
+	ws, err := s.newPlanWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.cleanup()
+
+	planJSON, failure, err := runTerraformPlan(ctx, ws.dir, varFile, stateFile, s.config.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if failure != "" {
+		output.WriteString(failure)
+		return &ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: output.String()}},
+			IsError: true,
+		}, nil
+	}
+
+	summary, err := summarizePlan(planJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+
+	writePlanSummary(&output, summary)
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: summary,
+	}, nil
+}
+
+// newPlanWorkspace copies the configuration in dir, including the templates
+// and scripts it reads, and its local modules into a temp workspace. Two
+// plans of the same directory never see each other's override, and a killed
+// plan leaves nothing behind in the user's directory.
+func (s *MCPServer) newPlanWorkspace(dir string) (*syntaxWorkspace, error) {
+	ws, err := s.newSyntaxWorkspace("terraform", nil, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := copyConfigTree(dir, ws.dir); err != nil {
+		ws.cleanup()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// copyConfigTree copies every regular file below src to dest, skipping
+// Terraform's data directory, VCS metadata and local state
+func copyConfigTree(src, dest string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != src && (name == ".terraform" || name == ".git") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, "terraform.tfstate") {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(dest, rel))
+	})
+}
+
+// runTerraformPlan initializes the copied configuration in dir against a
+// temporary local backend, plans and returns the JSON plan. When stateFile
+// is set, the backend starts from a copy of it. A non-empty failure holds
+// the formatted output of a failed terraform command.
+func runTerraformPlan(ctx context.Context, dir, varFile, stateFile, cacheDir string) (planJSON []byte, failure string, err error) {
+	workDir, err := os.MkdirTemp("", "iac-plan-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	statePath := filepath.Join(workDir, defaultStateFile)
+	if stateFile != "" {
+		if err := copyFile(stateFile, statePath); err != nil {
+			return nil, "", fmt.Errorf("failed to copy state: %w", err)
+		}
+	}
+	override := fmt.Sprintf("terraform {\n  backend \"local\" {\n    path = %q\n  }\n}\n", filepath.ToSlash(statePath))
+	overridePath := filepath.Join(dir, planOverrideFile)
+	if err := os.WriteFile(overridePath, []byte(override), 0644); err != nil {
+		return nil, "", fmt.Errorf("failed to write backend override: %w", err)
+	}
+
+	env := append(os.Environ(), "TF_DATA_DIR="+filepath.Join(workDir, ".terraform"), "TF_IN_AUTOMATION=1")
+	planFile := filepath.Join(workDir, "plan.tfplan")
+
+	planArgs := []string{"plan", "-input=false", "-no-color", "-lock=false", "-out=" + planFile}
+	if varFile != "" {
+		planArgs = append(planArgs, "-var-file="+varFile)
+	}
+
+	steps := []struct {
+		message string
+		args    []string
+	}{
+		{"Running terraform init", []string{"init", "-input=false", "-no-color", "-reconfigure"}},
+		{"Running terraform plan", planArgs},
+	}
+
+	reporter := reporterFrom(ctx)
+	for i, step := range steps {
+		reporter.progress(i+1, len(steps)+1, step.message)
+		cmd := commandContext(ctx, "terraform", step.args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		run := runCommand
+		if step.args[0] == "init" {
+			cmd.Env = append(env, pluginCacheEnv(cacheDir)...)
+			run = runTerraformInit
+		}
+		out, err := run(ctx, cmd)
+		if err != nil {
+			return nil, fmt.Sprintf("❌ **terraform %s failed**\n\n```\n%s\n```\n", step.args[0], strings.TrimSpace(string(out))), nil
+		}
+	}
+
+	reporter.progress(len(steps)+1, len(steps)+1, "Reading plan")
+	cmd := commandContext(ctx, "terraform", "show", "-json", "-no-color", planFile)
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		detail := err.Error()
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			detail = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return nil, fmt.Sprintf("❌ **terraform show failed**\n\n```\n%s\n```\n", detail), nil
+	}
+	return out, "", nil
+}
+
+// summarizePlan turns `terraform show -json` output into a PlanSummary
+func summarizePlan(data []byte) (*PlanSummary, error) {
+	var plan terraformPlanJSON
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, err
+	}
+
+	summary := &PlanSummary{Resources: []PlannedChange{}}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode == "data" {
+			continue
+		}
+
+		action := planAction(rc.Change.Actions)
+		switch action {
+		case "create":
+			summary.Add++
+		case "update":
+			summary.Change++
+		case "delete":
+			summary.Destroy++
+		case "replace":
+			summary.Replace++
+			summary.Add++
+			summary.Destroy++
+		default:
+			continue
+		}
+
+		change := PlannedChange{
+			Address: rc.Address,
+			Action:  action,
+			Reason:  describeActionReason(rc.ActionReason),
+		}
+		for _, p := range rc.Change.ReplacePaths {
+			change.ReplacePaths = append(change.ReplacePaths, formatAttributePath(p))
+		}
+		if action == "update" || action == "replace" {
+			change.Changes = attributeChanges(rc.Change)
+		}
+		summary.Resources = append(summary.Resources, change)
+	}
+
+	names := make([]string, 0, len(plan.OutputChanges))
+	for name := range plan.OutputChanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		oc := plan.OutputChanges[name]
+		action := planAction(oc.Actions)
+		if action == "no-op" {
+			continue
+		}
+		summary.Outputs = append(summary.Outputs, PlannedOutput{
+			Name:   name,
+			Action: action,
+			After:  maskValue(oc.After, oc.AfterSensitive, oc.AfterUnknown),
+		})
+	}
+
+	return summary, nil
+}
+
+// planAction collapses Terraform's action list into a single verb
+func planAction(actions []string) string {
+	switch strings.Join(actions, ",") {
+	case "create":
+		return "create"
+	case "update":
+		return "update"
+	case "delete":
+		return "delete"
+	case "delete,create", "create,delete":
+		return "replace"
+	case "read":
+		return "read"
+	}
+	return "no-op"
+}
+
+// describeActionReason turns Terraform's action_reason codes into text
+func describeActionReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "replace_because_cannot_update":
+		return "an argument that cannot be updated in-place changed"
+	case "replace_because_tainted":
+		return "the resource is tainted"
+	case "replace_by_request":
+		return "replacement was requested with -replace"
+	case "replace_by_triggers":
+		return "a replace_triggered_by reference changed"
+	case "delete_because_no_resource_config":
+		return "the resource was removed from the configuration"
+	case "delete_because_no_module":
+		return "its module was removed from the configuration"
+	case "delete_because_count_index", "delete_because_each_key":
+		return "its count index or for_each key no longer exists"
+	case "delete_because_wrong_repetition":
+		return "count/for_each was added or removed"
+	}
+	return strings.ReplaceAll(reason, "_", " ")
+}
+
+// attributeChanges lists the top-level attributes whose value changes, with
+// sensitive and unknown values masked. Values are compared before masking,
+// so a changed sensitive attribute is listed even though both sides read
+// "(sensitive value)".
+func attributeChanges(change planChange) []AttributeChange {
+	rawBefore, _ := change.Before.(map[string]interface{})
+	rawAfter, _ := change.After.(map[string]interface{})
+	before, _ := maskValue(change.Before, change.BeforeSensitive, nil).(map[string]interface{})
+	after, _ := maskValue(change.After, change.AfterSensitive, change.AfterUnknown).(map[string]interface{})
+
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	var names []string
+	for k := range keys {
+		b, _ := json.Marshal(before[k])
+		a, _ := json.Marshal(after[k])
+		rb, _ := json.Marshal(rawBefore[k])
+		ra, _ := json.Marshal(rawAfter[k])
+		if string(a) != string(b) || string(ra) != string(rb) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]AttributeChange, 0, len(names))
+	for _, k := range names {
+		changes = append(changes, AttributeChange{Attribute: k, Before: before[k], After: after[k]})
+	}
+	return changes
+}
+
+// maskValue replaces sensitive and unknown parts of value. sensitive and
+// unknown mirror the structure of value with `true` marking masked leaves.
+func maskValue(value, sensitive, unknown interface{}) interface{} {
+	if sensitive == true {
+		return sensitiveValue
+	}
+	if unknown == true {
+		return unknownValue
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		sMap, _ := sensitive.(map[string]interface{})
+		uMap, _ := unknown.(map[string]interface{})
+		masked := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			masked[k] = maskValue(child, sMap[k], uMap[k])
+		}
+		// Attributes only known after apply are absent from the value
+		for k, u := range uMap {
+			if _, ok := masked[k]; !ok && u == true {
+				masked[k] = unknownValue
+			}
+		}
+		return masked
+	case []interface{}:
+		sList, _ := sensitive.([]interface{})
+		uList, _ := unknown.([]interface{})
+		masked := make([]interface{}, len(v))
+		for i, child := range v {
+			var s, u interface{}
+			if i < len(sList) {
+				s = sList[i]
+			}
+			if i < len(uList) {
+				u = uList[i]
+			}
+			masked[i] = maskValue(child, s, u)
+		}
+		return masked
+	case nil:
+		if uMap, ok := unknown.(map[string]interface{}); ok && len(uMap) > 0 {
+			return maskValue(map[string]interface{}{}, sensitive, unknown)
+		}
+	}
+	return value
+}
+
+// formatAttributePath renders a replace_paths entry such as
+// ["network_rules", 0, "ip_rules"] as network_rules[0].ip_rules
+func formatAttributePath(path []interface{}) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch s := step.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(s)
+		case float64:
+			b.WriteString(fmt.Sprintf("[%d]", int(s)))
+		}
+	}
+	return b.String()
+}
+
+// writePlanSummary renders a PlanSummary as markdown
+func writePlanSummary(output *strings.Builder, summary *PlanSummary) {
+	output.WriteString(fmt.Sprintf("📊 **Plan:** %d to add, %d to change, %d to destroy",
+		summary.Add, summary.Change, summary.Destroy))
+	if summary.Replace > 0 {
+		output.WriteString(fmt.Sprintf(" (%d replacement(s))", summary.Replace))
+	}
+	output.WriteString("\n\n")
+
+	if len(summary.Resources) == 0 {
+		output.WriteString("✅ No changes. Infrastructure matches the configuration.\n")
+	}
+
+	sections := []struct {
+		action, title string
+	}{
+		{"create", "➕ **Create**"},
+		{"update", "🔄 **Update in-place**"},
+		{"replace", "♻️ **Replace**"},
+		{"delete", "🗑️ **Destroy**"},
+	}
+	for _, section := range sections {
+		var lines []string
+		for _, rc := range summary.Resources {
+			if rc.Action != section.action {
+				continue
+			}
+			line := fmt.Sprintf("   - `%s`", rc.Address)
+			if rc.Reason != "" {
+				line += fmt.Sprintf(" (%s)", rc.Reason)
+			}
+			if len(rc.ReplacePaths) > 0 {
+				line += fmt.Sprintf("\n       forces replacement: %s", strings.Join(rc.ReplacePaths, ", "))
+			}
+			for _, c := range rc.Changes {
+				line += fmt.Sprintf("\n       ~ %s: %s → %s", c.Attribute, formatPlanValue(c.Before), formatPlanValue(c.After))
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		output.WriteString(section.title + "\n")
+		output.WriteString(strings.Join(lines, "\n") + "\n\n")
+	}
+
+	if len(summary.Outputs) > 0 {
+		output.WriteString("📤 **Outputs**\n")
+		for _, o := range summary.Outputs {
+			output.WriteString(fmt.Sprintf("   - %s (%s): %s\n", o.Name, o.Action, formatPlanValue(o.After)))
+		}
+	}
+}
+
+// formatPlanValue renders a (masked) value compactly for the markdown summary
+func formatPlanValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if v == sensitiveValue || v == unknownValue {
+			return v
+		}
+	}
+	data, _ := json.Marshal(value)
+	text := string(data)
+	if len(text) > 80 {
+		text = text[:77] + "..."
+	}
+	return text
+}
@@ -0,0 +1,259 @@
+// =============================================================================
+// Terraform Plan Tool Tests
+// =============================================================================
+// testdata/plan-local/show.json is `terraform show -json` of the plan-local
+// fixture, applied with the defaults and api_key = "previous-secret", then
+// planned with fixture.tfvars: every resource is replaced and both outputs
+// change.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Secrets in testdata/plan-local/show.json: the api_key the fixture was
+// applied with, and the one in fixture.tfvars it is planned with
+var planSecrets = []string{"previous-secret", "not-a-real-secret"}
+
+func loadPlanSummary(t *testing.T) *PlanSummary {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "plan-local", "show.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := summarizePlan(data)
+	if err != nil {
+		t.Fatalf("summarizePlan: %v", err)
+	}
+	return summary
+}
+
+func TestSummarizePlanCounts(t *testing.T) {
+	summary := loadPlanSummary(t)
+
+	if summary.Add != 3 || summary.Change != 0 || summary.Destroy != 3 || summary.Replace != 3 {
+		t.Errorf("got %d add, %d change, %d destroy, %d replace; want 3, 0, 3, 3",
+			summary.Add, summary.Change, summary.Destroy, summary.Replace)
+	}
+
+	var addresses []string
+	for _, rc := range summary.Resources {
+		if rc.Action != "replace" {
+			t.Errorf("%s: action %q, want replace", rc.Address, rc.Action)
+		}
+		addresses = append(addresses, rc.Address)
+	}
+	want := []string{"local_file.config", "local_sensitive_file.secret", "null_resource.trigger"}
+	if !reflect.DeepEqual(addresses, want) {
+		t.Errorf("resources = %v, want %v", addresses, want)
+	}
+}
+
+func TestSummarizePlanMasksSensitiveValues(t *testing.T) {
+	summary := loadPlanSummary(t)
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	writePlanSummary(&text, summary)
+	for _, secret := range planSecrets {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("structured summary leaks %q", secret)
+		}
+		if strings.Contains(text.String(), secret) {
+			t.Errorf("text summary leaks %q", secret)
+		}
+	}
+
+	// The secret changed, so it is listed, masked on both sides
+	content := findAttributeChange(t, summary, "local_sensitive_file.secret", "content")
+	if content.Before != sensitiveValue || content.After != sensitiveValue {
+		t.Errorf("secret content = %v → %v, want both %q", content.Before, content.After, sensitiveValue)
+	}
+
+	outputs := make(map[string]interface{})
+	for _, o := range summary.Outputs {
+		outputs[o.Name] = o.After
+	}
+	if outputs["api_key"] != sensitiveValue {
+		t.Errorf("api_key output = %v, want %q", outputs["api_key"], sensitiveValue)
+	}
+	if outputs["config_file"] != "./out/test.txt" {
+		t.Errorf("config_file output = %v, want ./out/test.txt", outputs["config_file"])
+	}
+}
+
+func TestSummarizePlanMarksUnknownValues(t *testing.T) {
+	summary := loadPlanSummary(t)
+
+	id := findAttributeChange(t, summary, "null_resource.trigger", "id")
+	if id.After != unknownValue {
+		t.Errorf("null_resource id after = %v, want %q", id.After, unknownValue)
+	}
+	triggers := findAttributeChange(t, summary, "null_resource.trigger", "triggers")
+	if want := map[string]interface{}{"environment": "test"}; !reflect.DeepEqual(triggers.After, want) {
+		t.Errorf("triggers after = %v, want %v", triggers.After, want)
+	}
+}
+
+func findAttributeChange(t *testing.T, summary *PlanSummary, address, attribute string) AttributeChange {
+	t.Helper()
+	for _, rc := range summary.Resources {
+		if rc.Address != address {
+			continue
+		}
+		for _, c := range rc.Changes {
+			if c.Attribute == attribute {
+				return c
+			}
+		}
+	}
+	t.Fatalf("no change to %s.%s", address, attribute)
+	return AttributeChange{}
+}
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		name                      string
+		value, sensitive, unknown interface{}
+		want                      interface{}
+	}{
+		{"plain", "eastus", nil, nil, "eastus"},
+		{"sensitive leaf", "s3cret", true, nil, sensitiveValue},
+		{"unknown leaf", nil, nil, true, unknownValue},
+		{
+			"nested map",
+			map[string]interface{}{"name": "kv", "secret": "s3cret"},
+			map[string]interface{}{"secret": true},
+			nil,
+			map[string]interface{}{"name": "kv", "secret": sensitiveValue},
+		},
+		{
+			"list elements",
+			[]interface{}{"a", "s3cret"},
+			[]interface{}{false, true},
+			nil,
+			[]interface{}{"a", sensitiveValue},
+		},
+		{
+			"unknown attribute absent from value",
+			map[string]interface{}{"name": "kv"},
+			nil,
+			map[string]interface{}{"id": true},
+			map[string]interface{}{"name": "kv", "id": unknownValue},
+		},
+		{
+			"unknown block",
+			nil,
+			nil,
+			map[string]interface{}{"id": true},
+			map[string]interface{}{"id": unknownValue},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskValue(tt.value, tt.sensitive, tt.unknown); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("maskValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPlanWorkspaceCopiesConfiguration(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"env/main.tf":                 "module \"net\" {\n  source = \"../modules/net\"\n}\n",
+		"env/templates/init.sh":       "#!/bin/sh\n",
+		"env/terraform.tfstate":       "{}",
+		"env/.terraform/modules.json": "{}",
+		"modules/net/main.tf":         "variable \"name\" {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := NewMCPServer(strings.NewReader(""), io.Discard, Config{Roots: []string{root}})
+	defer server.Close()
+
+	dir := filepath.Join(server.roots()[0], "env")
+	ws, err := server.newPlanWorkspace(dir)
+	if err != nil {
+		t.Fatalf("newPlanWorkspace: %v", err)
+	}
+	defer ws.cleanup()
+
+	for _, name := range []string{"main.tf", "templates/init.sh", "../modules/net/main.tf"} {
+		if _, err := os.Stat(filepath.Join(ws.dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s was not copied: %v", name, err)
+		}
+	}
+	for _, name := range []string{"terraform.tfstate", ".terraform"} {
+		if _, err := os.Stat(filepath.Join(ws.dir, name)); err == nil {
+			t.Errorf("%s was copied", name)
+		}
+	}
+	if withinRoots(ws.dir, []string{root}) {
+		t.Errorf("workspace %s is inside the user's directory", ws.dir)
+	}
+}
+
+func TestRunTerraformPlanSeedsStateCopy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake terraform is a shell script")
+	}
+
+	// A fake terraform that records the backend's state file during plan and
+	// then modifies it, as a refresh would
+	bin := t.TempDir()
+	seen := filepath.Join(t.TempDir(), "seen.tfstate")
+	script := `#!/bin/sh
+case "$1" in
+plan)
+	state=$(sed -n 's/.*path = "\(.*\)"/\1/p' ` + planOverrideFile + `)
+	cp "$state" "$PLAN_TEST_SEEN" || exit 1
+	echo refreshed >> "$state" ;;
+show)
+	echo '{"resource_changes":[]}' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "terraform"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PLAN_TEST_SEEN", seen)
+
+	stateFile := filepath.Join(t.TempDir(), defaultStateFile)
+	state := `{"version": 4, "serial": 7, "resources": []}`
+	if err := os.WriteFile(stateFile, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, failure, err := runTerraformPlan(context.Background(), t.TempDir(), "", stateFile, "")
+	if err != nil || failure != "" {
+		t.Fatalf("runTerraformPlan: %v %s", err, failure)
+	}
+	if got, err := os.ReadFile(seen); err != nil || string(got) != state {
+		t.Errorf("plan saw state %q (%v), want %q", got, err, state)
+	}
+	if got, _ := os.ReadFile(stateFile); string(got) != state {
+		t.Errorf("original state was modified: %q", got)
+	}
+}
@@ -0,0 +1,203 @@
+// =============================================================================
+// Progress & Logging
+// =============================================================================
+// Keeps the client informed during long tool calls. When a tools/call request
+// carries _meta.progressToken, each phase (init, validate, fmt, build) is
+// reported as notifications/progress. Output lines of the terraform and az
+// child processes are forwarded as notifications/message through the MCP
+// logging capability, filtered by the level set with logging/setLevel.
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// logLevels lists the MCP (syslog) log levels from least to most severe
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// defaultLogLevel is the minimum level forwarded before logging/setLevel
+const defaultLogLevel = "info"
+
+// LoggingCapability indicates log message support
+type LoggingCapability struct{}
+
+// RequestMeta holds the _meta field of a request
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+// ProgressParams is the payload of notifications/progress
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      int         `json:"progress"`
+	Total         int         `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// LogMessageParams is the payload of notifications/message
+type LogMessageParams struct {
+	Level  string      `json:"level"`
+	Logger string      `json:"logger,omitempty"`
+	Data   interface{} `json:"data"`
+}
+
+// handleSetLogLevel processes logging/setLevel
+func (s *MCPServer) handleSetLogLevel(req *JSONRPCRequest) {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || logLevelRank(params.Level) < 0 {
+		s.sendError(req.ID, -32602, "Invalid params", "level must be one of: "+strings.Join(logLevels, ", "))
+		return
+	}
+
+	s.logMu.Lock()
+	s.logLevel = params.Level
+	s.logMu.Unlock()
+
+	s.sendResult(req.ID, map[string]string{})
+}
+
+// sendLog sends a notifications/message if level is at or above the level
+// requested by the client
+func (s *MCPServer) sendLog(level, logger string, data interface{}) {
+	s.logMu.Lock()
+	minimum := s.logLevel
+	s.logMu.Unlock()
+
+	if logLevelRank(level) < logLevelRank(minimum) {
+		return
+	}
+	s.sendNotification("notifications/message", LogMessageParams{
+		Level:  level,
+		Logger: logger,
+		Data:   data,
+	})
+}
+
+// logLevelRank returns the severity index of level, or -1 if unknown
+func logLevelRank(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// =============================================================================
+// Per-Call Reporter
+// =============================================================================
+
+// callReporter sends progress and log notifications for one tool call
+type callReporter struct {
+	server        *MCPServer
+	progressToken interface{}
+}
+
+type reporterKey struct{}
+
+// withReporter attaches a reporter for the current tool call to ctx
+func withReporter(ctx context.Context, reporter *callReporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, reporter)
+}
+
+// reporterFrom returns the reporter attached to ctx. The result may be nil;
+// all callReporter methods are no-ops on a nil reporter.
+func reporterFrom(ctx context.Context) *callReporter {
+	reporter, _ := ctx.Value(reporterKey{}).(*callReporter)
+	return reporter
+}
+
+// progress reports that phase number step out of total has started. Nothing
+// is sent unless the client asked for progress with a progressToken.
+func (r *callReporter) progress(step, total int, message string) {
+	if r == nil || r.progressToken == nil {
+		return
+	}
+	r.server.sendNotification("notifications/progress", ProgressParams{
+		ProgressToken: r.progressToken,
+		Progress:      step,
+		Total:         total,
+		Message:       message,
+	})
+}
+
+// log forwards a log line to the client
+func (r *callReporter) log(level, logger, message string) {
+	if r == nil {
+		return
+	}
+	r.server.sendLog(level, logger, message)
+}
+
+// =============================================================================
+// Command Output Streaming
+// =============================================================================
+
+// runCommand runs cmd and returns its combined output, like CombinedOutput,
+// while forwarding every output line to the client as a log message
+func runCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	writer := &lineLogger{
+		reporter: reporterFrom(ctx),
+		logger:   cmd.Args[0],
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	err := cmd.Run()
+	writer.flush()
+	return writer.output.Bytes(), err
+}
+
+// lineLogger collects command output and logs it one line at a time
+type lineLogger struct {
+	reporter *callReporter
+	logger   string
+
+	mu      sync.Mutex
+	output  bytes.Buffer
+	partial []byte
+}
+
+// Write implements io.Writer
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.output.Write(p)
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		l.emit(l.partial[:i])
+		l.partial = l.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush logs any trailing output that did not end with a newline
+func (l *lineLogger) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.emit(l.partial)
+	l.partial = nil
+}
+
+// emit logs a single non-empty line
+func (l *lineLogger) emit(line []byte) {
+	text := strings.TrimSpace(string(line))
+	if text != "" {
+		l.reporter.log("info", l.logger, text)
+	}
+}
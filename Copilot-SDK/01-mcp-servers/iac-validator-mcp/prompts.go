@@ -0,0 +1,474 @@
+// =============================================================================
+// MCP Prompts
+// =============================================================================
+// Curated, parameterized prompts that clients offer as one-click workflows
+// (slash commands in Copilot Chat). prompts/get runs the relevant tools first
+// and embeds their output and the source code in the prompt, so the model
+// starts from real validation and lint results instead of guessing.
+//
+//   - review_terraform_security: validate + lint a module, ask for a review
+//   - explain_validation_error:  validate, quote the failing lines, ask why
+//   - convert_bicep_to_terraform: compile-check a Bicep file, ask for HCL
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// promptSourceLimit caps how much source code is embedded in a prompt
+const promptSourceLimit = 64 * 1024
+
+// promptExcerptLines is the number of lines quoted around a diagnostic
+const promptExcerptLines = 3
+
+// promptMaxExcerpts caps the number of diagnostics quoted with source lines
+const promptMaxExcerpts = 10
+
+// PromptsCapability indicates prompt support
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// Prompt describes a prompt template offered by the server
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes an argument of a prompt template
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptsListResult contains the list of available prompts
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+// PromptGetParams contains the parameters of prompts/get
+type PromptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+	Meta      *RequestMeta      `json:"_meta,omitempty"`
+}
+
+// PromptMessage is one message of a rendered prompt
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+// PromptGetResult is returned from prompts/get
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// promptBuilder renders a prompt from its arguments
+type promptBuilder func(ctx context.Context, args map[string]string) (*PromptGetResult, error)
+
+// promptTemplate is a prompt together with the function that renders it
+type promptTemplate struct {
+	Prompt
+	build promptBuilder
+}
+
+// prompts returns the prompt templates, in list order
+func (s *MCPServer) prompts() []promptTemplate {
+	return []promptTemplate{
+		{
+			Prompt: Prompt{
+				Name:        "review_terraform_security",
+				Description: "Review a Terraform module for security issues. Embeds the module source and the output of validate_terraform and lint_iac.",
+				Arguments: []PromptArgument{
+					{Name: "path", Description: "Directory of the Terraform module", Required: true},
+					{Name: "focus", Description: "Optional area to concentrate on, e.g. 'network exposure' or 'secrets'"},
+				},
+			},
+			build: s.buildSecurityReviewPrompt,
+		},
+		{
+			Prompt: Prompt{
+				Name:        "explain_validation_error",
+				Description: "Explain why Terraform or Bicep code fails validation and how to fix it. Embeds the validation output and the source lines around each diagnostic.",
+				Arguments: []PromptArgument{
+					{Name: "path", Description: "Terraform directory, .tf file or .bicep file", Required: true},
+					{Name: "error", Description: "Optional error message seen elsewhere, e.g. in a pipeline log"},
+				},
+			},
+			build: s.buildExplainErrorPrompt,
+		},
+		{
+			Prompt: Prompt{
+				Name:        "convert_bicep_to_terraform",
+				Description: "Convert a Bicep file to Terraform (azurerm). Embeds the Bicep source and the output of validate_bicep.",
+				Arguments: []PromptArgument{
+					{Name: "path", Description: "Path to the .bicep file", Required: true},
+				},
+			},
+			build: s.buildConvertBicepPrompt,
+		},
+	}
+}
+
+// =============================================================================
+// Prompt Handlers
+// =============================================================================
+
+// handlePromptsList returns the available prompt templates
+func (s *MCPServer) handlePromptsList(req *JSONRPCRequest) {
+	prompts := []Prompt{}
+	for _, p := range s.prompts() {
+		prompts = append(prompts, p.Prompt)
+	}
+	s.sendResult(req.ID, PromptsListResult{Prompts: prompts})
+}
+
+// handlePromptsGet renders a prompt. The embedded tools can be slow, so the
+// prompt is built in the background like a tool call, with the same timeout,
+// progress reporting and cancellation.
+func (s *MCPServer) handlePromptsGet(req *JSONRPCRequest) {
+	var params PromptGetParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		s.sendError(req.ID, -32602, "Invalid params", err.Error())
+		return
+	}
+
+	var build promptBuilder
+	for _, p := range s.prompts() {
+		if p.Name != params.Name {
+			continue
+		}
+		for _, arg := range p.Arguments {
+			if arg.Required && params.Arguments[arg.Name] == "" {
+				s.sendError(req.ID, -32602, "Invalid params", fmt.Sprintf("missing required argument: %s", arg.Name))
+				return
+			}
+		}
+		build = p.build
+	}
+	if build == nil {
+		s.sendError(req.ID, -32602, "Unknown prompt", params.Name)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ToolTimeout)
+	reporter := &callReporter{server: s}
+	if params.Meta != nil {
+		reporter.progressToken = params.Meta.ProgressToken
+	}
+	ctx = withReporter(ctx, reporter)
+	key := idKey(req.ID)
+	s.trackCall(key, cancel)
+
+	s.calls.Add(1)
+	go func() {
+		defer s.calls.Done()
+		defer s.untrackCall(key)
+
+		result, err := build(ctx, params.Arguments)
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			debugLog("Prompt %s cancelled", params.Name)
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			s.sendError(req.ID, -32603, "Internal error", fmt.Sprintf("prompt timed out after %s", s.config.ToolTimeout))
+		case errors.As(err, new(*PathNotAllowedError)):
+			s.sendError(req.ID, errCodePathNotAllowed, "Path not allowed", err.Error())
+		case err != nil:
+			s.sendError(req.ID, -32602, "Invalid params", err.Error())
+		default:
+			s.sendResult(req.ID, result)
+		}
+	}()
+}
+
+// =============================================================================
+// Prompt Builders
+// =============================================================================
+
+// buildSecurityReviewPrompt renders review_terraform_security
+func (s *MCPServer) buildSecurityReviewPrompt(ctx context.Context, args map[string]string) (*PromptGetResult, error) {
+	dir, err := s.resolvePath(args["path"])
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("path must be a Terraform directory: %s", dir)
+	}
+	name := s.displayPath(dir)
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Review the Terraform module `%s` for security issues.\n\n", name))
+	if focus := args["focus"]; focus != "" {
+		text.WriteString(fmt.Sprintf("Concentrate on: %s\n\n", focus))
+	}
+	text.WriteString("Check at least: public network exposure (NSG rules, public IPs, firewall rules), " +
+		"encryption at rest and in transit (TLS versions, HTTPS-only), identity and access " +
+		"(managed identities, RBAC scope, local auth and shared keys), secrets in code or " +
+		"outputs not marked sensitive, and missing diagnostics/logging.\n\n")
+	text.WriteString("For each finding give the file and resource, the risk, its severity " +
+		"(high/medium/low) and a corrected HCL snippet. Treat the tool output below as " +
+		"ground truth and don't repeat findings that are only style.\n\n")
+
+	text.WriteString("## validate_terraform\n\n")
+	text.WriteString(s.runPromptTool(ctx, "validate_terraform", map[string]interface{}{"path": dir}))
+	text.WriteString("\n## lint_iac\n\n")
+	text.WriteString(s.runPromptTool(ctx, "lint_iac", map[string]interface{}{"path": dir, "type": "terraform"}))
+	text.WriteString("\n## Source\n\n")
+	text.WriteString(embedSources(dir, map[string]bool{".tf": true, ".tfvars": true}))
+
+	return userPrompt(fmt.Sprintf("Security review of %s", name), text.String()), nil
+}
+
+// buildExplainErrorPrompt renders explain_validation_error
+func (s *MCPServer) buildExplainErrorPrompt(ctx context.Context, args map[string]string) (*PromptGetResult, error) {
+	absPath, err := s.resolvePath(args["path"])
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %s", absPath)
+	}
+
+	tool, toolArgs, baseDir, err := validationTarget(absPath, info)
+	if err != nil {
+		return nil, err
+	}
+	name := s.displayPath(absPath)
+
+	result, err := s.callPromptTool(ctx, tool, toolArgs)
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Explain the validation errors in `%s` in plain language and show how to fix them.\n\n", name))
+	text.WriteString("For each error: say what the message means, point to the exact line that causes it, " +
+		"explain why it's wrong and give the corrected code. If several errors share a cause, " +
+		"explain the cause once.\n\n")
+	if reported := args["error"]; reported != "" {
+		text.WriteString("The user reported this error:\n\n")
+		text.WriteString(fmt.Sprintf("```\n%s\n```\n\n", strings.TrimSpace(reported)))
+	}
+
+	text.WriteString(fmt.Sprintf("## %s\n\n", tool))
+	if err != nil {
+		text.WriteString(fmt.Sprintf("Error: %s\n", err.Error()))
+	} else {
+		text.WriteString(resultText(result))
+		if report, ok := result.StructuredContent.(*DiagnosticsReport); ok {
+			if excerpts := diagnosticExcerpts(baseDir, report.Diagnostics); excerpts != "" {
+				text.WriteString("\n## Source lines\n\n")
+				text.WriteString(excerpts)
+			}
+			if report.Valid && args["error"] == "" {
+				text.WriteString("\nValidation passed locally. Explain what else could cause errors " +
+					"for this configuration, e.g. provider versions, missing variables or backend settings.\n")
+			}
+		}
+	}
+
+	return userPrompt(fmt.Sprintf("Explain validation errors in %s", name), text.String()), nil
+}
+
+// buildConvertBicepPrompt renders convert_bicep_to_terraform
+func (s *MCPServer) buildConvertBicepPrompt(ctx context.Context, args map[string]string) (*PromptGetResult, error) {
+	absPath, err := s.resolvePath(args["path"])
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(absPath), ".bicep") {
+		return nil, fmt.Errorf("file must have .bicep extension: %s", absPath)
+	}
+	src, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %s", absPath)
+	}
+	name := s.displayPath(absPath)
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Convert the Bicep file `%s` to Terraform using the azurerm provider.\n\n", name))
+	text.WriteString("- Map each Bicep resource to its azurerm resource and each property to the matching argument\n")
+	text.WriteString("- Turn params into variables (keep descriptions, defaults and @allowed values as validation blocks)\n")
+	text.WriteString("- Turn outputs into outputs, marking secrets as sensitive\n")
+	text.WriteString("- Replace symbolic references and dependsOn with resource references\n")
+	text.WriteString("- Add a `# TODO:` comment for anything without an azurerm equivalent instead of dropping it\n")
+	text.WriteString("- Split the result into main.tf, variables.tf and outputs.tf\n\n")
+
+	text.WriteString("## validate_bicep\n\n")
+	text.WriteString(s.runPromptTool(ctx, "validate_bicep", map[string]interface{}{"path": absPath}))
+	text.WriteString("\n## Source\n\n")
+	text.WriteString(fmt.Sprintf("`%s`:\n\n```bicep\n%s\n```\n", filepath.Base(absPath), strings.TrimRight(string(src), "\n")))
+
+	return userPrompt(fmt.Sprintf("Convert %s to Terraform", name), text.String()), nil
+}
+
+// =============================================================================
+// Prompt Helpers
+// =============================================================================
+
+// callPromptTool runs a registered tool for a prompt
+func (s *MCPServer) callPromptTool(ctx context.Context, name string, args map[string]interface{}) (*ToolCallResult, error) {
+	handler, ok := s.tools.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+	return handler(ctx, args)
+}
+
+// runPromptTool runs a registered tool and returns its text output, or the
+// error it failed with
+func (s *MCPServer) runPromptTool(ctx context.Context, name string, args map[string]interface{}) string {
+	result, err := s.callPromptTool(ctx, name, args)
+	if err != nil {
+		return fmt.Sprintf("Error: %s\n", err.Error())
+	}
+	return resultText(result)
+}
+
+// resultText joins the text content blocks of a tool result
+func resultText(result *ToolCallResult) string {
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(strings.TrimRight(block.Text, "\n"))
+			text.WriteString("\n")
+		}
+	}
+	return text.String()
+}
+
+// userPrompt wraps text in a single-message prompt result
+func userPrompt(description, text string) *PromptGetResult {
+	return &PromptGetResult{
+		Description: description,
+		Messages: []PromptMessage{{
+			Role:    "user",
+			Content: ContentBlock{Type: "text", Text: text},
+		}},
+	}
+}
+
+// embedSources returns the files directly in dir with one of exts as fenced
+// code blocks, up to promptSourceLimit bytes
+func embedSources(dir string, exts map[string]bool) string {
+	files, err := walkIaCFiles(dir, false, exts)
+	if err != nil || len(files) == 0 {
+		return "(no source files found)\n"
+	}
+	sort.Strings(files)
+
+	var out strings.Builder
+	size := 0
+	for i, name := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if size+len(data) > promptSourceLimit {
+			out.WriteString(fmt.Sprintf("(%d more file(s) omitted: the source is too large to embed)\n", len(files)-i))
+			break
+		}
+		size += len(data)
+		lang := "hcl"
+		if formatTypeFor(name) == "bicep" {
+			lang = "bicep"
+		}
+		out.WriteString(fmt.Sprintf("`%s`:\n\n```%s\n%s\n```\n\n", filepath.ToSlash(name), lang, strings.TrimRight(string(data), "\n")))
+	}
+	return out.String()
+}
+
+// validationTarget picks the validation tool for absPath, its arguments and
+// the directory diagnostic paths are relative to. A .tf file validates its
+// whole directory, since Terraform errors often involve several files.
+func validationTarget(absPath string, info os.FileInfo) (tool string, toolArgs map[string]interface{}, baseDir string, err error) {
+	switch {
+	case info.IsDir():
+		return "validate_terraform", map[string]interface{}{"path": absPath}, absPath, nil
+	case formatTypeFor(absPath) == "bicep" && strings.HasSuffix(strings.ToLower(absPath), ".bicep"):
+		return "validate_bicep", map[string]interface{}{"path": absPath}, filepath.Dir(absPath), nil
+	case formatTypeFor(absPath) == "terraform":
+		dir := filepath.Dir(absPath)
+		return "validate_terraform", map[string]interface{}{"path": dir}, dir, nil
+	}
+	return "", nil, "", fmt.Errorf("path must be a Terraform directory, .tf file or .bicep file: %s", absPath)
+}
+
+// diagnosticExcerpts quotes the source lines around each error and warning
+// that has a location. Relative file names are resolved against baseDir.
+func diagnosticExcerpts(baseDir string, diags []Diagnostic) string {
+	var out strings.Builder
+	count := 0
+	for _, diag := range diags {
+		if diag.Severity == severityInfo {
+			continue
+		}
+		if count == promptMaxExcerpts {
+			out.WriteString("(more diagnostics omitted)\n")
+			break
+		}
+
+		excerpt := diagnosticExcerpt(baseDir, diag)
+		if excerpt == "" {
+			continue
+		}
+		count++
+		out.WriteString(fmt.Sprintf("%s:%d: %s\n\n```\n%s```\n\n", filepath.ToSlash(diag.File), diag.Start.Line, diag.Summary, excerpt))
+	}
+	return out.String()
+}
+
+// diagnosticExcerpt returns the source lines around diag, or "" when it has
+// no location
+func diagnosticExcerpt(baseDir string, diag Diagnostic) string {
+	if diag.File == "" || diag.Start == nil {
+		return ""
+	}
+	path := diag.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, filepath.FromSlash(path))
+	}
+	endLine := diag.Start.Line
+	if diag.End != nil && diag.End.Line > endLine {
+		endLine = diag.End.Line
+	}
+	return sourceExcerpt(path, diag.Start.Line, endLine, promptExcerptLines)
+}
+
+// sourceExcerpt returns lines start..end of path (1-based) with context lines
+// around them, numbered and with the lines in range marked by ">". It returns
+// "" if the file can't be read or the range is outside the file.
+func sourceExcerpt(path string, start, end, context int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if start < 1 || start > len(lines) {
+		return ""
+	}
+	from := max(start-context, 1)
+	to := min(max(end, start)+context, len(lines))
+	width := len(fmt.Sprint(to))
+
+	var out strings.Builder
+	for n := from; n <= to; n++ {
+		marker := " "
+		if n >= start && n <= end {
+			marker = ">"
+		}
+		out.WriteString(fmt.Sprintf("%s %*d | %s\n", marker, width, n, lines[n-1]))
+	}
+	return out.String()
+}
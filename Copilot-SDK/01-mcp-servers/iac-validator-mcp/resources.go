@@ -0,0 +1,322 @@
+// =============================================================================
+// MCP Resources
+// =============================================================================
+// Publishes every Terraform and Bicep file in the workspace as an MCP resource
+// so clients can list, read and subscribe to them. Subscribed files are polled
+// for changes and a notifications/resources/updated message is sent whenever
+// one is edited on disk.
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resourceExtensions maps IaC file extensions to the MIME type reported for them
+var resourceExtensions = map[string]string{
+	".tf":         "text/x-terraform",
+	".tfvars":     "text/x-terraform",
+	".bicep":      "text/x-bicep",
+	".bicepparam": "text/x-bicep",
+}
+
+// resourcePollInterval is how often subscribed files are checked for changes
+const resourcePollInterval = 2 * time.Second
+
+// ResourcesCapability indicates resource support
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// Resource describes a single resource exposed by the server
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourcesListResult contains the list of available resources
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+// ResourceParams contains the URI argument shared by read/subscribe/unsubscribe
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ResourceContents holds the text of a resource
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// ResourcesReadResult is returned from resources/read
+type ResourcesReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// =============================================================================
+// Resource Handlers
+// =============================================================================
+
+// handleResourcesList returns every IaC file in the workspace roots
+func (s *MCPServer) handleResourcesList(req *JSONRPCRequest) {
+	roots := s.roots()
+	resources := []Resource{}
+
+	for _, root := range roots {
+		files, err := walkIaCFiles(root, true, resourceExtensionSet())
+		if err != nil {
+			s.sendError(req.ID, -32603, "Internal error", err.Error())
+			return
+		}
+
+		for _, relPath := range files {
+			name := filepath.ToSlash(relPath)
+			if len(roots) > 1 {
+				name = filepath.Base(root) + "/" + name
+			}
+			resources = append(resources, Resource{
+				URI:         fileURI(filepath.Join(root, relPath)),
+				Name:        name,
+				Description: describeIaCFile(relPath),
+				MimeType:    resourceExtensions[strings.ToLower(filepath.Ext(relPath))],
+			})
+		}
+	}
+
+	s.sendResult(req.ID, ResourcesListResult{Resources: resources})
+}
+
+// handleResourcesRead returns the contents of a single IaC file
+func (s *MCPServer) handleResourcesRead(req *JSONRPCRequest) {
+	path, ok := s.resolveResourceURI(req)
+	if !ok {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.sendError(req.ID, -32002, "Resource not found", err.Error())
+		return
+	}
+
+	s.sendResult(req.ID, ResourcesReadResult{
+		Contents: []ResourceContents{{
+			URI:      fileURI(path),
+			MimeType: resourceExtensions[strings.ToLower(filepath.Ext(path))],
+			Text:     string(data),
+		}},
+	})
+}
+
+// handleResourcesSubscribe starts watching an IaC file for changes
+func (s *MCPServer) handleResourcesSubscribe(req *JSONRPCRequest) {
+	path, ok := s.resolveResourceURI(req)
+	if !ok {
+		return
+	}
+
+	if err := s.resources.subscribe(path); err != nil {
+		s.sendError(req.ID, -32002, "Resource not found", err.Error())
+		return
+	}
+	s.sendResult(req.ID, map[string]string{})
+}
+
+// handleResourcesUnsubscribe stops watching an IaC file
+func (s *MCPServer) handleResourcesUnsubscribe(req *JSONRPCRequest) {
+	path, ok := s.resolveResourceURI(req)
+	if !ok {
+		return
+	}
+
+	s.resources.unsubscribe(path)
+	s.sendResult(req.ID, map[string]string{})
+}
+
+// resolveResourceURI parses the uri parameter of a resource request and maps
+// it to a file inside the workspace roots. On failure an error response is
+// sent and ok is false.
+func (s *MCPServer) resolveResourceURI(req *JSONRPCRequest) (path string, ok bool) {
+	var params ResourceParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		s.sendError(req.ID, -32602, "Invalid params", "uri parameter is required")
+		return "", false
+	}
+
+	path, err := pathFromFileURI(params.URI)
+	if err != nil {
+		s.sendError(req.ID, -32602, "Invalid params", err.Error())
+		return "", false
+	}
+
+	path, err = s.resolvePath(path)
+	if err != nil {
+		s.sendError(req.ID, errCodePathNotAllowed, "Path not allowed", err.Error())
+		return "", false
+	}
+	if _, known := resourceExtensions[strings.ToLower(filepath.Ext(path))]; !known {
+		s.sendError(req.ID, -32002, "Resource not found", params.URI)
+		return "", false
+	}
+
+	return path, true
+}
+
+// =============================================================================
+// Change Watcher
+// =============================================================================
+
+// resourceWatcher polls subscribed files and notifies the client on change
+type resourceWatcher struct {
+	server *MCPServer
+
+	mu      sync.Mutex
+	modTime map[string]time.Time
+	started sync.Once
+	done    chan struct{}
+	stopped sync.Once
+}
+
+// newResourceWatcher creates a watcher that reports changes through server
+func newResourceWatcher(server *MCPServer) *resourceWatcher {
+	return &resourceWatcher{
+		server:  server,
+		modTime: make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+}
+
+// subscribe records the current modification time of path and starts the
+// polling loop on first use
+func (w *resourceWatcher) subscribe(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.modTime[path] = info.ModTime()
+	w.mu.Unlock()
+
+	w.started.Do(func() { go w.poll() })
+	return nil
+}
+
+// unsubscribe stops watching path
+func (w *resourceWatcher) unsubscribe(path string) {
+	w.mu.Lock()
+	delete(w.modTime, path)
+	w.mu.Unlock()
+}
+
+// stop ends the polling loop
+func (w *resourceWatcher) stop() {
+	w.stopped.Do(func() { close(w.done) })
+}
+
+// poll checks subscribed files every resourcePollInterval until stopped
+func (w *resourceWatcher) poll() {
+	ticker := time.NewTicker(resourcePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, path := range w.changed() {
+			debugLog("Resource changed: %s", path)
+			w.server.sendNotification("notifications/resources/updated", ResourceParams{URI: fileURI(path)})
+		}
+	}
+}
+
+// changed returns the subscribed files modified (or removed) since last check
+func (w *resourceWatcher) changed() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var changed []string
+	for path, last := range w.modTime {
+		var current time.Time
+		if info, err := os.Stat(path); err == nil {
+			current = info.ModTime()
+		}
+		if !current.Equal(last) {
+			w.modTime[path] = current
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// =============================================================================
+// Helpers
+// =============================================================================
+
+// resourceExtensionSet returns the resource extensions in the form expected by
+// walkIaCFiles
+func resourceExtensionSet() map[string]bool {
+	exts := make(map[string]bool, len(resourceExtensions))
+	for ext := range resourceExtensions {
+		exts[ext] = true
+	}
+	return exts
+}
+
+// describeIaCFile returns a short human description of an IaC file
+func describeIaCFile(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tf":
+		return "Terraform configuration"
+	case ".tfvars":
+		return "Terraform variable values"
+	case ".bicep":
+		return "Bicep template"
+	case ".bicepparam":
+		return "Bicep parameters"
+	}
+	return ""
+}
+
+// fileURI converts an absolute path to a file:// URI
+func fileURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path // Windows drive letters
+	}
+	return u.String()
+}
+
+// pathFromFileURI converts a file:// URI back to a cleaned absolute path
+func pathFromFileURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid uri: %w", err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported uri scheme: %s (only file:// is supported)", u.Scheme)
+	}
+
+	path := filepath.FromSlash(u.Path)
+	if len(path) >= 3 && path[0] == filepath.Separator && path[2] == ':' {
+		path = path[1:] // Windows drive letters
+	}
+	return filepath.Clean(path), nil
+}
@@ -0,0 +1,221 @@
+// =============================================================================
+// Workspace Roots & Path Policy
+// =============================================================================
+// Every path a tool or resource request touches must resolve, after following
+// symlinks and `..`, to a location inside one of the workspace roots. Roots
+// come from the --root flag and from the client through the MCP roots
+// capability:
+//
+//   - --root only:        the flag roots are used
+//   - client roots only:  client roots are used if they lie inside the
+//                         directory the server was started in
+//   - both:               client roots are used if they lie inside a flag root
+//   - neither:            the directory the server was started in
+//
+// Client roots can only narrow the sandbox, never widen it: over HTTP the
+// client is whoever holds the token, so a client announcing file:/// must not
+// open up the whole machine.
+//
+// Paths outside the roots are rejected with a JSON-RPC error.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errCodePathNotAllowed is the JSON-RPC error code for paths outside the roots
+const errCodePathNotAllowed = -32602
+
+// rootsRequestTimeout bounds how long the server waits for roots/list
+const rootsRequestTimeout = 10 * time.Second
+
+// Root is a workspace root as reported by the client
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// PathNotAllowedError reports a path argument outside the workspace roots
+type PathNotAllowedError struct {
+	Path  string
+	Roots []string
+}
+
+// Error implements error
+func (e *PathNotAllowedError) Error() string {
+	return fmt.Sprintf("path %s is outside the workspace roots (%s)", e.Path, strings.Join(e.Roots, ", "))
+}
+
+// stringList is a flag.Value collecting repeated flags
+type stringList []string
+
+// String implements flag.Value
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+// Set implements flag.Value
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// =============================================================================
+// Root Management
+// =============================================================================
+
+// canonicalRoots turns root directories into absolute, symlink-free paths,
+// dropping the ones that don't exist
+func canonicalRoots(paths []string) []string {
+	var roots []string
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		real, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			debugLog("Ignoring root %s: %v", p, err)
+			continue
+		}
+		roots = append(roots, real)
+	}
+	return roots
+}
+
+// roots returns the directories path arguments are currently restricted to
+func (s *MCPServer) roots() []string {
+	s.rootsMu.Lock()
+	defer s.rootsMu.Unlock()
+
+	if len(s.clientRoots) > 0 {
+		return s.clientRoots
+	}
+	if len(s.config.Roots) > 0 {
+		return s.config.Roots
+	}
+	return []string{s.defaultRoot}
+}
+
+// setClientRoots replaces the roots announced by the client. Client roots
+// outside --root, or outside the default root without it, are ignored.
+func (s *MCPServer) setClientRoots(roots []Root) {
+	var paths []string
+	for _, root := range roots {
+		path, err := pathFromFileURI(root.URI)
+		if err != nil {
+			debugLog("Ignoring root %s: %v", root.URI, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	limit := s.config.Roots
+	if len(limit) == 0 {
+		limit = []string{s.defaultRoot}
+	}
+	var allowed []string
+	for _, path := range canonicalRoots(paths) {
+		if withinRoots(path, limit) {
+			allowed = append(allowed, path)
+		} else {
+			debugLog("Ignoring client root outside %v: %s", limit, path)
+		}
+	}
+
+	s.rootsMu.Lock()
+	s.clientRoots = allowed
+	s.rootsMu.Unlock()
+
+	debugLog("Workspace roots: %v", s.roots())
+}
+
+// refreshRoots asks the client for its roots. It must run outside the read
+// loop because the response arrives through it.
+func (s *MCPServer) refreshRoots() {
+	ctx, cancel := context.WithTimeout(context.Background(), rootsRequestTimeout)
+	defer cancel()
+
+	result, err := s.callClient(ctx, "roots/list", nil)
+	if err != nil {
+		debugLog("roots/list failed: %v", err)
+		return
+	}
+
+	var list struct {
+		Roots []Root `json:"roots"`
+	}
+	if err := json.Unmarshal(result, &list); err != nil {
+		debugLog("Invalid roots/list result: %v", err)
+		return
+	}
+	s.setClientRoots(list.Roots)
+}
+
+// =============================================================================
+// Path Resolution
+// =============================================================================
+
+// resolvePath turns a path argument into an absolute, symlink-free path and
+// checks it against the workspace roots. Relative paths are resolved against
+// the first root.
+func (s *MCPServer) resolvePath(path string) (string, error) {
+	roots := s.roots()
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(roots[0], path)
+	}
+	path = filepath.Clean(path)
+
+	real, err := evalExistingSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if !withinRoots(real, roots) {
+		return "", &PathNotAllowedError{Path: path, Roots: roots}
+	}
+	return real, nil
+}
+
+// evalExistingSymlinks resolves symlinks in the longest existing prefix of
+// path, so paths that don't exist yet are still checked against the roots
+func evalExistingSymlinks(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	realParent, err := evalExistingSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+// withinRoots reports whether path is one of roots or below one of them
+func withinRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
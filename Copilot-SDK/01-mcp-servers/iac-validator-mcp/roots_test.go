@@ -0,0 +1,130 @@
+// =============================================================================
+// Workspace Roots & Path Policy Tests
+// =============================================================================
+// Each test builds a workspace in a temporary directory, with a sibling
+// "outside" directory that paths must never reach, whether through `..`,
+// a symlink or a client root.
+// =============================================================================
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRootsFixture creates <tmp>/workspace and <tmp>/outside and returns a
+// server restricted to the workspace with their canonical paths
+func newRootsFixture(t *testing.T) (server *MCPServer, workspace, outside string) {
+	t.Helper()
+	base := canonicalRoots([]string{t.TempDir()})[0]
+	workspace = filepath.Join(base, "workspace")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(workspace, "env"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.tf"), []byte("# secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server = NewMCPServer(strings.NewReader(""), io.Discard, Config{Roots: []string{workspace}})
+	t.Cleanup(server.Close)
+	return server, workspace, outside
+}
+
+func TestResolvePathInsideRoot(t *testing.T) {
+	server, workspace, _ := newRootsFixture(t)
+
+	for _, path := range []string{"env", filepath.Join(workspace, "env"), "env/missing.tf", "env/../env"} {
+		got, err := server.resolvePath(path)
+		if err != nil {
+			t.Errorf("resolvePath(%q): %v", path, err)
+			continue
+		}
+		if !withinRoots(got, []string{workspace}) {
+			t.Errorf("resolvePath(%q) = %s, outside %s", path, got, workspace)
+		}
+	}
+}
+
+func TestResolvePathRejectsDotDotEscape(t *testing.T) {
+	server, workspace, outside := newRootsFixture(t)
+
+	for _, path := range []string{
+		"../outside/secret.tf",
+		"env/../../outside",
+		filepath.Join(workspace, "..", "outside", "secret.tf"),
+		outside,
+		"/",
+	} {
+		assertPathNotAllowed(t, server, path)
+	}
+}
+
+func TestResolvePathRejectsSymlinkEscape(t *testing.T) {
+	server, workspace, outside := newRootsFixture(t)
+
+	link := filepath.Join(workspace, "env", "shared")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// The link itself, a file behind it, and a file that doesn't exist yet
+	// behind it all resolve outside the workspace
+	for _, path := range []string{"env/shared", "env/shared/secret.tf", "env/shared/new/main.tf"} {
+		assertPathNotAllowed(t, server, path)
+	}
+}
+
+func TestClientRootsConfinedToFlagRoots(t *testing.T) {
+	server, workspace, outside := newRootsFixture(t)
+
+	server.setClientRoots([]Root{
+		{URI: fileURI(filepath.Join(workspace, "env"))},
+		{URI: fileURI(outside)},
+		{URI: "file:///"},
+	})
+	if got, want := server.roots(), []string{filepath.Join(workspace, "env")}; !equalStrings(got, want) {
+		t.Errorf("roots = %v, want %v", got, want)
+	}
+	assertPathNotAllowed(t, server, filepath.Join(outside, "secret.tf"))
+
+	// With only escaping client roots, the flag roots stay in force
+	server.setClientRoots([]Root{{URI: fileURI(outside)}, {URI: "file:///"}})
+	if got, want := server.roots(), []string{workspace}; !equalStrings(got, want) {
+		t.Errorf("roots = %v, want %v", got, want)
+	}
+}
+
+func TestClientRootsConfinedToDefaultRoot(t *testing.T) {
+	server := NewMCPServer(strings.NewReader(""), io.Discard, Config{})
+	defer server.Close()
+
+	testdata := filepath.Join(server.defaultRoot, "testdata")
+	server.setClientRoots([]Root{{URI: "file:///"}, {URI: fileURI(testdata)}})
+	if got, want := server.roots(), []string{testdata}; !equalStrings(got, want) {
+		t.Errorf("roots = %v, want %v", got, want)
+	}
+
+	// Without --root, file:/// must not widen the sandbox to the machine
+	server.setClientRoots([]Root{{URI: "file:///"}})
+	if got, want := server.roots(), []string{server.defaultRoot}; !equalStrings(got, want) {
+		t.Errorf("roots = %v, want %v", got, want)
+	}
+	assertPathNotAllowed(t, server, "/etc/passwd")
+}
+
+func assertPathNotAllowed(t *testing.T, server *MCPServer, path string) {
+	t.Helper()
+	got, err := server.resolvePath(path)
+	var notAllowed *PathNotAllowedError
+	if !errors.As(err, &notAllowed) {
+		t.Errorf("resolvePath(%q) = %q, %v; want PathNotAllowedError", path, got, err)
+	}
+}
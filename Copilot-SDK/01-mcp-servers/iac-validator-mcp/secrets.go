@@ -0,0 +1,521 @@
+// =============================================================================
+// Secret Scanning Tool
+// =============================================================================
+// Finds credentials committed in Terraform, Bicep, .tfvars and .bicepparam
+// files. Every string literal, heredoc and multi-line string is checked for:
+//
+//   - known formats: storage and Service Bus connection strings, account
+//     keys, SAS tokens, Entra ID client secrets and private keys
+//   - literals assigned to secret-looking names such as admin_password,
+//     administratorLoginPassword or a Terraform variable's default
+//   - high-entropy strings (reported as warnings)
+//
+// Values that come from elsewhere are not literals and are never reported:
+// var.admin_password, '${adminPassword}', Key Vault references
+// (@Microsoft.KeyVault(...), getSecret(...)) and interpolated parts of a
+// string such as AccountKey=${key}. Comments are skipped. Findings are
+// reported as diagnostics with the secret redacted.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// interpolationMask replaces the interpolated parts of a literal, keeping
+// columns intact
+const interpolationMask = '\x00'
+
+// secretRule is a known credential format. The first group of pattern is
+// the secret; patterns without a group are redacted as a whole.
+type secretRule struct {
+	code    string
+	summary string
+	pattern *regexp.Regexp
+}
+
+// secretRules are checked in order; a later rule never reports a span an
+// earlier one already covered
+var secretRules = []secretRule{
+	{"private-key", "Private key", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |ENCRYPTED )?PRIVATE KEY-----`)},
+	{"azure-storage-connection-string", "Storage account connection string with an account key", regexp.MustCompile(`(?i)DefaultEndpointsProtocol=[^;]*;.*?AccountKey=([^;'"\s\x00]+)`)},
+	{"azure-account-key", "Connection string with an account key", regexp.MustCompile(`(?i)\bAccountKey=([^;'"\s\x00]+)`)},
+	{"azure-shared-access-key", "Connection string with a shared access key", regexp.MustCompile(`(?i)\bSharedAccessKey=([^;'"\s\x00]+)`)},
+	{"azure-sas-token", "Shared access signature (SAS) token", regexp.MustCompile(`\bsv=\d{4}-\d{2}-\d{2}&[^'"\s\x00]*?\bsig=([A-Za-z0-9%+/=]{20,})`)},
+	{"connection-string-password", "Connection string with a password", regexp.MustCompile(`(?i)(?:^|;)\s*(?:password|pwd)\s*=\s*([^;'"\x00]+)`)},
+	{"azure-client-secret", "Entra ID client secret", regexp.MustCompile(`\b([A-Za-z0-9_~.-]{3}\dQ~[A-Za-z0-9_~.-]{31,34})\b`)},
+	{"azure-storage-account-key", "Storage account key", regexp.MustCompile(`(?:^|[^A-Za-z0-9+/])([A-Za-z0-9+/]{86}==)`)},
+}
+
+// secretKeyPattern matches attribute, parameter and variable names that hold
+// secrets
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|pwd|secret|token|api_?key|access_?key|account_?key|primary_?key|secondary_?key|shared_?key|private_?key|connection_?string|sas)`)
+
+// secretKeyExemptPattern matches secret-looking names that describe a secret
+// rather than hold one, such as secret_name or password_length
+var secretKeyExemptPattern = regexp.MustCompile(`(?i)(name|names|id|ids|uri|url|type|enabled|version|expiration|expiry|period|policy|policies|permissions|length|count|days|size|rules?|mode|kind|format)$`)
+
+// placeholderPattern matches obvious placeholder values
+var placeholderPattern = regexp.MustCompile(`^(<[^>]*>|\*+|\.\.\.|x+|X+)$`)
+
+// assignedKeyPattern finds the name a value is assigned to at the end of the
+// code before it: `name = `, `name: `, `"name" = ` or `param name string = `
+var assignedKeyPattern = regexp.MustCompile(`["']?([A-Za-z_][\w.-]*)["']?(?:\s+(?:string|int|bool|object|array))?\s*[:=]\s*$`)
+
+// heredocPattern matches the start of a Terraform heredoc
+var heredocPattern = regexp.MustCompile(`^<<-?([A-Za-z_]\w*)\s*$`)
+
+// variableBlockPattern matches the header of a Terraform variable block
+var variableBlockPattern = regexp.MustCompile(`^\s*variable\s+"([^"]+)"`)
+
+// entropyTokenPattern splits literals into base64/hex-like words
+var entropyTokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_-]{20,}`)
+
+// guidPattern matches GUIDs, which identify rather than authenticate
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// hexPattern matches hex strings
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// Minimum Shannon entropy, in bits per character, of a high-entropy string
+const (
+	base64EntropyThreshold = 4.3
+	hexEntropyThreshold    = 3.0
+)
+
+// secretLiteral is a string literal, or one line of a heredoc or multi-line
+// string
+type secretLiteral struct {
+	line   int
+	column int    // 1-based column of the first character of text
+	text   string // contents, with interpolations masked
+	key    string // name the literal is assigned to, if any
+	block  int    // heredoc or multi-line string the line belongs to, or 0
+}
+
+// handleScanSecrets scans IaC files for hard-coded credentials
+func (s *MCPServer) handleScanSecrets(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+	recursive := true
+	if r, ok := args["recursive"].(bool); ok {
+		recursive = r
+	}
+
+	absPath, err := s.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %s", absPath)
+	}
+
+	dir := absPath
+	var files []string
+	if info.IsDir() {
+		if files, err = walkIaCFiles(dir, recursive, resourceExtensionSet()); err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+	} else {
+		if _, ok := resourceExtensions[strings.ToLower(filepath.Ext(absPath))]; !ok {
+			return nil, fmt.Errorf("unsupported file type: %s (use .tf, .tfvars, .bicep or .bicepparam)", filepath.Base(absPath))
+		}
+		dir = filepath.Dir(absPath)
+		files = []string{filepath.Base(absPath)}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Terraform or Bicep files found at %s", absPath)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🔐 Scanning for secrets in: %s\n\n", absPath))
+
+	var diags []Diagnostic
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		src, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		diags = append(diags, scanSecrets(file, string(src))...)
+	}
+
+	report := newDiagnosticsReport("scan_secrets", diags)
+	output.WriteString(fmt.Sprintf("📄 Files scanned: %d\n\n", len(files)))
+	switch {
+	case !report.Valid:
+		output.WriteString(fmt.Sprintf("❌ **%d secret(s), %d suspicious value(s)**\n\n", report.ErrorCount, report.WarningCount))
+	case report.WarningCount > 0:
+		output.WriteString(fmt.Sprintf("⚠️ **%d suspicious value(s)**\n\n", report.WarningCount))
+	default:
+		output.WriteString("✅ **No secrets found!**\n\n")
+	}
+	writeDiagnostics(&output, diags)
+	return diagnosticsResult(output.String(), report), nil
+}
+
+// scanSecrets returns the secrets found in the source of file
+func scanSecrets(file, src string) []Diagnostic {
+	bicep := formatTypeFor(file) == "bicep"
+	lines := strings.Split(src, "\n")
+
+	var diags []Diagnostic
+	reported := make(map[int]bool)
+	for _, lit := range scanLiterals(src, bicep) {
+		// A heredoc such as a private key is reported once
+		if reported[lit.block] {
+			continue
+		}
+		// Key Vault references resolve the secret at deployment time
+		if strings.Contains(lit.text, "@Microsoft.KeyVault(") || strings.Contains(lines[lit.line-1], "getSecret(") {
+			continue
+		}
+
+		found := secretPatterns(file, lit, bicep)
+		if len(found) == 0 {
+			if diag, ok := secretAssignment(file, lit, bicep); ok {
+				found = append(found, diag)
+			}
+		}
+		if len(found) == 0 {
+			found = highEntropyStrings(file, lit, bicep)
+		}
+		if len(found) > 0 && lit.block != 0 {
+			reported[lit.block] = true
+		}
+		diags = append(diags, found...)
+	}
+	return diags
+}
+
+// secretPatterns reports the known credential formats in lit
+func secretPatterns(file string, lit secretLiteral, bicep bool) []Diagnostic {
+	var diags []Diagnostic
+	var covered [][2]int
+	for _, rule := range secretRules {
+		for _, match := range rule.pattern.FindAllStringSubmatchIndex(lit.text, -1) {
+			start, end := match[0], match[1]
+			if len(match) > 2 && match[2] >= 0 {
+				start, end = match[2], match[3]
+			}
+			if overlaps(covered, start, end) {
+				continue
+			}
+			covered = append(covered, [2]int{start, end})
+
+			value := lit.text[start:end]
+			if rule.pattern.NumSubexp() == 0 {
+				value = ""
+			}
+			diags = append(diags, secretDiagnostic(file, lit, start, end, severityError, rule.code, rule.summary, value, bicep))
+		}
+	}
+	return diags
+}
+
+// secretAssignment reports a literal assigned to a secret-looking name
+func secretAssignment(file string, lit secretLiteral, bicep bool) (Diagnostic, bool) {
+	if lit.key == "" || !secretKeyPattern.MatchString(lit.key) || secretKeyExemptPattern.MatchString(lit.key) {
+		return Diagnostic{}, false
+	}
+	value := strings.TrimSpace(strings.ReplaceAll(lit.text, string(interpolationMask), ""))
+	if value == "" || placeholderPattern.MatchString(value) {
+		return Diagnostic{}, false
+	}
+	summary := fmt.Sprintf("Hard-coded value for %s", lit.key)
+	return secretDiagnostic(file, lit, 0, len(lit.text), severityError, "hard-coded-secret", summary, value, bicep), true
+}
+
+// highEntropyStrings reports random-looking words in lit. Base64 words must
+// mix upper case, lower case and digits, which rules out resource names.
+func highEntropyStrings(file string, lit secretLiteral, bicep bool) []Diagnostic {
+	var diags []Diagnostic
+	for _, loc := range entropyTokenPattern.FindAllStringIndex(lit.text, -1) {
+		token := lit.text[loc[0]:loc[1]]
+		if guidPattern.MatchString(token) || strings.HasPrefix(lit.text, "/subscriptions/") || strings.HasPrefix(lit.text, "/providers/") {
+			continue
+		}
+
+		threshold := base64EntropyThreshold
+		if hexPattern.MatchString(token) {
+			if len(token) < 32 {
+				continue
+			}
+			threshold = hexEntropyThreshold
+		} else if !strings.ContainsAny(token, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") ||
+			!strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz") ||
+			!strings.ContainsAny(token, "0123456789") {
+			continue
+		}
+
+		entropy := shannonEntropy(token)
+		if entropy < threshold {
+			continue
+		}
+		summary := fmt.Sprintf("High-entropy string (%.1f bits per character)", entropy)
+		diags = append(diags, secretDiagnostic(file, lit, loc[0], loc[1], severityWarning, "high-entropy-string", summary, token, bicep))
+	}
+	return diags
+}
+
+// secretDiagnostic builds the finding for lit.text[start:end]. value is shown
+// redacted; an empty value is not shown at all.
+func secretDiagnostic(file string, lit secretLiteral, start, end int, severity, code, summary, value string, bicep bool) Diagnostic {
+	detail := "Move the value to a sensitive variable or a Key Vault secret and reference it instead."
+	if bicep {
+		detail = "Move the value to a @secure() parameter or read it with getSecret() from Key Vault."
+	}
+	if value != "" {
+		detail = fmt.Sprintf("Value: %s. %s", redactSecret(value), detail)
+	}
+	return Diagnostic{
+		Severity: severity,
+		Code:     code,
+		File:     filepath.ToSlash(file),
+		Start:    &Position{Line: lit.line, Column: lit.column + start},
+		End:      &Position{Line: lit.line, Column: lit.column + end},
+		Summary:  summary,
+		Detail:   detail,
+	}
+}
+
+// redactSecret keeps the first characters of value so a finding can be
+// recognized, without revealing the secret or its length
+func redactSecret(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 6 {
+		return "********"
+	}
+	return string(runes[:3]) + "********"
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// overlaps reports whether start:end overlaps one of spans
+func overlaps(spans [][2]int, start, end int) bool {
+	for _, span := range spans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// Literal Scanner
+// =============================================================================
+
+// scanLiterals returns the string literals in src, skipping comments.
+// Terraform strings are double-quoted and may be heredocs; Bicep strings are
+// single-quoted and may be multi-line ”' strings.
+func scanLiterals(src string, bicep bool) []secretLiteral {
+	quote := byte('"')
+	if bicep {
+		quote = '\''
+	}
+
+	var literals []secretLiteral
+	heredoc := ""      // closing marker of the open heredoc
+	multiline := false // inside a Bicep ''' string
+	multilineKey := "" // key of the open heredoc or multi-line string
+	block := 0         // number of the last heredoc or multi-line string
+	inComment := false // inside a /* */ comment
+	depth := 0         // brace depth outside strings
+	variable := ""     // name of the enclosing Terraform variable block
+
+	for i, line := range strings.Split(src, "\n") {
+		lineNo := i + 1
+		line = strings.TrimRight(line, "\r")
+
+		if heredoc != "" {
+			if strings.TrimSpace(line) == heredoc {
+				heredoc = ""
+			} else {
+				literals = append(literals, secretLiteral{line: lineNo, column: 1, text: maskInterpolations(line, bicep), key: multilineKey, block: block})
+			}
+			continue
+		}
+
+		j := 0
+		if multiline {
+			end := strings.Index(line, "'''")
+			if end < 0 {
+				literals = append(literals, secretLiteral{line: lineNo, column: 1, text: line, key: multilineKey, block: block})
+				continue
+			}
+			literals = append(literals, secretLiteral{line: lineNo, column: 1, text: line[:end], key: multilineKey, block: block})
+			multiline = false
+			j = end + 3
+		}
+
+		if !bicep && depth == 0 {
+			if match := variableBlockPattern.FindStringSubmatch(line); match != nil {
+				variable = match[1]
+			}
+		}
+
+		for j < len(line) {
+			if inComment {
+				if strings.HasPrefix(line[j:], "*/") {
+					inComment = false
+					j += 2
+				} else {
+					j++
+				}
+				continue
+			}
+
+			c := line[j]
+			switch {
+			case strings.HasPrefix(line[j:], "/*"):
+				inComment = true
+				j += 2
+			case strings.HasPrefix(line[j:], "//") || (!bicep && c == '#'):
+				j = len(line)
+			case bicep && strings.HasPrefix(line[j:], "'''"):
+				key := assignedKey(line[:j], variable)
+				rest := line[j+3:]
+				if end := strings.Index(rest, "'''"); end >= 0 {
+					literals = append(literals, secretLiteral{line: lineNo, column: j + 4, text: rest[:end], key: key})
+					j += 3 + end + 3
+					continue
+				}
+				block++
+				literals = append(literals, secretLiteral{line: lineNo, column: j + 4, text: rest, key: key, block: block})
+				multiline, multilineKey = true, key
+				j = len(line)
+			case c == quote:
+				end, text := readStringLiteral(line, j+1, quote, bicep)
+				literals = append(literals, secretLiteral{line: lineNo, column: j + 2, text: text, key: assignedKey(line[:j], variable)})
+				j = end + 1
+			case !bicep && c == '<' && heredocPattern.MatchString(line[j:]):
+				heredoc = heredocPattern.FindStringSubmatch(line[j:])[1]
+				multilineKey = assignedKey(line[:j], variable)
+				block++
+				j = len(line)
+			case c == '{':
+				depth++
+				j++
+			case c == '}':
+				if depth--; depth <= 0 {
+					depth, variable = 0, ""
+				}
+				j++
+			default:
+				j++
+			}
+		}
+	}
+	return literals
+}
+
+// readStringLiteral reads the string starting at line[start], just after the
+// opening quote. It returns the index of the closing quote (or the end of the
+// line) and the contents with interpolations masked.
+func readStringLiteral(line string, start int, quote byte, bicep bool) (int, string) {
+	var text strings.Builder
+	k := start
+	for k < len(line) {
+		c := line[k]
+		switch {
+		case c == '\\' && k+1 < len(line):
+			text.WriteString(line[k : k+2])
+			k += 2
+		case c == quote:
+			return k, text.String()
+		case !bicep && (strings.HasPrefix(line[k:], "$${") || strings.HasPrefix(line[k:], "%%{")):
+			// Escaped interpolation sequences are literal text
+			text.WriteString(line[k : k+3])
+			k += 3
+		case strings.HasPrefix(line[k:], "${") || (!bicep && strings.HasPrefix(line[k:], "%{")):
+			end := interpolationEnd(line, k+2, quote)
+			text.WriteString(strings.Repeat(string(interpolationMask), end-k))
+			k = end
+		default:
+			text.WriteByte(c)
+			k++
+		}
+	}
+	return k, text.String()
+}
+
+// interpolationEnd returns the index just past the } closing the
+// interpolation whose contents start at line[start]. Strings nested in the
+// interpolation are skipped.
+func interpolationEnd(line string, start int, quote byte) int {
+	depth := 1
+	k := start
+	for k < len(line) {
+		switch line[k] {
+		case quote:
+			for k++; k < len(line) && line[k] != quote; k++ {
+				if line[k] == '\\' {
+					k++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return k + 1
+			}
+		}
+		k++
+	}
+	return len(line)
+}
+
+// maskInterpolations masks the interpolations in one line of a heredoc
+func maskInterpolations(line string, bicep bool) string {
+	var text strings.Builder
+	for k := 0; k < len(line); {
+		if strings.HasPrefix(line[k:], "${") || (!bicep && strings.HasPrefix(line[k:], "%{")) {
+			end := interpolationEnd(line, k+2, '"')
+			text.WriteString(strings.Repeat(string(interpolationMask), end-k))
+			k = end
+			continue
+		}
+		text.WriteByte(line[k])
+		k++
+	}
+	return text.String()
+}
+
+// assignedKey returns the name a value starting after prefix is assigned to.
+// Inside a Terraform variable block, default resolves to the variable name.
+func assignedKey(prefix, variable string) string {
+	match := assignedKeyPattern.FindStringSubmatch(prefix)
+	if match == nil {
+		return ""
+	}
+	if match[1] == "default" && variable != "" {
+		return variable
+	}
+	return match[1]
+}
@@ -0,0 +1,817 @@
+// =============================================================================
+// Terraform State Tools
+// =============================================================================
+// Inspects local terraform.tfstate files (format version 4, Terraform 0.12+)
+// without the terraform binary or access to the backend:
+//
+//   - list_state_resources: resource addresses, providers and instance counts
+//   - show_state_resource:  the attributes of one resource, secrets redacted
+//   - find_state_orphans:   managed resources in state that the configuration
+//     no longer declares, i.e. what the next plan would destroy (stale data
+//     sources are listed separately; Terraform only drops them)
+//
+// Sensitive values are redacted using the state's sensitive_attributes and,
+// as a safety net, attribute names that usually hold secrets.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// defaultStateFile is looked up when a state tool is given a directory
+const defaultStateFile = "terraform.tfstate"
+
+// secretAttributePattern matches attribute names whose values are redacted
+// even when the state doesn't mark them sensitive
+var secretAttributePattern = regexp.MustCompile(`(?i)(password|secret|token|private_key|access_key|primary_key|secondary_key|connection_string|shared_key|sas|kube_config|kube_admin_config|client_certificate|client_key)`)
+
+// instanceKeyPattern matches instance keys such as [0] or ["name"] in an
+// address
+var instanceKeyPattern = regexp.MustCompile(`\[(?:\d+|"(?:[^"\\]|\\.)*")\]`)
+
+// =============================================================================
+// State File Format
+// =============================================================================
+
+// tfState is the subset of the tfstate v4 JSON format we read
+type tfState struct {
+	Version          int    `json:"version"`
+	TerraformVersion string `json:"terraform_version"`
+	Serial           int    `json:"serial"`
+	Lineage          string `json:"lineage"`
+	Outputs          map[string]struct {
+		Sensitive bool `json:"sensitive"`
+	} `json:"outputs"`
+	Resources []tfStateResource `json:"resources"`
+}
+
+// tfStateResource is one resource block of a state file
+type tfStateResource struct {
+	Module    string            `json:"module"`
+	Mode      string            `json:"mode"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Provider  string            `json:"provider"`
+	Instances []tfStateInstance `json:"instances"`
+}
+
+// tfStateInstance is one instance (count/for_each element) of a resource
+type tfStateInstance struct {
+	IndexKey            interface{}            `json:"index_key"`
+	Status              string                 `json:"status"`
+	Deposed             string                 `json:"deposed"`
+	Attributes          map[string]interface{} `json:"attributes"`
+	SensitiveAttributes []json.RawMessage      `json:"sensitive_attributes"`
+	Dependencies        []string               `json:"dependencies"`
+}
+
+// address returns the resource address without instance key, e.g.
+// module.net.azurerm_subnet.app
+func (r *tfStateResource) address() string {
+	address := r.Type + "." + r.Name
+	if r.Mode == "data" {
+		address = "data." + address
+	}
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	return address
+}
+
+// configAddress returns the address with all instance keys removed, as it
+// appears in the configuration
+func (r *tfStateResource) configAddress() string {
+	return instanceKeyPattern.ReplaceAllString(r.address(), "")
+}
+
+// instanceAddress returns the address of one instance, e.g. azurerm_subnet.app[0]
+func (r *tfStateResource) instanceAddress(instance tfStateInstance) string {
+	switch key := instance.IndexKey.(type) {
+	case float64:
+		return fmt.Sprintf("%s[%d]", r.address(), int(key))
+	case string:
+		quoted, _ := json.Marshal(key)
+		return fmt.Sprintf("%s[%s]", r.address(), quoted)
+	}
+	return r.address()
+}
+
+// providerName shortens provider["registry.terraform.io/hashicorp/azurerm"]
+// to hashicorp/azurerm
+func providerName(provider string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(provider, `provider["`), `"]`)
+	if i := strings.Index(name, `"]`); i >= 0 {
+		// Aliased: provider["..."].alias
+		name = name[:i] + name[i+2:]
+	}
+	return strings.TrimPrefix(name, "registry.terraform.io/")
+}
+
+// =============================================================================
+// Structured Results
+// =============================================================================
+
+// StateResource summarizes one resource in a state file
+type StateResource struct {
+	Address   string `json:"address"`
+	Mode      string `json:"mode"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Module    string `json:"module,omitempty"`
+	Provider  string `json:"provider"`
+	Instances int    `json:"instances"`
+}
+
+// StateSummary is the structured result of list_state_resources
+type StateSummary struct {
+	Path             string          `json:"path"`
+	TerraformVersion string          `json:"terraformVersion"`
+	Serial           int             `json:"serial"`
+	Lineage          string          `json:"lineage,omitempty"`
+	Outputs          []string        `json:"outputs,omitempty"`
+	Resources        []StateResource `json:"resources"`
+}
+
+// StateInstance holds the redacted attributes of one resource instance
+type StateInstance struct {
+	Address      string                 `json:"address"`
+	Status       string                 `json:"status,omitempty"`
+	Deposed      string                 `json:"deposed,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes"`
+	Dependencies []string               `json:"dependencies,omitempty"`
+}
+
+// StateResourceDetail is the structured result of show_state_resource
+type StateResourceDetail struct {
+	Path      string          `json:"path"`
+	Address   string          `json:"address"`
+	Provider  string          `json:"provider"`
+	Instances []StateInstance `json:"instances"`
+}
+
+// StateOrphan is a resource in state that the configuration doesn't declare
+type StateOrphan struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// StateOrphanReport is the structured result of find_state_orphans. Checked
+// and Orphans cover managed resources only; data sources are never destroyed,
+// so undeclared ones are listed separately as StaleDataSources.
+type StateOrphanReport struct {
+	Path             string        `json:"path"`
+	ConfigPath       string        `json:"configPath"`
+	Checked          int           `json:"checked"`
+	Orphans          []StateOrphan `json:"orphans"`
+	StaleDataSources []StateOrphan `json:"staleDataSources,omitempty"`
+	SkippedModules   []string      `json:"skippedModules,omitempty"`
+}
+
+// stateResourceSchema is the JSON Schema of StateResource
+var stateResourceSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"address":   map[string]interface{}{"type": "string"},
+		"mode":      map[string]interface{}{"type": "string", "enum": []string{"managed", "data"}},
+		"type":      map[string]interface{}{"type": "string"},
+		"name":      map[string]interface{}{"type": "string"},
+		"module":    map[string]interface{}{"type": "string"},
+		"provider":  map[string]interface{}{"type": "string"},
+		"instances": map[string]interface{}{"type": "integer"},
+	},
+	"required": []string{"address", "mode", "type", "name", "provider", "instances"},
+}
+
+// stateSummaryOutputSchema is the JSON Schema of StateSummary
+var stateSummaryOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":             map[string]interface{}{"type": "string"},
+		"terraformVersion": map[string]interface{}{"type": "string"},
+		"serial":           map[string]interface{}{"type": "integer"},
+		"lineage":          map[string]interface{}{"type": "string"},
+		"outputs":          map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"resources":        map[string]interface{}{"type": "array", "items": stateResourceSchema},
+	},
+	"required": []string{"path", "terraformVersion", "serial", "resources"},
+}
+
+// stateResourceDetailOutputSchema is the JSON Schema of StateResourceDetail
+var stateResourceDetailOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":     map[string]interface{}{"type": "string"},
+		"address":  map[string]interface{}{"type": "string"},
+		"provider": map[string]interface{}{"type": "string"},
+		"instances": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"address":      map[string]interface{}{"type": "string"},
+					"status":       map[string]interface{}{"type": "string"},
+					"deposed":      map[string]interface{}{"type": "string"},
+					"attributes":   map[string]interface{}{"type": "object"},
+					"dependencies": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"required": []string{"address", "attributes"},
+			},
+		},
+	},
+	"required": []string{"path", "address", "provider", "instances"},
+}
+
+// stateOrphanReportOutputSchema is the JSON Schema of StateOrphanReport
+var stateOrphanReportOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":       map[string]interface{}{"type": "string"},
+		"configPath": map[string]interface{}{"type": "string"},
+		"checked":    map[string]interface{}{"type": "integer"},
+		"orphans": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"address": map[string]interface{}{"type": "string"},
+					"reason":  map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"address", "reason"},
+			},
+		},
+		"staleDataSources": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"address": map[string]interface{}{"type": "string"},
+					"reason":  map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"address", "reason"},
+			},
+		},
+		"skippedModules": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	},
+	"required": []string{"path", "configPath", "checked", "orphans"},
+}
+
+// =============================================================================
+// Tool Handlers
+// =============================================================================
+
+// handleListStateResources lists the resources in a state file
+func (s *MCPServer) handleListStateResources(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	statePath, state, err := s.loadState(args)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := args["filter"].(string)
+
+	summary := &StateSummary{
+		Path:             s.displayPath(statePath),
+		TerraformVersion: state.TerraformVersion,
+		Serial:           state.Serial,
+		Lineage:          state.Lineage,
+		Resources:        []StateResource{},
+	}
+	for name := range state.Outputs {
+		summary.Outputs = append(summary.Outputs, name)
+	}
+	sort.Strings(summary.Outputs)
+
+	instances := 0
+	for _, r := range state.Resources {
+		address := r.address()
+		if filter != "" && !strings.Contains(address, filter) {
+			continue
+		}
+		summary.Resources = append(summary.Resources, StateResource{
+			Address:   address,
+			Mode:      r.Mode,
+			Type:      r.Type,
+			Name:      r.Name,
+			Module:    r.Module,
+			Provider:  providerName(r.Provider),
+			Instances: len(r.Instances),
+		})
+		instances += len(r.Instances)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🗂️ Terraform state: %s\n\n", summary.Path))
+	output.WriteString(fmt.Sprintf("📊 Terraform %s, serial %d: %d resource(s), %d instance(s)",
+		state.TerraformVersion, state.Serial, len(summary.Resources), instances))
+	if filter != "" {
+		output.WriteString(fmt.Sprintf(" matching %q", filter))
+	}
+	output.WriteString("\n\n")
+
+	for _, r := range summary.Resources {
+		line := fmt.Sprintf("   - `%s` (%s", r.Address, r.Provider)
+		if r.Instances != 1 {
+			line += fmt.Sprintf(", %d instances", r.Instances)
+		}
+		output.WriteString(line + ")\n")
+	}
+	if len(summary.Outputs) > 0 {
+		output.WriteString(fmt.Sprintf("\n📤 **Outputs:** %s\n", strings.Join(summary.Outputs, ", ")))
+	}
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: summary,
+	}, nil
+}
+
+// handleShowStateResource shows the attributes of one resource, or one
+// instance of it, with sensitive values redacted
+func (s *MCPServer) handleShowStateResource(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	address, ok := args["address"].(string)
+	if !ok || address == "" {
+		return nil, fmt.Errorf("address parameter is required")
+	}
+	statePath, state, err := s.loadState(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var detail *StateResourceDetail
+	for _, r := range state.Resources {
+		if address != r.address() && !strings.HasPrefix(address, r.address()+"[") {
+			continue
+		}
+		for _, instance := range r.Instances {
+			instanceAddress := r.instanceAddress(instance)
+			if address != r.address() && address != instanceAddress {
+				continue
+			}
+			if detail == nil {
+				detail = &StateResourceDetail{
+					Path:     s.displayPath(statePath),
+					Address:  address,
+					Provider: providerName(r.Provider),
+				}
+			}
+			detail.Instances = append(detail.Instances, StateInstance{
+				Address:      instanceAddress,
+				Status:       instance.Status,
+				Deposed:      instance.Deposed,
+				Attributes:   redactStateAttributes(instance.Attributes, instance.SensitiveAttributes),
+				Dependencies: instance.Dependencies,
+			})
+		}
+	}
+	if detail == nil {
+		return nil, fmt.Errorf("resource not found in state: %s (use list_state_resources to see the addresses)", address)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🔎 `%s` in %s (%s)\n\n", address, detail.Path, detail.Provider))
+	for i, instance := range detail.Instances {
+		if i > 0 {
+			output.WriteString("\n")
+		}
+		output.WriteString(fmt.Sprintf("### `%s`", instance.Address))
+		if instance.Status != "" {
+			output.WriteString(fmt.Sprintf(" ⚠️ %s", instance.Status))
+		}
+		if instance.Deposed != "" {
+			output.WriteString(fmt.Sprintf(" (deposed object %s)", instance.Deposed))
+		}
+		output.WriteString("\n\n")
+
+		attributes, _ := json.MarshalIndent(instance.Attributes, "", "  ")
+		output.WriteString(fmt.Sprintf("```json\n%s\n```\n", attributes))
+		if len(instance.Dependencies) > 0 {
+			output.WriteString(fmt.Sprintf("🔗 **Depends on:** %s\n", strings.Join(instance.Dependencies, ", ")))
+		}
+	}
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: detail,
+	}, nil
+}
+
+// handleFindStateOrphans reports resources in state that the configuration
+// doesn't declare
+func (s *MCPServer) handleFindStateOrphans(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+	statePath, state, err := s.loadState(args)
+	if err != nil {
+		return nil, err
+	}
+
+	configDir := filepath.Dir(statePath)
+	if c, ok := args["config_path"].(string); ok && c != "" {
+		if configDir, err = s.resolvePath(c); err != nil {
+			return nil, err
+		}
+	}
+	if info, err := os.Stat(configDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("config_path must be a directory: %s", configDir)
+	}
+	files, err := walkIaCFiles(configDir, false, map[string]bool{".tf": true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Terraform files found in %s", configDir)
+	}
+
+	config := newTerraformConfigIndex()
+	config.load(configDir, "", 0)
+
+	report := &StateOrphanReport{
+		Path:       s.displayPath(statePath),
+		ConfigPath: s.displayPath(configDir),
+		Orphans:    []StateOrphan{},
+	}
+	skipped := make(map[string]bool)
+	for _, r := range state.Resources {
+		address := r.configAddress()
+		if module := config.opaqueModule(address); module != "" {
+			skipped[module] = true
+			continue
+		}
+		if r.Mode == "data" {
+			if !config.resources[address] {
+				reason := "no data block with this address in the configuration"
+				if module := config.missingModule(address); module != "" {
+					reason = fmt.Sprintf("%s is not declared in the configuration", module)
+				}
+				report.StaleDataSources = append(report.StaleDataSources, StateOrphan{Address: r.address(), Reason: reason})
+			}
+			continue
+		}
+
+		report.Checked++
+		if config.resources[address] {
+			continue
+		}
+		if config.isMoved(address) {
+			// Terraform moves it instead of destroying it
+			continue
+		}
+
+		reason := "no resource block with this address in the configuration"
+		switch {
+		case config.isRemoved(address):
+			reason = "declared in a removed block, so Terraform will forget or destroy it"
+		case config.missingModule(address) != "":
+			reason = fmt.Sprintf("%s is not declared in the configuration", config.missingModule(address))
+		}
+		report.Orphans = append(report.Orphans, StateOrphan{Address: r.address(), Reason: reason})
+	}
+	for module := range skipped {
+		report.SkippedModules = append(report.SkippedModules, module)
+	}
+	sort.Strings(report.SkippedModules)
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🧭 Comparing state %s with the configuration in %s\n\n", report.Path, report.ConfigPath))
+	if len(report.Orphans) == 0 {
+		output.WriteString(fmt.Sprintf("✅ **No orphans:** all %d managed resource(s) in state are declared in the configuration.\n", report.Checked))
+	} else {
+		output.WriteString(fmt.Sprintf("⚠️ **%d of %d managed resource(s) in state are not in the configuration.** The next plan would destroy them.\n\n", len(report.Orphans), report.Checked))
+		for _, o := range report.Orphans {
+			output.WriteString(fmt.Sprintf("   - `%s`: %s\n", o.Address, o.Reason))
+		}
+	}
+	if len(report.StaleDataSources) > 0 {
+		output.WriteString(fmt.Sprintf("\nℹ️ **%d data source(s) in state are not in the configuration.** Nothing is destroyed; Terraform drops them from state on the next apply.\n\n", len(report.StaleDataSources)))
+		for _, o := range report.StaleDataSources {
+			output.WriteString(fmt.Sprintf("   - `%s`: %s\n", o.Address, o.Reason))
+		}
+	}
+	if len(report.SkippedModules) > 0 {
+		output.WriteString(fmt.Sprintf("\nℹ️ Not checked, the source of these modules isn't local: %s\n", strings.Join(report.SkippedModules, ", ")))
+	}
+
+	return &ToolCallResult{
+		Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+		StructuredContent: report,
+	}, nil
+}
+
+// loadState reads the state file at args["path"], which may also be a
+// directory containing terraform.tfstate
+func (s *MCPServer) loadState(args map[string]interface{}) (string, *tfState, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("path parameter is required")
+	}
+	statePath, err := s.resolvePath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(statePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("state file not found: %s", statePath)
+	}
+	if info.IsDir() {
+		statePath = filepath.Join(statePath, defaultStateFile)
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("state file not found: %s", statePath)
+	}
+	var state tfState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.Version != 4 {
+		return "", nil, fmt.Errorf("unsupported state format version %d (only version 4, written by Terraform 0.12 and later, is supported)", state.Version)
+	}
+	return statePath, &state, nil
+}
+
+// =============================================================================
+// Redaction
+// =============================================================================
+
+// redactStateAttributes returns a copy of attributes with the paths listed in
+// sensitive_attributes and secret-looking attribute names replaced
+func redactStateAttributes(attributes map[string]interface{}, sensitive []json.RawMessage) map[string]interface{} {
+	redacted, _ := redactSecretNames(attributes).(map[string]interface{})
+	if redacted == nil {
+		redacted = map[string]interface{}{}
+	}
+	for _, raw := range sensitive {
+		redactPath(redacted, sensitivePath(raw))
+	}
+	return redacted
+}
+
+// redactSecretNames copies value, replacing non-empty values of attributes
+// whose names match secretAttributePattern
+func redactSecretNames(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			if secretAttributePattern.MatchString(k) && child != nil && child != "" {
+				if _, isBool := child.(bool); !isBool {
+					copied[k] = sensitiveValue
+					continue
+				}
+			}
+			copied[k] = redactSecretNames(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, child := range v {
+			copied[i] = redactSecretNames(child)
+		}
+		return copied
+	}
+	return value
+}
+
+// sensitivePath decodes one sensitive_attributes entry, a list of steps such
+// as [{"type":"get_attr","value":"admin_password"}], into attribute names
+// (string) and list indexes (int). Map keys are index steps with a string
+// value.
+func sensitivePath(raw json.RawMessage) []interface{} {
+	var steps []struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &steps); err != nil {
+		return nil
+	}
+
+	var path []interface{}
+	for _, step := range steps {
+		var value interface{}
+		if err := json.Unmarshal(step.Value, &value); err != nil {
+			return nil
+		}
+		// Index steps wrap their key as {"value": ..., "type": ...}
+		if wrapped, ok := value.(map[string]interface{}); ok {
+			value = wrapped["value"]
+		}
+		switch v := value.(type) {
+		case string:
+			path = append(path, v)
+		case float64:
+			path = append(path, int(v))
+		default:
+			return nil
+		}
+	}
+	return path
+}
+
+// redactPath replaces the value at path inside value, if it exists
+func redactPath(value interface{}, path []interface{}) {
+	for i, step := range path {
+		last := i == len(path)-1
+		switch v := value.(type) {
+		case map[string]interface{}:
+			key, ok := step.(string)
+			if _, exists := v[key]; !ok || !exists {
+				return
+			}
+			if last {
+				v[key] = sensitiveValue
+				return
+			}
+			value = v[key]
+		case []interface{}:
+			index, ok := step.(int)
+			if !ok || index < 0 || index >= len(v) {
+				return
+			}
+			if last {
+				v[index] = sensitiveValue
+				return
+			}
+			value = v[index]
+		default:
+			return
+		}
+	}
+}
+
+// =============================================================================
+// Configuration Index
+// =============================================================================
+
+// terraformConfigIndex holds the addresses declared by a configuration and
+// its local modules, without instance keys
+type terraformConfigIndex struct {
+	resources map[string]bool   // module.x.azurerm_foo.bar, data.azurerm_baz.qux
+	modules   map[string]bool   // module.x, module.x.module.y
+	opaque    map[string]bool   // modules whose source isn't local
+	moved     map[string]string // moved block from → to
+	removed   map[string]bool   // removed block from
+}
+
+// maxModuleDepth guards against module source cycles
+const maxModuleDepth = 16
+
+// configIndexSchema extracts the blocks that declare addresses
+var configIndexSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "moved"},
+		{Type: "removed"},
+	},
+}
+
+// movedBlockSchema extracts the addresses of moved and removed blocks
+var movedBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "from"}, {Name: "to"}},
+}
+
+// newTerraformConfigIndex creates an empty index
+func newTerraformConfigIndex() *terraformConfigIndex {
+	return &terraformConfigIndex{
+		resources: make(map[string]bool),
+		modules:   make(map[string]bool),
+		opaque:    make(map[string]bool),
+		moved:     make(map[string]string),
+		removed:   make(map[string]bool),
+	}
+}
+
+// load indexes the .tf files in dir as the module at prefix ("" for the root
+// module, otherwise e.g. "module.net."), following local module sources
+func (c *terraformConfigIndex) load(dir, prefix string, depth int) {
+	files, err := walkIaCFiles(dir, false, map[string]bool{".tf": true})
+	if err != nil {
+		return
+	}
+
+	parser := hclparse.NewParser()
+	for _, name := range files {
+		file, diags := parser.ParseHCLFile(filepath.Join(dir, name))
+		if diags.HasErrors() {
+			continue
+		}
+		content, _, _ := file.Body.PartialContent(configIndexSchema)
+		for _, block := range content.Blocks {
+			switch block.Type {
+			case "resource":
+				c.resources[prefix+block.Labels[0]+"."+block.Labels[1]] = true
+			case "data":
+				c.resources[prefix+"data."+block.Labels[0]+"."+block.Labels[1]] = true
+			case "module":
+				module := prefix + "module." + block.Labels[0]
+				c.modules[module] = true
+				source := moduleSource(block)
+				if depth < maxModuleDepth && (strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")) {
+					c.load(filepath.Join(dir, filepath.FromSlash(source)), module+".", depth+1)
+				} else {
+					c.opaque[module] = true
+				}
+			case "moved", "removed":
+				body, _, _ := block.Body.PartialContent(movedBlockSchema)
+				from := traversalAddress(body.Attributes["from"])
+				if from == "" {
+					continue
+				}
+				if block.Type == "removed" {
+					c.removed[prefix+from] = true
+				} else {
+					c.moved[prefix+from] = prefix + traversalAddress(body.Attributes["to"])
+				}
+			}
+		}
+	}
+}
+
+// isMoved reports whether a moved block's from is address or a module
+// containing it
+func (c *terraformConfigIndex) isMoved(address string) bool {
+	for _, scope := range addressScopes(address) {
+		if _, ok := c.moved[scope]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isRemoved reports whether a removed block's from is address or a module
+// containing it
+func (c *terraformConfigIndex) isRemoved(address string) bool {
+	for _, scope := range addressScopes(address) {
+		if c.removed[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// addressScopes returns address followed by the modules containing it:
+// module.a.module.b.x.y gives itself, module.a and module.a.module.b
+func addressScopes(address string) []string {
+	scopes := []string{address}
+	parts := strings.Split(address, ".")
+	for i := 0; i+2 < len(parts) && parts[i] == "module"; i += 2 {
+		scopes = append(scopes, strings.Join(parts[:i+2], "."))
+	}
+	return scopes
+}
+
+// opaqueModule returns the module with a non-local source that contains
+// address, if any
+func (c *terraformConfigIndex) opaqueModule(address string) string {
+	for module := range c.opaque {
+		if strings.HasPrefix(address, module+".") {
+			return module
+		}
+	}
+	return ""
+}
+
+// missingModule returns the outermost module in address that the
+// configuration doesn't declare, or ""
+func (c *terraformConfigIndex) missingModule(address string) string {
+	parts := strings.Split(address, ".")
+	for i := 0; i+1 < len(parts) && parts[i] == "module"; i += 2 {
+		module := strings.Join(parts[:i+2], ".")
+		if !c.modules[module] {
+			return module
+		}
+	}
+	return ""
+}
+
+// traversalAddress renders the address in a moved/removed from or to
+// attribute, without instance keys
+func traversalAddress(attr *hcl.Attribute) string {
+	if attr == nil {
+		return ""
+	}
+	traversal, diags := hcl.AbsTraversalForExpr(attr.Expr)
+	if diags.HasErrors() {
+		return ""
+	}
+	var parts []string
+	for _, step := range traversal {
+		switch t := step.(type) {
+		case hcl.TraverseRoot:
+			parts = append(parts, t.Name)
+		case hcl.TraverseAttr:
+			parts = append(parts, t.Name)
+		}
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,128 @@
+// =============================================================================
+// Terraform State Tool Tests
+// =============================================================================
+// find_state_orphans over a small configuration with moved and removed
+// blocks, at resource and at module level, next to a hand-written state.
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const orphanConfig = `
+resource "azurerm_resource_group" "main" {}
+
+module "new" {
+  source = "./modules/app"
+}
+
+moved {
+  from = azurerm_storage_account.old
+  to   = azurerm_storage_account.new
+}
+
+resource "azurerm_storage_account" "new" {}
+
+moved {
+  from = module.old
+  to   = module.new
+}
+
+removed {
+  from = module.legacy
+  lifecycle {
+    destroy = false
+  }
+}
+
+removed {
+  from = azurerm_key_vault.gone
+}
+`
+
+const orphanState = `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "azurerm_resource_group", "name": "main", "instances": [{}]},
+    {"mode": "managed", "type": "azurerm_storage_account", "name": "old", "instances": [{}]},
+    {"module": "module.old", "mode": "managed", "type": "azurerm_linux_web_app", "name": "app", "instances": [{}]},
+    {"module": "module.old[\"eu\"]", "mode": "managed", "type": "azurerm_service_plan", "name": "plan", "instances": [{}]},
+    {"module": "module.legacy", "mode": "managed", "type": "azurerm_linux_web_app", "name": "app", "instances": [{}]},
+    {"module": "module.legacy.module.inner", "mode": "managed", "type": "azurerm_service_plan", "name": "plan", "instances": [{}]},
+    {"mode": "managed", "type": "azurerm_key_vault", "name": "gone", "instances": [{}]},
+    {"module": "module.older", "mode": "managed", "type": "azurerm_linux_web_app", "name": "app", "instances": [{}]},
+    {"mode": "managed", "type": "azurerm_virtual_network", "name": "stray", "instances": [{}]}
+  ]
+}`
+
+func TestFindStateOrphansMovedAndRemoved(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.tf":             orphanConfig,
+		"modules/app/main.tf": "resource \"azurerm_linux_web_app\" \"app\" {}\n",
+		defaultStateFile:      orphanState,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := NewMCPServer(strings.NewReader(""), io.Discard, Config{Roots: []string{root}})
+	defer server.Close()
+
+	result, err := server.handleFindStateOrphans(context.Background(), map[string]interface{}{"path": root})
+	if err != nil {
+		t.Fatalf("handleFindStateOrphans: %v", err)
+	}
+	report := result.StructuredContent.(*StateOrphanReport)
+
+	// Everything under module.old is moved; module.older is a different
+	// module, not one inside it
+	reasons := make(map[string]string)
+	for _, o := range report.Orphans {
+		reasons[o.Address] = o.Reason
+	}
+	want := map[string]string{
+		"azurerm_key_vault.gone":                               "removed block",
+		"module.legacy.azurerm_linux_web_app.app":              "removed block",
+		"module.legacy.module.inner.azurerm_service_plan.plan": "removed block",
+		"module.older.azurerm_linux_web_app.app":               "module.older is not declared",
+		"azurerm_virtual_network.stray":                        "no resource block",
+	}
+	if len(reasons) != len(want) {
+		t.Errorf("orphans = %v, want %d", reasons, len(want))
+	}
+	for address, reason := range want {
+		if got, ok := reasons[address]; !ok {
+			t.Errorf("%s is not reported", address)
+		} else if !strings.Contains(got, reason) {
+			t.Errorf("%s: reason %q, want %q", address, got, reason)
+		}
+	}
+}
+
+func TestAddressScopes(t *testing.T) {
+	tests := map[string][]string{
+		"azurerm_subnet.app":                      {"azurerm_subnet.app"},
+		"module.net.azurerm_subnet.app":           {"module.net.azurerm_subnet.app", "module.net"},
+		"module.a.module.b.data.azurerm_client.x": {"module.a.module.b.data.azurerm_client.x", "module.a", "module.a.module.b"},
+	}
+	for address, want := range tests {
+		if got := addressScopes(address); !reflect.DeepEqual(got, want) {
+			t.Errorf("addressScopes(%q) = %v, want %v", address, got, want)
+		}
+	}
+}
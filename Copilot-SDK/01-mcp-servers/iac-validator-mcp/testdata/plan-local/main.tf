@@ -0,0 +1,63 @@
+# =============================================================================
+# plan_terraform fixture
+# =============================================================================
+# Uses only the hashicorp/null and hashicorp/local providers so the plan tool
+# can be exercised without an Azure subscription:
+#
+#   {"name":"plan_terraform","arguments":{"path":"testdata/plan-local","var_file":"fixture.tfvars"}}
+#
+# show.json is a recorded `terraform show -json` of this configuration, used
+# by plan_test.go to check the summary and the masking of sensitive values.
+# =============================================================================
+
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    null = {
+      source  = "hashicorp/null"
+      version = "~> 3.2"
+    }
+    local = {
+      source  = "hashicorp/local"
+      version = "~> 2.5"
+    }
+  }
+}
+
+variable "environment" {
+  type        = string
+  description = "Environment name written into the generated file"
+  default     = "dev"
+}
+
+variable "api_key" {
+  type        = string
+  description = "Secret value used to exercise sensitive-value masking"
+  sensitive   = true
+}
+
+resource "null_resource" "trigger" {
+  triggers = {
+    environment = var.environment
+  }
+}
+
+resource "local_file" "config" {
+  filename = "${path.module}/out/${var.environment}.txt"
+  content  = "environment = ${var.environment}\n"
+}
+
+resource "local_sensitive_file" "secret" {
+  filename = "${path.module}/out/${var.environment}.key"
+  content  = var.api_key
+}
+
+output "config_file" {
+  value = local_file.config.filename
+}
+
+output "api_key" {
+  value     = var.api_key
+  sensitive = true
+}
@@ -0,0 +1,1838 @@
+// =============================================================================
+// Terraform → Bicep Conversion
+// =============================================================================
+// Converts an azurerm configuration (one or more .tf files) to one Bicep file:
+//
+//   - variables become params (validation blocks become decorators where
+//     they match @allowed, @minLength and friends) and locals become vars
+//   - resources use the mappings in convertmap.go. count becomes a loop or a
+//     condition, for_each a loop over the set or items() of the map, and
+//     data sources become existing resources.
+//   - azurerm_resource_group becomes the deployment scope when the file
+//     creates anything else; NSG associations fold into the subnet
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// irBlocks holds the nested blocks of one type in a Terraform body; a
+// dynamic block is an *irFor whose Body is the content object
+type irBlocks struct{ Items []irNode }
+
+// bicepConverter converts one Terraform configuration
+type bicepConverter struct {
+	params     map[string]string // variable name → param symbol
+	paramTypes map[string]string // variable name → Bicep type
+	vars       map[string]string // local name → var symbol
+	locals     map[string]irNode
+	targets    map[string]*bicepTarget // by address
+	order      []*bicepTarget
+	taken      map[string]bool // symbols in use
+	// associations are NSG associations by the address of their subnet
+	associations map[string]*bicepTarget
+	scopes       []map[string]*loopVar
+	loop         *bicepLoop // loop of the resource being rendered
+	pending      []string
+	todos        int
+}
+
+// bicepTarget is a Terraform resource, data source or module being
+// converted
+type bicepTarget struct {
+	kind    string // resource, data or module
+	tfType  string
+	name    string
+	address string
+	block   *hclsyntax.Block
+	body    *irObject
+	src     []byte
+	mapping *resourceMapping
+	symbol  string // empty when there is no mapping
+	scopeRG bool   // a resource group that became the deployment scope
+	folded  *bicepTarget
+	cond    irNode
+	loop    *bicepLoop
+	// children are generated merged children such as blobServices, by type
+	children map[string]string
+	todos    int
+}
+
+// bicepLoop is a resource loop from count or for_each
+type bicepLoop struct {
+	count  irNode // count = N
+	source irNode // for_each source
+	isMap  bool   // for_each over a map: the loop runs over items()
+	item   string
+	index  string
+	used   bool // the index variable is referenced
+}
+
+// loopVar is what a Terraform loop variable renders as; target is set when
+// it stands for an element of a resource loop
+type loopVar struct {
+	text   string
+	target *bicepTarget
+	used   *bool
+}
+
+// convertTerraformToBicep converts the .tf files of one configuration
+func convertTerraformToBicep(sources map[string][]byte) (*conversionOutput, error) {
+	c := &bicepConverter{
+		params:       make(map[string]string),
+		paramTypes:   make(map[string]string),
+		vars:         make(map[string]string),
+		locals:       make(map[string]irNode),
+		targets:      make(map[string]*bicepTarget),
+		taken:        make(map[string]bool),
+		associations: make(map[string]*bicepTarget),
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var variables, outputs []sourceBlock
+	var notes []string
+	type local struct {
+		name string
+		attr *hclsyntax.Attribute
+		src  []byte
+	}
+	var locals []local
+	for _, name := range names {
+		src := sources[name]
+		file, diags := hclsyntax.ParseConfig(src, name, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%s: %s", name, diags.Error())
+		}
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			switch block.Type {
+			case "terraform":
+			case "provider":
+				if len(block.Body.Attributes) > 0 {
+					notes = append(notes, c.todoLines("provider settings have no Bicep equivalent; set them on the deployment", blockSource(block, src))...)
+				}
+			case "variable":
+				variables = append(variables, sourceBlock{block, src})
+			case "locals":
+				for _, attr := range sortedAttributes(block.Body) {
+					locals = append(locals, local{attr.Name, attr, src})
+					c.locals[attr.Name] = irFromHCL(attr.Expr, src)
+				}
+			case "output":
+				outputs = append(outputs, sourceBlock{block, src})
+			case "resource", "data", "module":
+				t := &bicepTarget{kind: block.Type, block: block, src: src, body: blockObject(block.Body, src)}
+				if block.Type == "module" {
+					t.name, t.address = block.Labels[0], "module."+block.Labels[0]
+				} else {
+					t.tfType, t.name = block.Labels[0], block.Labels[1]
+					t.address = t.tfType + "." + t.name
+					if block.Type == "data" {
+						t.address = "data." + t.address
+					}
+					t.mapping = terraformMapping(t.tfType)
+				}
+				c.targets[t.address] = t
+				c.order = append(c.order, t)
+			default:
+				notes = append(notes, c.todoLines(fmt.Sprintf("Terraform %s blocks have no Bicep equivalent", block.Type), blockSource(block, src))...)
+			}
+		}
+	}
+
+	// Symbols: params and vars keep their names, then resources and modules
+	for _, v := range variables {
+		name := v.block.Labels[0]
+		c.params[name] = c.symbol(camelCase(name), "Param")
+		c.paramTypes[name] = c.paramType(v)
+	}
+	for _, l := range locals {
+		c.vars[l.name] = c.symbol(camelCase(l.name), "Var")
+	}
+	c.planScope()
+	for _, t := range c.order {
+		c.plan(t)
+	}
+
+	var results []ConvertedResource
+	var resources []string
+	for _, t := range c.order {
+		if t.scopeRG {
+			notes = append(notes, c.todoLines(fmt.Sprintf("%s is the deployment scope: create the resource group first and deploy this file into it", t.address), blockSource(t.block, t.src))...)
+		}
+	}
+	for _, t := range c.order {
+		if code := c.resource(t); code != "" {
+			resources = append(resources, code)
+		}
+		results = append(results, c.result(t))
+	}
+
+	var params, vars, outs []string
+	for _, v := range variables {
+		params = append(params, c.param(v))
+	}
+	for _, l := range locals {
+		text := c.expr(irFromHCL(l.attr.Expr, l.src))
+		code := strings.Join(c.takePending(l.name+" = "+hclSource(l.attr.Expr, l.src)), "\n")
+		if code != "" {
+			code += "\n"
+		}
+		vars = append(vars, code+fmt.Sprintf("var %s = %s", c.vars[l.name], text))
+	}
+	for _, o := range outputs {
+		outs = append(outs, c.output(o))
+	}
+
+	var out strings.Builder
+	sections := [][]string{}
+	if c.subscriptionScope() {
+		sections = append(sections, []string{"targetScope = 'subscription'"})
+	}
+	if len(notes) > 0 {
+		sections = append(sections, []string{strings.Join(notes, "\n")})
+	}
+	sections = append(sections, params, []string{strings.Join(vars, "\n")}, resources, outs)
+	for _, section := range sections {
+		for _, code := range section {
+			if code == "" {
+				continue
+			}
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString(code + "\n")
+		}
+	}
+	return &conversionOutput{code: out.String(), resources: results}, nil
+}
+
+// sourceBlock is a top-level block with the source of its file
+type sourceBlock struct {
+	block *hclsyntax.Block
+	src   []byte
+}
+
+// sortedAttributes returns the attributes of a body in source order
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte })
+	return attrs
+}
+
+// blockSource returns the source text of a block
+func blockSource(block *hclsyntax.Block, src []byte) string {
+	r := hcl.RangeBetween(block.TypeRange, block.CloseBraceRange)
+	return string(src[r.Start.Byte:r.End.Byte])
+}
+
+// blockObject converts a block body to the IR; nested blocks of one type
+// are grouped into an *irBlocks item
+func blockObject(body *hclsyntax.Body, src []byte) *irObject {
+	type entry struct {
+		pos  int
+		item *irItem
+	}
+	var entries []entry
+	for _, attr := range body.Attributes {
+		entries = append(entries, entry{attr.SrcRange.Start.Byte, &irItem{
+			Key:   attr.Name,
+			Value: irFromHCL(attr.Expr, src),
+			Src:   hclSource(attr.Expr, src),
+			Line:  attr.SrcRange.Start.Line,
+		}})
+	}
+	groups := make(map[string]*irItem)
+	for _, block := range body.Blocks {
+		typ := block.Type
+		var node irNode
+		if typ == "dynamic" && len(block.Labels) == 1 {
+			typ = block.Labels[0]
+			node = dynamicBlock(block, src)
+		} else {
+			node = blockObject(block.Body, src)
+		}
+		if group, ok := groups[typ]; ok {
+			blocks := group.Value.(*irBlocks)
+			blocks.Items = append(blocks.Items, node)
+			group.Src += "\n" + blockSource(block, src)
+			continue
+		}
+		group := &irItem{Key: typ, Value: &irBlocks{Items: []irNode{node}}, Src: blockSource(block, src), Line: block.TypeRange.Start.Line}
+		groups[typ] = group
+		entries = append(entries, entry{block.TypeRange.Start.Byte, group})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].pos < entries[j].pos })
+
+	object := &irObject{}
+	for _, e := range entries {
+		object.Items = append(object.Items, e.item)
+	}
+	return object
+}
+
+// dynamicBlock converts a dynamic block to a for-expression over its content
+func dynamicBlock(block *hclsyntax.Block, src []byte) irNode {
+	loop := &irFor{Value: block.Labels[0], Source: &irNull{}}
+	if attr, ok := block.Body.Attributes["for_each"]; ok {
+		loop.Source = irFromHCL(attr.Expr, src)
+	}
+	if attr, ok := block.Body.Attributes["iterator"]; ok {
+		loop.Value = hcl.ExprAsKeyword(attr.Expr)
+	}
+	loop.Body = &irObject{}
+	for _, content := range block.Body.Blocks {
+		if content.Type == "content" {
+			loop.Body = blockObject(content.Body, src)
+		}
+	}
+	return loop
+}
+
+// tfLookup follows a dotted path of arguments and single nested blocks
+func tfLookup(object *irObject, path string) *irItem {
+	keys := strings.Split(path, ".")
+	for i, key := range keys {
+		item := object.get(key)
+		if item == nil || i == len(keys)-1 {
+			return item
+		}
+		blocks, ok := item.Value.(*irBlocks)
+		if !ok || len(blocks.Items) != 1 {
+			return nil
+		}
+		if object, ok = blocks.Items[0].(*irObject); !ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// symbol reserves a Bicep symbol, adding suffix on a collision
+func (c *bicepConverter) symbol(name, suffix string) string {
+	if bicepReserved[name] || c.taken[name] {
+		name += suffix
+	}
+	candidate := name
+	for i := 2; c.taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	c.taken[candidate] = true
+	return candidate
+}
+
+// bicepReserved are names a symbol must not shadow
+var bicepReserved = map[string]bool{
+	"resourceGroup": true, "subscription": true, "tenant": true, "managementGroup": true,
+	"deployment": true, "az": true, "sys": true, "resource": true, "module": true,
+	"param": true, "var": true, "output": true, "existing": true, "for": true, "if": true,
+}
+
+// genericNames are resource names that say nothing about the resource
+var genericNames = map[string]bool{"main": true, "this": true, "example": true, "default": true, "primary": true, "current": true}
+
+// planScope decides which resource groups become the deployment scope
+func (c *bicepConverter) planScope() {
+	others := false
+	for _, t := range c.order {
+		if t.tfType != "azurerm_resource_group" && t.tfType != "azurerm_client_config" && t.tfType != "azurerm_subscription" {
+			others = true
+		}
+	}
+	for _, t := range c.order {
+		if t.tfType == "azurerm_resource_group" && (others || t.kind == "data") {
+			t.scopeRG = true
+		}
+	}
+}
+
+// subscriptionScope reports whether the file creates resource groups
+func (c *bicepConverter) subscriptionScope() bool {
+	for _, t := range c.order {
+		if t.tfType == "azurerm_resource_group" && t.kind == "resource" && !t.scopeRG {
+			return true
+		}
+	}
+	return false
+}
+
+// plan names a target and reads its loop before anything is rendered
+func (c *bicepConverter) plan(t *bicepTarget) {
+	if t.tfType == subnetNSGAssociation {
+		if subnet := c.associatedSubnet(t); subnet != nil && c.associations[subnet.address] == nil {
+			c.associations[subnet.address] = t
+			t.folded = subnet
+		}
+		return
+	}
+	if t.kind != "module" && (t.mapping == nil || t.scopeRG) {
+		return
+	}
+
+	short := camelCase(strings.TrimPrefix(t.tfType, "azurerm_"))
+	switch {
+	case t.kind == "module":
+		t.symbol = c.symbol(camelCase(t.name), "Module")
+	case genericNames[t.name]:
+		t.symbol = c.symbol(short, "Resource")
+	case !c.taken[camelCase(t.name)] && !bicepReserved[camelCase(t.name)]:
+		t.symbol = c.symbol(camelCase(t.name), "")
+	default:
+		t.symbol = c.symbol(short, strings.ToUpper(t.name[:1])+camelCase(t.name)[1:])
+	}
+
+	if item := t.body.get("count"); item != nil {
+		if cond, ok := item.Value.(*irCond); ok && isNumber(cond.Then, "1") && isNumber(cond.Else, "0") {
+			t.cond = cond.Cond
+		} else {
+			t.loop = &bicepLoop{count: item.Value, item: "i", index: "i"}
+		}
+	}
+	if item := t.body.get("for_each"); item != nil {
+		t.loop = &bicepLoop{source: item.Value, item: "item", index: "i"}
+		if call, ok := item.Value.(*irCall); ok && call.Name == "toset" && len(call.Args) == 1 {
+			t.loop.source = call.Args[0]
+		} else {
+			t.loop.isMap = true
+		}
+	}
+}
+
+// isNumber reports whether a node is a given number literal
+func isNumber(node irNode, text string) bool {
+	n, ok := node.(*irNumber)
+	return ok && n.Text == text
+}
+
+// associatedSubnet returns the subnet an NSG association attaches to, when
+// it can fold into that subnet: both loop over the same collection
+func (c *bicepConverter) associatedSubnet(t *bicepTarget) *bicepTarget {
+	item := t.body.get("subnet_id")
+	if item == nil {
+		return nil
+	}
+	root, accessors := chain(item.Value)
+	ref, ok := root.(*irRef)
+	if !ok || len(accessors) == 0 {
+		return nil
+	}
+	get, ok := accessors[0].(*irGet)
+	if !ok {
+		return nil
+	}
+	subnet := c.targets[ref.Name+"."+get.Name]
+	if subnet == nil || subnet.tfType != "azurerm_subnet" {
+		return nil
+	}
+	own, theirs := t.body.get("for_each"), subnet.body.get("for_each")
+	if (own == nil) != (theirs == nil) || (own != nil && own.Src != theirs.Src) || t.body.get("count") != nil {
+		return nil
+	}
+	return subnet
+}
+
+// result summarizes how a target was converted
+func (c *bicepConverter) result(t *bicepTarget) ConvertedResource {
+	result := ConvertedResource{Source: t.address, SourceType: t.tfType, Status: convertedStatus}
+	switch {
+	case t.kind == "module":
+		result.SourceType = "module"
+		result.Target, result.TargetType = t.symbol, "module"
+	case t.scopeRG:
+		result.Target, result.TargetType = "resourceGroup()", "deployment scope"
+	case t.folded != nil:
+		result.Target, result.TargetType = t.folded.symbol, "properties.networkSecurityGroup"
+	case t.symbol == "":
+		result.Status = unmappedStatus
+		return result
+	default:
+		result.Target, result.TargetType = t.symbol, t.mapping.bicepType
+	}
+	if t.todos > 0 {
+		result.Status = partialStatus
+	}
+	return result
+}
+
+// =============================================================================
+// Declarations
+// =============================================================================
+
+// paramType converts the type of a variable, or infers it from the default
+func (c *bicepConverter) paramType(v sourceBlock) string {
+	attrs := v.block.Body.Attributes
+	typ := ""
+	if attr, ok := attrs["type"]; ok {
+		typ = bicepType(irFromHCL(attr.Expr, v.src))
+	} else if attr, ok := attrs["default"]; ok {
+		typ = inferType(irFromHCL(attr.Expr, v.src))
+	}
+	if attr, ok := attrs["default"]; ok && typ != "" {
+		if _, isNull := irFromHCL(attr.Expr, v.src).(*irNull); isNull {
+			typ += "?"
+		}
+	}
+	return typ
+}
+
+// bicepType converts a Terraform type constraint; empty when there is no
+// equivalent
+func bicepType(node irNode) string {
+	switch n := node.(type) {
+	case *irRef:
+		switch n.Name {
+		case "string":
+			return "string"
+		case "number":
+			return "int"
+		case "bool":
+			return "bool"
+		}
+	case *irCall:
+		switch n.Name {
+		case "list", "set", "tuple":
+			return "array"
+		case "map", "object":
+			return "object"
+		}
+	}
+	return ""
+}
+
+// inferType guesses the Bicep type of a literal
+func inferType(node irNode) string {
+	switch n := node.(type) {
+	case *irString, *irTemplate:
+		return "string"
+	case *irNumber:
+		return "int"
+	case *irBool:
+		return "bool"
+	case *irArray, *irSplat:
+		return "array"
+	case *irObject:
+		return "object"
+	case *irFor:
+		if n.KeyExpr != nil {
+			return "object"
+		}
+		return "array"
+	case *irCond:
+		if typ := inferType(n.Then); typ != "" {
+			return typ
+		}
+		return inferType(n.Else)
+	case *irCall:
+		return functionTypes[n.Name]
+	}
+	return ""
+}
+
+// functionTypes are the result types of common Terraform functions
+var functionTypes = map[string]string{
+	"lower": "string", "upper": "string", "format": "string", "join": "string", "substr": "string",
+	"replace": "string", "tostring": "string", "jsonencode": "string", "trimspace": "string",
+	"length": "int", "tonumber": "int", "merge": "object", "tomap": "object",
+	"tolist": "array", "toset": "array", "concat": "array", "split": "array", "keys": "array",
+	"values": "array", "flatten": "array", "distinct": "array",
+}
+
+// param converts a variable
+func (c *bicepConverter) param(v sourceBlock) string {
+	name := v.block.Labels[0]
+	body := blockObject(v.block.Body, v.src)
+	var lines []string
+
+	if item := body.get("description"); item != nil {
+		lines = append(lines, fmt.Sprintf("@description(%s)", c.expr(item.Value)))
+		lines = append(lines, c.takePending("description = "+item.Src)...)
+	}
+	if item := body.get("sensitive"); item != nil {
+		if b, ok := item.Value.(*irBool); ok && b.Value {
+			lines = append(lines, "@secure()")
+		}
+	}
+	if item := body.get("validation"); item != nil {
+		for _, node := range item.Value.(*irBlocks).Items {
+			if object, ok := node.(*irObject); ok {
+				lines = append(lines, c.validation(name, object)...)
+			}
+		}
+	}
+
+	typ := c.paramTypes[name]
+	if typ == "" {
+		typ = "string"
+		lines = append(lines, c.todoLines("the variable has no type Bicep can express; check the param type", "")...)
+	}
+	declaration := fmt.Sprintf("param %s %s", c.params[name], typ)
+	if item := body.get("default"); item != nil {
+		if _, isNull := item.Value.(*irNull); !isNull {
+			declaration += " = " + c.expr(item.Value)
+			lines = append(lines, c.takePending("default = "+item.Src)...)
+		}
+	}
+	return strings.Join(commentsFirst(append(lines, declaration)), "\n")
+}
+
+// validation converts a validation block to decorators where it matches
+// one; anything else becomes a TODO
+func (c *bicepConverter) validation(name string, block *irObject) []string {
+	condition := block.get("condition")
+	if condition == nil {
+		return nil
+	}
+	var lines []string
+	matched := true
+	for _, check := range splitAnd(condition.Value) {
+		if decorator, ok := c.decorator(name, check); ok {
+			lines = append(lines, decorator)
+		} else {
+			matched = false
+		}
+	}
+	if !matched {
+		quoted := "condition = " + condition.Src
+		if message := block.get("error_message"); message != nil {
+			quoted += "\nerror_message = " + message.Src
+		}
+		lines = append(lines, c.todoLines("validation has no Bicep decorator; check the value in the template", quoted)...)
+	}
+	return lines
+}
+
+// splitAnd splits a condition on its top-level && operators
+func splitAnd(node irNode) []irNode {
+	if b, ok := node.(*irBinary); ok && b.Op == "&&" {
+		return append(splitAnd(b.Left), splitAnd(b.Right)...)
+	}
+	return []irNode{node}
+}
+
+// decorator matches one validation check against the Bicep decorators
+func (c *bicepConverter) decorator(name string, check irNode) (string, bool) {
+	isVar := func(node irNode) bool {
+		get, ok := node.(*irGet)
+		if !ok {
+			return false
+		}
+		ref, ok := get.Target.(*irRef)
+		return ok && ref.Name == "var" && get.Name == name
+	}
+	if call, ok := check.(*irCall); ok && call.Name == "contains" && len(call.Args) == 2 {
+		if list, ok := call.Args[0].(*irArray); ok && isVar(call.Args[1]) {
+			return fmt.Sprintf("@allowed(%s)", c.expr(list)), true
+		}
+	}
+	b, ok := check.(*irBinary)
+	if !ok {
+		return "", false
+	}
+	limit, ok := b.Right.(*irNumber)
+	if !ok {
+		return "", false
+	}
+	kind := "Value"
+	subject := b.Left
+	if call, ok := subject.(*irCall); ok && call.Name == "length" && len(call.Args) == 1 {
+		kind, subject = "Length", call.Args[0]
+	}
+	if !isVar(subject) {
+		return "", false
+	}
+	switch b.Op {
+	case ">=":
+		return fmt.Sprintf("@min%s(%s)", kind, limit.Text), true
+	case "<=":
+		return fmt.Sprintf("@max%s(%s)", kind, limit.Text), true
+	}
+	return "", false
+}
+
+// output converts an output
+func (c *bicepConverter) output(o sourceBlock) string {
+	body := blockObject(o.block.Body, o.src)
+	var lines []string
+	if item := body.get("description"); item != nil {
+		lines = append(lines, fmt.Sprintf("@description(%s)", c.expr(item.Value)))
+		lines = append(lines, c.takePending("description = "+item.Src)...)
+	}
+	if item := body.get("sensitive"); item != nil {
+		if b, ok := item.Value.(*irBool); ok && b.Value {
+			lines = append(lines, "@secure()")
+		}
+	}
+	value := body.get("value")
+	if value == nil {
+		return ""
+	}
+	text := c.expr(value.Value)
+	lines = append(lines, c.takePending("value = "+value.Src)...)
+	typ := c.typeOf(value.Value)
+	if typ == "" {
+		typ = "string"
+		lines = append(lines, c.todoLines("check the output type", "")...)
+	}
+	return strings.Join(commentsFirst(append(lines, fmt.Sprintf("output %s %s = %s", camelCase(o.block.Labels[0]), typ, text))), "\n")
+}
+
+// commentsFirst moves TODO comments above the decorators of a declaration
+func commentsFirst(lines []string) []string {
+	sort.SliceStable(lines, func(i, j int) bool {
+		return strings.HasPrefix(lines[i], "//") && !strings.HasPrefix(lines[j], "//")
+	})
+	return lines
+}
+
+// typeOf infers the type of an expression, following references
+func (c *bicepConverter) typeOf(node irNode) string {
+	if typ := inferType(node); typ != "" {
+		return typ
+	}
+	root, accessors := chain(node)
+	ref, ok := root.(*irRef)
+	if !ok || len(accessors) == 0 {
+		return ""
+	}
+	get, ok := accessors[0].(*irGet)
+	if !ok {
+		return ""
+	}
+	switch ref.Name {
+	case "var":
+		if len(accessors) == 1 {
+			return strings.TrimSuffix(c.paramTypes[get.Name], "?")
+		}
+		return ""
+	case "local":
+		if value := c.locals[get.Name]; value != nil && len(accessors) == 1 {
+			return c.typeOf(value)
+		}
+		return ""
+	}
+	last, ok := accessors[len(accessors)-1].(*irGet)
+	if !ok {
+		return ""
+	}
+	switch {
+	case last.Name == "tags":
+		return "object"
+	case last.Name == "id" || last.Name == "name" || last.Name == "location":
+		return "string"
+	}
+	for _, suffix := range []string{"_id", "_name", "_key", "_uri", "_url", "_hostname", "_endpoint", "_string", "_fqdn", "_version"} {
+		if strings.HasSuffix(last.Name, suffix) {
+			return "string"
+		}
+	}
+	return ""
+}
+
+// =============================================================================
+// Resources
+// =============================================================================
+
+// resource renders a resource, data source or module
+func (c *bicepConverter) resource(t *bicepTarget) string {
+	before := c.todos
+	defer func() { t.todos = c.todos - before }()
+
+	switch {
+	case t.scopeRG || t.folded != nil:
+		return ""
+	case t.kind == "data" && (t.tfType == "azurerm_client_config" || t.tfType == "azurerm_subscription"):
+		return ""
+	case t.kind == "module":
+		return c.module(t)
+	case t.symbol == "":
+		message := fmt.Sprintf("no Microsoft.* mapping for %s; convert it by hand", t.tfType)
+		if hint, ok := unmappedHints[t.tfType]; ok {
+			message += " (" + hint + ")"
+		}
+		return strings.Join(c.todoLines(message, blockSource(t.block, t.src)), "\n")
+	}
+
+	c.loop = t.loop
+	defer func() { c.loop = nil }()
+	if t.loop != nil {
+		c.scopes = append(c.scopes, c.loopScope(t.loop))
+		defer func() { c.scopes = c.scopes[:len(c.scopes)-1] }()
+	}
+
+	object := &bicepObject{}
+	consumed := map[string]bool{"name": true, "resource_group_name": true, "count": true, "for_each": true, "depends_on": true}
+	var extra []string
+
+	if link := t.mapping.parent; link != nil {
+		consumed[link.arg] = true
+		if item := t.body.get(link.arg); item != nil {
+			parent, ok := c.parentSymbol(t, item.Value)
+			if ok {
+				object.set("parent", parent, c.takePending(link.arg+" = "+item.Src))
+			} else {
+				c.pending = nil
+				c.todo(object, fmt.Sprintf("set parent: %s does not reference a converted resource", link.arg), link.arg+" = "+item.Src)
+			}
+		}
+	}
+	if item := t.body.get("name"); item != nil {
+		object.set("name", c.expr(item.Value), c.takePending("name = "+item.Src))
+	}
+	if item := t.body.get("resource_group_name"); item != nil && !c.isScopeReference(item.Value) && t.kind == "data" {
+		object.set("scope", fmt.Sprintf("resourceGroup(%s)", c.expr(item.Value)), c.takePending("resource_group_name = "+item.Src))
+	}
+	for _, key := range []string{"provider", "lifecycle"} {
+		if item := t.body.get(key); item != nil {
+			consumed[key] = true
+			quoted := item.Src
+			if key == "provider" {
+				quoted = "provider = " + item.Src
+			}
+			c.todo(object, fmt.Sprintf("Terraform %s has no Bicep equivalent", key), quoted)
+		}
+	}
+
+	if t.kind == "data" {
+		c.unmapped(t.body, object, "", consumed, "existing resource")
+	} else {
+		c.applyRules(t.mapping.rules, t.body, object, consumed)
+		extra = c.mergedChildren(t, consumed)
+		if association := c.associations[t.address]; association != nil {
+			if item := association.body.get("network_security_group_id"); item != nil {
+				object.set("properties.networkSecurityGroup.id", c.expr(item.Value), c.takePending("network_security_group_id = "+item.Src))
+			}
+		}
+		c.unmapped(t.body, object, "", consumed, t.mapping.bicepType)
+		if item := t.body.get("depends_on"); item != nil {
+			if deps := c.dependsOn(item.Value); deps != "" {
+				object.set("dependsOn", deps, c.takePending("depends_on = "+item.Src))
+			}
+		}
+	}
+	object.sortTopLevel()
+
+	var out strings.Builder
+	header := "= "
+	switch {
+	case t.kind == "data":
+		header = "existing = "
+	case t.loop != nil:
+		header += c.loopHeader(t.loop)
+	case t.cond != nil:
+		header += fmt.Sprintf("if (%s) ", c.expr(t.cond))
+	}
+	for _, key := range []string{"count", "for_each"} {
+		if item := t.body.get(key); item != nil {
+			for _, line := range c.takePending(key + " = " + item.Src) {
+				out.WriteString(line + "\n")
+			}
+		}
+	}
+	out.WriteString(fmt.Sprintf("resource %s '%s@%s' %s", t.symbol, t.mapping.bicepType, t.mapping.apiVersion, header))
+	object.write(&out, "")
+	if t.loop != nil {
+		out.WriteString("]")
+	}
+	return strings.Join(append([]string{out.String()}, extra...), "\n\n")
+}
+
+// unmappedHints point at Bicep replacements for common non-azurerm types
+var unmappedHints = map[string]string{
+	"random_string":   "use uniqueString(resourceGroup().id) in the name",
+	"random_id":       "use uniqueString(resourceGroup().id) in the name",
+	"random_uuid":     "use guid() or newGuid() as a param default",
+	"random_integer":  "use a param",
+	"random_password": "use a @secure() param",
+	"null_resource":   "use a Microsoft.Resources/deploymentScripts resource",
+	"time_sleep":      "Bicep deployments have no delays; use dependsOn",
+}
+
+// loopScope binds each and count for a resource loop
+func (c *bicepConverter) loopScope(loop *bicepLoop) map[string]*loopVar {
+	if loop.count != nil {
+		return map[string]*loopVar{"count.index": {text: loop.index}}
+	}
+	if loop.isMap {
+		return map[string]*loopVar{
+			"each.key":   {text: loop.item + ".key"},
+			"each.value": {text: loop.item + ".value"},
+		}
+	}
+	return map[string]*loopVar{"each.key": {text: loop.item}, "each.value": {text: loop.item}}
+}
+
+// loopHeader renders the opening of a resource loop
+func (c *bicepConverter) loopHeader(loop *bicepLoop) string {
+	if loop.count != nil {
+		return fmt.Sprintf("[for %s in range(0, %s): ", loop.index, c.expr(loop.count))
+	}
+	source := c.expr(loop.source)
+	if loop.isMap {
+		source = fmt.Sprintf("items(%s)", source)
+	}
+	if loop.used {
+		return fmt.Sprintf("[for (%s, %s) in %s: ", loop.item, loop.index, source)
+	}
+	return fmt.Sprintf("[for %s in %s: ", loop.item, source)
+}
+
+// loopKeys renders the keys of a for_each loop in loop order
+func (c *bicepConverter) loopKeys(loop *bicepLoop) string {
+	if loop.isMap {
+		return fmt.Sprintf("objectKeys(%s)", c.expr(loop.source))
+	}
+	return c.expr(loop.source)
+}
+
+// parentSymbol resolves a parent argument such as storage_account_id to
+// the parent's symbol, creating merged children such as blobServices
+func (c *bicepConverter) parentSymbol(t *bicepTarget, node irNode) (string, bool) {
+	root, accessors := chain(node)
+	ref, ok := root.(*irRef)
+	if !ok || len(accessors) == 0 {
+		return "", false
+	}
+	get, ok := accessors[0].(*irGet)
+	if !ok {
+		return "", false
+	}
+	parent := c.targets[ref.Name+"."+get.Name]
+	if parent == nil || parent.symbol == "" || parent.mapping == nil {
+		return "", false
+	}
+	symbol := parent.symbol
+	if len(accessors) > 2 {
+		if idx, ok := accessors[1].(*irIndex); ok {
+			symbol += c.index(parent, idx.Key)
+		}
+	}
+	want := parentBicepType(t.mapping.bicepType)
+	if strings.EqualFold(want, parent.mapping.bicepType) {
+		return symbol, true
+	}
+	// The parent is a child folded into the Terraform resource
+	for _, child := range mergedChildren(parent.mapping.bicepType) {
+		if strings.EqualFold(child.bicepType, want) && parent.loop == nil {
+			return c.childSymbol(parent, child), true
+		}
+	}
+	return "", false
+}
+
+// childSymbol returns the symbol of a merged child, reserving it on first
+// use
+func (c *bicepConverter) childSymbol(parent *bicepTarget, child *resourceMapping) string {
+	if parent.children == nil {
+		parent.children = make(map[string]string)
+	}
+	if symbol, ok := parent.children[child.bicepType]; ok {
+		return symbol
+	}
+	segment := child.bicepType[strings.LastIndex(child.bicepType, "/")+1:]
+	symbol := c.symbol(parent.symbol+strings.ToUpper(segment[:1])+strings.TrimSuffix(segment[1:], "s"), "Resource")
+	parent.children[child.bicepType] = symbol
+	return symbol
+}
+
+// mergedChildren renders the child resources folded into blocks such as
+// blob_properties, and the ones other resources need as a parent
+func (c *bicepConverter) mergedChildren(t *bicepTarget, consumed map[string]bool) []string {
+	var out []string
+	for _, child := range mergedChildren(t.mapping.bicepType) {
+		item := t.body.get(child.mergeBlock)
+		if item == nil && !c.childNeeded(t, child) {
+			continue
+		}
+		object := &bicepObject{}
+		object.set("parent", t.symbol, nil)
+		object.set("name", bicepQuote(child.mergeName), nil)
+		if item != nil {
+			consumed[child.mergeBlock] = true
+			if t.loop != nil {
+				c.todo(object, fmt.Sprintf("%s is a loop; add a %s loop for each element", t.symbol, child.bicepType), item.Src)
+			} else if blocks, ok := item.Value.(*irBlocks); ok && len(blocks.Items) == 1 {
+				if block, ok := blocks.Items[0].(*irObject); ok {
+					childConsumed := make(map[string]bool)
+					c.applyRules(child.rules, block, object, childConsumed)
+					c.unmapped(block, object, child.mergeBlock+".", childConsumed, child.bicepType)
+				}
+			}
+		}
+		object.sortTopLevel()
+
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("resource %s '%s@%s' = ", c.childSymbol(t, child), child.bicepType, child.apiVersion))
+		object.write(&b, "")
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// childNeeded reports whether another resource names a merged child of t
+// as its parent, such as a container in a storage account
+func (c *bicepConverter) childNeeded(t *bicepTarget, child *resourceMapping) bool {
+	for _, other := range c.order {
+		if other.mapping == nil || other.mapping.parent == nil || !strings.EqualFold(parentBicepType(other.mapping.bicepType), child.bicepType) {
+			continue
+		}
+		if item := other.body.get(other.mapping.parent.arg); item != nil {
+			root, accessors := chain(item.Value)
+			if ref, ok := root.(*irRef); ok && len(accessors) > 0 {
+				if get, ok := accessors[0].(*irGet); ok && c.targets[ref.Name+"."+get.Name] == t {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// module renders a module call; the module itself still has to be
+// converted
+func (c *bicepConverter) module(t *bicepTarget) string {
+	c.loop = t.loop
+	defer func() { c.loop = nil }()
+	if t.loop != nil {
+		c.scopes = append(c.scopes, c.loopScope(t.loop))
+		defer func() { c.scopes = c.scopes[:len(c.scopes)-1] }()
+	}
+
+	source := ""
+	if item := t.body.get("source"); item != nil {
+		source, _ = literalString(item.Value)
+	}
+	var notes []string
+	path := source
+	if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+		path = strings.TrimSuffix(source, "/") + "/main.bicep"
+		notes = c.todoLines(fmt.Sprintf("convert the Terraform module %s to Bicep in %s", source, path), "")
+	} else {
+		notes = c.todoLines(fmt.Sprintf("%s is a registry module; point the module at an equivalent Bicep module (e.g. br/public:avm/...)", source), "")
+	}
+
+	object := &bicepObject{}
+	object.set("name", bicepQuote(t.name), nil)
+	params := object.child("params")
+	for _, item := range t.body.Items {
+		switch item.Key {
+		case "source", "version", "count", "for_each", "depends_on":
+			continue
+		case "providers":
+			c.todo(object, "Terraform module providers have no Bicep equivalent", "providers = "+item.Src)
+			continue
+		}
+		params.set(camelCase(item.Key), c.expr(item.Value), c.takePending(item.Key+" = "+item.Src))
+	}
+	if item := t.body.get("depends_on"); item != nil {
+		if deps := c.dependsOn(item.Value); deps != "" {
+			object.set("dependsOn", deps, c.takePending("depends_on = "+item.Src))
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(strings.Join(notes, "\n") + "\n")
+	out.WriteString(fmt.Sprintf("module %s %s = ", t.symbol, bicepQuote(path)))
+	switch {
+	case t.loop != nil:
+		out.WriteString(c.loopHeader(t.loop))
+		object.write(&out, "")
+		out.WriteString("]")
+	case t.cond != nil:
+		out.WriteString(fmt.Sprintf("if (%s) ", c.expr(t.cond)))
+		object.write(&out, "")
+	default:
+		object.write(&out, "")
+	}
+	return out.String()
+}
+
+// applyRules maps the arguments of a Terraform body into a Bicep object
+func (c *bicepConverter) applyRules(rules []propertyRule, src *irObject, dst *bicepObject, consumed map[string]bool) {
+	var defaults []propertyRule
+	for _, rule := range rules {
+		if rule.tf == "" {
+			if rule.bicepDefault != "" {
+				defaults = append(defaults, rule)
+			}
+			continue
+		}
+		item := tfLookup(src, rule.tf)
+		// A plain rule does not map an argument a conversion already mapped,
+		// such as address_prefixes to both addressPrefix and addressPrefixes
+		if item == nil || (consumed[rule.tf] && rule.toBicep == nil) {
+			continue
+		}
+		if rule.elements != nil {
+			consumed[rule.tf] = true
+			c.applyElements(rule, item, dst)
+			continue
+		}
+
+		text, ok := "", true
+		if rule.toBicep != nil {
+			text, ok = rule.toBicep(c, item.Value, src)
+		} else {
+			text = c.expr(item.Value)
+		}
+		if !ok {
+			c.pending = nil
+			continue
+		}
+		consumed[rule.tf] = true
+		if text != "" {
+			dst.set(rule.bicep, text, c.takePending(item.Key+" = "+item.Src))
+		}
+	}
+
+	// Required Bicep properties Terraform implies, such as the Key Vault SKU
+	// family, go next to their siblings
+	for _, rule := range defaults {
+		parent := ""
+		if i := strings.LastIndex(rule.bicep, "."); i >= 0 {
+			parent = rule.bicep[:i]
+		}
+		if parent == "" || dst.lookup(parent) != nil {
+			dst.set(rule.bicep, rule.bicepDefault, nil)
+		}
+	}
+}
+
+// applyElements maps repeated nested blocks to an array of objects, and a
+// dynamic block to a for-expression
+func (c *bicepConverter) applyElements(rule propertyRule, item *irItem, dst *bicepObject) {
+	blocks, ok := item.Value.(*irBlocks)
+	if !ok {
+		c.todo(dst, fmt.Sprintf("%s is not a block", item.Key), item.Key+" = "+item.Src)
+		return
+	}
+	for _, element := range blocks.Items {
+		switch e := element.(type) {
+		case *irObject:
+			object := dst.appendElement(rule.bicep)
+			consumed := make(map[string]bool)
+			c.applyRules(rule.elements, e, object, consumed)
+			c.unmapped(e, object, "", consumed, rule.bicep)
+		case *irFor:
+			if len(blocks.Items) > 1 {
+				c.todo(dst, fmt.Sprintf("combine the static and dynamic %s blocks into %s", item.Key, rule.bicep), item.Src)
+				return
+			}
+			body, _ := e.Body.(*irObject)
+			name := camelCase(e.Value)
+			isMap := c.isMap(e.Source)
+			source := c.expr(e.Source)
+			scope := map[string]*loopVar{e.Value + ".value": {text: name}, e.Value + ".key": {text: name}}
+			if isMap {
+				source = fmt.Sprintf("items(%s)", source)
+				scope = map[string]*loopVar{e.Value + ".value": {text: name + ".value"}, e.Value + ".key": {text: name + ".key"}}
+			}
+			c.scopes = append(c.scopes, scope)
+			object := &bicepObject{}
+			consumed := make(map[string]bool)
+			c.applyRules(rule.elements, body, object, consumed)
+			c.unmapped(body, object, "", consumed, rule.bicep)
+			c.scopes = c.scopes[:len(c.scopes)-1]
+
+			var b strings.Builder
+			b.WriteString(fmt.Sprintf("[for %s in %s: ", name, source))
+			object.write(&b, "")
+			b.WriteString("]")
+			dst.set(rule.bicep, b.String(), c.takePending(""))
+		}
+	}
+}
+
+// unmapped adds a TODO for every Terraform argument no rule consumed
+func (c *bicepConverter) unmapped(src *irObject, dst *bicepObject, prefix string, consumed map[string]bool, target string) {
+	for _, item := range src.Items {
+		path := prefix + item.Key
+		if consumed[path] {
+			continue
+		}
+		blocks, isBlock := item.Value.(*irBlocks)
+		if isBlock && len(blocks.Items) == 1 && consumedUnder(consumed, path) {
+			if object, ok := blocks.Items[0].(*irObject); ok {
+				c.unmapped(object, dst, path+".", consumed, target)
+				continue
+			}
+		}
+		quoted := item.Src
+		if !isBlock {
+			quoted = item.Key + " = " + item.Src
+		}
+		c.todo(dst, fmt.Sprintf("no %s property for Terraform argument %s", target, path), quoted)
+	}
+}
+
+// dependsOn converts depends_on; references to the deployment scope drop
+func (c *bicepConverter) dependsOn(node irNode) string {
+	list, ok := node.(*irArray)
+	if !ok {
+		c.pending = append(c.pending, "depends_on is not a list")
+		return ""
+	}
+	var symbols []string
+	for _, item := range list.Items {
+		root, accessors := chain(item)
+		ref, ok := root.(*irRef)
+		address := ""
+		if ok && len(accessors) > 0 {
+			if get, ok := accessors[0].(*irGet); ok {
+				address = ref.Name + "." + get.Name
+			}
+			if get, ok := accessors[len(accessors)-1].(*irGet); ok && ref.Name == "data" && len(accessors) == 2 {
+				address = "data." + accessors[0].(*irGet).Name + "." + get.Name
+			}
+		}
+		target := c.targets[address]
+		switch {
+		case target == nil:
+			c.pending = append(c.pending, "depends_on entry is not a resource or module")
+		case target.scopeRG:
+		case target.symbol == "":
+			c.pending = append(c.pending, fmt.Sprintf("depends_on %s, which was not converted", address))
+		default:
+			symbols = append(symbols, target.symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(symbols, ", ") + "]"
+}
+
+// isScopeReference reports whether a resource_group_name references the
+// deployment scope
+func (c *bicepConverter) isScopeReference(node irNode) bool {
+	root, accessors := chain(node)
+	ref, ok := root.(*irRef)
+	if !ok || len(accessors) < 2 {
+		return false
+	}
+	if ref.Name == "data" && len(accessors) >= 3 {
+		first, _ := accessors[0].(*irGet)
+		second, _ := accessors[1].(*irGet)
+		if first != nil && second != nil {
+			t := c.targets["data."+first.Name+"."+second.Name]
+			return t != nil && t.scopeRG
+		}
+	}
+	get, ok := accessors[0].(*irGet)
+	if !ok {
+		return false
+	}
+	t := c.targets[ref.Name+"."+get.Name]
+	return t != nil && t.scopeRG
+}
+
+// todoLines renders a TODO comment and counts it
+func (c *bicepConverter) todoLines(message, quoted string) []string {
+	c.todos++
+	return todoLines("//", message, quoted)
+}
+
+// todo adds a standalone TODO comment to an object
+func (c *bicepConverter) todo(object *bicepObject, message, quoted string) {
+	object.note(c.todoLines(message, quoted))
+}
+
+// takePending turns problems in the last rendered expression into a TODO
+// comment quoting the Terraform source
+func (c *bicepConverter) takePending(quoted string) []string {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	message := strings.Join(c.pending, "; ")
+	c.pending = nil
+	return c.todoLines(message, quoted)
+}
+
+// =============================================================================
+// Expressions
+// =============================================================================
+
+// expr renders an expression as Bicep. Parts without an equivalent render
+// as null and are recorded in pending.
+func (c *bicepConverter) expr(node irNode) string {
+	switch n := node.(type) {
+	case *irString:
+		return bicepQuote(n.Value)
+	case *irNumber:
+		if strings.ContainsAny(n.Text, ".eE") {
+			c.pending = append(c.pending, "Bicep has no fractional numbers")
+			return fmt.Sprintf("json('%s')", n.Text)
+		}
+		return n.Text
+	case *irBool:
+		return fmt.Sprint(n.Value)
+	case *irNull:
+		return "null"
+	case *irTemplate:
+		var b strings.Builder
+		b.WriteString("'")
+		for _, part := range n.Parts {
+			if s, ok := part.(*irString); ok {
+				quoted := bicepQuote(s.Value)
+				b.WriteString(quoted[1 : len(quoted)-1])
+			} else {
+				b.WriteString("${" + c.expr(part) + "}")
+			}
+		}
+		b.WriteString("'")
+		return b.String()
+	case *irArray:
+		return bicepArray(c.exprs(n.Items))
+	case *irObject:
+		if len(n.Items) == 0 {
+			return "{}"
+		}
+		var lines []string
+		for _, item := range n.Items {
+			key := item.Key
+			if !identifierPattern.MatchString(key) {
+				key = bicepQuote(key)
+			}
+			lines = append(lines, "  "+key+": "+indentLines(c.expr(item.Value), "  "))
+		}
+		return "{\n" + strings.Join(lines, "\n") + "\n}"
+	case *irParen:
+		return "(" + c.expr(n.X) + ")"
+	case *irUnary:
+		return n.Op + c.expr(n.X)
+	case *irBinary:
+		return fmt.Sprintf("%s %s %s", c.expr(n.Left), n.Op, c.expr(n.Right))
+	case *irCond:
+		return fmt.Sprintf("%s ? %s : %s", c.expr(n.Cond), c.expr(n.Then), c.expr(n.Else))
+	case *irRef, *irGet, *irIndex:
+		return c.reference(node)
+	case *irCall:
+		return c.call(n)
+	case *irFor:
+		return c.forExpr(n)
+	case *irSplat:
+		path := strings.Join(n.Path, ".")
+		if t, ok := c.resourceCollection(n.Source); ok {
+			c.scopes = append(c.scopes, map[string]*loopVar{"item": {text: t.symbol + "[i]", target: t}})
+			defer func() { c.scopes = c.scopes[:len(c.scopes)-1] }()
+			return fmt.Sprintf("map(range(0, %s), i => %s)", c.collectionLength(t), c.reference(c.splatPath(&irRef{Name: "item"}, n.Path)))
+		}
+		return fmt.Sprintf("map(%s, item => item.%s)", c.expr(n.Source), path)
+	case *irRaw:
+		c.pending = append(c.pending, fmt.Sprintf("no Bicep equivalent for %s", n.Src))
+	}
+	return "null"
+}
+
+// exprs renders a list of expressions
+func (c *bicepConverter) exprs(nodes []irNode) []string {
+	texts := make([]string, len(nodes))
+	for i, node := range nodes {
+		texts[i] = c.expr(node)
+	}
+	return texts
+}
+
+// bicepArray renders array items on one line when they are short, else one
+// per line
+func bicepArray(items []string) string {
+	line := "[" + strings.Join(items, ", ") + "]"
+	if len(line) <= 60 && !strings.Contains(line, "\n") {
+		return line
+	}
+	var b strings.Builder
+	b.WriteString("[\n")
+	for _, item := range items {
+		b.WriteString("  " + indentLines(item, "  ") + "\n")
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// splatPath appends attribute accessors to a node
+func (c *bicepConverter) splatPath(node irNode, path []string) irNode {
+	for _, name := range path {
+		node = &irGet{Target: node, Name: name}
+	}
+	return node
+}
+
+// forExpr converts a for-expression; object for-expressions use toObject()
+// and filtered ones filter()
+func (c *bicepConverter) forExpr(n *irFor) string {
+	name := n.Value
+	scope := map[string]*loopVar{n.Value: {text: n.Value}}
+	var source string
+	if t, ok := c.resourceCollection(n.Source); ok {
+		// Resource collections are indexed by position
+		name = "i"
+		source = fmt.Sprintf("range(0, %s)", c.collectionLength(t))
+		scope = map[string]*loopVar{n.Value: {text: t.symbol + "[i]", target: t}}
+		if n.Key != "" {
+			scope[n.Key] = &loopVar{text: "i"}
+			if t.loop.count == nil {
+				scope[n.Key] = &loopVar{text: c.loopKeys(t.loop) + "[i]"}
+			}
+		}
+	} else if c.isMap(n.Source) {
+		source = fmt.Sprintf("items(%s)", c.expr(n.Source))
+		scope = map[string]*loopVar{n.Value: {text: name + ".value"}}
+		if n.Key != "" {
+			scope[n.Key] = &loopVar{text: name + ".key"}
+		}
+	} else {
+		source = c.expr(n.Source)
+		if n.Key != "" {
+			scope[n.Key] = &loopVar{text: n.Key}
+		}
+	}
+
+	c.scopes = append(c.scopes, scope)
+	defer func() { c.scopes = c.scopes[:len(c.scopes)-1] }()
+	body := c.expr(n.Body)
+	if n.Cond != nil {
+		source = fmt.Sprintf("filter(%s, %s => %s)", source, name, c.expr(n.Cond))
+	}
+	if n.KeyExpr != nil {
+		return fmt.Sprintf("toObject(%s, %s => %s, %s => %s)", source, name, c.expr(n.KeyExpr), name, body)
+	}
+	if n.Key != "" && scope[n.Key].text == n.Key {
+		if n.Cond != nil {
+			c.pending = append(c.pending, "the index of a filtered list changes; check the loop")
+		}
+		return fmt.Sprintf("[for (%s, %s) in %s: %s]", name, n.Key, source, body)
+	}
+	return fmt.Sprintf("[for %s in %s: %s]", name, source, body)
+}
+
+// isMap guesses whether an expression is a map, which loops over items()
+func (c *bicepConverter) isMap(node irNode) bool {
+	switch n := node.(type) {
+	case *irObject:
+		return true
+	case *irFor:
+		return n.KeyExpr != nil
+	case *irCall:
+		return n.Name == "merge" || n.Name == "tomap"
+	}
+	root, accessors := chain(node)
+	ref, ok := root.(*irRef)
+	if !ok || len(accessors) != 1 {
+		return false
+	}
+	get, ok := accessors[0].(*irGet)
+	if !ok {
+		return false
+	}
+	switch ref.Name {
+	case "var":
+		return strings.TrimSuffix(c.paramTypes[get.Name], "?") == "object"
+	case "local":
+		return c.locals[get.Name] != nil && c.isMap(c.locals[get.Name])
+	}
+	return false
+}
+
+// resourceCollection reports whether a node is a whole resource loop
+func (c *bicepConverter) resourceCollection(node irNode) (*bicepTarget, bool) {
+	root, accessors := chain(node)
+	ref, ok := root.(*irRef)
+	if !ok || len(accessors) != 1 {
+		return nil, false
+	}
+	get, ok := accessors[0].(*irGet)
+	if !ok {
+		return nil, false
+	}
+	t := c.targets[ref.Name+"."+get.Name]
+	return t, t != nil && t.symbol != "" && t.loop != nil
+}
+
+// collectionLength renders the number of elements of a resource loop
+func (c *bicepConverter) collectionLength(t *bicepTarget) string {
+	if t.loop.count != nil {
+		return c.expr(t.loop.count)
+	}
+	return fmt.Sprintf("length(%s)", c.loopKeys(t.loop))
+}
+
+// index renders the index into a resource loop for a Terraform key
+func (c *bicepConverter) index(t *bicepTarget, key irNode) string {
+	if t.loop == nil {
+		if t.cond != nil && isNumber(key, "0") {
+			return ""
+		}
+		c.pending = append(c.pending, fmt.Sprintf("%s is not a loop in Bicep", t.symbol))
+		return ""
+	}
+	if t.loop.count != nil {
+		return "[" + c.expr(key) + "]"
+	}
+	// The same key as the loop being rendered is the same position
+	if c.loop != nil && c.loop.count == nil && c.loop.isMap == t.loop.isMap && c.loopKeys(c.loop) == c.loopKeys(t.loop) {
+		if each, ok := key.(*irGet); ok && each.Name == "key" {
+			if ref, ok := each.Target.(*irRef); ok && ref.Name == "each" {
+				c.loop.used = true
+				return "[" + c.loop.index + "]"
+			}
+		}
+	}
+	return fmt.Sprintf("[indexOf(%s, %s)]", c.loopKeys(t.loop), c.expr(key))
+}
+
+// reference renders a reference with its attribute accesses
+func (c *bicepConverter) reference(node irNode) string {
+	root, accessors := chain(node)
+	ref, ok := root.(*irRef)
+	if !ok {
+		return c.expr(root) + c.accessors(accessors)
+	}
+
+	// Loop variables: each.key, count.index, iterators and for variables
+	if len(accessors) > 0 {
+		if get, ok := accessors[0].(*irGet); ok {
+			if v := c.lookupScope(ref.Name + "." + get.Name); v != nil {
+				return v.text + c.accessors(accessors[1:])
+			}
+		}
+	}
+	if v := c.lookupScope(ref.Name); v != nil {
+		if v.target != nil {
+			return c.attribute(v.target, v.text, accessors)
+		}
+		return v.text + c.accessors(accessors)
+	}
+	if len(accessors) == 0 {
+		c.pending = append(c.pending, fmt.Sprintf("unknown reference %s", ref.Name))
+		return "null"
+	}
+	first, ok := accessors[0].(*irGet)
+	if !ok {
+		c.pending = append(c.pending, fmt.Sprintf("unknown reference %s", ref.Name))
+		return "null"
+	}
+
+	switch ref.Name {
+	case "var":
+		if symbol, ok := c.params[first.Name]; ok {
+			return symbol + c.accessors(accessors[1:])
+		}
+	case "local":
+		if symbol, ok := c.vars[first.Name]; ok {
+			return symbol + c.accessors(accessors[1:])
+		}
+	case "each", "count":
+		c.pending = append(c.pending, fmt.Sprintf("%s.%s is used outside its loop", ref.Name, first.Name))
+		return "null"
+	case "path", "terraform":
+		c.pending = append(c.pending, fmt.Sprintf("%s.%s has no Bicep equivalent", ref.Name, first.Name))
+		return "null"
+	case "module":
+		t := c.targets["module."+first.Name]
+		if t == nil {
+			break
+		}
+		base := t.symbol
+		rest := accessors[1:]
+		if len(rest) > 0 {
+			if idx, ok := rest[0].(*irIndex); ok {
+				base += c.index(t, idx.Key)
+				rest = rest[1:]
+			}
+		}
+		if len(rest) > 0 {
+			if output, ok := rest[0].(*irGet); ok {
+				return base + ".outputs." + camelCase(output.Name) + c.accessors(rest[1:])
+			}
+		}
+		c.pending = append(c.pending, fmt.Sprintf("read module %s through one of its outputs", first.Name))
+		return "null"
+	case "data":
+		if len(accessors) >= 2 {
+			if second, ok := accessors[1].(*irGet); ok {
+				if t := c.targets["data."+first.Name+"."+second.Name]; t != nil {
+					return c.targetReference(t, accessors[2:])
+				}
+			}
+		}
+	default:
+		if t := c.targets[ref.Name+"."+first.Name]; t != nil {
+			return c.targetReference(t, accessors[1:])
+		}
+	}
+	c.pending = append(c.pending, fmt.Sprintf("unknown reference %s.%s", ref.Name, first.Name))
+	return "null"
+}
+
+// lookupScope finds a loop variable
+func (c *bicepConverter) lookupScope(name string) *loopVar {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if v, ok := c.scopes[i][name]; ok {
+			if v.used != nil {
+				*v.used = true
+			}
+			return v
+		}
+	}
+	return nil
+}
+
+// accessors renders .name and [index] accessors
+func (c *bicepConverter) accessors(accessors []irNode) string {
+	var b strings.Builder
+	for _, accessor := range accessors {
+		switch a := accessor.(type) {
+		case *irGet:
+			b.WriteString("." + a.Name)
+		case *irIndex:
+			b.WriteString("[" + c.expr(a.Key) + "]")
+		}
+	}
+	return b.String()
+}
+
+// targetReference renders a reference to a resource or data source
+func (c *bicepConverter) targetReference(t *bicepTarget, accessors []irNode) string {
+	if t.scopeRG {
+		return c.deploymentScope("resourceGroup()", map[string]string{"name": "name", "location": "location", "id": "id", "tags": "tags"}, t, accessors)
+	}
+	switch t.tfType {
+	case "azurerm_client_config":
+		return c.deploymentScope("", map[string]string{
+			"tenant_id":       "tenant().tenantId",
+			"subscription_id": "subscription().subscriptionId",
+			"object_id":       "deployer().objectId",
+		}, t, accessors)
+	case "azurerm_subscription":
+		return c.deploymentScope("", map[string]string{
+			"subscription_id": "subscription().subscriptionId",
+			"id":              "subscription().id",
+			"display_name":    "subscription().displayName",
+			"tenant_id":       "subscription().tenantId",
+		}, t, accessors)
+	}
+	if t.symbol == "" {
+		message := fmt.Sprintf("%s was not converted", t.address)
+		if hint, ok := unmappedHints[t.tfType]; ok {
+			message += "; " + hint
+		}
+		c.pending = append(c.pending, message)
+		return "null"
+	}
+
+	base := t.symbol
+	if len(accessors) > 0 {
+		if idx, ok := accessors[0].(*irIndex); ok {
+			base += c.index(t, idx.Key)
+			accessors = accessors[1:]
+		}
+	} else if t.loop != nil {
+		c.pending = append(c.pending, fmt.Sprintf("%s is a loop; pick an element", t.symbol))
+	}
+	return c.attribute(t, base, accessors)
+}
+
+// deploymentScope renders reads of the deployment scope and client config
+func (c *bicepConverter) deploymentScope(prefix string, attributes map[string]string, t *bicepTarget, accessors []irNode) string {
+	if len(accessors) > 0 {
+		if get, ok := accessors[0].(*irGet); ok {
+			if text, ok := attributes[get.Name]; ok {
+				if prefix != "" {
+					text = prefix + "." + text
+				}
+				return text + c.accessors(accessors[1:])
+			}
+		}
+	}
+	c.pending = append(c.pending, fmt.Sprintf("no Bicep equivalent for %s%s", t.address, c.accessors(accessors)))
+	return "null"
+}
+
+// storageKeys are the storage account attributes read through listKeys()
+var storageKeys = map[string]string{
+	"primary_access_key":        "listKeys().keys[0].value",
+	"secondary_access_key":      "listKeys().keys[1].value",
+	"primary_connection_string": "",
+}
+
+// attribute renders an attribute read of a resource symbol
+func (c *bicepConverter) attribute(t *bicepTarget, base string, accessors []irNode) string {
+	if len(accessors) == 0 {
+		return base
+	}
+	for n := len(accessors); n > 0; n-- {
+		path := strings.TrimPrefix(c.accessors(accessors[:n]), ".")
+		if property, ok := bicepAttribute(t.mapping, path); ok {
+			return base + "." + property + c.accessors(accessors[n:])
+		}
+	}
+	if get, ok := accessors[0].(*irGet); ok && t.mapping.tfType == "azurerm_storage_account" {
+		if keys, ok := storageKeys[get.Name]; ok {
+			if keys == "" {
+				return fmt.Sprintf("'DefaultEndpointsProtocol=https;AccountName=${%s.name};AccountKey=${%s.listKeys().keys[0].value};EndpointSuffix=${az.environment().suffixes.storage}'", base, base)
+			}
+			return base + "." + keys + c.accessors(accessors[1:])
+		}
+	}
+	c.pending = append(c.pending, fmt.Sprintf("no %s property for %s%s", t.mapping.bicepType, t.address, c.accessors(accessors)))
+	return "null"
+}
+
+// bicepAttribute maps a Terraform attribute read to a Bicep property path
+func bicepAttribute(mapping *resourceMapping, path string) (string, bool) {
+	switch path {
+	case "id", "name":
+		return path, true
+	}
+	for property, attr := range mapping.attributes {
+		if attr == path {
+			return property, true
+		}
+	}
+	for _, rule := range mapping.rules {
+		if rule.tf == path && rule.toBicep == nil && rule.elements == nil {
+			return rule.bicep, true
+		}
+	}
+	return "", false
+}
+
+// bicepFunctions are Terraform functions with a same-argument Bicep
+// equivalent
+var bicepFunctions = map[string]string{
+	"lower":        "toLower",
+	"upper":        "toUpper",
+	"length":       "length",
+	"contains":     "contains",
+	"replace":      "replace",
+	"merge":        "union",
+	"trimspace":    "trim",
+	"startswith":   "startsWith",
+	"endswith":     "endsWith",
+	"tostring":     "string",
+	"tonumber":     "int",
+	"tobool":       "bool",
+	"jsondecode":   "json",
+	"jsonencode":   "string",
+	"base64encode": "base64",
+	"base64decode": "base64ToString",
+	"coalesce":     "coalesce",
+	"index":        "indexOf",
+	"flatten":      "flatten",
+	"keys":         "objectKeys",
+	"concat":       "concat",
+	"min":          "min",
+	"max":          "max",
+	"file":         "loadTextContent",
+	"filebase64":   "loadFileAsBase64",
+}
+
+// bicepFunctionHints explain Terraform functions without a Bicep equivalent
+var bicepFunctionHints = map[string]string{
+	"timestamp":    "utcNow() is only allowed in param defaults",
+	"uuid":         "newGuid() is only allowed in param defaults",
+	"sha1":         "use uniqueString() for stable unique names",
+	"sha256":       "use uniqueString() for stable unique names",
+	"md5":          "use uniqueString() for stable unique names",
+	"cidrsubnet":   "Bicep's cidrSubnet() takes the new prefix length, not the added bits",
+	"try":          "Bicep has no try(); use the safe-dereference operator .? with ??",
+	"can":          "Bicep has no can()",
+	"templatefile": "Bicep has no templates; use loadTextContent() with format() or replace()",
+}
+
+// call converts a function call
+func (c *bicepConverter) call(n *irCall) string {
+	if hint, ok := bicepFunctionHints[n.Name]; ok {
+		c.pending = append(c.pending, hint)
+		return "null"
+	}
+	args := c.exprs(n.Args)
+	if renamed, ok := bicepFunctions[n.Name]; ok {
+		return fmt.Sprintf("%s(%s)", renamed, strings.Join(args, ", "))
+	}
+
+	switch {
+	case (n.Name == "tolist" || n.Name == "tomap") && len(args) == 1:
+		return args[0]
+	case (n.Name == "toset" || n.Name == "distinct") && len(args) == 1:
+		return fmt.Sprintf("union(%s, [])", args[0])
+	case n.Name == "join" && len(args) == 2:
+		return fmt.Sprintf("join(%s, %s)", args[1], args[0])
+	case n.Name == "split" && len(args) == 2:
+		return fmt.Sprintf("split(%s, %s)", args[1], args[0])
+	case n.Name == "substr" && len(args) == 3:
+		if isNumber(n.Args[1], "0") {
+			return fmt.Sprintf("take(%s, %s)", args[0], args[2])
+		}
+		if u, ok := n.Args[2].(*irUnary); ok && u.Op == "-" && isNumber(u.X, "1") {
+			return fmt.Sprintf("skip(%s, %s)", args[0], args[1])
+		}
+		return fmt.Sprintf("substring(%s, %s, %s)", args[0], args[1], args[2])
+	case n.Name == "lookup" && len(args) == 2:
+		return fmt.Sprintf("%s[%s]", args[0], args[1])
+	case n.Name == "lookup" && len(args) == 3:
+		return fmt.Sprintf("(%s[?%s] ?? %s)", args[0], args[1], args[2])
+	case n.Name == "element" && len(args) == 2:
+		return fmt.Sprintf("%s[%s]", args[0], args[1])
+	case n.Name == "values" && len(args) == 1:
+		return fmt.Sprintf("map(items(%s), item => item.value)", args[0])
+	case n.Name == "range" && len(args) == 1:
+		return fmt.Sprintf("range(0, %s)", args[0])
+	case n.Name == "range" && len(args) == 2:
+		return fmt.Sprintf("range(%s, %s - %s)", args[0], args[1], args[0])
+	case n.Name == "one" && len(args) == 1:
+		return fmt.Sprintf("(empty(%s) ? null : first(%s))", args[0], args[0])
+	case n.Name == "format" && len(args) > 0:
+		if layout, ok := literalString(n.Args[0]); ok {
+			if converted, ok := bicepFormat(layout); ok {
+				return fmt.Sprintf("format(%s)", strings.Join(append([]string{bicepQuote(converted)}, args[1:]...), ", "))
+			}
+		}
+	}
+	c.pending = append(c.pending, fmt.Sprintf("no Bicep equivalent for %s()", n.Name))
+	return "null"
+}
+
+// bicepFormat converts a format() string with %s, %d and %v verbs to {0},
+// {1}, ...
+func bicepFormat(layout string) (string, bool) {
+	var b strings.Builder
+	next := 0
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			// Literal braces are doubled in Bicep format strings
+			if layout[i] == '{' || layout[i] == '}' {
+				b.WriteByte(layout[i])
+			}
+			b.WriteByte(layout[i])
+			continue
+		}
+		if i+1 >= len(layout) {
+			return "", false
+		}
+		i++
+		switch layout[i] {
+		case '%':
+			b.WriteByte('%')
+		case 's', 'd', 'v':
+			b.WriteString(fmt.Sprintf("{%d}", next))
+			next++
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
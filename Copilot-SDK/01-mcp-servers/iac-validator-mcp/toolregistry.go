@@ -0,0 +1,676 @@
+// =============================================================================
+// Tool Registry
+// =============================================================================
+// Every tool the server exposes is declared once, as a Tool that carries its
+// handler: the built-in tools in builtinTools (main.go) and the command-backed
+// tools of an optional tools file (--tools-file). tools/list and tools/call
+// both read from the registry, so a definition can't drift from its handler.
+//
+// The tools file is YAML, or JSON (which is valid YAML):
+//
+//   tools:
+//     - name: tfsec
+//       description: Run tfsec security checks on a Terraform directory
+//       inputSchema:
+//         type: object
+//         properties:
+//           path: {type: string, description: Terraform directory to scan}
+//         required: [path]
+//       paths: [path]
+//       command: [tfsec, "{{.path}}", --format, sarif, --no-color]
+//       output: sarif
+//
+// Each command argument is a text/template over the tool arguments, and
+// arguments that render empty are dropped. Commands run directly, never
+// through a shell. Arguments listed in paths are resolved within the
+// workspace roots first. The output parser turns the command output into the
+// tool result (see commandOutputFormats).
+//
+// The file is polled for changes. After a successful reload the server sends
+// notifications/tools/list_changed; a broken file keeps the previous tools.
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// toolsFilePollInterval is how often the tools file is checked for changes
+const toolsFilePollInterval = 2 * time.Second
+
+// toolNamePattern is the set of tool names clients accept
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// commandOutputFormats describes the output parsers of command-backed tools
+var commandOutputFormats = map[string]string{
+	"text":               "Command output as text (default)",
+	"lines":              "One item per non-empty output line",
+	"json":               "stdout parsed as JSON and returned as structuredContent",
+	"terraform-validate": "Diagnostics from `terraform validate -json` output",
+	"tflint":             "Diagnostics from `tflint --format=json` output",
+	"sarif":              "Diagnostics from a SARIF log on stdout",
+	"bicep":              "Diagnostics from Bicep CLI output",
+	"regex":              "Diagnostics from output lines matching pattern",
+}
+
+// regexGroups are the named groups a regex output pattern may use
+var regexGroups = map[string]bool{
+	"file": true, "line": true, "column": true, "severity": true, "code": true, "message": true,
+}
+
+// linesOutputSchema is the JSON Schema of the lines output format
+var linesOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"exitCode": map[string]interface{}{"type": "integer"},
+		"lines":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	},
+	"required": []string{"exitCode", "lines"},
+}
+
+// toolsFile is the document read from Config.ToolsFile
+type toolsFile struct {
+	Tools []commandToolSpec `json:"tools"`
+}
+
+// commandToolSpec declares a tool backed by an external command
+type commandToolSpec struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	InputSchema  InputSchema            `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+
+	// Command is the program and its arguments, each a text/template
+	Command []string `json:"command"`
+	// Dir is the working directory template; defaults to the directory of
+	// the first path argument, or the first workspace root
+	Dir string `json:"dir,omitempty"`
+	// Paths names the arguments that are workspace paths
+	Paths []string `json:"paths,omitempty"`
+	// Output selects the output parser, one of commandOutputFormats
+	Output string `json:"output,omitempty"`
+	// Pattern is the regular expression of the regex output format
+	Pattern string `json:"pattern,omitempty"`
+	// Severity is used for regex matches without a severity group
+	Severity string `json:"severity,omitempty"`
+}
+
+// commandTool is a parsed commandToolSpec, ready to run
+type commandTool struct {
+	spec    commandToolSpec
+	command []*template.Template
+	dir     *template.Template
+	pattern *regexp.Regexp
+}
+
+// toolRegistry holds the tools exposed through tools/list and tools/call
+type toolRegistry struct {
+	mu       sync.RWMutex
+	builtin  []Tool
+	external []Tool
+
+	done    chan struct{}
+	stopped sync.Once
+}
+
+// newToolRegistry creates an empty registry
+func newToolRegistry() *toolRegistry {
+	return &toolRegistry{done: make(chan struct{})}
+}
+
+// setBuiltin registers the tools implemented by the server
+func (r *toolRegistry) setBuiltin(tools []Tool) {
+	r.mu.Lock()
+	r.builtin = tools
+	r.mu.Unlock()
+}
+
+// setExternal replaces the tools loaded from the tools file. Tools that
+// clash with a built-in tool are rejected.
+func (r *toolRegistry) setExternal(tools []Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tool := range tools {
+		for _, builtin := range r.builtin {
+			if builtin.Name == tool.Name {
+				return fmt.Errorf("tool %s is already a built-in tool", tool.Name)
+			}
+		}
+	}
+	r.external = tools
+	return nil
+}
+
+// list returns every registered tool, built-in tools first
+func (r *toolRegistry) list() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(r.builtin)+len(r.external))
+	tools = append(tools, r.builtin...)
+	return append(tools, r.external...)
+}
+
+// lookup returns the handler of the named tool
+func (r *toolRegistry) lookup(name string) (ToolHandler, bool) {
+	for _, tool := range r.list() {
+		if tool.Name == name {
+			return tool.Handler, true
+		}
+	}
+	return nil, false
+}
+
+// stop ends the tools file polling loop
+func (r *toolRegistry) stop() {
+	r.stopped.Do(func() { close(r.done) })
+}
+
+// =============================================================================
+// Tools File
+// =============================================================================
+
+// watchToolsFile loads Config.ToolsFile and starts polling it for changes
+func (s *MCPServer) watchToolsFile() {
+	path := s.config.ToolsFile
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	if err := s.reloadTools(); err != nil {
+		debugLog("Failed to load tools file: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(toolsFilePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.tools.done:
+				return
+			case <-ticker.C:
+			}
+
+			var current time.Time
+			if info, err := os.Stat(path); err == nil {
+				current = info.ModTime()
+			}
+			if current.Equal(modTime) {
+				continue
+			}
+			modTime = current
+
+			if err := s.reloadTools(); err != nil {
+				debugLog("Failed to reload tools file: %v", err)
+				s.sendLog("warning", "tools", fmt.Sprintf("Keeping the previous tools: %v", err))
+				continue
+			}
+			debugLog("Tools file reloaded: %s", path)
+			s.sendNotification("notifications/tools/list_changed", nil)
+		}
+	}()
+}
+
+// reloadTools replaces the command-backed tools with those in the tools file
+func (s *MCPServer) reloadTools() error {
+	specs, err := loadToolsFile(s.config.ToolsFile)
+	if err != nil {
+		return err
+	}
+
+	tools := make([]Tool, 0, len(specs))
+	for _, spec := range specs {
+		tool, err := newCommandTool(spec)
+		if err != nil {
+			return err
+		}
+		tools = append(tools, Tool{
+			Name:         spec.Name,
+			Description:  spec.Description,
+			InputSchema:  spec.InputSchema,
+			OutputSchema: tool.outputSchema(),
+			Handler: func(ctx context.Context, args map[string]interface{}) (*ToolCallResult, error) {
+				return s.runCommandTool(ctx, tool, args)
+			},
+		})
+	}
+	return s.tools.setExternal(tools)
+}
+
+// loadToolsFile reads and checks the tool declarations in path
+func loadToolsFile(path string) ([]commandToolSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tools file: %w", err)
+	}
+
+	// Decode the YAML generically, then strictly through the JSON tags so
+	// both formats share one schema and misspelled keys are reported
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid tools file %s: %w", path, err)
+	}
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tools file %s: %w", path, err)
+	}
+	var file toolsFile
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid tools file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, spec := range file.Tools {
+		if _, err := newCommandTool(spec); err != nil {
+			return nil, fmt.Errorf("invalid tools file %s: %w", path, err)
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("invalid tools file %s: tool %s is declared twice", path, spec.Name)
+		}
+		seen[spec.Name] = true
+	}
+	return file.Tools, nil
+}
+
+// newCommandTool checks spec and parses its templates and pattern
+func newCommandTool(spec commandToolSpec) (*commandTool, error) {
+	if !toolNamePattern.MatchString(spec.Name) {
+		return nil, fmt.Errorf("invalid tool name %q (use letters, digits, '_' and '-')", spec.Name)
+	}
+	if spec.Description == "" {
+		return nil, fmt.Errorf("tool %s: description is required", spec.Name)
+	}
+	if len(spec.Command) == 0 {
+		return nil, fmt.Errorf("tool %s: command is required", spec.Name)
+	}
+	if spec.InputSchema.Type == "" {
+		spec.InputSchema.Type = "object"
+	}
+	if spec.InputSchema.Type != "object" {
+		return nil, fmt.Errorf("tool %s: inputSchema type must be object", spec.Name)
+	}
+	if spec.Output == "" {
+		spec.Output = "text"
+	}
+	if _, ok := commandOutputFormats[spec.Output]; !ok {
+		return nil, fmt.Errorf("tool %s: unknown output %q (use %s)", spec.Name, spec.Output, strings.Join(outputFormatNames(), ", "))
+	}
+	for _, name := range append(append([]string{}, spec.Paths...), spec.InputSchema.Required...) {
+		if _, ok := spec.InputSchema.Properties[name]; !ok {
+			return nil, fmt.Errorf("tool %s: %s is not an inputSchema property", spec.Name, name)
+		}
+	}
+
+	tool := &commandTool{spec: spec}
+	for i, arg := range spec.Command {
+		tmpl, err := template.New(fmt.Sprintf("%s.command[%d]", spec.Name, i)).Option("missingkey=zero").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", spec.Name, err)
+		}
+		tool.command = append(tool.command, tmpl)
+	}
+	if spec.Dir != "" {
+		tmpl, err := template.New(spec.Name + ".dir").Option("missingkey=zero").Parse(spec.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", spec.Name, err)
+		}
+		tool.dir = tmpl
+	}
+
+	switch {
+	case spec.Output == "regex" && spec.Pattern == "":
+		return nil, fmt.Errorf("tool %s: pattern is required for regex output", spec.Name)
+	case spec.Output != "regex" && (spec.Pattern != "" || spec.Severity != ""):
+		return nil, fmt.Errorf("tool %s: pattern and severity are only used by regex output", spec.Name)
+	case spec.Severity != "" && spec.Severity != severityError && spec.Severity != severityWarning && spec.Severity != severityInfo:
+		return nil, fmt.Errorf("tool %s: unknown severity %q (use error, warning or info)", spec.Name, spec.Severity)
+	case spec.Pattern != "":
+		pattern, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: invalid pattern: %w", spec.Name, err)
+		}
+		for _, group := range pattern.SubexpNames()[1:] {
+			if group != "" && !regexGroups[group] {
+				return nil, fmt.Errorf("tool %s: unknown pattern group %q", spec.Name, group)
+			}
+		}
+		if pattern.SubexpIndex("message") < 0 {
+			return nil, fmt.Errorf("tool %s: pattern needs a (?P<message>...) group", spec.Name)
+		}
+		tool.pattern = pattern
+	}
+	return tool, nil
+}
+
+// outputFormatNames returns the output formats in alphabetical order
+func outputFormatNames() []string {
+	names := make([]string, 0, len(commandOutputFormats))
+	for name := range commandOutputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputSchema returns the outputSchema advertised for the tool
+func (t *commandTool) outputSchema() map[string]interface{} {
+	switch t.spec.Output {
+	case "text":
+		return nil
+	case "lines":
+		return linesOutputSchema
+	case "json":
+		return t.spec.OutputSchema
+	}
+	return diagnosticsOutputSchema
+}
+
+// =============================================================================
+// Command Execution
+// =============================================================================
+
+// runCommandTool renders the command of tool for args, runs it and parses
+// its output
+func (s *MCPServer) runCommandTool(ctx context.Context, tool *commandTool, args map[string]interface{}) (*ToolCallResult, error) {
+	spec := tool.spec
+	for _, name := range spec.InputSchema.Required {
+		if _, ok := args[name]; !ok {
+			return nil, fmt.Errorf("%s parameter is required", name)
+		}
+	}
+
+	// Unset properties render empty instead of "<no value>"
+	data := make(map[string]interface{})
+	for name := range spec.InputSchema.Properties {
+		data[name] = ""
+	}
+	for name, value := range args {
+		data[name] = value
+	}
+
+	dir := ""
+	for _, name := range spec.Paths {
+		value, ok := args[name]
+		if !ok {
+			continue
+		}
+		path, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s parameter must be a string", name)
+		}
+		absPath, err := s.resolvePath(path)
+		if err != nil {
+			return nil, err
+		}
+		data[name] = absPath
+		if dir == "" {
+			dir = absPath
+			if info, err := os.Stat(absPath); err == nil && !info.IsDir() {
+				dir = filepath.Dir(absPath)
+			}
+		}
+	}
+	if tool.dir != nil {
+		rendered, err := renderTemplate(tool.dir, data)
+		if err != nil {
+			return nil, err
+		}
+		if dir, err = s.resolvePath(rendered); err != nil {
+			return nil, err
+		}
+	}
+	if dir == "" {
+		dir = s.roots()[0]
+	}
+
+	var argv []string
+	for _, tmpl := range tool.command {
+		arg, err := renderTemplate(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		if arg != "" {
+			argv = append(argv, arg)
+		}
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("command of %s rendered empty", spec.Name)
+	}
+	if _, err := exec.LookPath(argv[0]); err != nil {
+		return nil, fmt.Errorf("%s not found: install it or fix the command of %s", argv[0], spec.Name)
+	}
+
+	reporterFrom(ctx).progress(1, 1, fmt.Sprintf("Running %s", argv[0]))
+	cmd := commandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+
+	var out []byte
+	var runErr error
+	switch spec.Output {
+	case "json", "terraform-validate", "tflint", "sarif":
+		// Machine-readable output is read from stdout only
+		stderr := &lineLogger{reporter: reporterFrom(ctx), logger: argv[0]}
+		cmd.Stderr = stderr
+		out, runErr = cmd.Output()
+		stderr.flush()
+		if runErr != nil && stderr.output.Len() > 0 && len(bytes.TrimSpace(out)) == 0 {
+			out = stderr.output.Bytes()
+		}
+	default:
+		out, runErr = runCommand(ctx, cmd)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if runErr != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", argv[0], runErr)
+	}
+
+	return tool.parseOutput(strings.Join(argv, " "), dir, out, exitCode), nil
+}
+
+// renderTemplate executes tmpl with the tool arguments
+func renderTemplate(tmpl *template.Template, data map[string]interface{}) (string, error) {
+	var text strings.Builder
+	if err := tmpl.Execute(&text, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// =============================================================================
+// Output Parsers
+// =============================================================================
+
+// parseOutput turns the output of the command line run in dir into a result
+func (t *commandTool) parseOutput(command, dir string, out []byte, exitCode int) *ToolCallResult {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🔧 Ran: `%s`\n\n", command))
+
+	failed := func() *ToolCallResult {
+		output.WriteString(fmt.Sprintf("❌ **Exit status %d**\n\n", exitCode))
+		writeCommandOutput(&output, "", out)
+		return &ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: output.String()}},
+			IsError: true,
+		}
+	}
+
+	var diags []Diagnostic
+	switch t.spec.Output {
+	case "text":
+		if exitCode != 0 {
+			return failed()
+		}
+		writeCommandOutput(&output, "", out)
+		return &ToolCallResult{Content: []ContentBlock{{Type: "text", Text: output.String()}}}
+
+	case "lines":
+		lines := []string{}
+		for _, line := range strings.Split(string(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if exitCode != 0 {
+			output.WriteString(fmt.Sprintf("❌ **Exit status %d**\n\n", exitCode))
+		}
+		output.WriteString(fmt.Sprintf("📋 **%d line(s)**\n\n", len(lines)))
+		for _, line := range lines {
+			output.WriteString(fmt.Sprintf("- %s\n", line))
+		}
+		return &ToolCallResult{
+			Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+			StructuredContent: map[string]interface{}{"exitCode": exitCode, "lines": lines},
+			IsError:           exitCode != 0,
+		}
+
+	case "json":
+		var value interface{}
+		if err := json.Unmarshal(out, &value); err != nil {
+			return failed()
+		}
+		// structuredContent must be an object
+		if _, ok := value.(map[string]interface{}); !ok {
+			value = map[string]interface{}{"result": value}
+		}
+		if exitCode != 0 {
+			output.WriteString(fmt.Sprintf("❌ **Exit status %d**\n\n", exitCode))
+		}
+		pretty, _ := json.MarshalIndent(value, "", "  ")
+		writeCommandOutput(&output, "json", pretty)
+		return &ToolCallResult{
+			Content:           []ContentBlock{{Type: "text", Text: output.String()}},
+			StructuredContent: value,
+			IsError:           exitCode != 0,
+		}
+
+	case "terraform-validate":
+		parsed, err := parseTerraformValidateJSON(out)
+		if err != nil {
+			return failed()
+		}
+		diags = parsed
+	case "tflint":
+		parsed, err := parseTFLintJSON(out)
+		if err != nil {
+			return failed()
+		}
+		diags = parsed
+	case "sarif":
+		parsed, err := parseSARIF(out, dir)
+		if err != nil {
+			return failed()
+		}
+		diags = parsed
+	case "bicep":
+		diags = parseBicepOutput(string(out), dir)
+	case "regex":
+		diags = t.parseRegex(out, dir)
+	}
+
+	// Linters usually exit non-zero when they report issues, and search
+	// tools when they find nothing, so a failure only counts when nothing
+	// could be parsed from the output it printed
+	if len(diags) == 0 && exitCode != 0 && len(bytes.TrimSpace(out)) > 0 {
+		diags = []Diagnostic{{
+			Severity: severityError,
+			Code:     t.spec.Name,
+			Summary:  fmt.Sprintf("%s failed with exit status %d", t.spec.Name, exitCode),
+			Detail:   strings.TrimSpace(string(out)),
+		}}
+	}
+
+	report := newDiagnosticsReport(t.spec.Name, diags)
+	switch {
+	case !report.Valid:
+		output.WriteString(fmt.Sprintf("❌ **%d error(s), %d warning(s)**\n\n", report.ErrorCount, report.WarningCount))
+	case report.WarningCount > 0:
+		output.WriteString(fmt.Sprintf("⚠️ **%d warning(s)**\n\n", report.WarningCount))
+	default:
+		output.WriteString("✅ **No issues found!**\n\n")
+	}
+	writeDiagnostics(&output, diags)
+	return diagnosticsResult(output.String(), report)
+}
+
+// parseRegex converts output lines matching the tool pattern. Lines without
+// a severity group get the tool severity, or error.
+func (t *commandTool) parseRegex(out []byte, dir string) []Diagnostic {
+	group := func(match []string, name string) string {
+		if i := t.pattern.SubexpIndex(name); i >= 0 {
+			return strings.TrimSpace(match[i])
+		}
+		return ""
+	}
+
+	var diags []Diagnostic
+	for _, line := range strings.Split(string(out), "\n") {
+		match := t.pattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+
+		diag := Diagnostic{
+			Severity: t.spec.Severity,
+			Code:     group(match, "code"),
+			File:     group(match, "file"),
+			Summary:  group(match, "message"),
+		}
+		if severity := group(match, "severity"); severity != "" {
+			diag.Severity = lintSeverity(severity)
+		} else if diag.Severity == "" {
+			diag.Severity = severityError
+		}
+		if diag.File != "" && filepath.IsAbs(diag.File) {
+			if rel, err := filepath.Rel(dir, diag.File); err == nil {
+				diag.File = rel
+			}
+		}
+		if lineNo, err := strconv.Atoi(group(match, "line")); err == nil {
+			column, _ := strconv.Atoi(group(match, "column"))
+			if column == 0 {
+				column = 1
+			}
+			diag.Start = &Position{Line: lineNo, Column: column}
+		}
+		diags = append(diags, diag)
+	}
+	return diags
+}
+
+// writeCommandOutput writes out as a fenced code block
+func writeCommandOutput(output *strings.Builder, lang string, out []byte) {
+	text := strings.TrimRight(string(out), "\n")
+	if strings.TrimSpace(text) == "" {
+		output.WriteString("_(no output)_\n")
+		return
+	}
+	output.WriteString(fmt.Sprintf("```%s\n%s\n```\n", lang, text))
+}
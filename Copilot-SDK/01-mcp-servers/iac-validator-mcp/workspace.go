@@ -0,0 +1,322 @@
+// =============================================================================
+// Syntax Check Workspaces
+// =============================================================================
+// check_iac_syntax validates snippets in a temporary workspace. Snippets often
+// reference variables, locals or modules defined in sibling files, so the
+// workspace can be seeded from a real directory (base_path) with the snippet
+// files overlaid on top.
+//
+// The base directory is copied to the same position relative to its workspace
+// root, so relative module sources such as "../modules/networking" resolve
+// inside the temp tree. Local Terraform modules and Bicep module files are
+// then copied in, following references recursively.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// workspaceCopyExtensions lists the file types copied from base_path and
+// module directories. .json covers *.tf.json and bicepconfig.json; .hcl
+// covers .terraform.lock.hcl.
+var workspaceCopyExtensions = map[string]bool{
+	".tf":         true,
+	".tfvars":     true,
+	".bicep":      true,
+	".bicepparam": true,
+	".json":       true,
+	".hcl":        true,
+}
+
+// bicepModulePattern matches local Bicep module references such as
+// `module vnet './modules/vnet.bicep' = {`
+var bicepModulePattern = regexp.MustCompile(`(?m)^\s*module\s+\w+\s+'([^':]+)'`)
+
+// syntaxWorkspace is a temporary directory holding the code to check
+type syntaxWorkspace struct {
+	root  string       // temp directory, removed by cleanup
+	dir   string       // directory the snippet files are written to
+	files []string     // snippet files, relative to dir
+	diags []Diagnostic // modules that could not be copied
+}
+
+// cleanup removes the workspace
+func (w *syntaxWorkspace) cleanup() {
+	os.RemoveAll(w.root)
+}
+
+// newSyntaxWorkspace creates a workspace containing files (name → content),
+// overlaid on a copy of basePath when it is set
+func (s *MCPServer) newSyntaxWorkspace(codeType string, files map[string]string, basePath string) (*syntaxWorkspace, error) {
+	root, err := os.MkdirTemp("", "iac-syntax-check-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	ws := &syntaxWorkspace{root: root, dir: root}
+
+	// sourceRoot is the real directory mirrored by ws.root
+	var sourceRoot string
+	if basePath != "" {
+		base, err := s.resolvePath(basePath)
+		if err != nil {
+			ws.cleanup()
+			return nil, err
+		}
+		if info, err := os.Stat(base); err != nil || !info.IsDir() {
+			ws.cleanup()
+			return nil, fmt.Errorf("base_path must be a directory: %s", base)
+		}
+
+		sourceRoot = base
+		for _, r := range s.roots() {
+			if withinRoots(base, []string{r}) {
+				sourceRoot = r
+				break
+			}
+		}
+		rel, _ := filepath.Rel(sourceRoot, base)
+		ws.dir = filepath.Join(root, rel)
+		if err := copyWorkspaceFiles(base, ws.dir); err != nil {
+			ws.cleanup()
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := filepath.Join(ws.dir, filepath.FromSlash(name))
+		if filepath.IsAbs(name) || !withinRoots(target, []string{ws.dir}) || target == ws.dir {
+			ws.cleanup()
+			return nil, fmt.Errorf("invalid file name: %s (must be relative and stay inside the workspace)", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			ws.cleanup()
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, []byte(files[name]), 0644); err != nil {
+			ws.cleanup()
+			return nil, fmt.Errorf("failed to write temp file: %w", err)
+		}
+		ws.files = append(ws.files, filepath.Clean(filepath.FromSlash(name)))
+	}
+
+	if sourceRoot != "" {
+		visited := make(map[string]bool)
+		if codeType == "bicep" {
+			for _, name := range ws.files {
+				s.copyBicepModules(ws, sourceRoot, filepath.Join(ws.dir, name), visited)
+			}
+		} else {
+			s.copyTerraformModules(ws, sourceRoot, ws.dir, visited)
+		}
+	}
+	return ws, nil
+}
+
+// copyTerraformModules copies the local modules called from the Terraform
+// configuration in dir into the workspace, recursively
+func (s *MCPServer) copyTerraformModules(ws *syntaxWorkspace, sourceRoot, dir string, visited map[string]bool) {
+	if visited[dir] {
+		return
+	}
+	visited[dir] = true
+
+	for _, source := range terraformModuleSources(dir) {
+		target := filepath.Join(dir, filepath.FromSlash(source))
+		if _, err := os.Stat(target); err != nil {
+			if !s.copyFromSource(ws, sourceRoot, target, true, source) {
+				continue
+			}
+		}
+		s.copyTerraformModules(ws, sourceRoot, target, visited)
+	}
+}
+
+// copyBicepModules copies the local Bicep modules referenced by file into the
+// workspace, recursively
+func (s *MCPServer) copyBicepModules(ws *syntaxWorkspace, sourceRoot, file string, visited map[string]bool) {
+	if visited[file] {
+		return
+	}
+	visited[file] = true
+
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	for _, match := range bicepModulePattern.FindAllStringSubmatch(string(src), -1) {
+		target := filepath.Join(filepath.Dir(file), filepath.FromSlash(match[1]))
+		if _, err := os.Stat(target); err != nil {
+			if !s.copyFromSource(ws, sourceRoot, target, false, match[1]) {
+				continue
+			}
+		}
+		s.copyBicepModules(ws, sourceRoot, target, visited)
+	}
+}
+
+// copyFromSource copies the real counterpart of target, a path inside the
+// workspace, from below sourceRoot. It records a warning and returns false if
+// that isn't possible.
+func (s *MCPServer) copyFromSource(ws *syntaxWorkspace, sourceRoot, target string, isDir bool, reference string) bool {
+	warn := func(detail string) bool {
+		ws.diags = append(ws.diags, Diagnostic{
+			Severity: severityWarning,
+			Code:     "module",
+			Summary:  fmt.Sprintf("Module %q was not copied into the check workspace", reference),
+			Detail:   detail,
+		})
+		return false
+	}
+
+	rel, err := filepath.Rel(ws.root, target)
+	if err != nil || !withinRoots(target, []string{ws.root}) {
+		return warn("It lies outside the workspace root.")
+	}
+	real, err := s.resolvePath(filepath.Join(sourceRoot, rel))
+	if err != nil {
+		return warn(err.Error())
+	}
+	info, err := os.Stat(real)
+	if err != nil || info.IsDir() != isDir {
+		return warn("Not found: " + real)
+	}
+
+	if isDir {
+		err = copyWorkspaceFiles(real, target)
+	} else {
+		err = copyFile(real, target)
+	}
+	if err != nil {
+		return warn(err.Error())
+	}
+	return true
+}
+
+// copyWorkspaceFiles copies the IaC files directly inside src to dest
+func copyWorkspaceFiles(src, dest string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !workspaceCopyExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, name), filepath.Join(dest, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a single file, creating parent directories
+func copyFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+// moduleSourceSchema extracts module blocks from a Terraform file
+var moduleSourceSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+}
+
+// moduleBodySchema extracts the source argument of a module block
+var moduleBodySchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "source"}},
+}
+
+// terraformModuleSources returns the local ("./" or "../") module sources
+// used by the Terraform configuration in dir. Files that don't parse are
+// skipped; the syntax check reports them.
+func terraformModuleSources(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	parser := hclparse.NewParser()
+	var sources []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tf") {
+			continue
+		}
+		file, diags := parser.ParseHCLFile(filepath.Join(dir, entry.Name()))
+		if diags.HasErrors() {
+			continue
+		}
+		content, _, _ := file.Body.PartialContent(moduleSourceSchema)
+		for _, block := range content.Blocks {
+			source := moduleSource(block)
+			if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// moduleSource returns the literal source of a module block, or ""
+func moduleSource(block *hcl.Block) string {
+	body, _, _ := block.Body.PartialContent(moduleBodySchema)
+	attr, ok := body.Attributes["source"]
+	if !ok {
+		return ""
+	}
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !value.Type().Equals(cty.String) || value.IsNull() {
+		return ""
+	}
+	return value.AsString()
+}
+
+// checkWorkspaceHCL runs the in-process HCL checks on every directory that
+// contains a snippet file. File names are reported relative to ws.dir.
+func checkWorkspaceHCL(ws *syntaxWorkspace) ([]Diagnostic, error) {
+	seen := make(map[string]bool)
+	var diags []Diagnostic
+	for _, name := range ws.files {
+		rel := filepath.Dir(name)
+		if seen[rel] || !strings.HasSuffix(name, ".tf") {
+			continue
+		}
+		seen[rel] = true
+
+		hclDiags, _, err := checkTerraformDir(filepath.Join(ws.dir, rel))
+		if err != nil {
+			return nil, err
+		}
+		for _, diag := range fromHCLDiagnostics(hclDiags) {
+			if diag.File != "" && rel != "." {
+				diag.File = filepath.ToSlash(filepath.Join(rel, diag.File))
+			}
+			diags = append(diags, diag)
+		}
+	}
+	return diags, nil
+}
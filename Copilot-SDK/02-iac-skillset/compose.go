@@ -0,0 +1,169 @@
+// =============================================================================
+// Composite Generation
+// =============================================================================
+// Turns one description into one configuration. Every pattern whose
+// keywords appear is included ("AKS cluster with ACR, Key Vault and a VNet"
+// yields four), after the base pattern that declares the shared resource
+// group, location and tags. Each section (variables, resources, outputs) is
+// rendered for every pattern and the results are concatenated, so the
+// configuration has a single variables and outputs set.
+//
+// Patterns reference each other through TemplateData.Has: the AKS template
+// puts the cluster in the VNet's subnet when the VNet is present, the ACR
+// template grants AcrPull to the kubelet identity when AKS is present, and
+// so on.
+//
+// Parameters:
+//   - location and tags are shared by all patterns
+//   - name is the resource name when there is one component; with several it
+//     is a workload name and each pattern applies its name_format
+//   - an object keyed by pattern name overrides parameters for that pattern,
+//     e.g. {"aks": {"sku": "Standard_D4s_v5", "node_count": 5}}
+// =============================================================================
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errInvalidParameters marks errors caused by the request's parameters
+var errInvalidParameters = errors.New("invalid parameters")
+
+// Generation is a configuration composed from one or more patterns
+type Generation struct {
+	Language   string
+	Title      string
+	Components []string
+	Sections   []Section
+	Notes      []string
+}
+
+// Section is the code of one section across all patterns
+type Section struct {
+	Name    string
+	Content string
+}
+
+// Generate composes the patterns requested by description into one
+// configuration for lang
+func (l *TemplateLibrary) Generate(description, lang string, params map[string]interface{}) (*Generation, error) {
+	components := l.Compose(description, lang)
+	if len(components) == 0 {
+		return nil, fmt.Errorf("no %s template matches the description", lang)
+	}
+
+	var primary []*Template
+	present := make(map[string]bool)
+	for _, tmpl := range components {
+		present[tmpl.Name] = true
+		if !tmpl.Base {
+			primary = append(primary, tmpl)
+		}
+	}
+	if len(primary) == 0 {
+		primary = components
+	}
+
+	gen := &Generation{Language: lang}
+	var titles []string
+	for _, tmpl := range primary {
+		titles = append(titles, tmpl.Title)
+		if note := tmpl.Notes[lang]; note != "" {
+			gen.Notes = append(gen.Notes, note)
+		}
+	}
+	gen.Title = strings.Join(titles, " + ")
+
+	// Parameters shared by every pattern; objects keyed by a pattern name
+	// are that pattern's overrides
+	shared := make(map[string]interface{})
+	for key, value := range params {
+		if _, override := value.(map[string]interface{}); override && present[key] {
+			continue
+		}
+		shared[key] = value
+	}
+	if _, ok := shared["sku"]; ok && len(primary) > 1 {
+		delete(shared, "sku")
+		example := primary[0].Name
+		for _, tmpl := range primary {
+			if _, ok := tmpl.Defaults["sku"]; ok {
+				example = tmpl.Name
+				break
+			}
+		}
+		gen.Notes = append(gen.Notes, fmt.Sprintf(
+			"The sku parameter was ignored because it is ambiguous across %d components; set it per component instead, e.g. \"parameters\": {\"%s\": {\"sku\": \"...\"}}.",
+			len(primary), example))
+	}
+
+	data := make([]TemplateData, len(components))
+	for i, tmpl := range components {
+		layered := make(map[string]interface{})
+		for key, value := range shared {
+			layered[key] = value
+		}
+		if name, ok := shared["name"].(string); ok && (len(primary) > 1 || primary[0] != tmpl) {
+			layered["name"] = tmpl.resourceName(name)
+		}
+		if override, ok := params[tmpl.Name].(map[string]interface{}); ok {
+			for key, value := range override {
+				layered[key] = value
+			}
+		}
+
+		d, err := newTemplateData(description, lang, tmpl.Defaults, layered)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errInvalidParameters, tmpl.Name, err)
+		}
+		d.Components = present
+		data[i] = d
+		gen.Components = append(gen.Components, tmpl.Name)
+	}
+
+	for _, section := range templateSections[lang] {
+		var parts []string
+		for i, tmpl := range components {
+			part, err := tmpl.Render(lang, section, data[i])
+			if err != nil {
+				return nil, err
+			}
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) > 0 {
+			gen.Sections = append(gen.Sections, Section{Name: section, Content: strings.Join(parts, "\n\n")})
+		}
+	}
+	return gen, nil
+}
+
+// Code renders the whole configuration as a single file
+func (g *Generation) Code(description string) string {
+	comment := "#"
+	if g.Language == "bicep" {
+		comment = "//"
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s %s\n", comment, g.Title))
+	out.WriteString(fmt.Sprintf("%s Generated by IaC Helper Skillset\n", comment))
+	out.WriteString(fmt.Sprintf("%s Description: %s\n", comment, oneLine(description)))
+	for _, section := range g.Sections {
+		out.WriteString("\n")
+		out.WriteString(sectionBanner(comment, section.Name))
+		out.WriteString(section.Content)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// sectionBanner is the comment block that opens a section, as in the labs
+func sectionBanner(comment, name string) string {
+	rule := comment + " " + strings.Repeat("-", 77)
+	return fmt.Sprintf("%s\n%s %s\n%s\n", rule, comment, strings.ToUpper(name[:1])+name[1:], rule)
+}
@@ -0,0 +1,338 @@
+// =============================================================================
+// Resource Knowledge Base
+// =============================================================================
+// /explain answers from a knowledge base on disk (KNOWLEDGE_DIR, default
+// ./knowledge). Each JSON file describes one Azure resource under both of
+// its names, so azurerm_key_vault and Microsoft.KeyVault/vaults share an
+// entry:
+//
+//   knowledge/key-vault.json
+//     terraform, bicep     Resource type names (either may be empty)
+//     summary              What the resource creates
+//     best_practices       Bulleted guidance
+//     examples             Per-language example code
+//     documentation        Per-language documentation URLs
+//     related              Per-language related resource types
+//     properties           Per-property description, allowed values,
+//                          default and security notes
+//
+// Property names are matched by their Terraform or Bicep path, either in full
+// (default_node_pool.vm_size, properties.minimumTlsVersion) or by the last
+// segment (vm_size, minimumTlsVersion), and by aliases such as the names
+// azurerm used before 4.0 (enable_rbac, enable_https_traffic_only).
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KnowledgeEntry describes one Azure resource
+type KnowledgeEntry struct {
+	Name          string              `json:"name"`
+	Terraform     string              `json:"terraform,omitempty"`
+	Bicep         string              `json:"bicep,omitempty"`
+	Summary       string              `json:"summary"`
+	BestPractices []string            `json:"best_practices,omitempty"`
+	Examples      map[string]string   `json:"examples,omitempty"`
+	Documentation map[string]string   `json:"documentation,omitempty"`
+	Related       map[string][]string `json:"related,omitempty"`
+	Properties    []PropertyDoc       `json:"properties,omitempty"`
+}
+
+// PropertyDoc describes one property of a resource
+type PropertyDoc struct {
+	Terraform     string            `json:"terraform,omitempty"`
+	Bicep         string            `json:"bicep,omitempty"`
+	Aliases       []string          `json:"aliases,omitempty"` // former or alternative names
+	Description   string            `json:"description"`
+	Required      bool              `json:"required,omitempty"`
+	AllowedValues []string          `json:"allowed_values,omitempty"`
+	Default       string            `json:"default,omitempty"`
+	Security      string            `json:"security,omitempty"`
+	Examples      map[string]string `json:"examples,omitempty"`
+}
+
+// KnowledgeBase is the set of entries loaded from disk
+type KnowledgeBase struct {
+	Dir     string
+	Entries []*KnowledgeEntry
+}
+
+// loadKnowledgeBase reads every *.json file in dir
+func loadKnowledgeBase(dir string) (*KnowledgeBase, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no knowledge base entries found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	kb := &KnowledgeBase{Dir: dir}
+	seen := make(map[string]string)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		entry := &KnowledgeEntry{}
+		if err := decoder.Decode(entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if entry.Terraform == "" && entry.Bicep == "" {
+			return nil, fmt.Errorf("%s: terraform or bicep resource type is required", filepath.Base(path))
+		}
+		for _, name := range []string{entry.Terraform, entry.Bicep} {
+			key := strings.ToLower(name)
+			if other, ok := seen[key]; ok && name != "" {
+				return nil, fmt.Errorf("%s: %s is already described by %s", filepath.Base(path), name, other)
+			}
+			seen[key] = filepath.Base(path)
+		}
+		kb.Entries = append(kb.Entries, entry)
+	}
+	return kb, nil
+}
+
+// Lookup finds the entry for a resource type and the language it was named
+// in. API versions (Microsoft.Storage/storageAccounts@2023-01-01) are ignored.
+func (kb *KnowledgeBase) Lookup(resource string) (*KnowledgeEntry, string) {
+	resource = strings.ToLower(strings.TrimSpace(resource))
+	if i := strings.Index(resource, "@"); i >= 0 {
+		resource = resource[:i]
+	}
+	if resource == "" {
+		return nil, ""
+	}
+
+	for _, entry := range kb.Entries {
+		if strings.ToLower(entry.Terraform) == resource {
+			return entry, "terraform"
+		}
+		if strings.ToLower(entry.Bicep) == resource {
+			return entry, "bicep"
+		}
+	}
+	// Partial type names ("kubernetes_cluster", "storageAccounts"), then
+	// display names ("AKS", "key vault")
+	if entry, lang := kb.closest(resource, false); entry != nil {
+		return entry, lang
+	}
+	return kb.closest(resource, true)
+}
+
+// typeName is a name an entry can be looked up by, and its language
+type typeName struct {
+	name, lang string
+}
+
+// closest returns the entry with the shortest type name (or display name)
+// containing resource, so "key vault" finds the vault rather than the secret
+func (kb *KnowledgeBase) closest(resource string, displayName bool) (*KnowledgeEntry, string) {
+	var best *KnowledgeEntry
+	var bestLang string
+	bestLen := 0
+	for _, entry := range kb.Entries {
+		candidates := []typeName{{entry.Terraform, "terraform"}, {entry.Bicep, "bicep"}}
+		if displayName {
+			candidates = []typeName{{entry.Name, entry.language()}}
+		}
+		for _, c := range candidates {
+			if c.name == "" || !strings.Contains(strings.ToLower(c.name), resource) {
+				continue
+			}
+			if best == nil || len(c.name) < bestLen {
+				best, bestLang, bestLen = entry, c.lang, len(c.name)
+			}
+		}
+	}
+	return best, bestLang
+}
+
+// language is the language the entry is primarily named in
+func (e *KnowledgeEntry) language() string {
+	if e.Terraform == "" {
+		return "bicep"
+	}
+	return "terraform"
+}
+
+// TypeName is the resource type in lang, falling back to the other language
+func (e *KnowledgeEntry) TypeName(lang string) string {
+	if lang == "bicep" && e.Bicep != "" || e.Terraform == "" {
+		return e.Bicep
+	}
+	return e.Terraform
+}
+
+// Property finds a property by its Terraform or Bicep path or an alias, or
+// by the last segment of either path
+func (e *KnowledgeEntry) Property(name string) *PropertyDoc {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i := range e.Properties {
+		prop := &e.Properties[i]
+		if strings.ToLower(prop.Terraform) == name || strings.ToLower(prop.Bicep) == name {
+			return prop
+		}
+		for _, alias := range prop.Aliases {
+			if strings.ToLower(alias) == name {
+				return prop
+			}
+		}
+	}
+	for i := range e.Properties {
+		prop := &e.Properties[i]
+		if lastSegment(prop.Terraform) == name || lastSegment(prop.Bicep) == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// PropertyName is the property's path in lang, falling back to the other
+// language
+func (p *PropertyDoc) PropertyName(lang string) string {
+	if lang == "bicep" && p.Bicep != "" || p.Terraform == "" {
+		return p.Bicep
+	}
+	return p.Terraform
+}
+
+// nameIn is the property's path in lang, or "" when it has none
+func (p *PropertyDoc) nameIn(lang string) string {
+	if lang == "bicep" {
+		return p.Bicep
+	}
+	return p.Terraform
+}
+
+// lastSegment is the lower-cased last part of a dotted property path
+func lastSegment(path string) string {
+	path = strings.ToLower(strings.TrimSuffix(path, "[]"))
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		path = path[i+1:]
+	}
+	return strings.TrimSuffix(path, "[]")
+}
+
+// Explain describes the resource in lang
+func (e *KnowledgeEntry) Explain(lang string) ExplainResponse {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s (%s)\n\n%s", e.TypeName(lang), e.Name, e.Summary))
+	if other := e.counterpart(lang); other != "" {
+		out.WriteString(fmt.Sprintf("\n\n%s equivalent: %s", languageTitle(otherLanguage(lang)), other))
+	}
+
+	var keys []string
+	for _, prop := range e.Properties {
+		name := prop.nameIn(lang)
+		if name == "" {
+			continue
+		}
+		line := fmt.Sprintf("• %s: %s", name, prop.Description)
+		if len(prop.AllowedValues) > 0 {
+			line += " (" + strings.Join(prop.AllowedValues, ", ") + ")"
+		}
+		keys = append(keys, line)
+	}
+	if len(keys) > 0 {
+		out.WriteString("\n\nKey properties:\n" + strings.Join(keys, "\n"))
+	}
+	if len(e.BestPractices) > 0 {
+		out.WriteString("\n\nBest practices:\n• " + strings.Join(e.BestPractices, "\n• "))
+	}
+
+	response := ExplainResponse{
+		Explanation:      out.String(),
+		DocumentationURL: e.Documentation[lang],
+		RelatedResources: e.Related[lang],
+	}
+	if example := e.Examples[lang]; example != "" {
+		response.Examples = []string{example}
+	}
+	return response
+}
+
+// ExplainProperty describes one property of the resource in lang
+func (e *KnowledgeEntry) ExplainProperty(lang string, prop *PropertyDoc) ExplainResponse {
+	name := prop.PropertyName(lang)
+	required := "no"
+	if prop.Required {
+		required = "yes"
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s (%s)\n\n%s\n\nRequired: %s", name, e.TypeName(lang), prop.Description, required))
+	if len(prop.AllowedValues) > 0 {
+		out.WriteString("\nAllowed values: " + strings.Join(prop.AllowedValues, ", "))
+	}
+	if prop.Default != "" {
+		out.WriteString("\nDefault: " + prop.Default)
+	}
+	other := otherLanguage(lang)
+	if otherName := prop.nameIn(other); otherName != name && otherName != "" {
+		out.WriteString(fmt.Sprintf("\n%s equivalent: %s", languageTitle(other), otherName))
+	}
+	if prop.Security != "" {
+		out.WriteString("\n\n🔒 Security: " + prop.Security)
+	}
+
+	response := ExplainResponse{
+		Explanation:      out.String(),
+		DocumentationURL: e.Documentation[lang],
+		RelatedResources: e.Related[lang],
+		Property:         name,
+		AllowedValues:    prop.AllowedValues,
+		Default:          prop.Default,
+		SecurityNotes:    prop.Security,
+	}
+	if example := prop.Examples[lang]; example != "" {
+		response.Examples = []string{example}
+	}
+	return response
+}
+
+// counterpart is the resource type in the other language
+func (e *KnowledgeEntry) counterpart(lang string) string {
+	if lang == "bicep" {
+		return e.Terraform
+	}
+	return e.Bicep
+}
+
+// propertyNames lists the documented properties in lang
+func (e *KnowledgeEntry) propertyNames(lang string) []string {
+	var names []string
+	for _, prop := range e.Properties {
+		if name := prop.nameIn(lang); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func otherLanguage(lang string) string {
+	if lang == "bicep" {
+		return "terraform"
+	}
+	return "bicep"
+}
+
+func languageTitle(lang string) string {
+	if lang == "bicep" {
+		return "Bicep"
+	}
+	return "Terraform"
+}
@@ -0,0 +1,154 @@
+// =============================================================================
+// Module Layout
+// =============================================================================
+// Lays a generated configuration out as files. The default "file" layout is
+// a single main.tf or main.bicep. The "module" layout matches the Level-1/2
+// lab solutions, so the files drop straight into a repo:
+//
+//   Terraform: main.tf, variables.tf, outputs.tf, versions.tf
+//   Bicep:     main.bicep, main.bicepparam
+//
+// main.bicepparam is derived from the parameters section of main.bicep:
+// literal defaults are copied, required parameters get a placeholder, and
+// parameters whose default is an expression such as resourceGroup().location
+// are left to main.bicep.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Layouts accepted by /generate
+const (
+	layoutFile   = "file"
+	layoutModule = "module"
+)
+
+// mainFiles is the single file of the "file" layout
+var mainFiles = map[string]string{
+	"terraform": "main.tf",
+	"bicep":     "main.bicep",
+}
+
+// moduleFiles maps Terraform sections to files, in response order
+var moduleFiles = []struct {
+	Path    string
+	Section string
+	Title   string
+}{
+	{"main.tf", "resources", "Resources"},
+	{"variables.tf", "variables", "Variables"},
+	{"outputs.tf", "outputs", "Outputs"},
+	{"versions.tf", "versions", "Terraform and Provider Versions"},
+}
+
+// bicepPlaceholders are written to main.bicepparam for required parameters
+var bicepPlaceholders = map[string]string{
+	"string": "''",
+	"int":    "0",
+	"bool":   "false",
+	"object": "{}",
+	"array":  "[]",
+}
+
+var (
+	bicepParamPattern      = regexp.MustCompile(`^param (\w+) (\w+)(?: = (.*))?$`)
+	bicepExpressionPattern = regexp.MustCompile(`^[A-Za-z_]`)
+)
+
+// Files lays the configuration out according to layout
+func (g *Generation) Files(layout, description string) []GeneratedFile {
+	if layout != layoutModule {
+		return []GeneratedFile{{Path: mainFiles[g.Language], Content: g.Code(description)}}
+	}
+	if g.Language == "bicep" {
+		return []GeneratedFile{
+			{Path: "main.bicep", Content: g.Code(description)},
+			{Path: "main.bicepparam", Content: g.bicepParamFile()},
+		}
+	}
+
+	var files []GeneratedFile
+	for _, file := range moduleFiles {
+		content := g.section(file.Section)
+		if content == "" {
+			continue
+		}
+		var out strings.Builder
+		out.WriteString(fileBanner("#", g.Title+": "+file.Title))
+		out.WriteString("\n")
+		out.WriteString(content)
+		out.WriteString("\n")
+		files = append(files, GeneratedFile{Path: file.Path, Content: out.String()})
+	}
+	return files
+}
+
+// section returns the code of one section, or "" when no pattern defines it
+func (g *Generation) section(name string) string {
+	for _, section := range g.Sections {
+		if section.Name == name {
+			return section.Content
+		}
+	}
+	return ""
+}
+
+// bicepParamFile builds main.bicepparam from the parameters of main.bicep
+func (g *Generation) bicepParamFile() string {
+	var out strings.Builder
+	out.WriteString(fileBanner("//", g.Title+": Parameter File"))
+	out.WriteString("\nusing './main.bicep'\n\n")
+
+	lines := strings.Split(g.section("parameters"), "\n")
+	for i := 0; i < len(lines); i++ {
+		match := bicepParamPattern.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		name, typ, value := match[1], match[2], match[3]
+		// Object and array defaults span several lines
+		for bracketDepth(value) > 0 && i+1 < len(lines) {
+			i++
+			value += "\n" + lines[i]
+		}
+
+		switch {
+		case value == "":
+			placeholder, ok := bicepPlaceholders[typ]
+			if !ok {
+				placeholder = "''"
+			}
+			out.WriteString(fmt.Sprintf("param %s = %s // TODO: required\n", name, placeholder))
+		case bicepExpressionPattern.MatchString(value) && value != "true" && value != "false":
+			continue
+		default:
+			out.WriteString(fmt.Sprintf("param %s = %s\n", name, value))
+		}
+	}
+	return out.String()
+}
+
+// bracketDepth counts the braces and brackets value leaves open
+func bracketDepth(value string) int {
+	depth := 0
+	for _, r := range value {
+		switch r {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// fileBanner is the header of a module file, as in the lab solutions
+func fileBanner(comment, title string) string {
+	rule := comment + " " + strings.Repeat("=", 77)
+	return fmt.Sprintf("%s\n%s %s\n%s Generated by IaC Helper Skillset\n%s\n", rule, comment, title, comment, rule)
+}
@@ -9,10 +9,19 @@
 //   POST /generate  - Generate IaC from descriptions
 //   POST /explain   - Explain IaC resources
 //
+// /generate renders templates from the library in ./templates (see
+// templates.go); set TEMPLATES_DIR to use another library. Generated code is
+// validated before it is returned (see selfcheck.go). /explain answers from
+// the resource knowledge base in ./knowledge (see knowledge.go); set
+// KNOWLEDGE_DIR to use another one.
+//
 // Usage:
 //   go run .
 //   # Server starts on :8080
 //   # Use ngrok to expose: ngrok http 8080
+//
+//   go run . -self-check
+//   # Validates every built-in template and exits
 // =============================================================================
 
 package main
@@ -22,6 +31,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -40,6 +51,9 @@ import (
 type Config struct {
 	Port          string
 	WebhookSecret string
+	TemplatesDir  string
+	KnowledgeDir  string
+	PolicyRules   string
 	Debug         bool
 }
 
@@ -49,9 +63,27 @@ func loadConfig() *Config {
 		port = "8080"
 	}
 
+	templatesDir := os.Getenv("TEMPLATES_DIR")
+	if templatesDir == "" {
+		templatesDir = "templates"
+	}
+
+	knowledgeDir := os.Getenv("KNOWLEDGE_DIR")
+	if knowledgeDir == "" {
+		knowledgeDir = "knowledge"
+	}
+
+	policyRules := os.Getenv("POLICY_RULES")
+	if policyRules == "" {
+		policyRules = filepath.Join("..", "03-policy-agent", "policies", "rules.json")
+	}
+
 	return &Config{
 		Port:          port,
 		WebhookSecret: os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		TemplatesDir:  templatesDir,
+		KnowledgeDir:  knowledgeDir,
+		PolicyRules:   policyRules,
 		Debug:         os.Getenv("DEBUG") != "",
 	}
 }
@@ -82,15 +114,26 @@ type ValidationError struct {
 
 // GenerateRequest is the request body for /generate
 type GenerateRequest struct {
-	Description string `json:"description"`
-	Type        string `json:"type"` // "terraform" or "bicep"
+	Description string                 `json:"description"`
+	Type        string                 `json:"type"`                 // "terraform" or "bicep"
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // name, location, sku, tags, node_count
+	Layout      string                 `json:"layout,omitempty"`     // "file" (default) or "module"
 }
 
 // GenerateResponse is the response for /generate
 type GenerateResponse struct {
-	Code     string `json:"code"`
-	Language string `json:"language"`
-	Notes    string `json:"notes,omitempty"`
+	Code       string              `json:"code"`
+	Language   string              `json:"language"`
+	Components []string            `json:"components,omitempty"` // template patterns used
+	Files      []GeneratedFile     `json:"files"`
+	Notes      string              `json:"notes,omitempty"`
+	Validation *GenerateValidation `json:"validation"`
+}
+
+// GeneratedFile is one file of the generated configuration
+type GeneratedFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
 }
 
 // ExplainRequest is the request body for /explain
@@ -105,6 +148,10 @@ type ExplainResponse struct {
 	Examples         []string `json:"examples,omitempty"`
 	DocumentationURL string   `json:"documentation_url,omitempty"`
 	RelatedResources []string `json:"related_resources,omitempty"`
+	Property         string   `json:"property,omitempty"` // set when a documented property was explained
+	AllowedValues    []string `json:"allowed_values,omitempty"`
+	Default          string   `json:"default,omitempty"`
+	SecurityNotes    string   `json:"security_notes,omitempty"`
 }
 
 // =============================================================================
@@ -112,14 +159,20 @@ type ExplainResponse struct {
 // =============================================================================
 
 type Server struct {
-	config *Config
-	mux    *http.ServeMux
+	config    *Config
+	templates *TemplateLibrary
+	knowledge *KnowledgeBase
+	policies  []PolicyRule
+	mux       *http.ServeMux
 }
 
-func NewServer(config *Config) *Server {
+func NewServer(config *Config, templates *TemplateLibrary, knowledge *KnowledgeBase, policies []PolicyRule) *Server {
 	s := &Server{
-		config: config,
-		mux:    http.NewServeMux(),
+		config:    config,
+		templates: templates,
+		knowledge: knowledge,
+		policies:  policies,
+		mux:       http.NewServeMux(),
 	}
 	s.setupRoutes()
 	return s
@@ -134,8 +187,9 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/generate", s.withLogging(s.handleGenerate))
 	s.mux.HandleFunc("/explain", s.withLogging(s.handleExplain))
 
-	// Manifest endpoint
+	// Manifest and template library
 	s.mux.HandleFunc("/manifest.json", s.handleManifest)
+	s.mux.HandleFunc("/templates", s.handleTemplates)
 }
 
 func (s *Server) withLogging(next http.HandlerFunc) http.HandlerFunc {
@@ -183,6 +237,10 @@ func (s *Server) Run() error {
 	log.Printf("   POST /explain   - Explain IaC resources")
 	log.Printf("   GET  /health    - Health check")
 	log.Printf("   GET  /manifest.json - Skillset manifest")
+	log.Printf("   GET  /templates - Template library")
+	log.Printf("📚 Loaded %d templates from %s", len(s.templates.Templates), s.templates.Dir)
+	log.Printf("📖 Loaded %d knowledge base entries from %s", len(s.knowledge.Entries), s.knowledge.Dir)
+	log.Printf("🛡️ Loaded %d policy rules from %s", len(s.policies), s.config.PolicyRules)
 	return http.ListenAndServe(addr, s.mux)
 }
 
@@ -212,6 +270,19 @@ func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
 	w.Write(manifest)
 }
 
+// =============================================================================
+// Templates Handler
+// =============================================================================
+
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	metas := make([]TemplateMeta, 0, len(s.templates.Templates))
+	for _, tmpl := range s.templates.Templates {
+		metas = append(metas, tmpl.TemplateMeta)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metas)
+}
+
 // =============================================================================
 // Validate Handler
 // =============================================================================
@@ -268,9 +339,17 @@ func (s *Server) validateTerraform(code string) ValidateResponse {
 		}
 	}
 
-	// Run terraform init
+	// Run terraform init, reusing downloaded providers across runs
 	initCmd := exec.Command("terraform", "init", "-backend=false", "-no-color")
 	initCmd.Dir = tempDir
+	if os.Getenv("TF_PLUGIN_CACHE_DIR") == "" {
+		if cacheDir, err := os.UserCacheDir(); err == nil {
+			pluginDir := filepath.Join(cacheDir, "iac-skillset", "terraform-plugins")
+			if os.MkdirAll(pluginDir, 0755) == nil {
+				initCmd.Env = append(os.Environ(), "TF_PLUGIN_CACHE_DIR="+pluginDir)
+			}
+		}
+	}
 	initCmd.CombinedOutput()
 
 	// Run terraform validate
@@ -390,95 +469,50 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var response GenerateResponse
-
-	switch strings.ToLower(req.Type) {
-	case "terraform":
-		response = s.generateTerraform(req.Description)
-	case "bicep":
-		response = s.generateBicep(req.Description)
-	default:
+	lang := strings.ToLower(req.Type)
+	if lang != "terraform" && lang != "bicep" {
 		http.Error(w, "Type must be 'terraform' or 'bicep'", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func (s *Server) generateTerraform(description string) GenerateResponse {
-	// Template library based on common patterns
-	desc := strings.ToLower(description)
-
-	var code string
-	var notes string
-
-	switch {
-	case strings.Contains(desc, "storage") && strings.Contains(desc, "account"):
-		code = generateTerraformStorageAccount()
-		notes = "Generated Azure Storage Account with recommended settings. Customize the name, location, and SKU as needed."
-
-	case strings.Contains(desc, "kubernetes") || strings.Contains(desc, "aks"):
-		code = generateTerraformAKS()
-		notes = "Generated AKS cluster with system node pool. Add user node pools as needed for workloads."
-
-	case strings.Contains(desc, "virtual network") || strings.Contains(desc, "vnet"):
-		code = generateTerraformVNet()
-		notes = "Generated Virtual Network with a default subnet. Add more subnets as needed."
-
-	case strings.Contains(desc, "resource group"):
-		code = generateTerraformResourceGroup()
-		notes = "Generated Resource Group. This is typically the foundation for other resources."
-
-	case strings.Contains(desc, "key vault"):
-		code = generateTerraformKeyVault()
-		notes = "Generated Key Vault with RBAC authorization. Configure access policies based on your security requirements."
-
-	default:
-		code = generateTerraformGeneric(description)
-		notes = "Generated a basic resource template. Please customize based on your specific requirements."
+	layout := strings.ToLower(req.Layout)
+	if layout == "" {
+		layout = layoutFile
 	}
-
-	return GenerateResponse{
-		Code:     code,
-		Language: "terraform",
-		Notes:    notes,
+	if layout != layoutFile && layout != layoutModule {
+		http.Error(w, "Layout must be 'file' or 'module'", http.StatusBadRequest)
+		return
 	}
-}
 
-func (s *Server) generateBicep(description string) GenerateResponse {
-	desc := strings.ToLower(description)
-
-	var code string
-	var notes string
-
-	switch {
-	case strings.Contains(desc, "storage") && strings.Contains(desc, "account"):
-		code = generateBicepStorageAccount()
-		notes = "Generated Azure Storage Account with recommended settings. Customize parameters as needed."
-
-	case strings.Contains(desc, "kubernetes") || strings.Contains(desc, "aks"):
-		code = generateBicepAKS()
-		notes = "Generated AKS cluster with system node pool. Modify node count and VM size based on workload."
-
-	case strings.Contains(desc, "virtual network") || strings.Contains(desc, "vnet"):
-		code = generateBicepVNet()
-		notes = "Generated Virtual Network with a default subnet. Add more subnets as needed."
-
-	case strings.Contains(desc, "key vault"):
-		code = generateBicepKeyVault()
-		notes = "Generated Key Vault with RBAC authorization enabled."
+	gen, err := s.templates.Generate(req.Description, lang, req.Parameters)
+	if errors.Is(err, errInvalidParameters) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Generate failed: %v", err)
+		http.Error(w, "Failed to generate code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	default:
-		code = generateBicepGeneric(description)
-		notes = "Generated a basic resource template. Please customize based on your specific requirements."
+	code := gen.Code(req.Description)
+	validation := s.validateGeneration(lang, code)
+	if !validation.Passed {
+		log.Printf("⚠️ Generated %s [%s] failed self-validation: syntax %s, policy %s",
+			lang, strings.Join(gen.Components, ", "), validation.Syntax, validation.Policy)
 	}
 
-	return GenerateResponse{
-		Code:     code,
-		Language: "bicep",
-		Notes:    notes,
+	response := GenerateResponse{
+		Code:       code,
+		Language:   lang,
+		Components: gen.Components,
+		Files:      gen.Files(layout, req.Description),
+		Notes:      strings.Join(gen.Notes, " "),
+		Validation: validation,
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // =============================================================================
@@ -509,181 +543,11 @@ func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) explainResource(resource, property string) ExplainResponse {
-	// Resource explanations database
-	explanations := map[string]ExplainResponse{
-		"azurerm_kubernetes_cluster": {
-			Explanation: `The azurerm_kubernetes_cluster resource creates an Azure Kubernetes Service (AKS) cluster.
-
-Key features:
-• Managed Kubernetes control plane (free)
-• Integration with Azure AD for authentication
-• Support for multiple node pools
-• Built-in monitoring with Azure Monitor
-• Network policies with Azure CNI or Kubenet
-
-Best practices:
-• Use managed identities instead of service principals
-• Enable Azure Policy add-on for governance
-• Configure auto-scaling for node pools
-• Use availability zones for high availability`,
-			Examples: []string{
-				`resource "azurerm_kubernetes_cluster" "example" {
-  name                = "aks-cluster"
-  location            = azurerm_resource_group.example.location
-  resource_group_name = azurerm_resource_group.example.name
-  dns_prefix          = "aks"
-
-  default_node_pool {
-    name       = "system"
-    node_count = 3
-    vm_size    = "Standard_D2s_v3"
-  }
-
-  identity {
-    type = "SystemAssigned"
-  }
-}`,
-			},
-			DocumentationURL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs/resources/kubernetes_cluster",
-			RelatedResources: []string{
-				"azurerm_kubernetes_cluster_node_pool",
-				"azurerm_container_registry",
-				"azurerm_log_analytics_workspace",
-			},
-		},
-		"azurerm_storage_account": {
-			Explanation: `The azurerm_storage_account resource creates an Azure Storage Account.
-
-Supported services:
-• Blob storage (object storage)
-• File shares (SMB/NFS)
-• Queues (messaging)
-• Tables (NoSQL)
-
-Key configurations:
-• account_tier: Standard or Premium
-• account_replication_type: LRS, GRS, ZRS, GZRS, RA-GRS, RA-GZRS
-• account_kind: StorageV2 (recommended), BlobStorage, FileStorage
-
-Best practices:
-• Enable soft delete for blob and container recovery
-• Use private endpoints for secure access
-• Enable infrastructure encryption for sensitive data
-• Configure lifecycle management for cost optimization`,
-			Examples: []string{
-				`resource "azurerm_storage_account" "example" {
-  name                     = "storageaccountname"
-  resource_group_name      = azurerm_resource_group.example.name
-  location                 = azurerm_resource_group.example.location
-  account_tier             = "Standard"
-  account_replication_type = "GRS"
-
-  blob_properties {
-    delete_retention_policy {
-      days = 7
-    }
-  }
-}`,
-			},
-			DocumentationURL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs/resources/storage_account",
-			RelatedResources: []string{
-				"azurerm_storage_container",
-				"azurerm_storage_blob",
-				"azurerm_storage_share",
-				"azurerm_private_endpoint",
-			},
-		},
-		"azurerm_virtual_network": {
-			Explanation: `The azurerm_virtual_network resource creates an Azure Virtual Network (VNet).
-
-Purpose:
-• Provides isolated network for Azure resources
-• Enables secure communication between resources
-• Supports hybrid connectivity (VPN, ExpressRoute)
-• Foundation for subnet segmentation
-
-Key features:
-• Address space definition (CIDR blocks)
-• DNS server configuration
-• DDoS protection
-• Service endpoints and private endpoints
-
-Best practices:
-• Plan address space for future growth
-• Use subnets to segment workloads
-• Implement NSGs for traffic filtering
-• Use Azure Firewall or NVAs for advanced security`,
-			Examples: []string{
-				`resource "azurerm_virtual_network" "example" {
-  name                = "vnet-example"
-  location            = azurerm_resource_group.example.location
-  resource_group_name = azurerm_resource_group.example.name
-  address_space       = ["10.0.0.0/16"]
-
-  subnet {
-    name           = "default"
-    address_prefix = "10.0.1.0/24"
-  }
-}`,
-			},
-			DocumentationURL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs/resources/virtual_network",
-			RelatedResources: []string{
-				"azurerm_subnet",
-				"azurerm_network_security_group",
-				"azurerm_route_table",
-				"azurerm_virtual_network_peering",
-			},
-		},
-		"Microsoft.Storage/storageAccounts": {
-			Explanation: `The Microsoft.Storage/storageAccounts Bicep resource creates an Azure Storage Account.
-
-API Version: Use 2023-01-01 or later for latest features.
-
-Key properties:
-• sku.name: Standard_LRS, Standard_GRS, Standard_ZRS, Premium_LRS
-• kind: StorageV2 (recommended), BlobStorage, FileStorage
-• properties.supportsHttpsTrafficOnly: Always set to true
-• properties.minimumTlsVersion: TLS1_2 recommended
-
-Best practices:
-• Use parameters for configurable values
-• Enable blob soft delete
-• Configure network rules for security
-• Use managed identities for access`,
-			Examples: []string{
-				`resource storageAccount 'Microsoft.Storage/storageAccounts@2023-01-01' = {
-  name: storageAccountName
-  location: location
-  sku: {
-    name: 'Standard_LRS'
-  }
-  kind: 'StorageV2'
-  properties: {
-    supportsHttpsTrafficOnly: true
-    minimumTlsVersion: 'TLS1_2'
-  }
-}`,
-			},
-			DocumentationURL: "https://learn.microsoft.com/azure/templates/microsoft.storage/storageaccounts",
-			RelatedResources: []string{
-				"Microsoft.Storage/storageAccounts/blobServices",
-				"Microsoft.Storage/storageAccounts/fileServices",
-				"Microsoft.Network/privateEndpoints",
-			},
-		},
-	}
-
-	// Look up the resource
-	resource = strings.ToLower(resource)
-	for key, explanation := range explanations {
-		if strings.ToLower(key) == resource || strings.Contains(strings.ToLower(key), resource) {
-			return explanation
-		}
-	}
-
-	// Default response for unknown resources
-	return ExplainResponse{
-		Explanation: fmt.Sprintf(`Resource '%s' explanation not found in the local database.
+	entry, lang := s.knowledge.Lookup(resource)
+	if entry == nil {
+		// Default response for unknown resources
+		return ExplainResponse{
+			Explanation: fmt.Sprintf(`Resource '%s' explanation not found in the local database.
 
 To learn more about this resource:
 1. Check the Terraform Registry: https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs
@@ -691,572 +555,22 @@ To learn more about this resource:
 3. Use 'az provider show' command for ARM resource types
 
 Would you like me to search for documentation online?`, resource),
-		DocumentationURL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs",
+			DocumentationURL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs",
+		}
 	}
-}
-
-// =============================================================================
-// Template Generation Functions
-// =============================================================================
-
-func generateTerraformStorageAccount() string {
-	return `# Azure Storage Account
-# Generated by IaC Helper Skillset
-
-variable "resource_group_name" {
-  description = "Name of the resource group"
-  type        = string
-}
-
-variable "location" {
-  description = "Azure region for resources"
-  type        = string
-  default     = "eastus"
-}
-
-variable "storage_account_name" {
-  description = "Name of the storage account (must be globally unique)"
-  type        = string
-}
-
-resource "azurerm_storage_account" "main" {
-  name                     = var.storage_account_name
-  resource_group_name      = var.resource_group_name
-  location                 = var.location
-  account_tier             = "Standard"
-  account_replication_type = "GRS"
-  min_tls_version          = "TLS1_2"
-
-  blob_properties {
-    delete_retention_policy {
-      days = 7
-    }
-    container_delete_retention_policy {
-      days = 7
-    }
-  }
-
-  tags = {
-    environment = "production"
-    managed_by  = "terraform"
-  }
-}
-
-output "storage_account_id" {
-  description = "The ID of the storage account"
-  value       = azurerm_storage_account.main.id
-}
-
-output "primary_blob_endpoint" {
-  description = "The primary blob endpoint"
-  value       = azurerm_storage_account.main.primary_blob_endpoint
-}`
-}
-
-func generateTerraformAKS() string {
-	return `# Azure Kubernetes Service (AKS) Cluster
-# Generated by IaC Helper Skillset
-
-variable "resource_group_name" {
-  description = "Name of the resource group"
-  type        = string
-}
-
-variable "location" {
-  description = "Azure region for resources"
-  type        = string
-  default     = "eastus"
-}
-
-variable "cluster_name" {
-  description = "Name of the AKS cluster"
-  type        = string
-}
-
-variable "dns_prefix" {
-  description = "DNS prefix for the cluster"
-  type        = string
-}
-
-variable "node_count" {
-  description = "Number of nodes in the default node pool"
-  type        = number
-  default     = 3
-}
-
-resource "azurerm_kubernetes_cluster" "main" {
-  name                = var.cluster_name
-  location            = var.location
-  resource_group_name = var.resource_group_name
-  dns_prefix          = var.dns_prefix
-  kubernetes_version  = "1.28"
-
-  default_node_pool {
-    name                = "system"
-    node_count          = var.node_count
-    vm_size             = "Standard_D2s_v3"
-    enable_auto_scaling = true
-    min_count           = 1
-    max_count           = 5
-  }
-
-  identity {
-    type = "SystemAssigned"
-  }
-
-  network_profile {
-    network_plugin    = "azure"
-    network_policy    = "azure"
-    load_balancer_sku = "standard"
-  }
-
-  tags = {
-    environment = "production"
-    managed_by  = "terraform"
-  }
-}
-
-output "cluster_id" {
-  description = "The ID of the AKS cluster"
-  value       = azurerm_kubernetes_cluster.main.id
-}
-
-output "kube_config" {
-  description = "Kubernetes config for kubectl"
-  value       = azurerm_kubernetes_cluster.main.kube_config_raw
-  sensitive   = true
-}`
-}
-
-func generateTerraformVNet() string {
-	return `# Azure Virtual Network
-# Generated by IaC Helper Skillset
-
-variable "resource_group_name" {
-  description = "Name of the resource group"
-  type        = string
-}
-
-variable "location" {
-  description = "Azure region for resources"
-  type        = string
-  default     = "eastus"
-}
-
-variable "vnet_name" {
-  description = "Name of the virtual network"
-  type        = string
-}
-
-variable "address_space" {
-  description = "Address space for the VNet"
-  type        = list(string)
-  default     = ["10.0.0.0/16"]
-}
-
-resource "azurerm_virtual_network" "main" {
-  name                = var.vnet_name
-  location            = var.location
-  resource_group_name = var.resource_group_name
-  address_space       = var.address_space
-
-  tags = {
-    environment = "production"
-    managed_by  = "terraform"
-  }
-}
-
-resource "azurerm_subnet" "default" {
-  name                 = "default"
-  resource_group_name  = var.resource_group_name
-  virtual_network_name = azurerm_virtual_network.main.name
-  address_prefixes     = ["10.0.1.0/24"]
-}
-
-output "vnet_id" {
-  description = "The ID of the virtual network"
-  value       = azurerm_virtual_network.main.id
-}
-
-output "subnet_id" {
-  description = "The ID of the default subnet"
-  value       = azurerm_subnet.default.id
-}`
-}
-
-func generateTerraformResourceGroup() string {
-	return `# Azure Resource Group
-# Generated by IaC Helper Skillset
-
-variable "resource_group_name" {
-  description = "Name of the resource group"
-  type        = string
-}
-
-variable "location" {
-  description = "Azure region for resources"
-  type        = string
-  default     = "eastus"
-}
-
-resource "azurerm_resource_group" "main" {
-  name     = var.resource_group_name
-  location = var.location
-
-  tags = {
-    environment = "production"
-    managed_by  = "terraform"
-  }
-}
-
-output "resource_group_id" {
-  description = "The ID of the resource group"
-  value       = azurerm_resource_group.main.id
-}
-
-output "resource_group_name" {
-  description = "The name of the resource group"
-  value       = azurerm_resource_group.main.name
-}`
-}
-
-func generateTerraformKeyVault() string {
-	return `# Azure Key Vault
-# Generated by IaC Helper Skillset
-
-variable "resource_group_name" {
-  description = "Name of the resource group"
-  type        = string
-}
-
-variable "location" {
-  description = "Azure region for resources"
-  type        = string
-  default     = "eastus"
-}
-
-variable "key_vault_name" {
-  description = "Name of the Key Vault (must be globally unique)"
-  type        = string
-}
-
-data "azurerm_client_config" "current" {}
-
-resource "azurerm_key_vault" "main" {
-  name                        = var.key_vault_name
-  location                    = var.location
-  resource_group_name         = var.resource_group_name
-  tenant_id                   = data.azurerm_client_config.current.tenant_id
-  sku_name                    = "standard"
-  enable_rbac_authorization   = true
-  purge_protection_enabled    = true
-  soft_delete_retention_days  = 90
-
-  network_acls {
-    default_action = "Deny"
-    bypass         = "AzureServices"
-  }
-
-  tags = {
-    environment = "production"
-    managed_by  = "terraform"
-  }
-}
-
-output "key_vault_id" {
-  description = "The ID of the Key Vault"
-  value       = azurerm_key_vault.main.id
-}
-
-output "key_vault_uri" {
-  description = "The URI of the Key Vault"
-  value       = azurerm_key_vault.main.vault_uri
-}`
-}
-
-func generateTerraformGeneric(description string) string {
-	return fmt.Sprintf(`# Terraform Template
-# Generated by IaC Helper Skillset
-# Description: %s
-
-# TODO: Replace with specific resource configuration
-
-variable "resource_group_name" {
-  description = "Name of the resource group"
-  type        = string
-}
-
-variable "location" {
-  description = "Azure region for resources"
-  type        = string
-  default     = "eastus"
-}
-
-# Add your resources here based on: %s
-
-# Example resource:
-# resource "azurerm_<resource_type>" "example" {
-#   name                = "example"
-#   resource_group_name = var.resource_group_name
-#   location            = var.location
-# }
-`, description, description)
-}
-
-func generateBicepStorageAccount() string {
-	return `// Azure Storage Account
-// Generated by IaC Helper Skillset
-
-@description('Name of the storage account (must be globally unique)')
-@minLength(3)
-@maxLength(24)
-param storageAccountName string
-
-@description('Azure region for the storage account')
-param location string = resourceGroup().location
-
-@description('Storage account SKU')
-@allowed([
-  'Standard_LRS'
-  'Standard_GRS'
-  'Standard_ZRS'
-  'Premium_LRS'
-])
-param skuName string = 'Standard_LRS'
-
-@description('Tags for the resource')
-param tags object = {
-  environment: 'production'
-  managedBy: 'bicep'
-}
-
-resource storageAccount 'Microsoft.Storage/storageAccounts@2023-01-01' = {
-  name: storageAccountName
-  location: location
-  sku: {
-    name: skuName
-  }
-  kind: 'StorageV2'
-  properties: {
-    supportsHttpsTrafficOnly: true
-    minimumTlsVersion: 'TLS1_2'
-    allowBlobPublicAccess: false
-    networkAcls: {
-      defaultAction: 'Deny'
-      bypass: 'AzureServices'
-    }
-  }
-  tags: tags
-}
-
-resource blobService 'Microsoft.Storage/storageAccounts/blobServices@2023-01-01' = {
-  parent: storageAccount
-  name: 'default'
-  properties: {
-    deleteRetentionPolicy: {
-      enabled: true
-      days: 7
-    }
-    containerDeleteRetentionPolicy: {
-      enabled: true
-      days: 7
-    }
-  }
-}
-
-@description('The resource ID of the storage account')
-output storageAccountId string = storageAccount.id
-
-@description('The primary blob endpoint')
-output primaryBlobEndpoint string = storageAccount.properties.primaryEndpoints.blob`
-}
-
-func generateBicepAKS() string {
-	return `// Azure Kubernetes Service (AKS) Cluster
-// Generated by IaC Helper Skillset
-
-@description('Name of the AKS cluster')
-param clusterName string
-
-@description('Azure region for the cluster')
-param location string = resourceGroup().location
 
-@description('DNS prefix for the cluster')
-param dnsPrefix string
-
-@description('Number of nodes in the default node pool')
-@minValue(1)
-@maxValue(100)
-param nodeCount int = 3
-
-@description('VM size for the nodes')
-param nodeVmSize string = 'Standard_D2s_v3'
-
-@description('Kubernetes version')
-param kubernetesVersion string = '1.28'
-
-@description('Tags for the resource')
-param tags object = {
-  environment: 'production'
-  managedBy: 'bicep'
-}
-
-resource aksCluster 'Microsoft.ContainerService/managedClusters@2024-01-01' = {
-  name: clusterName
-  location: location
-  identity: {
-    type: 'SystemAssigned'
-  }
-  properties: {
-    dnsPrefix: dnsPrefix
-    kubernetesVersion: kubernetesVersion
-    agentPoolProfiles: [
-      {
-        name: 'system'
-        count: nodeCount
-        vmSize: nodeVmSize
-        mode: 'System'
-        enableAutoScaling: true
-        minCount: 1
-        maxCount: 5
-      }
-    ]
-    networkProfile: {
-      networkPlugin: 'azure'
-      networkPolicy: 'azure'
-      loadBalancerSku: 'standard'
-    }
-  }
-  tags: tags
-}
-
-@description('The resource ID of the AKS cluster')
-output clusterId string = aksCluster.id
-
-@description('The FQDN of the AKS cluster')
-output clusterFqdn string = aksCluster.properties.fqdn`
-}
-
-func generateBicepVNet() string {
-	return `// Azure Virtual Network
-// Generated by IaC Helper Skillset
-
-@description('Name of the virtual network')
-param vnetName string
-
-@description('Azure region for the virtual network')
-param location string = resourceGroup().location
-
-@description('Address space for the VNet')
-param addressPrefix string = '10.0.0.0/16'
-
-@description('Address prefix for the default subnet')
-param subnetPrefix string = '10.0.1.0/24'
-
-@description('Tags for the resource')
-param tags object = {
-  environment: 'production'
-  managedBy: 'bicep'
-}
-
-resource virtualNetwork 'Microsoft.Network/virtualNetworks@2023-05-01' = {
-  name: vnetName
-  location: location
-  properties: {
-    addressSpace: {
-      addressPrefixes: [
-        addressPrefix
-      ]
-    }
-    subnets: [
-      {
-        name: 'default'
-        properties: {
-          addressPrefix: subnetPrefix
-        }
-      }
-    ]
-  }
-  tags: tags
-}
-
-@description('The resource ID of the virtual network')
-output vnetId string = virtualNetwork.id
-
-@description('The resource ID of the default subnet')
-output subnetId string = virtualNetwork.properties.subnets[0].id`
-}
-
-func generateBicepKeyVault() string {
-	return `// Azure Key Vault
-// Generated by IaC Helper Skillset
-
-@description('Name of the Key Vault (must be globally unique)')
-@minLength(3)
-@maxLength(24)
-param keyVaultName string
-
-@description('Azure region for the Key Vault')
-param location string = resourceGroup().location
-
-@description('Tags for the resource')
-param tags object = {
-  environment: 'production'
-  managedBy: 'bicep'
-}
-
-resource keyVault 'Microsoft.KeyVault/vaults@2023-07-01' = {
-  name: keyVaultName
-  location: location
-  properties: {
-    tenantId: subscription().tenantId
-    sku: {
-      family: 'A'
-      name: 'standard'
-    }
-    enableRbacAuthorization: true
-    enableSoftDelete: true
-    softDeleteRetentionInDays: 90
-    enablePurgeProtection: true
-    networkAcls: {
-      defaultAction: 'Deny'
-      bypass: 'AzureServices'
-    }
-  }
-  tags: tags
-}
-
-@description('The resource ID of the Key Vault')
-output keyVaultId string = keyVault.id
-
-@description('The URI of the Key Vault')
-output keyVaultUri string = keyVault.properties.vaultUri`
-}
-
-func generateBicepGeneric(description string) string {
-	return fmt.Sprintf(`// Bicep Template
-// Generated by IaC Helper Skillset
-// Description: %s
-
-@description('Azure region for resources')
-param location string = resourceGroup().location
-
-@description('Tags for resources')
-param tags object = {
-  environment: 'production'
-  managedBy: 'bicep'
-}
-
-// TODO: Add your resources here based on: %s
+	if property == "" {
+		return entry.Explain(lang)
+	}
+	if prop := entry.Property(property); prop != nil {
+		return entry.ExplainProperty(lang, prop)
+	}
 
-// Example resource:
-// resource exampleResource 'Microsoft.<Provider>/<ResourceType>@<ApiVersion>' = {
-//   name: 'example'
-//   location: location
-//   properties: {}
-//   tags: tags
-// }
-`, description, description)
+	// Unknown property: explain the resource and list what is documented
+	response := entry.Explain(lang)
+	response.Explanation = fmt.Sprintf("Property '%s' is not in the knowledge base for %s. Documented properties: %s.\n\n%s",
+		property, entry.TypeName(lang), strings.Join(entry.propertyNames(lang), ", "), response.Explanation)
+	return response
 }
 
 // =============================================================================
@@ -1264,8 +578,31 @@ param tags object = {
 // =============================================================================
 
 func main() {
+	selfCheck := flag.Bool("self-check", false, "Validate every built-in template and exit")
+	flag.Parse()
+
 	config := loadConfig()
-	server := NewServer(config)
+	templates, err := loadTemplateLibrary(config.TemplatesDir)
+	if err != nil {
+		log.Fatalf("Template library error: %v", err)
+	}
+	knowledge, err := loadKnowledgeBase(config.KnowledgeDir)
+	if err != nil {
+		log.Fatalf("Knowledge base error: %v", err)
+	}
+	policies, err := loadPolicyRules(config.PolicyRules)
+	if err != nil {
+		log.Printf("Warning: Could not load policy rules: %v", err)
+	}
+	server := NewServer(config, templates, knowledge, policies)
+
+	if *selfCheck {
+		if !server.selfCheck() {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := server.Run(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
@@ -0,0 +1,209 @@
+// =============================================================================
+// Policy Rules
+// =============================================================================
+// The custom policy rules of the Policy Checker Agent (../03-policy-agent),
+// evaluated the same way the agent evaluates them, so generated code is held
+// to the rules users will check it against. Set POLICY_RULES to use another
+// rules file.
+//
+// The rules name azurerm properties, so they apply to Terraform only.
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// PolicyRule defines a custom policy check
+type PolicyRule struct {
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Severity      string      `json:"severity"`
+	ResourceType  string      `json:"resourceType"`
+	Check         PolicyCheck `json:"check"`
+	Remediation   string      `json:"remediation"`
+	Documentation string      `json:"documentation"`
+}
+
+// PolicyCheck defines how to check a policy
+type PolicyCheck struct {
+	Property string      `json:"property"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+	Default  interface{} `json:"default,omitempty"`
+}
+
+// PolicyViolation represents a policy check failure
+type PolicyViolation struct {
+	PolicyID     string `json:"policy_id"`
+	PolicyName   string `json:"policy_name"`
+	ResourceType string `json:"resource_type"`
+	ResourceName string `json:"resource_name"`
+	Severity     string `json:"severity"`
+	Message      string `json:"message"`
+	Remediation  string `json:"remediation"`
+	Line         int    `json:"line,omitempty"`
+}
+
+// policyResource is a parsed Terraform resource
+type policyResource struct {
+	Type       string
+	Name       string
+	Properties map[string]interface{}
+	Line       int
+}
+
+// loadPolicyRules reads the customPolicies of a policy agent rules file
+func loadPolicyRules(path string) ([]PolicyRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		CustomPolicies []PolicyRule `json:"customPolicies"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config.CustomPolicies, nil
+}
+
+// checkPolicies evaluates every rule against the resources in code
+func checkPolicies(rules []PolicyRule, code string) []PolicyViolation {
+	var violations []PolicyViolation
+	for _, resource := range parseTerraformResources(code) {
+		for _, rule := range rules {
+			if !strings.EqualFold(rule.ResourceType, resource.Type) {
+				continue
+			}
+			if !evaluatePolicy(resource, rule) {
+				violations = append(violations, PolicyViolation{
+					PolicyID:     rule.ID,
+					PolicyName:   rule.Name,
+					ResourceType: resource.Type,
+					ResourceName: resource.Name,
+					Severity:     rule.Severity,
+					Message:      rule.Description,
+					Remediation:  rule.Remediation,
+					Line:         resource.Line,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func evaluatePolicy(resource policyResource, rule PolicyRule) bool {
+	value := getNestedProperty(resource.Properties, rule.Check.Property)
+
+	// If value is nil, check default
+	if value == nil {
+		if rule.Check.Default != nil {
+			value = rule.Check.Default
+		} else {
+			return rule.Check.Operator == "not_exists"
+		}
+	}
+
+	switch rule.Check.Operator {
+	case "equals":
+		return fmt.Sprintf("%v", value) == fmt.Sprintf("%v", rule.Check.Value)
+	case "not_equals":
+		return fmt.Sprintf("%v", value) != fmt.Sprintf("%v", rule.Check.Value)
+	case "exists":
+		return value != nil
+	case "not_exists":
+		return value == nil
+	case "contains":
+		return strings.Contains(fmt.Sprintf("%v", value), fmt.Sprintf("%v", rule.Check.Value))
+	case "greater_than":
+		return fmt.Sprintf("%v", value) > fmt.Sprintf("%v", rule.Check.Value)
+	default:
+		return true
+	}
+}
+
+func getNestedProperty(props map[string]interface{}, path string) interface{} {
+	var current interface{} = props
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+var (
+	tfResourcePattern = regexp.MustCompile(`(?m)^resource\s+"([^"]+)"\s+"([^"]+)"\s*\{`)
+	tfAttrPattern     = regexp.MustCompile(`(?m)^\s*([a-z_]+)\s*=\s*(.+)$`)
+	tfBlockPattern    = regexp.MustCompile(`(?m)^\s*([a-z_]+)\s*\{`)
+)
+
+// parseTerraformResources finds the resource blocks in code
+func parseTerraformResources(code string) []policyResource {
+	var resources []policyResource
+	for _, match := range tfResourcePattern.FindAllStringSubmatchIndex(code, -1) {
+		resource := policyResource{
+			Type: code[match[2]:match[3]],
+			Name: code[match[4]:match[5]],
+			Line: strings.Count(code[:match[0]], "\n") + 1,
+		}
+		if end := findMatchingBrace(code, match[1]-1); end > match[1] {
+			resource.Properties = parseTerraformBlock(code[match[1]:end])
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
+func parseTerraformBlock(block string) map[string]interface{} {
+	props := make(map[string]interface{})
+
+	for _, match := range tfAttrPattern.FindAllStringSubmatch(block, -1) {
+		key := strings.TrimSpace(match[1])
+		value := strings.TrimSpace(match[2])
+		switch {
+		case strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\""):
+			props[key] = strings.Trim(value, "\"")
+		case value == "true":
+			props[key] = true
+		case value == "false":
+			props[key] = false
+		default:
+			props[key] = value
+		}
+	}
+
+	for _, match := range tfBlockPattern.FindAllStringSubmatchIndex(block, -1) {
+		name := block[match[2]:match[3]]
+		if end := findMatchingBrace(block, match[1]-1); end > match[1] {
+			props[name] = parseTerraformBlock(block[match[1]:end])
+		}
+	}
+	return props
+}
+
+// findMatchingBrace returns the index of the brace closing the one at start
+func findMatchingBrace(code string, start int) int {
+	depth := 0
+	for i := start; i < len(code); i++ {
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(code)
+}
@@ -0,0 +1,177 @@
+// =============================================================================
+// Self-Validation
+// =============================================================================
+// /generate checks its own output before returning it. The code goes
+// through the same validation as /validate (terraform validate or az bicep
+// build) and, for Terraform, through the policy agent's rules (policy.go).
+// The outcome is returned in the response's validation section. A check
+// whose CLI or rules are unavailable is reported as skipped, not failed.
+//
+// `go run . -self-check` generates every built-in pattern on its own and all
+// of them together, in both languages, and exits non-zero when any result
+// fails or could not be syntax-checked, so CI notices when a template stops
+// validating. selfcheck_test.go runs the same cases under go test.
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// Check outcomes
+const (
+	checkPassed  = "passed"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+// validationCLIs is the CLI each language's syntax check needs
+var validationCLIs = map[string]string{
+	"terraform": "terraform",
+	"bicep":     "az",
+}
+
+// GenerateValidation is the self-check of generated code
+type GenerateValidation struct {
+	Passed     bool              `json:"passed"`
+	Syntax     string            `json:"syntax"` // passed, failed or skipped
+	Errors     []ValidationError `json:"errors,omitempty"`
+	Policy     string            `json:"policy"` // passed, failed or skipped
+	Violations []PolicyViolation `json:"violations,omitempty"`
+	Notes      []string          `json:"notes,omitempty"`
+}
+
+// validateGeneration runs generated code through validation and policy
+func (s *Server) validateGeneration(lang, code string) *GenerateValidation {
+	validation := &GenerateValidation{Syntax: checkSkipped, Policy: checkSkipped}
+
+	if cli := validationCLIs[lang]; !hasCLI(cli) {
+		validation.Notes = append(validation.Notes, fmt.Sprintf("Syntax check skipped: %s CLI not found", cli))
+	} else {
+		var result ValidateResponse
+		if lang == "terraform" {
+			result = s.validateTerraform(code)
+		} else {
+			result = s.validateBicep(code)
+		}
+		validation.Syntax = checkPassed
+		if !result.Valid {
+			validation.Syntax = checkFailed
+			validation.Errors = result.Errors
+		}
+	}
+
+	switch {
+	case lang != "terraform":
+		validation.Notes = append(validation.Notes, "Policy check skipped: the policy rules are written against azurerm properties")
+	case len(s.policies) == 0:
+		validation.Notes = append(validation.Notes, "Policy check skipped: no policy rules loaded")
+	default:
+		validation.Violations = checkPolicies(s.policies, code)
+		validation.Policy = checkPassed
+		if len(validation.Violations) > 0 {
+			validation.Policy = checkFailed
+		}
+	}
+
+	validation.Passed = validation.Syntax != checkFailed && validation.Policy != checkFailed
+	return validation
+}
+
+// hasCLI reports whether name is on the PATH
+func hasCLI(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// selfCheckCase is one generation exercised by the self-check
+type selfCheckCase struct {
+	Language    string
+	Description string
+}
+
+// selfCheckCases covers every pattern alone and all patterns together
+func (l *TemplateLibrary) selfCheckCases() []selfCheckCase {
+	var cases []selfCheckCase
+	for _, lang := range []string{"terraform", "bicep"} {
+		var all []string
+		for _, tmpl := range l.Templates {
+			if tmpl.templates[lang] == nil {
+				continue
+			}
+			description := "something no template matches"
+			if len(tmpl.Match) > 0 {
+				description = strings.Join(tmpl.Match[0], " ")
+				if !tmpl.Base {
+					all = append(all, description)
+				}
+			}
+			cases = append(cases, selfCheckCase{Language: lang, Description: description})
+		}
+		if len(all) > 1 {
+			cases = append(cases, selfCheckCase{Language: lang, Description: strings.Join(all, " with ")})
+		}
+	}
+	return cases
+}
+
+// selfCheck generates and validates every case, logging each result, and
+// reports whether all of them passed
+func (s *Server) selfCheck() bool {
+	log.Printf("🔍 Self-checking %d templates from %s", len(s.templates.Templates), s.templates.Dir)
+	for lang, cli := range validationCLIs {
+		if !hasCLI(cli) {
+			log.Printf("⚠️  %s CLI not found: %s syntax checks will be skipped", cli, lang)
+		}
+	}
+
+	failed, unchecked := 0, 0
+	cases := s.templates.selfCheckCases()
+	for _, c := range cases {
+		params := map[string]interface{}{"name": "selfcheck"}
+		gen, err := s.templates.Generate(c.Description, c.Language, params)
+		if err != nil {
+			log.Printf("❌ %s %q: %v", c.Language, c.Description, err)
+			failed++
+			continue
+		}
+
+		validation := s.validateGeneration(c.Language, gen.Code(c.Description))
+		components := strings.Join(gen.Components, ", ")
+		if validation.Syntax == checkSkipped {
+			unchecked++
+		}
+		if validation.Passed {
+			log.Printf("✅ %s [%s]: syntax %s, policy %s", c.Language, components, validation.Syntax, validation.Policy)
+			continue
+		}
+
+		failed++
+		log.Printf("❌ %s [%s]: syntax %s, policy %s", c.Language, components, validation.Syntax, validation.Policy)
+		for _, verr := range validation.Errors {
+			if verr.Line > 0 {
+				log.Printf("     Line %d: %s", verr.Line, verr.Message)
+			} else {
+				log.Printf("     %s", verr.Message)
+			}
+		}
+		for _, v := range validation.Violations {
+			log.Printf("     %s (%s.%s, line %d): %s", v.PolicyID, v.ResourceType, v.ResourceName, v.Line, v.Remediation)
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("❌ Self-check failed: %d of %d generations did not validate", failed, len(cases))
+		return false
+	}
+	if unchecked > 0 {
+		log.Printf("❌ Self-check incomplete: the syntax of %d of %d generations was not checked (install terraform and the Azure CLI)", unchecked, len(cases))
+		return false
+	}
+	log.Printf("✅ Self-check passed: %d generations", len(cases))
+	return true
+}
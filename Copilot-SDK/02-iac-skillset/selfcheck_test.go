@@ -0,0 +1,196 @@
+// =============================================================================
+// Template Tests
+// =============================================================================
+// Renders every self-check case (each pattern alone and all patterns
+// together, in both languages) and checks the result:
+//
+//   - TestTemplatesParse:    Terraform output parses as HCL, in-process
+//   - TestTemplatesPolicy:   Terraform output passes the policy agent's rules
+//   - TestTemplatesValidate: terraform validate / az bicep build; skipped,
+//                            with the reason, when the CLI isn't installed
+//                            and CI isn't set
+//   - TestTemplatesRendered: Terraform output matches the copies the policy
+//                            agent tests its own checker against
+//
+// After an intended template change, refresh the policy agent's copies with:
+//
+//   go test -run TestTemplatesRendered -update
+// =============================================================================
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+var update = flag.Bool("update", false, "rewrite the rendered templates in the policy agent's testdata")
+
+// renderedTemplatesDir holds a copy of every rendered Terraform case for the
+// policy agent's tests, so the agent checks exactly what the skillset generates
+var renderedTemplatesDir = filepath.Join("..", "03-policy-agent", "testdata", "templates")
+
+// templateCase is a rendered self-check case
+type templateCase struct {
+	selfCheckCase
+	name string
+	code string
+}
+
+// renderTemplates renders every self-check case of the built-in templates
+func renderTemplates(t *testing.T) (*Server, []templateCase) {
+	t.Helper()
+	templates, err := loadTemplateLibrary("templates")
+	if err != nil {
+		t.Fatalf("loading templates: %v", err)
+	}
+	policies, err := loadPolicyRules(loadConfig().PolicyRules)
+	if err != nil {
+		t.Fatalf("loading policy rules: %v", err)
+	}
+	server := NewServer(&Config{}, templates, nil, policies)
+
+	var cases []templateCase
+	for i, c := range templates.selfCheckCases() {
+		gen, err := templates.Generate(c.Description, c.Language, map[string]interface{}{"name": "selfcheck"})
+		if err != nil {
+			t.Fatalf("%s %q: %v", c.Language, c.Description, err)
+		}
+		cases = append(cases, templateCase{
+			selfCheckCase: c,
+			name:          fmt.Sprintf("%s/%02d %s", c.Language, i, c.Description),
+			code:          gen.Code(c.Description),
+		})
+	}
+	if len(cases) == 0 {
+		t.Fatal("no templates rendered")
+	}
+	return server, cases
+}
+
+func TestTemplatesParse(t *testing.T) {
+	_, cases := renderTemplates(t)
+	for _, c := range cases {
+		if c.Language != "terraform" {
+			continue
+		}
+		t.Run(c.name, func(t *testing.T) {
+			_, diags := hclsyntax.ParseConfig([]byte(c.code), "main.tf", hcl.InitialPos)
+			for _, diag := range diags {
+				t.Errorf("%s", diag.Error())
+			}
+			if diags.HasErrors() {
+				t.Logf("generated code:\n%s", c.code)
+			}
+		})
+	}
+}
+
+func TestTemplatesPolicy(t *testing.T) {
+	server, cases := renderTemplates(t)
+	if len(server.policies) == 0 {
+		t.Fatal("no policy rules loaded")
+	}
+	for _, c := range cases {
+		if c.Language != "terraform" {
+			continue
+		}
+		t.Run(c.name, func(t *testing.T) {
+			for _, v := range checkPolicies(server.policies, c.code) {
+				t.Errorf("%s (%s.%s, line %d): %s", v.PolicyID, v.ResourceType, v.ResourceName, v.Line, v.Remediation)
+			}
+		})
+	}
+}
+
+func TestTemplatesValidate(t *testing.T) {
+	server, cases := renderTemplates(t)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if cli := validationCLIs[c.Language]; !hasCLI(cli) {
+				// A CI job without the CLI would otherwise pass having
+				// validated nothing
+				if os.Getenv("CI") != "" {
+					t.Fatalf("%s CLI not found: %s syntax can't be validated in CI", cli, c.Language)
+				}
+				t.Skipf("%s CLI not found: %s syntax not validated", cli, c.Language)
+			}
+
+			var result ValidateResponse
+			if c.Language == "terraform" {
+				result = server.validateTerraform(c.code)
+			} else {
+				result = server.validateBicep(c.code)
+			}
+			for _, verr := range result.Errors {
+				t.Errorf("line %d: %s", verr.Line, verr.Message)
+			}
+			if !result.Valid && len(result.Errors) == 0 {
+				t.Error("validation failed without errors")
+			}
+		})
+	}
+}
+
+func TestTemplatesRendered(t *testing.T) {
+	_, cases := renderTemplates(t)
+
+	want := make(map[string]bool)
+	for i, c := range cases {
+		if c.Language != "terraform" {
+			continue
+		}
+		name := renderedTemplateName(i, c)
+		want[name] = true
+		path := filepath.Join(renderedTemplatesDir, name)
+
+		if *update {
+			if err := os.MkdirAll(renderedTemplatesDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(c.code), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v (run go test -run TestTemplatesRendered -update)", c.name, err)
+		} else if string(data) != c.code {
+			t.Errorf("%s differs from the rendered template (run go test -run TestTemplatesRendered -update)", path)
+		}
+	}
+
+	entries, err := os.ReadDir(renderedTemplatesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if want[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(renderedTemplatesDir, entry.Name())
+		if !*update {
+			t.Errorf("%s is no longer rendered (run go test -run TestTemplatesRendered -update)", path)
+		} else if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// nonSlugPattern matches the runs of characters dropped from file names
+var nonSlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// renderedTemplateName names the copy of case i, e.g. 05-kubernetes.tf
+func renderedTemplateName(i int, c templateCase) string {
+	slug := strings.Trim(nonSlugPattern.ReplaceAllString(strings.ToLower(c.Description), "-"), "-")
+	return fmt.Sprintf("%02d-%s.tf", i, slug)
+}
@@ -0,0 +1,465 @@
+// =============================================================================
+// Template Library
+// =============================================================================
+// /generate renders text/template files from a library on disk, so the
+// platform team can add patterns without recompiling. Each pattern is a
+// directory under the library root (TEMPLATES_DIR, default ./templates):
+//
+//   templates/storage-account/
+//     template.json     Metadata: match keywords, priority, defaults, notes
+//     main.tf.tmpl      Terraform template (optional)
+//     main.bicep.tmpl   Bicep template (optional)
+//
+// A template file defines named sections ("versions", "variables" or
+// "parameters", "resources", "outputs") rather than a whole file, so
+// several patterns can be composed into one configuration (see compose.go).
+// Sections are executed with a TemplateData value built from the request's
+// parameters (name, location, sku, tags, node_count) layered over the
+// pattern's defaults.
+//
+// The library is loaded at startup; restart the skillset to pick up new or
+// changed patterns.
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templateFiles maps each language to its template file name
+var templateFiles = map[string]string{
+	"terraform": "main.tf.tmpl",
+	"bicep":     "main.bicep.tmpl",
+}
+
+// templateSections are the named templates a pattern defines per language,
+// in the order they appear in the generated code
+var templateSections = map[string][]string{
+	"terraform": {"versions", "variables", "resources", "outputs"},
+	"bicep":     {"parameters", "resources", "outputs"},
+}
+
+// TemplateMeta is the template.json of one pattern
+type TemplateMeta struct {
+	Name        string                 `json:"name"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Match       [][]string             `json:"match,omitempty"` // any group whose keywords all appear
+	Priority    int                    `json:"priority,omitempty"`
+	Base        bool                   `json:"base,omitempty"`        // part of every configuration
+	Fallback    bool                   `json:"fallback,omitempty"`    // used when nothing else matches
+	NameFormat  string                 `json:"name_format,omitempty"` // e.g. "aks-{name}"
+	NameAlnum   bool                   `json:"name_alnum,omitempty"`  // strip all but lowercase letters and digits
+	Defaults    map[string]interface{} `json:"defaults,omitempty"`
+	Notes       map[string]string      `json:"notes,omitempty"` // per language
+	Languages   []string               `json:"languages"`
+}
+
+// Template is a pattern with its parsed template per language
+type Template struct {
+	TemplateMeta
+	matchers  [][]*regexp.Regexp
+	templates map[string]*template.Template
+}
+
+// TemplateLibrary holds all patterns, ordered by priority
+type TemplateLibrary struct {
+	Dir       string
+	Templates []*Template
+}
+
+// TemplateData is the value templates are executed with
+type TemplateData struct {
+	Description string
+	Name        string
+	Location    string
+	SKU         string
+	NodeCount   int
+	Tags        map[string]string
+	Params      map[string]interface{} // all parameters, including custom ones
+	Components  map[string]bool        // patterns in the same configuration
+}
+
+// Has reports whether the configuration includes the named pattern, so a
+// template can reference the resources it declares
+func (d TemplateData) Has(name string) bool {
+	return d.Components[name]
+}
+
+// defaultTags are applied when the request sets no tags
+var defaultTags = map[string]map[string]string{
+	"terraform": {"environment": "production", "managed_by": "terraform"},
+	"bicep":     {"environment": "production", "managedBy": "bicep"},
+}
+
+// loadTemplateLibrary parses every pattern directory under dir
+func loadTemplateLibrary(dir string) (*TemplateLibrary, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template library: %w", err)
+	}
+
+	library := &TemplateLibrary{Dir: dir}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tmpl, err := loadTemplate(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", entry.Name(), err)
+		}
+		library.Templates = append(library.Templates, tmpl)
+	}
+	if len(library.Templates) == 0 {
+		return nil, fmt.Errorf("no templates found in %s", dir)
+	}
+
+	sort.SliceStable(library.Templates, func(i, j int) bool {
+		a, b := library.Templates[i], library.Templates[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.Name < b.Name
+	})
+	return library, nil
+}
+
+// loadTemplate reads template.json and the template files of one pattern
+func loadTemplate(dir string) (*Template, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "template.json"))
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	tmpl := &Template{templates: make(map[string]*template.Template)}
+	if err := decoder.Decode(&tmpl.TemplateMeta); err != nil {
+		return nil, fmt.Errorf("invalid template.json: %w", err)
+	}
+	if tmpl.Name == "" {
+		tmpl.Name = filepath.Base(dir)
+	}
+	if tmpl.Title == "" {
+		tmpl.Title = tmpl.Name
+	}
+	if len(tmpl.Match) == 0 && !tmpl.Fallback && !tmpl.Base {
+		return nil, fmt.Errorf("template.json needs match keywords, \"base\": true or \"fallback\": true")
+	}
+	for _, group := range tmpl.Match {
+		var matchers []*regexp.Regexp
+		for _, keyword := range group {
+			// Whole words only, so "acr" does not match "across"
+			pattern := `\b` + regexp.QuoteMeta(strings.ToLower(keyword)) + `s?\b`
+			matchers = append(matchers, regexp.MustCompile(pattern))
+		}
+		tmpl.matchers = append(tmpl.matchers, matchers)
+	}
+
+	tmpl.Languages = nil
+	for _, lang := range []string{"terraform", "bicep"} {
+		path := filepath.Join(dir, templateFiles[lang])
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		parsed, err := template.New(templateFiles[lang]).Funcs(templateFuncs).ParseFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		tmpl.templates[lang] = parsed.Option("missingkey=zero")
+		if len(tmpl.sections(lang)) == 0 {
+			return nil, fmt.Errorf("%s defines none of the sections %s", templateFiles[lang], strings.Join(templateSections[lang], ", "))
+		}
+		tmpl.Languages = append(tmpl.Languages, lang)
+	}
+	if len(tmpl.Languages) == 0 {
+		return nil, fmt.Errorf("no %s or %s found", templateFiles["terraform"], templateFiles["bicep"])
+	}
+	return tmpl, nil
+}
+
+// Compose returns the patterns for every component the description asks
+// for, after the base pattern. The fallback pattern is added when no
+// matched pattern declares resources for lang.
+func (l *TemplateLibrary) Compose(description, lang string) []*Template {
+	desc := strings.ToLower(description)
+	var components []*Template
+	var fallback *Template
+	hasResources := false
+
+	for _, tmpl := range l.Templates {
+		if tmpl.templates[lang] == nil {
+			continue
+		}
+		matched := tmpl.matches(desc)
+		switch {
+		case tmpl.Fallback:
+			if fallback == nil {
+				fallback = tmpl
+			}
+		case tmpl.Base || matched:
+			components = append(components, tmpl)
+			if matched && tmpl.hasSection(lang, "resources") {
+				hasResources = true
+			}
+		}
+	}
+	if !hasResources && fallback != nil {
+		components = append(components, fallback)
+	}
+	return components
+}
+
+// matches reports whether every keyword of any match group is in desc
+func (t *Template) matches(desc string) bool {
+	for _, group := range t.matchers {
+		all := len(group) > 0
+		for _, matcher := range group {
+			if !matcher.MatchString(desc) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// sections returns the sections the pattern defines for lang
+func (t *Template) sections(lang string) []string {
+	var sections []string
+	for _, section := range templateSections[lang] {
+		if t.hasSection(lang, section) {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+func (t *Template) hasSection(lang, section string) bool {
+	tmpl := t.templates[lang]
+	return tmpl != nil && tmpl.Lookup(section) != nil
+}
+
+// Render executes one section of the pattern's template for lang
+func (t *Template) Render(lang, section string, data TemplateData) (string, error) {
+	if !t.hasSection(lang, section) {
+		return "", nil
+	}
+	var out bytes.Buffer
+	if err := t.templates[lang].ExecuteTemplate(&out, section, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", t.Name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// resourceName applies the pattern's naming convention to a workload name
+func (t *Template) resourceName(name string) string {
+	if name == "" || t.NameFormat == "" {
+		return name
+	}
+	formatted := strings.ReplaceAll(t.NameFormat, "{name}", name)
+	if t.NameAlnum {
+		formatted = nonAlnumPattern.ReplaceAllString(strings.ToLower(formatted), "")
+	}
+	return formatted
+}
+
+var nonAlnumPattern = regexp.MustCompile(`[^a-z0-9]`)
+
+// newTemplateData layers the request parameters over the pattern defaults
+func newTemplateData(description, lang string, defaults, params map[string]interface{}) (TemplateData, error) {
+	merged := make(map[string]interface{})
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range params {
+		merged[key] = value
+	}
+
+	data := TemplateData{Description: description, Params: merged}
+	var err error
+	if data.Name, err = stringParam(merged, "name"); err != nil {
+		return data, err
+	}
+	if data.Location, err = stringParam(merged, "location"); err != nil {
+		return data, err
+	}
+	if data.SKU, err = stringParam(merged, "sku"); err != nil {
+		return data, err
+	}
+	if data.NodeCount, err = intParam(merged, "node_count"); err != nil {
+		return data, err
+	}
+	if data.Tags, err = tagsParam(merged, "tags"); err != nil {
+		return data, err
+	}
+	if data.Tags == nil {
+		data.Tags = defaultTags[lang]
+	}
+	return data, nil
+}
+
+func stringParam(params map[string]interface{}, key string) (string, error) {
+	value, ok := params[key]
+	if !ok || value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s parameter must be a string", key)
+	}
+	return strings.TrimSpace(s), nil
+}
+
+func intParam(params map[string]interface{}, key string) (int, error) {
+	value, ok := params[key]
+	if !ok || value == nil {
+		return 0, nil
+	}
+	n, ok := value.(float64)
+	if !ok || n != math.Trunc(n) || n < 1 {
+		return 0, fmt.Errorf("%s parameter must be a positive integer", key)
+	}
+	return int(n), nil
+}
+
+func tagsParam(params map[string]interface{}, key string) (map[string]string, error) {
+	value, ok := params[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s parameter must be an object of strings", key)
+	}
+	tags := make(map[string]string, len(object))
+	for name, v := range object {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s parameter must be an object of strings", key)
+		}
+		tags[name] = s
+	}
+	return tags, nil
+}
+
+// =============================================================================
+// Template Functions
+// =============================================================================
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+var templateFuncs = template.FuncMap{
+	"hcl":      hclString,
+	"bicep":    bicepString,
+	"hclMap":   hclMap,
+	"bicepMap": bicepMap,
+	"oneLine":  oneLine,
+	"skuTier":  skuTier,
+	"skuRepl":  skuReplication,
+	"maxInt":   maxInt,
+}
+
+// hclString quotes s as an HCL string literal
+func hclString(s string) string {
+	quoted, _ := json.Marshal(s)
+	escaped := strings.ReplaceAll(string(quoted), "${", "$${")
+	return strings.ReplaceAll(escaped, "%{", "%%{")
+}
+
+// bicepString quotes s as a Bicep string literal
+func bicepString(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", `\${`)
+	return "'" + replacer.Replace(s) + "'"
+}
+
+// hclMap renders a map of strings as an HCL object, aligned like terraform fmt
+func hclMap(indent int, m map[string]string) string {
+	keys, width := sortedKeys(m, hclString)
+	pad := strings.Repeat(" ", indent)
+
+	var out strings.Builder
+	out.WriteString("{\n")
+	for _, key := range keys {
+		name := key
+		if !identPattern.MatchString(key) {
+			name = hclString(key)
+		}
+		out.WriteString(fmt.Sprintf("%s  %-*s = %s\n", pad, width, name, hclString(m[key])))
+	}
+	out.WriteString(pad + "}")
+	return out.String()
+}
+
+// bicepMap renders a map of strings as a Bicep object
+func bicepMap(indent int, m map[string]string) string {
+	keys, _ := sortedKeys(m, bicepString)
+	pad := strings.Repeat(" ", indent)
+
+	var out strings.Builder
+	out.WriteString("{\n")
+	for _, key := range keys {
+		name := key
+		if !identPattern.MatchString(key) || strings.Contains(key, "-") {
+			name = bicepString(key)
+		}
+		out.WriteString(fmt.Sprintf("%s  %s: %s\n", pad, name, bicepString(m[key])))
+	}
+	out.WriteString(pad + "}")
+	return out.String()
+}
+
+// sortedKeys returns the keys of m in order and the widest rendered key
+func sortedKeys(m map[string]string, quote func(string) string) ([]string, int) {
+	keys := make([]string, 0, len(m))
+	width := 0
+	for key := range m {
+		keys = append(keys, key)
+		name := key
+		if !identPattern.MatchString(key) {
+			name = quote(key)
+		}
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(keys)
+	return keys, width
+}
+
+// oneLine collapses s to a single line for use in comments
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// skuTier returns the tier of a storage SKU such as Standard_GRS
+func skuTier(sku string) string {
+	tier, _, _ := strings.Cut(sku, "_")
+	return tier
+}
+
+// skuReplication returns the replication of a storage SKU such as Standard_GRS
+func skuReplication(sku string) string {
+	_, replication, _ := strings.Cut(sku, "_")
+	return replication
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -208,11 +208,11 @@ func (s *Server) getDefaultRules() []PolicyRule {
 			Severity:     "high",
 			ResourceType: "azurerm_storage_account",
 			Check: PolicyCheck{
-				Property: "enable_https_traffic_only",
+				Property: "https_traffic_only_enabled",
 				Operator: "equals",
 				Value:    true,
 			},
-			Remediation:   "Set enable_https_traffic_only = true",
+			Remediation:   "Set https_traffic_only_enabled = true",
 			Documentation: "https://learn.microsoft.com/azure/storage/common/storage-require-secure-transfer",
 		},
 		{
@@ -559,9 +559,9 @@ func (s *Server) parseTerraform(code string) []Resource {
 			// Find the line number
 			line := strings.Count(code[:match[0]], "\n") + 1
 
-			// Extract the resource block
+			// Extract the resource block, starting the brace count at its {
 			blockStart := match[1]
-			blockEnd := findMatchingBrace(code, blockStart)
+			blockEnd := findMatchingBrace(code, blockStart-1)
 
 			var props map[string]interface{}
 			if blockEnd > blockStart {
@@ -615,7 +615,7 @@ func parseTerraformBlock(block string) map[string]interface{} {
 		if len(match) >= 4 {
 			blockName := block[match[2]:match[3]]
 			blockStart := match[1]
-			blockEnd := findMatchingBrace(block, blockStart)
+			blockEnd := findMatchingBrace(block, blockStart-1)
 
 			if blockEnd > blockStart {
 				nestedBlock := block[blockStart:blockEnd]
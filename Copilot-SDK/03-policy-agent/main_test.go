@@ -0,0 +1,79 @@
+// =============================================================================
+// Policy Checker Tests
+// =============================================================================
+// testdata/templates holds the Terraform the IaC skillset generates, kept in
+// step with its templates by the skillset's TestTemplatesRendered. The agent
+// must find no violations in it, just as the skillset's own check finds none.
+// =============================================================================
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedTemplatesPassPolicies(t *testing.T) {
+	server := NewServer(loadConfig())
+
+	files, err := filepath.Glob(filepath.Join("testdata", "templates", "*.tf"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no rendered templates in testdata/templates (%v)", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resources := server.parseResources(string(data), "Terraform")
+			if len(resources) == 0 {
+				t.Fatal("no resources parsed")
+			}
+			for _, v := range server.checkPolicies(resources) {
+				t.Errorf("%s (%s.%s, line %d): %s", v.PolicyID, v.ResourceType, v.ResourceName, v.Line, v.Remediation)
+			}
+		})
+	}
+}
+
+func TestParseTerraformNestedBlocks(t *testing.T) {
+	code := `resource "azurerm_kubernetes_cluster" "aks" {
+  name = "aks"
+
+  default_node_pool {
+    name = "system"
+  }
+
+  identity {
+    type = "SystemAssigned"
+  }
+
+  network_profile {
+    network_plugin = "azure"
+    network_policy = "calico"
+  }
+
+  role_based_access_control_enabled = true
+}
+`
+	server := &Server{}
+	resources := server.parseTerraform(code)
+	if len(resources) != 1 {
+		t.Fatalf("parsed %d resources, want 1", len(resources))
+	}
+
+	// Each property lies past the first nested block, where parsing used to stop
+	props := resources[0].Properties
+	for path, want := range map[string]interface{}{
+		"default_node_pool.name":            "system",
+		"identity.type":                     "SystemAssigned",
+		"network_profile.network_policy":    "calico",
+		"role_based_access_control_enabled": true,
+	} {
+		if got := getNestedProperty(props, path); got != want {
+			t.Errorf("%s = %v, want %v", path, got, want)
+		}
+	}
+}
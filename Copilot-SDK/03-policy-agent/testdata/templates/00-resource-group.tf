@@ -0,0 +1,68 @@
+# Azure Resource Group
+# Generated by IaC Helper Skillset
+# Description: resource group
+
+# -----------------------------------------------------------------------------
+# Versions
+# -----------------------------------------------------------------------------
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {}
+}
+
+# -----------------------------------------------------------------------------
+# Variables
+# -----------------------------------------------------------------------------
+variable "resource_group_name" {
+  description = "Name of the resource group"
+  type        = string
+  default     = "selfcheck"
+}
+
+variable "location" {
+  description = "Azure region for resources"
+  type        = string
+  default     = "eastus"
+}
+
+variable "tags" {
+  description = "Tags applied to every resource"
+  type        = map(string)
+  default = {
+    environment = "production"
+    managed_by  = "terraform"
+  }
+}
+
+# -----------------------------------------------------------------------------
+# Resources
+# -----------------------------------------------------------------------------
+resource "azurerm_resource_group" "main" {
+  name     = var.resource_group_name
+  location = var.location
+
+  tags = var.tags
+}
+
+# -----------------------------------------------------------------------------
+# Outputs
+# -----------------------------------------------------------------------------
+output "resource_group_id" {
+  description = "The ID of the resource group"
+  value       = azurerm_resource_group.main.id
+}
+
+output "resource_group_name" {
+  description = "The name of the resource group"
+  value       = azurerm_resource_group.main.name
+}
@@ -0,0 +1,106 @@
+# Azure Virtual Network
+# Generated by IaC Helper Skillset
+# Description: virtual network
+
+# -----------------------------------------------------------------------------
+# Versions
+# -----------------------------------------------------------------------------
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {}
+}
+
+# -----------------------------------------------------------------------------
+# Variables
+# -----------------------------------------------------------------------------
+variable "resource_group_name" {
+  description = "Name of the resource group"
+  type        = string
+  default     = "rg-selfcheck"
+}
+
+variable "location" {
+  description = "Azure region for resources"
+  type        = string
+  default     = "eastus"
+}
+
+variable "tags" {
+  description = "Tags applied to every resource"
+  type        = map(string)
+  default = {
+    environment = "production"
+    managed_by  = "terraform"
+  }
+}
+
+variable "vnet_name" {
+  description = "Name of the virtual network"
+  type        = string
+  default     = "selfcheck"
+}
+
+variable "address_space" {
+  description = "Address space for the VNet"
+  type        = list(string)
+  default     = ["10.0.0.0/16"]
+}
+
+# -----------------------------------------------------------------------------
+# Resources
+# -----------------------------------------------------------------------------
+resource "azurerm_resource_group" "main" {
+  name     = var.resource_group_name
+  location = var.location
+
+  tags = var.tags
+}
+
+resource "azurerm_virtual_network" "main" {
+  name                = var.vnet_name
+  location            = azurerm_resource_group.main.location
+  resource_group_name = azurerm_resource_group.main.name
+  address_space       = var.address_space
+
+  tags = var.tags
+}
+
+resource "azurerm_subnet" "default" {
+  name                 = "default"
+  resource_group_name  = azurerm_resource_group.main.name
+  virtual_network_name = azurerm_virtual_network.main.name
+  address_prefixes     = ["10.0.1.0/24"]
+}
+
+# -----------------------------------------------------------------------------
+# Outputs
+# -----------------------------------------------------------------------------
+output "resource_group_id" {
+  description = "The ID of the resource group"
+  value       = azurerm_resource_group.main.id
+}
+
+output "resource_group_name" {
+  description = "The name of the resource group"
+  value       = azurerm_resource_group.main.name
+}
+
+output "vnet_id" {
+  description = "The ID of the virtual network"
+  value       = azurerm_virtual_network.main.id
+}
+
+output "subnet_id" {
+  description = "The ID of the default subnet"
+  value       = azurerm_subnet.default.id
+}
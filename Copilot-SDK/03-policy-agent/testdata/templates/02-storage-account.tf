@@ -0,0 +1,108 @@
+# Azure Storage Account
+# Generated by IaC Helper Skillset
+# Description: storage account
+
+# -----------------------------------------------------------------------------
+# Versions
+# -----------------------------------------------------------------------------
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {}
+}
+
+# -----------------------------------------------------------------------------
+# Variables
+# -----------------------------------------------------------------------------
+variable "resource_group_name" {
+  description = "Name of the resource group"
+  type        = string
+  default     = "rg-selfcheck"
+}
+
+variable "location" {
+  description = "Azure region for resources"
+  type        = string
+  default     = "eastus"
+}
+
+variable "tags" {
+  description = "Tags applied to every resource"
+  type        = map(string)
+  default = {
+    environment = "production"
+    managed_by  = "terraform"
+  }
+}
+
+variable "storage_account_name" {
+  description = "Name of the storage account (must be globally unique)"
+  type        = string
+  default     = "selfcheck"
+}
+
+# -----------------------------------------------------------------------------
+# Resources
+# -----------------------------------------------------------------------------
+resource "azurerm_resource_group" "main" {
+  name     = var.resource_group_name
+  location = var.location
+
+  tags = var.tags
+}
+
+resource "azurerm_storage_account" "main" {
+  name                     = var.storage_account_name
+  resource_group_name      = azurerm_resource_group.main.name
+  location                 = azurerm_resource_group.main.location
+  account_tier             = "Standard"
+  account_replication_type = "GRS"
+
+  # Security settings
+  https_traffic_only_enabled      = true
+  min_tls_version                 = "TLS1_2"
+  allow_nested_items_to_be_public = false
+
+  blob_properties {
+    delete_retention_policy {
+      days = 7
+    }
+    container_delete_retention_policy {
+      days = 7
+    }
+  }
+
+  tags = var.tags
+}
+
+# -----------------------------------------------------------------------------
+# Outputs
+# -----------------------------------------------------------------------------
+output "resource_group_id" {
+  description = "The ID of the resource group"
+  value       = azurerm_resource_group.main.id
+}
+
+output "resource_group_name" {
+  description = "The name of the resource group"
+  value       = azurerm_resource_group.main.name
+}
+
+output "storage_account_id" {
+  description = "The ID of the storage account"
+  value       = azurerm_storage_account.main.id
+}
+
+output "primary_blob_endpoint" {
+  description = "The primary blob endpoint"
+  value       = azurerm_storage_account.main.primary_blob_endpoint
+}
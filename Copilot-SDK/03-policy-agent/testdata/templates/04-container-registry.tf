@@ -0,0 +1,100 @@
+# Azure Container Registry
+# Generated by IaC Helper Skillset
+# Description: container registry
+
+# -----------------------------------------------------------------------------
+# Versions
+# -----------------------------------------------------------------------------
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {}
+}
+
+# -----------------------------------------------------------------------------
+# Variables
+# -----------------------------------------------------------------------------
+variable "resource_group_name" {
+  description = "Name of the resource group"
+  type        = string
+  default     = "rg-selfcheck"
+}
+
+variable "location" {
+  description = "Azure region for resources"
+  type        = string
+  default     = "eastus"
+}
+
+variable "tags" {
+  description = "Tags applied to every resource"
+  type        = map(string)
+  default = {
+    environment = "production"
+    managed_by  = "terraform"
+  }
+}
+
+variable "container_registry_name" {
+  description = "Name of the container registry (globally unique, alphanumeric)"
+  type        = string
+  default     = "selfcheck"
+}
+
+variable "container_registry_sku" {
+  description = "SKU of the container registry (Basic, Standard or Premium)"
+  type        = string
+  default     = "Premium"
+}
+
+# -----------------------------------------------------------------------------
+# Resources
+# -----------------------------------------------------------------------------
+resource "azurerm_resource_group" "main" {
+  name     = var.resource_group_name
+  location = var.location
+
+  tags = var.tags
+}
+
+resource "azurerm_container_registry" "main" {
+  name                = var.container_registry_name
+  resource_group_name = azurerm_resource_group.main.name
+  location            = azurerm_resource_group.main.location
+  sku                 = var.container_registry_sku
+  admin_enabled       = false
+
+  tags = var.tags
+}
+
+# -----------------------------------------------------------------------------
+# Outputs
+# -----------------------------------------------------------------------------
+output "resource_group_id" {
+  description = "The ID of the resource group"
+  value       = azurerm_resource_group.main.id
+}
+
+output "resource_group_name" {
+  description = "The name of the resource group"
+  value       = azurerm_resource_group.main.name
+}
+
+output "container_registry_id" {
+  description = "The ID of the container registry"
+  value       = azurerm_container_registry.main.id
+}
+
+output "container_registry_login_server" {
+  description = "The login server of the container registry"
+  value       = azurerm_container_registry.main.login_server
+}
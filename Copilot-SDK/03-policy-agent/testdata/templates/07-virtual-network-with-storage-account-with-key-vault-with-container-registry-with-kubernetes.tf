@@ -0,0 +1,316 @@
+# Azure Virtual Network + Azure Storage Account + Azure Key Vault + Azure Container Registry + Azure Kubernetes Service (AKS) Cluster
+# Generated by IaC Helper Skillset
+# Description: virtual network with storage account with key vault with container registry with kubernetes
+
+# -----------------------------------------------------------------------------
+# Versions
+# -----------------------------------------------------------------------------
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {}
+}
+
+# -----------------------------------------------------------------------------
+# Variables
+# -----------------------------------------------------------------------------
+variable "resource_group_name" {
+  description = "Name of the resource group"
+  type        = string
+  default     = "rg-selfcheck"
+}
+
+variable "location" {
+  description = "Azure region for resources"
+  type        = string
+  default     = "eastus"
+}
+
+variable "tags" {
+  description = "Tags applied to every resource"
+  type        = map(string)
+  default = {
+    environment = "production"
+    managed_by  = "terraform"
+  }
+}
+
+variable "vnet_name" {
+  description = "Name of the virtual network"
+  type        = string
+  default     = "vnet-selfcheck"
+}
+
+variable "address_space" {
+  description = "Address space for the VNet"
+  type        = list(string)
+  default     = ["10.0.0.0/16"]
+}
+
+variable "storage_account_name" {
+  description = "Name of the storage account (must be globally unique)"
+  type        = string
+  default     = "stselfcheck"
+}
+
+variable "key_vault_name" {
+  description = "Name of the Key Vault (must be globally unique)"
+  type        = string
+  default     = "kv-selfcheck"
+}
+
+variable "container_registry_name" {
+  description = "Name of the container registry (globally unique, alphanumeric)"
+  type        = string
+  default     = "acrselfcheck"
+}
+
+variable "container_registry_sku" {
+  description = "SKU of the container registry (Basic, Standard or Premium)"
+  type        = string
+  default     = "Premium"
+}
+
+variable "cluster_name" {
+  description = "Name of the AKS cluster"
+  type        = string
+  default     = "aks-selfcheck"
+}
+
+variable "dns_prefix" {
+  description = "DNS prefix for the cluster"
+  type        = string
+  default     = "aks-selfcheck"
+}
+
+variable "node_count" {
+  description = "Number of nodes in the default node pool"
+  type        = number
+  default     = 3
+}
+
+variable "node_vm_size" {
+  description = "VM size for the nodes"
+  type        = string
+  default     = "Standard_D2s_v3"
+}
+
+# -----------------------------------------------------------------------------
+# Resources
+# -----------------------------------------------------------------------------
+resource "azurerm_resource_group" "main" {
+  name     = var.resource_group_name
+  location = var.location
+
+  tags = var.tags
+}
+
+resource "azurerm_virtual_network" "main" {
+  name                = var.vnet_name
+  location            = azurerm_resource_group.main.location
+  resource_group_name = azurerm_resource_group.main.name
+  address_space       = var.address_space
+
+  tags = var.tags
+}
+
+resource "azurerm_subnet" "default" {
+  name                 = "default"
+  resource_group_name  = azurerm_resource_group.main.name
+  virtual_network_name = azurerm_virtual_network.main.name
+  address_prefixes     = ["10.0.1.0/24"]
+}
+
+# Azure CNI assigns node and pod IPs from this subnet
+resource "azurerm_subnet" "aks" {
+  name                 = "snet-aks"
+  resource_group_name  = azurerm_resource_group.main.name
+  virtual_network_name = azurerm_virtual_network.main.name
+  address_prefixes     = ["10.0.16.0/20"]
+}
+
+resource "azurerm_storage_account" "main" {
+  name                     = var.storage_account_name
+  resource_group_name      = azurerm_resource_group.main.name
+  location                 = azurerm_resource_group.main.location
+  account_tier             = "Standard"
+  account_replication_type = "GRS"
+
+  # Security settings
+  https_traffic_only_enabled      = true
+  min_tls_version                 = "TLS1_2"
+  allow_nested_items_to_be_public = false
+
+  blob_properties {
+    delete_retention_policy {
+      days = 7
+    }
+    container_delete_retention_policy {
+      days = 7
+    }
+  }
+
+  tags = var.tags
+}
+
+data "azurerm_client_config" "current" {}
+
+resource "azurerm_key_vault" "main" {
+  name                       = var.key_vault_name
+  location                   = azurerm_resource_group.main.location
+  resource_group_name        = azurerm_resource_group.main.name
+  tenant_id                  = data.azurerm_client_config.current.tenant_id
+  sku_name                   = "standard"
+  enable_rbac_authorization  = true
+  purge_protection_enabled   = true
+  soft_delete_retention_days = 90
+
+  network_acls {
+    default_action = "Deny"
+    bypass         = "AzureServices"
+  }
+
+  tags = var.tags
+}
+
+# Lets the AKS Key Vault secrets provider read secrets
+resource "azurerm_role_assignment" "aks_key_vault_secrets_user" {
+  scope                = azurerm_key_vault.main.id
+  role_definition_name = "Key Vault Secrets User"
+  principal_id         = azurerm_kubernetes_cluster.main.key_vault_secrets_provider[0].secret_identity[0].object_id
+}
+
+resource "azurerm_container_registry" "main" {
+  name                = var.container_registry_name
+  resource_group_name = azurerm_resource_group.main.name
+  location            = azurerm_resource_group.main.location
+  sku                 = var.container_registry_sku
+  admin_enabled       = false
+
+  tags = var.tags
+}
+
+# Lets AKS nodes pull images from the registry
+resource "azurerm_role_assignment" "aks_acr_pull" {
+  scope                            = azurerm_container_registry.main.id
+  role_definition_name             = "AcrPull"
+  principal_id                     = azurerm_kubernetes_cluster.main.kubelet_identity[0].object_id
+  skip_service_principal_aad_check = true
+}
+
+resource "azurerm_kubernetes_cluster" "main" {
+  name                = var.cluster_name
+  location            = azurerm_resource_group.main.location
+  resource_group_name = azurerm_resource_group.main.name
+  dns_prefix          = var.dns_prefix
+  kubernetes_version  = "1.28"
+
+  role_based_access_control_enabled = true
+
+  default_node_pool {
+    name                 = "system"
+    node_count           = var.node_count
+    vm_size              = var.node_vm_size
+    auto_scaling_enabled = true
+    min_count            = 1
+    max_count            = 5
+    vnet_subnet_id       = azurerm_subnet.aks.id
+  }
+
+  identity {
+    type = "SystemAssigned"
+  }
+
+  network_profile {
+    network_plugin    = "azure"
+    network_policy    = "azure"
+    load_balancer_sku = "standard"
+    # Must not overlap the VNet address space
+    service_cidr   = "172.16.0.0/16"
+    dns_service_ip = "172.16.0.10"
+  }
+
+  key_vault_secrets_provider {
+    secret_rotation_enabled = true
+  }
+
+  tags = var.tags
+}
+
+# -----------------------------------------------------------------------------
+# Outputs
+# -----------------------------------------------------------------------------
+output "resource_group_id" {
+  description = "The ID of the resource group"
+  value       = azurerm_resource_group.main.id
+}
+
+output "resource_group_name" {
+  description = "The name of the resource group"
+  value       = azurerm_resource_group.main.name
+}
+
+output "vnet_id" {
+  description = "The ID of the virtual network"
+  value       = azurerm_virtual_network.main.id
+}
+
+output "subnet_id" {
+  description = "The ID of the default subnet"
+  value       = azurerm_subnet.default.id
+}
+
+output "storage_account_id" {
+  description = "The ID of the storage account"
+  value       = azurerm_storage_account.main.id
+}
+
+output "primary_blob_endpoint" {
+  description = "The primary blob endpoint"
+  value       = azurerm_storage_account.main.primary_blob_endpoint
+}
+
+output "key_vault_id" {
+  description = "The ID of the Key Vault"
+  value       = azurerm_key_vault.main.id
+}
+
+output "key_vault_uri" {
+  description = "The URI of the Key Vault"
+  value       = azurerm_key_vault.main.vault_uri
+}
+
+output "container_registry_id" {
+  description = "The ID of the container registry"
+  value       = azurerm_container_registry.main.id
+}
+
+output "container_registry_login_server" {
+  description = "The login server of the container registry"
+  value       = azurerm_container_registry.main.login_server
+}
+
+output "cluster_id" {
+  description = "The ID of the AKS cluster"
+  value       = azurerm_kubernetes_cluster.main.id
+}
+
+output "kube_config" {
+  description = "Kubernetes config for kubectl"
+  value       = azurerm_kubernetes_cluster.main.kube_config_raw
+  sensitive   = true
+}
+
+output "kubelet_identity_object_id" {
+  description = "Object ID of the kubelet managed identity"
+  value       = azurerm_kubernetes_cluster.main.kubelet_identity[0].object_id
+}